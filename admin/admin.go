@@ -3,14 +3,21 @@ package admin
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"os"
+	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
+	"github.com/mailgun/kafka-pixy/none"
+	"github.com/mailgun/kafka-pixy/offsetmgr"
+	"github.com/mailgun/kazoo-go"
 	"github.com/pkg/errors"
 	"github.com/samuel/go-zookeeper/zk"
 )
@@ -19,25 +26,72 @@ type (
 	ErrInvalidParam error
 )
 
+var (
+	ErrGroupNotEmpty = errors.New("group has active members")
+	ErrGroupNotExist = errors.New("group is not registered")
+
+	ErrTopicExists          = errors.New("topic already exists")
+	ErrBadPartitionCount    = errors.New("partition count must be greater than 0")
+	ErrBadReplicationFactor = errors.New("replication factor must be between 1 and the number of brokers")
+	ErrTopicNotExist        = errors.New("topic does not exist")
+	ErrTopicNotDeleted      = errors.New("topic is marked for deletion but has not been deleted, make sure that `delete.topic.enable` is true on all brokers")
+	ErrPartitionsNotAdded   = errors.New("new partition count must be greater than the current one")
+	ErrNoTopicConfig        = errors.New("no topic config given")
+
+	ErrRetentionUnsupported = errors.New("offset retention requires kafka.version 0.9.0.0 or later")
+	ErrOffsetDecrease       = errors.New("new offset is less than the committed one")
+
+	ErrOwnershipUnstable = errors.New("partition ownership keeps changing, the group is probably rebalancing")
+)
+
 const (
 	ProtocolVer1 = 1 // Supported by Kafka v0.8.2 and later
+	ProtocolVer2 = 2 // Supported by Kafka v0.9.0 and later
+
+	// deleteTopicTimeout is how long DeleteTopic waits for the Kafka
+	// controller to delete a topic marked for deletion.
+	deleteTopicTimeout = 30 * time.Second
+
+	// topicConfigChangePrefix is the path prefix of sequential ZooKeeper
+	// nodes that notify Kafka brokers about entity config changes.
+	topicConfigChangePrefix = "/config/changes/config_change_"
+
+	// groupStatusAttempts is how many times GetGroupStatus tries to take a
+	// snapshot that partition ownership has not changed while it was taken.
+	groupStatusAttempts = 3
+
+	// maxStampedMetadataBytes is the default of `offset.metadata.max.bytes`
+	// broker setting. Metadata that would exceed it if stamped is committed
+	// without a stamp.
+	maxStampedMetadataBytes = 4096
+
+	// minOffsetRetention is the retention time that offsets of a deleted
+	// group are committed with to make Kafka remove them.
+	minOffsetRetention = time.Millisecond
 )
 
 // T provides methods to perform administrative operations on a Kafka cluster.
 type T struct {
 	parentActDesc *actor.Descriptor
 	cfg           *config.Proxy
+	hostname      string
 	kafkaClt      sarama.Client
 	zkConn        *zk.Conn
+	kazooClt      *kazoo.Kazoo
 	mtx           sync.Mutex
 }
 
 // Spawn creates an admin instance with the specified configuration and starts
 // internal goroutines to support its operation.
 func Spawn(parentActDesc *actor.Descriptor, cfg *config.Proxy) (*T, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = cfg.ClientID
+	}
 	a := T{
 		parentActDesc: parentActDesc,
 		cfg:           cfg,
+		hostname:      hostname,
 	}
 	return &a, nil
 }
@@ -52,6 +106,9 @@ func (a *T) Stop() {
 	if a.zkConn != nil {
 		a.zkConn.Close()
 	}
+	if a.kazooClt != nil {
+		a.kazooClt.Close()
+	}
 }
 
 type PartitionOffset struct {
@@ -60,6 +117,64 @@ type PartitionOffset struct {
 	End       int64
 	Offset    int64
 	Metadata  string
+
+	// The host and the time at which the offset was committed, if it was
+	// committed by SetGroupOffsets or ResetGroupOffsets rather than by a
+	// consumer. They are ignored by SetGroupOffsets.
+	CommittedBy string
+	CommittedAt time.Time
+}
+
+// Lag returns the number of messages in the partition that are beyond the
+// committed offset. If the group has never committed an offset to the
+// partition then the lag is considered to be zero.
+func (po *PartitionOffset) Lag() int64 {
+	switch po.Offset {
+	case sarama.OffsetNewest:
+		return 0
+	case sarama.OffsetOldest:
+		return po.End - po.Begin
+	default:
+		return po.End - po.Offset
+	}
+}
+
+// PartitionOffsetRange is the range of offsets of messages available in a
+// partition. Begin is the log start offset and End is the high water mark.
+type PartitionOffsetRange struct {
+	Partition int32
+	Begin     int64
+	End       int64
+}
+
+// PartitionLag describes how far behind a consumer group is in a partition.
+type PartitionLag struct {
+	Topic         string
+	Partition     int32
+	Offset        int64
+	HighWaterMark int64
+	Lag           int64
+}
+
+// GroupTopicStatus is a snapshot of consumption of a topic by a consumer
+// group.
+type GroupTopicStatus struct {
+	Group      string
+	Topic      string
+	Partitions []PartitionStatus
+}
+
+// PartitionStatus describes consumption of a partition by a consumer group.
+// Owner is the client ID of the group member that consumes the partition, it
+// is empty if no member does.
+type PartitionStatus struct {
+	Partition int32
+	Begin     int64
+	End       int64
+	Offset    int64
+	Metadata  string
+	Lag       int64
+	Owner     string
 }
 
 type PartitionMetadata struct {
@@ -80,6 +195,50 @@ type TopicConfig struct {
 	Config  map[string]string `json:"config"`
 }
 
+// ClusterMetadata describes brokers of a Kafka cluster.
+type ClusterMetadata struct {
+	// Brokers known to the Kafka client of the proxy, sorted by ID.
+	Brokers []BrokerMetadata
+	// ID of the controller broker, or -1 if no broker is elected controller
+	// at the moment.
+	ControllerID int32
+}
+
+type BrokerMetadata struct {
+	ID   int32
+	Host string
+	Port int
+}
+
+// GroupDescription describes the state of a consumer group as seen by its
+// coordinator broker.
+type GroupDescription struct {
+	Group string
+	// One of Stable, PreparingRebalance, AwaitingSync, Empty, Dead. The
+	// state of a group that does not exist is reported as Dead.
+	State        string
+	ProtocolType string
+	Protocol     string
+	Members      []GroupMemberDescription
+}
+
+// GroupMemberDescription describes a consumer group member. Topics and
+// Assignment are only decoded if the group protocol type is `consumer`.
+type GroupMemberDescription struct {
+	MemberID   string
+	ClientID   string
+	ClientHost string
+	Topics     []string
+	Assignment map[string][]int32
+}
+
+// topicAssignment is the partition replica assignment of a topic as it is
+// stored by Kafka in ZooKeeper under `/brokers/topics/<topic>`.
+type topicAssignment struct {
+	Version    int                `json:"version"`
+	Partitions map[string][]int32 `json:"partitions"`
+}
+
 type indexedPartition struct {
 	index     int
 	partition int32
@@ -106,6 +265,137 @@ func (a *T) GetGroupOffsets(group, topic string) ([]PartitionOffset, error) {
 	return results, nil
 }
 
+// GetGroupLag returns the committed offset, the high water mark, and the lag
+// of the specified consumer group for every partition of the topic.
+func (a *T) GetGroupLag(group, topic string) ([]PartitionLag, error) {
+	offsets, err := a.GetGroupOffsets(group, topic)
+	if err != nil {
+		return nil, err
+	}
+	lags := make([]PartitionLag, len(offsets))
+	for i, po := range offsets {
+		lags[i] = PartitionLag{
+			Topic:         topic,
+			Partition:     po.Partition,
+			Offset:        po.Offset,
+			HighWaterMark: po.End,
+			Lag:           po.Lag(),
+		}
+	}
+	return lags, nil
+}
+
+// GetLaggingPartitions returns partitions of all topics consumed by the group
+// that the group lags on by more than the threshold number of messages. They
+// are sorted by topic and then by partition. Kafka-Pixy consumer groups do not
+// keep track of subscribed topics when they have no members, therefore topics
+// that the group has committed offsets to are considered to be consumed by it.
+func (a *T) GetLaggingPartitions(group string, threshold int64) ([]PartitionLag, error) {
+	export, err := a.exportGroupOffsets(group)
+	if err != nil {
+		a.ResetKafkaClt()
+		if export, err = a.exportGroupOffsets(group); err != nil {
+			return nil, err
+		}
+	}
+	// Exported offsets are sorted by topic.
+	lagging := []PartitionLag{}
+	for i, eo := range export.Offsets {
+		if i > 0 && export.Offsets[i-1].Topic == eo.Topic {
+			continue
+		}
+		lags, err := a.GetGroupLag(group, eo.Topic)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get lag, topic=%s", eo.Topic)
+		}
+		for _, pl := range lags {
+			if pl.Lag > threshold {
+				lagging = append(lagging, pl)
+			}
+		}
+	}
+	return lagging, nil
+}
+
+// GetGroupStatus returns the committed offset, the lag, and the owner of every
+// partition of the specified topic in one snapshot. Partition ownership is
+// read before and after offsets are fetched, and if it changed in between
+// the snapshot is taken again. ErrOwnershipUnstable is returned if the
+// ownership keeps changing, that is the case while the group rebalances.
+func (a *T) GetGroupStatus(group, topic string) (GroupTopicStatus, error) {
+	status, err := a.getGroupStatus(group, topic)
+	if err != nil && errors.Cause(err) != ErrOwnershipUnstable {
+		a.ResetKafkaClt()
+		return a.getGroupStatus(group, topic)
+	}
+	return status, err
+}
+
+func (a *T) getGroupStatus(group, topic string) (GroupTopicStatus, error) {
+	zkConn, err := a.lazyZKConn()
+	if err != nil {
+		return GroupTopicStatus{}, err
+	}
+	for attempt := 0; attempt < groupStatusAttempts; attempt++ {
+		owners, version, err := a.getPartitionOwners(zkConn, group, topic)
+		if err != nil {
+			return GroupTopicStatus{}, err
+		}
+		offsets, err := a.getGroupOffsets(group, topic)
+		if err != nil {
+			return GroupTopicStatus{}, err
+		}
+		_, versionAfter, err := a.getPartitionOwners(zkConn, group, topic)
+		if err != nil {
+			return GroupTopicStatus{}, err
+		}
+		if versionAfter != version {
+			continue
+		}
+		status := GroupTopicStatus{
+			Group:      group,
+			Topic:      topic,
+			Partitions: make([]PartitionStatus, len(offsets)),
+		}
+		for i, po := range offsets {
+			status.Partitions[i] = PartitionStatus{
+				Partition: po.Partition,
+				Begin:     po.Begin,
+				End:       po.End,
+				Offset:    po.Offset,
+				Metadata:  po.Metadata,
+				Lag:       po.Lag(),
+				Owner:     owners[po.Partition],
+			}
+		}
+		return status, nil
+	}
+	return GroupTopicStatus{}, ErrOwnershipUnstable
+}
+
+// GetTopicOffsets returns the range of available offsets for every partition
+// of the specified topic.
+func (a *T) GetTopicOffsets(topic string) ([]PartitionOffsetRange, error) {
+	ranges, err := a.getTopicOffsets(topic)
+	if err != nil {
+		a.ResetKafkaClt()
+		return a.getTopicOffsets(topic)
+	}
+	return ranges, nil
+}
+
+func (a *T) getTopicOffsets(topic string) ([]PartitionOffsetRange, error) {
+	kafkaClt, err := a.lazyKafkaClt()
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := kafkaClt.Partitions(topic)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get topic partitions")
+	}
+	return getOffsetRanges(kafkaClt, topic, partitions)
+}
+
 func (a *T) getGroupOffsets(group, topic string) ([]PartitionOffset, error) {
 	kafkaClt, err := a.lazyKafkaClt()
 	if err != nil {
@@ -115,7 +405,70 @@ func (a *T) getGroupOffsets(group, topic string) ([]PartitionOffset, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get topic partitions")
 	}
+	ranges, err := getOffsetRanges(kafkaClt, topic, partitions)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]PartitionOffset, len(partitions))
+	for i, r := range ranges {
+		offsets[i].Partition = r.Partition
+		offsets[i].Begin = r.Begin
+		offsets[i].End = r.End
+	}
+
+	// Fetch the last committed offsets for all partitions of the group/topic.
+	coordinator, err := kafkaClt.Coordinator(group)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get coordinator")
+	}
+	committed, err := fetchCommittedOffsets(coordinator, group, topic, partitions)
+	if err != nil {
+		return nil, err
+	}
+	for i, block := range committed {
+		offsets[i].Offset = block.Offset
+		offsets[i].Metadata = block.Metadata
+		if stamp, ok := offsetmgr.DecodeAdminStamp(block.Metadata); ok {
+			offsets[i].Metadata = stamp.Meta
+			offsets[i].CommittedBy = stamp.CommittedBy
+			offsets[i].CommittedAt = stamp.CommittedAt
+		}
+	}
+
+	return offsets, nil
+}
+
+// fetchCommittedOffsets returns the last offsets committed by the group for
+// the specified topic partitions, in the order the partitions are given.
+func fetchCommittedOffsets(coordinator *sarama.Broker, group, topic string, partitions []int32) ([]*sarama.OffsetFetchResponseBlock, error) {
+	req := sarama.OffsetFetchRequest{ConsumerGroup: group, Version: ProtocolVer1}
+	for _, p := range partitions {
+		req.AddPartition(topic, p)
+	}
+	res, err := coordinator.FetchOffset(&req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch offsets")
+	}
+	blocks := make([]*sarama.OffsetFetchResponseBlock, len(partitions))
+	for i, p := range partitions {
+		block := res.GetBlock(topic, p)
+		if block == nil {
+			return nil, errors.Errorf("offset block is missing, partition=%d", p)
+		}
+		// If a coordinator is still loading offsets, then it returns -1
+		// offsets with empty metadata, that must not be mistaken for the
+		// last committed ones.
+		if block.Err != sarama.ErrNoError {
+			return nil, errors.Wrapf(block.Err, "failed to fetch offset, partition=%d", p)
+		}
+		blocks[i] = block
+	}
+	return blocks, nil
+}
 
+// getOffsetRanges queries partition leaders for the oldest and newest offsets
+// of the specified topic partitions.
+func getOffsetRanges(kafkaClt sarama.Client, topic string, partitions []int32) ([]PartitionOffsetRange, error) {
 	// Figure out distribution of partitions among brokers.
 	brokerToPartitions := make(map[*sarama.Broker][]indexedPartition)
 	for i, p := range partitions {
@@ -128,7 +481,7 @@ func (a *T) getGroupOffsets(group, topic string) ([]PartitionOffset, error) {
 
 	// Query brokers for the oldest and newest offsets of the partitions that
 	// they are leaders for.
-	offsets := make([]PartitionOffset, len(partitions))
+	ranges := make([]PartitionOffsetRange, len(partitions))
 	var wg sync.WaitGroup
 	errorsCh := make(chan error, len(brokerToPartitions))
 	for broker, brokerPartitions := range brokerToPartitions {
@@ -162,9 +515,9 @@ func (a *T) getGroupOffsets(group, topic string) ([]PartitionOffset, error) {
 					errorsCh <- errors.Wrapf(err, "failed to fetch newest offset, broker=%v", broker.ID())
 					return
 				}
-				offsets[xp.index].Partition = xp.partition
-				offsets[xp.index].Begin = begin
-				offsets[xp.index].End = end
+				ranges[xp.index].Partition = xp.partition
+				ranges[xp.index].Begin = begin
+				ranges[xp.index].End = end
 			}
 		})
 	}
@@ -175,43 +528,56 @@ func (a *T) getGroupOffsets(group, topic string) ([]PartitionOffset, error) {
 	if err, ok := <-errorsCh; ok {
 		return nil, err
 	}
-
-	// Fetch the last committed offsets for all partitions of the group/topic.
-	coordinator, err := kafkaClt.Coordinator(group)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get coordinator")
-	}
-	req := sarama.OffsetFetchRequest{ConsumerGroup: group, Version: ProtocolVer1}
-	for _, p := range partitions {
-		req.AddPartition(topic, p)
-	}
-	res, err := coordinator.FetchOffset(&req)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to fetch offsets")
-	}
-	for i, p := range partitions {
-		block := res.GetBlock(topic, p)
-		if block == nil {
-			return nil, errors.Wrapf(nil, "offset block is missing, partition=%d", p)
-		}
-		offsets[i].Offset = block.Offset
-		offsets[i].Metadata = block.Metadata
-	}
-
-	return offsets, nil
+	return ranges, nil
 }
 
 // SetGroupOffsets commits specific offset values along with metadata for a list
 // of partitions of a particular topic on behalf of the specified group.
+//
+// To leave an audit trail of offset manipulations, the committed metadata is
+// an offsetmgr.AdminStamp JSON that records the host name of the proxy and the
+// current time along with the given metadata. GetGroupOffsets returns them
+// in CommittedBy and CommittedAt, and the given metadata in Metadata. If the
+// stamped metadata would be longer than 4096 bytes, that is the default
+// `offset.metadata.max.bytes` of Kafka brokers, then the given metadata is
+// committed as is, without a stamp.
 func (a *T) SetGroupOffsets(group, topic string, offsets []PartitionOffset) error {
-	if err := a.setGroupOffsets(group, topic, offsets); err != nil {
+	return a.SetGroupOffsetsWithOpts(group, topic, offsets, SetOffsetsOpts{})
+}
+
+// SetOffsetsOpts holds optional parameters of SetGroupOffsetsWithOpts.
+type SetOffsetsOpts struct {
+	// Retention overrides `offsets.retention.minutes` of the Kafka cluster for
+	// the committed offsets. If zero then the cluster default is used.
+	Retention time.Duration
+
+	// NoDecrease makes the commit fail with ErrOffsetDecrease if any of the
+	// new offsets is less than the one currently committed by the group for
+	// the respective partition. The check and the commit are not atomic, so
+	// an offset committed concurrently by a consumer can still be rewound.
+	NoDecrease bool
+}
+
+// SetGroupOffsetsWithOpts is the same as SetGroupOffsets but allows to
+// specify optional parameters of the commit. Setting retention requires
+// `kafka.version` 0.9.0.0 or later, otherwise ErrRetentionUnsupported is
+// returned. If NoDecrease is set and a commit would move an offset backwards
+// then ErrOffsetDecrease is returned and none of the offsets are committed.
+func (a *T) SetGroupOffsetsWithOpts(group, topic string, offsets []PartitionOffset, opts SetOffsetsOpts) error {
+	if opts.Retention > 0 && !a.cfg.Kafka.Version.IsAtLeast(sarama.V0_9_0_0) {
+		return ErrRetentionUnsupported
+	}
+	if err := a.setGroupOffsets(group, topic, offsets, opts); err != nil {
+		if errors.Cause(err) == ErrOffsetDecrease {
+			return err
+		}
 		a.ResetKafkaClt()
-		return a.setGroupOffsets(group, topic, offsets)
+		return a.setGroupOffsets(group, topic, offsets, opts)
 	}
 	return nil
 }
 
-func (a *T) setGroupOffsets(group, topic string, offsets []PartitionOffset) error {
+func (a *T) setGroupOffsets(group, topic string, offsets []PartitionOffset, opts SetOffsetsOpts) error {
 	kafkaClt, err := a.lazyKafkaClt()
 	if err != nil {
 		return err
@@ -220,98 +586,1010 @@ func (a *T) setGroupOffsets(group, topic string, offsets []PartitionOffset) erro
 	if err != nil {
 		return errors.Wrap(err, "failed to get coordinator")
 	}
+	if opts.NoDecrease {
+		partitions := make([]int32, len(offsets))
+		for i, po := range offsets {
+			partitions[i] = po.Partition
+		}
+		committed, err := fetchCommittedOffsets(coordinator, group, topic, partitions)
+		if err != nil {
+			return err
+		}
+		for i, po := range offsets {
+			if po.Offset < committed[i].Offset {
+				return errors.Wrapf(ErrOffsetDecrease, "partition=%d, committed=%d, new=%d",
+					po.Partition, committed[i].Offset, po.Offset)
+			}
+		}
+	}
 
 	req := sarama.OffsetCommitRequest{
 		Version:                 ProtocolVer1,
 		ConsumerGroup:           group,
 		ConsumerGroupGeneration: sarama.GroupGenerationUndefined,
 	}
+	if opts.Retention > 0 {
+		req.Version = ProtocolVer2
+		req.RetentionTime = int64(opts.Retention / time.Millisecond)
+	}
+	stamp := offsetmgr.AdminStamp{CommittedBy: a.hostname, CommittedAt: time.Now().UTC()}
 	for _, po := range offsets {
-		req.AddBlock(topic, po.Partition, po.Offset, sarama.ReceiveTime, po.Metadata)
+		stamp.Meta = po.Metadata
+		metadata := stamp.Encode()
+		if len(metadata) > maxStampedMetadataBytes {
+			metadata = po.Metadata
+		}
+		req.AddBlock(topic, po.Partition, po.Offset, sarama.ReceiveTime, metadata)
 	}
 	res, err := coordinator.CommitOffset(&req)
 	if err != nil {
 		return errors.Wrap(err, "failed to commit offsets")
 	}
 	for p, err := range res.Errors[topic] {
-		if err != sarama.ErrNoError {
+		switch err {
+		case sarama.ErrNoError:
+		case sarama.ErrOffsetMetadataTooLarge:
+			return errors.Wrapf(err, "failed to commit offset, metadata exceeds `offset.metadata.max.bytes` of the broker, partition=%d", p)
+		default:
 			return errors.Wrapf(err, "failed to commit offset, partition=%d", p)
 		}
 	}
 	return nil
 }
 
-// GetTopicConsumers returns client-id -> consumed-partitions-list mapping
-// for a clients from a particular consumer group and a particular topic.
-func (a *T) GetTopicConsumers(group, topic string) (map[string][]int32, error) {
-	zkConn, err := a.lazyZKConn()
+// ResetSpec defines how ResetGroupOffsets selects new committed offsets. Use
+// Earliest, Latest, ToTimestamp or ToOffset to create a value.
+type ResetSpec struct {
+	// Either sarama.OffsetOldest, sarama.OffsetNewest or a timestamp in
+	// milliseconds. Ignored if offsets is not nil.
+	time    int64
+	offsets map[int32]int64
+}
+
+// Earliest returns a spec that resets a group to the oldest offsets available
+// in the topic partitions.
+func Earliest() ResetSpec {
+	return ResetSpec{time: sarama.OffsetOldest}
+}
+
+// Latest returns a spec that resets a group to the newest offsets of the topic
+// partitions, effectively skipping all messages that are in there.
+func Latest() ResetSpec {
+	return ResetSpec{time: sarama.OffsetNewest}
+}
+
+// ToTimestamp returns a spec that resets a group to the earliest offsets of
+// messages produced at or after the given time. Note that with Kafka versions
+// older than 0.10.1.0 the offsets are resolved with log segment granularity.
+// For partitions that have no messages after the given time the newest offset
+// is selected.
+func ToTimestamp(t time.Time) ResetSpec {
+	return ResetSpec{time: toMillis(t)}
+}
+
+// ToOffset returns a spec that resets a group to explicit partition offsets.
+// Partitions that are not mentioned in the map are left intact. Offsets must
+// not be negative, use Earliest or Latest to reset to the oldest or newest
+// offsets.
+func ToOffset(offsets map[int32]int64) ResetSpec {
+	return ResetSpec{offsets: offsets}
+}
+
+// ResetGroupOffsets commits offsets selected according to the spec for all
+// partitions of a topic on behalf of the specified group. Committed metadata
+// is replaced with a stamp of the host name and the time, as SetGroupOffsets
+// does it with empty metadata. Note that if the group is actively consuming
+// the topic at the time, then its members will overwrite the reset offsets
+// with their own.
+func (a *T) ResetGroupOffsets(group, topic string, spec ResetSpec) error {
+	if err := a.resetGroupOffsets(group, topic, spec); err != nil {
+		if _, ok := err.(ErrInvalidParam); ok {
+			return err
+		}
+		a.ResetKafkaClt()
+		return a.resetGroupOffsets(group, topic, spec)
+	}
+	return nil
+}
+
+func (a *T) resetGroupOffsets(group, topic string, spec ResetSpec) error {
+	kafkaClt, err := a.lazyKafkaClt()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	consumedPartitionsPath := fmt.Sprintf("%s/consumers/%s/owners/%s",
-		a.cfg.ZooKeeper.Chroot, group, topic)
-	partitionNodes, _, err := zkConn.Children(consumedPartitionsPath)
+	partitions, err := kafkaClt.Partitions(topic)
 	if err != nil {
-		if err == zk.ErrNoNode {
-			return nil, ErrInvalidParam(errors.New("either group or topic is incorrect"))
-		}
-		return nil, errors.Wrap(err, "failed to fetch partition owners data")
+		return errors.Wrap(err, "failed to get topic partitions")
 	}
 
-	consumers := make(map[string][]int32)
-	for _, partitionNode := range partitionNodes {
-		partition, err := strconv.Atoi(partitionNode)
-		if err != nil {
-			return nil, errors.Wrapf(err, "invalid partition id, %s", partitionNode)
+	var offsets []PartitionOffset
+	if spec.offsets != nil {
+		partitionCount := int32(len(partitions))
+		for p, offset := range spec.offsets {
+			if p < 0 || p >= partitionCount {
+				return ErrInvalidParam(errors.Errorf("invalid partition: %d", p))
+			}
+			if offset < 0 {
+				return ErrInvalidParam(errors.Errorf("invalid offset: partition=%d, offset=%d", p, offset))
+			}
+			offsets = append(offsets, PartitionOffset{Partition: p, Offset: offset})
 		}
-		partitionPath := fmt.Sprintf("%s/%s", consumedPartitionsPath, partitionNode)
-		partitionNodeData, _, err := zkConn.Get(partitionPath)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to fetch partition owner")
+	} else {
+		offsets = make([]PartitionOffset, len(partitions))
+		for i, p := range partitions {
+			offset, err := getOffsetForTime(kafkaClt, topic, p, spec.time)
+			if err != nil {
+				return err
+			}
+			offsets[i] = PartitionOffset{Partition: p, Offset: offset}
 		}
-		clientID := string(partitionNodeData)
-		consumers[clientID] = append(consumers[clientID], int32(partition))
 	}
+	return a.setGroupOffsets(group, topic, offsets, SetOffsetsOpts{})
+}
 
-	for _, partitions := range consumers {
-		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+// GetOffsetForTime returns the earliest offset of a message produced to the
+// topic partition at or after the given time. If there are no such messages,
+// then the newest offset of the partition is returned. Note that with Kafka
+// versions older than 0.10.1.0 offsets are resolved with log segment
+// granularity.
+func (a *T) GetOffsetForTime(topic string, partition int32, t time.Time) (int64, error) {
+	offset, err := a.getOffsetForTime(topic, partition, t)
+	if err != nil {
+		a.ResetKafkaClt()
+		return a.getOffsetForTime(topic, partition, t)
 	}
-
-	return consumers, nil
+	return offset, nil
 }
 
-// GetAllTopicConsumers returns group -> client-id -> consumed-partitions-list
-// mapping for a particular topic. Warning, the function performs scan of all
-// consumer groups registered in ZooKeeper and therefore can take a lot of time.
-func (a *T) GetAllTopicConsumers(topic string) (map[string]map[string][]int32, error) {
-	kzConn, err := a.lazyZKConn()
+func (a *T) getOffsetForTime(topic string, partition int32, t time.Time) (int64, error) {
+	kafkaClt, err := a.lazyKafkaClt()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
+	return getOffsetForTime(kafkaClt, topic, partition, toMillis(t))
+}
 
-	groupsPath := fmt.Sprintf("%s/consumers", a.cfg.ZooKeeper.Chroot)
-	groups, _, err := kzConn.Children(groupsPath)
+// GetOffsetsForTime is the same as GetOffsetForTime, but it returns offsets
+// for all partitions of the topic.
+func (a *T) GetOffsetsForTime(topic string, t time.Time) (map[int32]int64, error) {
+	offsets, err := a.getOffsetsForTime(topic, t)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to fetch consumer groups")
+		a.ResetKafkaClt()
+		return a.getOffsetsForTime(topic, t)
 	}
+	return offsets, nil
+}
 
-	consumers := make(map[string]map[string][]int32)
-	for _, group := range groups {
-		groupConsumers, err := a.GetTopicConsumers(group, topic)
+func (a *T) getOffsetsForTime(topic string, t time.Time) (map[int32]int64, error) {
+	kafkaClt, err := a.lazyKafkaClt()
+	if err != nil {
+		return nil, err
+	}
+	partitions, err := kafkaClt.Partitions(topic)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get topic partitions")
+	}
+	offsets := make(map[int32]int64, len(partitions))
+	for _, p := range partitions {
+		offset, err := getOffsetForTime(kafkaClt, topic, p, toMillis(t))
 		if err != nil {
-			if _, ok := err.(ErrInvalidParam); ok {
-				continue
-			}
-			return nil, errors.Wrapf(err, "failed to fetch group `%s` data", group)
-		}
-		if len(groupConsumers) > 0 {
-			consumers[group] = groupConsumers
+			return nil, err
 		}
+		offsets[p] = offset
 	}
-	return consumers, nil
+	return offsets, nil
 }
 
-func (a *T) lazyKafkaClt() (sarama.Client, error) {
+// getOffsetForTime returns an offset of a partition for a timestamp in
+// milliseconds, or sarama.OffsetOldest/sarama.OffsetNewest.
+func getOffsetForTime(kafkaClt sarama.Client, topic string, partition int32, timestamp int64) (int64, error) {
+	offset, err := kafkaClt.GetOffset(topic, partition, timestamp)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get offset, partition=%d", partition)
+	}
+	// Kafka returns -1 if there are no messages after the timestamp.
+	if offset < 0 {
+		if offset, err = kafkaClt.GetOffset(topic, partition, sarama.OffsetNewest); err != nil {
+			return 0, errors.Wrapf(err, "failed to get newest offset, partition=%d", partition)
+		}
+	}
+	return offset, nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// GetTopicConsumers returns client-id -> consumed-partitions-list mapping
+// for a clients from a particular consumer group and a particular topic.
+func (a *T) GetTopicConsumers(group, topic string) (map[string][]int32, error) {
+	zkConn, err := a.lazyZKConn()
+	if err != nil {
+		return nil, err
+	}
+	owners, version, err := a.getPartitionOwners(zkConn, group, topic)
+	if err != nil {
+		return nil, err
+	}
+	if version < 0 {
+		return nil, ErrInvalidParam(errors.New("either group or topic is incorrect"))
+	}
+
+	consumers := make(map[string][]int32)
+	for partition, clientID := range owners {
+		consumers[clientID] = append(consumers[clientID], partition)
+	}
+
+	for _, partitions := range consumers {
+		sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+	}
+
+	return consumers, nil
+}
+
+// getPartitionOwners returns partition -> client-id mapping of the partitions
+// of a topic claimed by members of a consumer group, along with the children
+// version of the ZooKeeper node that claims are registered under. The version
+// changes every time a partition is claimed or released. If the node does not
+// exist then the version is -1.
+func (a *T) getPartitionOwners(zkConn *zk.Conn, group, topic string) (map[int32]string, int32, error) {
+	consumedPartitionsPath := fmt.Sprintf("%s/consumers/%s/owners/%s",
+		a.cfg.ZooKeeper.Chroot, group, topic)
+	partitionNodes, stat, err := zkConn.Children(consumedPartitionsPath)
+	if err != nil {
+		if err == zk.ErrNoNode {
+			return nil, -1, nil
+		}
+		return nil, 0, errors.Wrap(err, "failed to fetch partition owners data")
+	}
+
+	owners := make(map[int32]string, len(partitionNodes))
+	for _, partitionNode := range partitionNodes {
+		partition, err := strconv.Atoi(partitionNode)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid partition id, %s", partitionNode)
+		}
+		partitionPath := fmt.Sprintf("%s/%s", consumedPartitionsPath, partitionNode)
+		partitionNodeData, _, err := zkConn.Get(partitionPath)
+		if err != nil {
+			// The partition has been released since the children were
+			// listed, that is reflected by the children version.
+			if err == zk.ErrNoNode {
+				continue
+			}
+			return nil, 0, errors.Wrap(err, "failed to fetch partition owner")
+		}
+		owners[int32(partition)] = string(partitionNodeData)
+	}
+	return owners, stat.Cversion, nil
+}
+
+// GetAllTopicConsumers returns group -> client-id -> consumed-partitions-list
+// mapping for a particular topic. Warning, the function performs scan of all
+// consumer groups registered in ZooKeeper and therefore can take a lot of time.
+func (a *T) GetAllTopicConsumers(topic string) (map[string]map[string][]int32, error) {
+	kzConn, err := a.lazyZKConn()
+	if err != nil {
+		return nil, err
+	}
+
+	groupsPath := fmt.Sprintf("%s/consumers", a.cfg.ZooKeeper.Chroot)
+	groups, _, err := kzConn.Children(groupsPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch consumer groups")
+	}
+
+	consumers := make(map[string]map[string][]int32)
+	for _, group := range groups {
+		groupConsumers, err := a.GetTopicConsumers(group, topic)
+		if err != nil {
+			if _, ok := err.(ErrInvalidParam); ok {
+				continue
+			}
+			return nil, errors.Wrapf(err, "failed to fetch group `%s` data", group)
+		}
+		if len(groupConsumers) > 0 {
+			consumers[group] = groupConsumers
+		}
+	}
+	return consumers, nil
+}
+
+// GetClusterMetadata returns brokers of the Kafka cluster and the ID of the
+// controller broker. Brokers are taken from the metadata cached by the Kafka
+// client, that is not refreshed by the call, and the controller is read from
+// ZooKeeper.
+func (a *T) GetClusterMetadata() (ClusterMetadata, error) {
+	kafkaClt, err := a.lazyKafkaClt()
+	if err != nil {
+		return ClusterMetadata{}, err
+	}
+	kazooClt, err := a.lazyKazooClt()
+	if err != nil {
+		return ClusterMetadata{}, err
+	}
+	var cm ClusterMetadata
+	for _, broker := range kafkaClt.Brokers() {
+		host, portStr, err := net.SplitHostPort(broker.Addr())
+		if err != nil {
+			return ClusterMetadata{}, errors.Wrapf(err, "bad broker address, id=%d", broker.ID())
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return ClusterMetadata{}, errors.Wrapf(err, "bad broker port, id=%d", broker.ID())
+		}
+		cm.Brokers = append(cm.Brokers, BrokerMetadata{ID: broker.ID(), Host: host, Port: port})
+	}
+	sort.Slice(cm.Brokers, func(i, j int) bool { return cm.Brokers[i].ID < cm.Brokers[j].ID })
+
+	if cm.ControllerID, err = getControllerID(kazooClt); err != nil {
+		return ClusterMetadata{}, err
+	}
+	return cm, nil
+}
+
+// GetControllerID returns the ID of the controller broker read from
+// ZooKeeper. If no broker is elected controller at the moment, then -1 is
+// returned.
+func (a *T) GetControllerID() (int32, error) {
+	kazooClt, err := a.lazyKazooClt()
+	if err != nil {
+		return -1, err
+	}
+	return getControllerID(kazooClt)
+}
+
+func getControllerID(kazooClt *kazoo.Kazoo) (int32, error) {
+	controllerID, err := kazooClt.Controller()
+	if err != nil {
+		if errors.Cause(err) != zk.ErrNoNode {
+			return -1, errors.Wrap(err, "failed to get controller")
+		}
+		return -1, nil
+	}
+	return controllerID, nil
+}
+
+// ListConsumerGroups returns a sorted list of all consumer groups known to the
+// Kafka cluster. That includes groups that only commit offsets to Kafka and
+// use other means of coordination, e.g. Kafka-Pixy consumer groups.
+func (a *T) ListConsumerGroups() ([]string, error) {
+	groups, err := a.listConsumerGroups()
+	if err != nil {
+		a.ResetKafkaClt()
+		return a.listConsumerGroups()
+	}
+	return groups, nil
+}
+
+func (a *T) listConsumerGroups() ([]string, error) {
+	kafkaClt, err := a.lazyKafkaClt()
+	if err != nil {
+		return nil, err
+	}
+	// Refresh metadata to make sure that all brokers are known.
+	if err = kafkaClt.RefreshMetadata(); err != nil {
+		return nil, errors.Wrap(err, "failed to refresh metadata")
+	}
+	// Each broker only knows about groups that it coordinates, so all of
+	// them have to be queried.
+	saramaCfg := a.cfg.SaramaClientCfg()
+	groupSet := make(map[string]none.T)
+	for _, broker := range kafkaClt.Brokers() {
+		if err := broker.Open(saramaCfg); err != nil && err != sarama.ErrAlreadyConnected {
+			return nil, errors.Wrapf(err, "failed to connect, broker=%d", broker.ID())
+		}
+		res, err := broker.ListGroups(&sarama.ListGroupsRequest{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list groups, broker=%d", broker.ID())
+		}
+		if res.Err != sarama.ErrNoError {
+			return nil, errors.Wrapf(res.Err, "failed to list groups, broker=%d", broker.ID())
+		}
+		for group := range res.Groups {
+			groupSet[group] = none.V
+		}
+	}
+	groups := make([]string, 0, len(groupSet))
+	for group := range groupSet {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups, nil
+}
+
+// DescribeConsumerGroup returns the state, the protocol, and the members of
+// the consumer group along with partitions assigned to them. Note that
+// Kafka-Pixy consumer groups are coordinated via ZooKeeper, so they are
+// reported as Empty and their members are returned by GetTopicConsumers.
+func (a *T) DescribeConsumerGroup(group string) (GroupDescription, error) {
+	gd, err := a.describeConsumerGroup(group)
+	if err != nil {
+		a.ResetKafkaClt()
+		return a.describeConsumerGroup(group)
+	}
+	return gd, nil
+}
+
+func (a *T) describeConsumerGroup(group string) (GroupDescription, error) {
+	kafkaClt, err := a.lazyKafkaClt()
+	if err != nil {
+		return GroupDescription{}, err
+	}
+	coordinator, err := kafkaClt.Coordinator(group)
+	if err != nil {
+		return GroupDescription{}, errors.Wrap(err, "failed to get coordinator")
+	}
+	req := sarama.DescribeGroupsRequest{Groups: []string{group}}
+	res, err := coordinator.DescribeGroups(&req)
+	if err != nil {
+		return GroupDescription{}, errors.Wrap(err, "failed to describe group")
+	}
+	if len(res.Groups) != 1 {
+		return GroupDescription{}, errors.Errorf("bad describe groups response, groups=%d", len(res.Groups))
+	}
+	saramaGD := res.Groups[0]
+	if saramaGD.Err != sarama.ErrNoError {
+		return GroupDescription{}, errors.Wrap(saramaGD.Err, "failed to describe group")
+	}
+	gd := GroupDescription{
+		Group:        saramaGD.GroupId,
+		State:        saramaGD.State,
+		ProtocolType: saramaGD.ProtocolType,
+		Protocol:     saramaGD.Protocol,
+		Members:      make([]GroupMemberDescription, 0, len(saramaGD.Members)),
+	}
+	for memberID, saramaGMD := range saramaGD.Members {
+		gmd := GroupMemberDescription{
+			MemberID:   memberID,
+			ClientID:   saramaGMD.ClientId,
+			ClientHost: saramaGMD.ClientHost,
+		}
+		// Metadata and assignment formats of other protocol types, e.g.
+		// `connect`, are opaque to us.
+		if saramaGD.ProtocolType == "consumer" {
+			metadata, err := saramaGMD.GetMemberMetadata()
+			if err != nil {
+				return GroupDescription{}, errors.Wrapf(err, "failed to decode metadata, member=%s", memberID)
+			}
+			gmd.Topics = metadata.Topics
+			sort.Strings(gmd.Topics)
+			assignment, err := saramaGMD.GetMemberAssignment()
+			if err != nil {
+				return GroupDescription{}, errors.Wrapf(err, "failed to decode assignment, member=%s", memberID)
+			}
+			gmd.Assignment = assignment.Topics
+			for _, partitions := range gmd.Assignment {
+				sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+			}
+		}
+		gd.Members = append(gd.Members, gmd)
+	}
+	sort.Slice(gd.Members, func(i, j int) bool { return gd.Members[i].MemberID < gd.Members[j].MemberID })
+	return gd, nil
+}
+
+// DeleteConsumerGroup removes registration of a Kafka-Pixy consumer group from
+// ZooKeeper. ErrGroupNotEmpty is returned if the group has active members,
+// and ErrGroupNotExist if it is not registered.
+//
+// The version of the Kafka protocol used by Kafka-Pixy cannot delete offsets
+// committed by the group, so they are committed again with the minimal
+// retention time instead, and Kafka removes them on its next offsets cleanup,
+// see `offsets.retention.check.interval.ms`. That requires `kafka.version`
+// 0.9.0.0 or later, with older versions offsets are left to be removed after
+// `offsets.retention.minutes`.
+func (a *T) DeleteConsumerGroup(group string) error {
+	kazooClt, err := a.lazyKazooClt()
+	if err != nil {
+		return err
+	}
+	kzGroup := kazooClt.Consumergroup(group)
+	exists, err := kzGroup.Exists()
+	if err != nil {
+		return errors.Wrap(err, "failed to check group registration")
+	}
+	if !exists {
+		return ErrGroupNotExist
+	}
+	// Groups that are coordinated by Kafka rather than ZooKeeper may still
+	// have active members.
+	gd, err := a.DescribeConsumerGroup(group)
+	if err != nil {
+		return err
+	}
+	if len(gd.Members) > 0 {
+		return ErrGroupNotEmpty
+	}
+	if err = kzGroup.Delete(); err != nil {
+		if err == kazoo.ErrRunningInstances {
+			return ErrGroupNotEmpty
+		}
+		return errors.Wrap(err, "failed to delete group registration")
+	}
+	if !a.cfg.Kafka.Version.IsAtLeast(sarama.V0_9_0_0) {
+		return nil
+	}
+	if err = a.expireGroupOffsets(group); err != nil {
+		return errors.Wrap(err, "group registration deleted, but failed to expire offsets")
+	}
+	return nil
+}
+
+// expireGroupOffsets commits all offsets committed by the group again with
+// the minimal retention time, so that Kafka removes them on its next offsets
+// cleanup.
+func (a *T) expireGroupOffsets(group string) error {
+	export, err := a.exportGroupOffsets(group)
+	if err != nil {
+		a.ResetKafkaClt()
+		if export, err = a.exportGroupOffsets(group); err != nil {
+			return err
+		}
+	}
+	var topics []string
+	offsetsByTopic := make(map[string][]PartitionOffset)
+	for _, eo := range export.Offsets {
+		if _, ok := offsetsByTopic[eo.Topic]; !ok {
+			topics = append(topics, eo.Topic)
+		}
+		offsetsByTopic[eo.Topic] = append(offsetsByTopic[eo.Topic], PartitionOffset{
+			Partition: eo.Partition,
+			Offset:    eo.Offset,
+		})
+	}
+	opts := SetOffsetsOpts{Retention: minOffsetRetention}
+	for _, topic := range topics {
+		if err := a.SetGroupOffsetsWithOpts(group, topic, offsetsByTopic[topic], opts); err != nil {
+			return errors.Wrapf(err, "topic=%s", topic)
+		}
+	}
+	return nil
+}
+
+// GroupOffsetsExport is the portable representation of offsets committed by
+// a consumer group, that is produced by ExportGroupOffsets and consumed by
+// ImportGroupOffsets. It does not depend on the cluster it was exported
+// from, so offsets can be restored on another cluster, e.g. on failover.
+type GroupOffsetsExport struct {
+	Version int              `json:"version"`
+	Group   string           `json:"group"`
+	Offsets []ExportedOffset `json:"offsets"`
+}
+
+// ExportedOffset is an offset committed to a topic partition.
+type ExportedOffset struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Metadata  string `json:"metadata"`
+}
+
+const groupOffsetsExportVersion = 1
+
+// ExportGroupOffsets returns all offsets committed by the consumer group as
+// a GroupOffsetsExport JSON. Kafka-Pixy consumer groups do not keep track of
+// subscribed topics when they have no members, therefore all topics of the
+// cluster are checked, and partitions that the group has never committed an
+// offset to are omitted.
+func (a *T) ExportGroupOffsets(group string) ([]byte, error) {
+	export, err := a.exportGroupOffsets(group)
+	if err != nil {
+		a.ResetKafkaClt()
+		if export, err = a.exportGroupOffsets(group); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(export)
+}
+
+func (a *T) exportGroupOffsets(group string) (GroupOffsetsExport, error) {
+	export := GroupOffsetsExport{Version: groupOffsetsExportVersion, Group: group, Offsets: []ExportedOffset{}}
+	kafkaClt, err := a.lazyKafkaClt()
+	if err != nil {
+		return export, err
+	}
+	if err = kafkaClt.RefreshMetadata(); err != nil {
+		return export, errors.Wrap(err, "failed to refresh metadata")
+	}
+	topics, err := kafkaClt.Topics()
+	if err != nil {
+		return export, errors.Wrap(err, "failed to get topics")
+	}
+	sort.Strings(topics)
+	coordinator, err := kafkaClt.Coordinator(group)
+	if err != nil {
+		return export, errors.Wrap(err, "failed to get coordinator")
+	}
+	for _, topic := range topics {
+		partitions, err := kafkaClt.Partitions(topic)
+		if err != nil {
+			return export, errors.Wrapf(err, "failed to get partitions, topic=%s", topic)
+		}
+		committed, err := fetchCommittedOffsets(coordinator, group, topic, partitions)
+		if err != nil {
+			return export, errors.Wrapf(err, "topic=%s", topic)
+		}
+		for i, block := range committed {
+			if block.Offset == sarama.OffsetNewest {
+				continue
+			}
+			// Offsets are stamped again when they are imported.
+			metadata := block.Metadata
+			if stamp, ok := offsetmgr.DecodeAdminStamp(metadata); ok {
+				metadata = stamp.Meta
+			}
+			export.Offsets = append(export.Offsets, ExportedOffset{
+				Topic:     topic,
+				Partition: partitions[i],
+				Offset:    block.Offset,
+				Metadata:  metadata,
+			})
+		}
+	}
+	return export, nil
+}
+
+// ImportGroupOffsets commits offsets given as a GroupOffsetsExport JSON on
+// behalf of the specified consumer group. The group does not have to be the
+// one that the offsets were exported from. Offsets are committed topic by
+// topic, so if an error is returned then offsets of some topics may have
+// been committed already.
+func (a *T) ImportGroupOffsets(group string, data []byte) error {
+	var export GroupOffsetsExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return ErrInvalidParam(errors.Wrap(err, "invalid offsets export"))
+	}
+	if export.Version != groupOffsetsExportVersion {
+		return ErrInvalidParam(errors.Errorf("unsupported offsets export version: %d", export.Version))
+	}
+	var topics []string
+	offsetsByTopic := make(map[string][]PartitionOffset)
+	for _, eo := range export.Offsets {
+		if eo.Topic == "" || eo.Partition < 0 || eo.Offset < 0 {
+			return ErrInvalidParam(errors.Errorf("invalid offset: %+v", eo))
+		}
+		if _, ok := offsetsByTopic[eo.Topic]; !ok {
+			topics = append(topics, eo.Topic)
+		}
+		offsetsByTopic[eo.Topic] = append(offsetsByTopic[eo.Topic], PartitionOffset{
+			Partition: eo.Partition,
+			Offset:    eo.Offset,
+			Metadata:  eo.Metadata,
+		})
+	}
+	for _, topic := range topics {
+		if err := a.SetGroupOffsets(group, topic, offsetsByTopic[topic]); err != nil {
+			return errors.Wrapf(err, "failed to import offsets, topic=%s", topic)
+		}
+	}
+	return nil
+}
+
+// CreateTopic creates a topic with the specified number of partitions,
+// replication factor and topic level configuration overrides. If the topic
+// already exists then ErrTopicExists is returned, unless ifNotExists is true.
+//
+// Topics are created the same way as `kafka-topics.sh --zookeeper` does it,
+// by writing a random partition replica assignment to ZooKeeper. The Kafka
+// controller picks it up and creates the topic asynchronously, therefore it
+// may take a moment for the topic to become available.
+func (a *T) CreateTopic(topic string, partitions int32, replication int16, configs map[string]string, ifNotExists bool) error {
+	if partitions <= 0 {
+		return ErrBadPartitionCount
+	}
+	if replication <= 0 {
+		return ErrBadReplicationFactor
+	}
+	kazooClt, err := a.lazyKazooClt()
+	if err != nil {
+		return err
+	}
+	err = kazooClt.CreateTopic(topic, int(partitions), int(replication), configs)
+	switch err {
+	case nil:
+		return nil
+	case kazoo.ErrTopicExists:
+		if ifNotExists {
+			return nil
+		}
+		return ErrTopicExists
+	case kazoo.ErrInvalidReplicationFactor:
+		return ErrBadReplicationFactor
+	default:
+		return errors.Wrap(err, "failed to create topic")
+	}
+}
+
+// DeleteTopic marks a topic for deletion in ZooKeeper and waits for the Kafka
+// controller to delete it. If the topic is not deleted in a timely manner,
+// that is usually the case when `delete.topic.enable` is false on brokers,
+// then ErrTopicNotDeleted is returned.
+func (a *T) DeleteTopic(topic string) error {
+	kazooClt, err := a.lazyKazooClt()
+	if err != nil {
+		return err
+	}
+	exists, err := kazooClt.Topic(topic).Exists()
+	if err != nil {
+		return errors.Wrap(err, "failed to check topic existence")
+	}
+	if !exists {
+		return ErrTopicNotExist
+	}
+	err = kazooClt.DeleteTopicSync(topic, deleteTopicTimeout)
+	switch err {
+	case nil:
+		return nil
+	case kazoo.ErrTopicMarkedForDelete, kazoo.ErrDeletionTimedOut:
+		return ErrTopicNotDeleted
+	default:
+		return errors.Wrap(err, "failed to delete topic")
+	}
+}
+
+// CreatePartitions increases the number of topic partitions to newTotal. Kafka
+// does not support decreasing the number of partitions, so if newTotal is not
+// greater than the current partition count, then ErrPartitionsNotAdded is
+// returned. New partitions get the same number of replicas as existing ones.
+//
+// Partitions are added the same way as `kafka-topics.sh --alter` does it, by
+// updating the topic partition replica assignment in ZooKeeper. The Kafka
+// controller picks it up and creates partitions asynchronously.
+func (a *T) CreatePartitions(topic string, newTotal int32) error {
+	zkConn, err := a.lazyZKConn()
+	if err != nil {
+		return err
+	}
+	topicPath := fmt.Sprintf("%s/brokers/topics/%s", a.cfg.ZooKeeper.Chroot, topic)
+	data, stat, err := zkConn.Get(topicPath)
+	if err != nil {
+		if err == zk.ErrNoNode {
+			return ErrTopicNotExist
+		}
+		return errors.Wrap(err, "failed to fetch partition assignment")
+	}
+	var assignment topicAssignment
+	if err = json.Unmarshal(data, &assignment); err != nil {
+		return errors.Wrap(err, "bad partition assignment")
+	}
+	partitionCount := int32(len(assignment.Partitions))
+	if newTotal <= partitionCount {
+		return ErrPartitionsNotAdded
+	}
+	replication := len(assignment.Partitions["0"])
+
+	brokerNodes, _, err := zkConn.Children(fmt.Sprintf("%s/brokers/ids", a.cfg.ZooKeeper.Chroot))
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch brokers")
+	}
+	brokers := make([]int32, len(brokerNodes))
+	for i, brokerNode := range brokerNodes {
+		brokerID, err := strconv.Atoi(brokerNode)
+		if err != nil {
+			return errors.Wrapf(err, "invalid broker id, %s", brokerNode)
+		}
+		brokers[i] = int32(brokerID)
+	}
+	if replication < 1 || replication > len(brokers) {
+		return ErrBadReplicationFactor
+	}
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i] < brokers[j] })
+
+	// Replicas of new partitions are assigned to brokers round robin.
+	for p := partitionCount; p < newTotal; p++ {
+		replicas := make([]int32, replication)
+		for r := range replicas {
+			replicas[r] = brokers[(int(p)+r)%len(brokers)]
+		}
+		assignment.Partitions[strconv.Itoa(int(p))] = replicas
+	}
+	if data, err = json.Marshal(&assignment); err != nil {
+		return errors.Wrap(err, "failed to encode partition assignment")
+	}
+	// The stat version guards against concurrent assignment updates.
+	if _, err = zkConn.Set(topicPath, data, stat.Version); err != nil {
+		return errors.Wrap(err, "failed to update partition assignment")
+	}
+	return nil
+}
+
+// knownTopicConfigs maps topic level config parameters supported by Kafka to
+// functions that validate their values. Brokers do not validate config
+// overrides written to ZooKeeper, and fail to apply a config with a malformed
+// value, so AlterTopicConfig rejects unknown parameters to protect from typos
+// and values that brokers would not parse.
+var knownTopicConfigs = map[string]func(value string) error{
+	"cleanup.policy":       validateListOf("compact", "delete"),
+	"compression.type":     validateOneOf("uncompressed", "zstd", "lz4", "snappy", "gzip", "producer"),
+	"delete.retention.ms":  validateLong,
+	"file.delete.delay.ms": validateLong,
+	"flush.messages":       validateLong,
+	"flush.ms":             validateLong,
+	"follower.replication.throttled.replicas": validateThrottledReplicas,
+	"index.interval.bytes":                    validateInt,
+	"leader.replication.throttled.replicas":   validateThrottledReplicas,
+	"max.compaction.lag.ms":                   validateLong,
+	"max.message.bytes":                       validateInt,
+	"message.downconversion.enable":           validateBool,
+	"message.format.version":                  validateAny,
+	"message.timestamp.difference.max.ms":     validateLong,
+	"message.timestamp.type":                  validateOneOf("CreateTime", "LogAppendTime"),
+	"min.cleanable.dirty.ratio":               validateDouble,
+	"min.compaction.lag.ms":                   validateLong,
+	"min.insync.replicas":                     validateInt,
+	"preallocate":                             validateBool,
+	"retention.bytes":                         validateLong,
+	"retention.ms":                            validateLong,
+	"segment.bytes":                           validateInt,
+	"segment.index.bytes":                     validateInt,
+	"segment.jitter.ms":                       validateLong,
+	"segment.ms":                              validateLong,
+	"unclean.leader.election.enable":          validateBool,
+}
+
+// throttledReplicasRe matches a list of partition:broker pairs, or a wildcard
+// that stands for all replicas.
+var throttledReplicasRe = regexp.MustCompile(`^(\*|\d+\s*:\s*\d+(\s*,\s*\d+\s*:\s*\d+)*)$`)
+
+// Topic config values are parsed by brokers the same way as values given in
+// server.properties, that is with leading and trailing spaces trimmed, and
+// validators below follow that.
+
+func validateAny(value string) error {
+	return nil
+}
+
+func validateLong(value string) error {
+	if _, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err != nil {
+		return errors.Errorf("%s is not a long", value)
+	}
+	return nil
+}
+
+func validateInt(value string) error {
+	if _, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32); err != nil {
+		return errors.Errorf("%s is not an int", value)
+	}
+	return nil
+}
+
+func validateDouble(value string) error {
+	if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+		return errors.Errorf("%s is not a double", value)
+	}
+	return nil
+}
+
+func validateBool(value string) error {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "false":
+		return nil
+	}
+	return errors.Errorf("%s is not a boolean", value)
+}
+
+func validateThrottledReplicas(value string) error {
+	if !throttledReplicasRe.MatchString(strings.TrimSpace(value)) {
+		return errors.Errorf("%s is not a list of partition:broker pairs", value)
+	}
+	return nil
+}
+
+// validateOneOf returns a validator of values that must be one of the
+// specified ones.
+func validateOneOf(valid ...string) func(string) error {
+	return func(value string) error {
+		for _, v := range valid {
+			if strings.TrimSpace(value) == v {
+				return nil
+			}
+		}
+		return errors.Errorf("%s is not one of %s", value, strings.Join(valid, ", "))
+	}
+}
+
+// validateListOf returns a validator of comma separated lists of values,
+// each of which must be one of the specified ones.
+func validateListOf(valid ...string) func(string) error {
+	validateItem := validateOneOf(valid...)
+	return func(value string) error {
+		for _, item := range strings.Split(value, ",") {
+			if err := validateItem(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AlterTopicConfig updates topic level config overrides of a topic with the
+// given ones. Parameters with empty values are removed from the overrides,
+// so that broker defaults apply to them again. Overrides that are not
+// mentioned are left intact. ErrInvalidParam is returned if a parameter is
+// not a known topic level config, or its value is not of the parameter type.
+//
+// Configs are altered the same way as `kafka-configs.sh --zookeeper` does
+// it, by updating topic config overrides in ZooKeeper and notifying brokers
+// about the change. Brokers apply the change asynchronously.
+func (a *T) AlterTopicConfig(topic string, configs map[string]string) error {
+	if len(configs) == 0 {
+		return ErrNoTopicConfig
+	}
+	for name, value := range configs {
+		validate := knownTopicConfigs[name]
+		if validate == nil {
+			return ErrInvalidParam(errors.Errorf("unknown topic config: %s", name))
+		}
+		if value == "" {
+			continue
+		}
+		if err := validate(value); err != nil {
+			return ErrInvalidParam(errors.Wrapf(err, "bad topic config %s", name))
+		}
+	}
+	zkConn, err := a.lazyZKConn()
+	if err != nil {
+		return err
+	}
+	exists, _, err := zkConn.Exists(fmt.Sprintf("%s/brokers/topics/%s", a.cfg.ZooKeeper.Chroot, topic))
+	if err != nil {
+		return errors.Wrap(err, "failed to check topic existence")
+	}
+	if !exists {
+		return ErrTopicNotExist
+	}
+	cfgPath := fmt.Sprintf("%s/config/topics/%s", a.cfg.ZooKeeper.Chroot, topic)
+	data, stat, err := zkConn.Get(cfgPath)
+	if err != nil && err != zk.ErrNoNode {
+		return errors.Wrap(err, "failed to fetch topic config")
+	}
+	cfgExists := err == nil
+	topicConfig := TopicConfig{Version: 1, Config: make(map[string]string)}
+	if cfgExists {
+		if err = json.Unmarshal(data, &topicConfig); err != nil {
+			return errors.Wrap(err, "bad topic config")
+		}
+		if topicConfig.Config == nil {
+			topicConfig.Config = make(map[string]string)
+		}
+	}
+	for name, value := range configs {
+		if value == "" {
+			delete(topicConfig.Config, name)
+			continue
+		}
+		topicConfig.Config[name] = value
+	}
+	if data, err = json.Marshal(&topicConfig); err != nil {
+		return errors.Wrap(err, "failed to encode topic config")
+	}
+	if !cfgExists {
+		_, err = zkConn.Create(cfgPath, data, 0, zk.WorldACL(zk.PermAll))
+	} else {
+		// The stat version guards against concurrent config updates.
+		_, err = zkConn.Set(cfgPath, data, stat.Version)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to update topic config")
+	}
+	// Brokers watch change notifications to reload entity configs.
+	notification, err := json.Marshal(map[string]interface{}{
+		"version":     1,
+		"entity_type": "topics",
+		"entity_name": topic,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode config change notification")
+	}
+	changePath := a.cfg.ZooKeeper.Chroot + topicConfigChangePrefix
+	if _, err = zkConn.Create(changePath, notification, zk.FlagSequence, zk.WorldACL(zk.PermAll)); err != nil {
+		return errors.Wrap(err, "failed to notify brokers of config change")
+	}
+	return nil
+}
+
+func (a *T) lazyKafkaClt() (sarama.Client, error) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 	if a.kafkaClt == nil {
@@ -335,6 +1613,18 @@ func (a *T) lazyZKConn() (*zk.Conn, error) {
 	return a.zkConn, nil
 }
 
+func (a *T) lazyKazooClt() (*kazoo.Kazoo, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.kazooClt == nil {
+		var err error
+		if a.kazooClt, err = kazoo.NewKazoo(a.cfg.ZooKeeper.SeedPeers, a.cfg.KazooCfg()); err != nil {
+			return nil, errors.Wrap(err, "failed to create kazoo.Kazoo")
+		}
+	}
+	return a.kazooClt, nil
+}
+
 func getOffsetResult(res *sarama.OffsetResponse, topic string, partition int32) (int64, error) {
 	block := res.GetBlock(topic, partition)
 	if block == nil {
@@ -349,8 +1639,48 @@ func getOffsetResult(res *sarama.OffsetResponse, topic string, partition int32)
 	return block.Offsets[0], nil
 }
 
+// TopicFilter selects topics returned by ListTopicsWithFilter. A topic is
+// selected if it matches all specified criteria. The zero value selects all
+// topics.
+type TopicFilter struct {
+	// If not empty, then only topics with names starting with it are selected.
+	Prefix string
+
+	// If not nil, then only topics with names matching it are selected.
+	Pattern *regexp.Regexp
+
+	// If not empty, then only topics that have all these configuration
+	// entries set to the given values are selected. Only topic level
+	// overrides are considered, so a topic that relies on a broker default
+	// is not matched even if the default has the given value.
+	Config map[string]string
+}
+
+func (f *TopicFilter) matchName(topic string) bool {
+	if !strings.HasPrefix(topic, f.Prefix) {
+		return false
+	}
+	return f.Pattern == nil || f.Pattern.MatchString(topic)
+}
+
+func (f *TopicFilter) matchConfig(topicConfig *TopicConfig) bool {
+	for k, v := range f.Config {
+		if actual, ok := topicConfig.Config[k]; !ok || actual != v {
+			return false
+		}
+	}
+	return true
+}
+
 // ListTopics returns a list of all topics existing in the Kafka cluster.
 func (a *T) ListTopics(withPartitions, withConfig bool) ([]TopicMetadata, error) {
+	return a.ListTopicsWithFilter(withPartitions, withConfig, TopicFilter{})
+}
+
+// ListTopicsWithFilter is the same as ListTopics but returns only the topics
+// selected by the filter. Metadata is only fetched for topics with matching
+// names, so on large clusters a name filter makes the call much cheaper.
+func (a *T) ListTopicsWithFilter(withPartitions, withConfig bool, filter TopicFilter) ([]TopicMetadata, error) {
 	kafkaClt, err := a.lazyKafkaClt()
 	if err != nil {
 		return nil, err
@@ -367,13 +1697,24 @@ func (a *T) ListTopics(withPartitions, withConfig bool) ([]TopicMetadata, error)
 		return nil, errors.Wrap(err, "failed to get topics")
 	}
 
-	topicMetadatas := make([]TopicMetadata, len(topics))
-	for i, topic := range topics {
-		tm, err := a.GetTopicMetadata(topic, withPartitions, withConfig)
+	// Config is needed to match config entries even if it is not requested.
+	fetchConfig := withConfig || len(filter.Config) > 0
+	topicMetadatas := make([]TopicMetadata, 0, len(topics))
+	for _, topic := range topics {
+		if !filter.matchName(topic) {
+			continue
+		}
+		tm, err := a.GetTopicMetadata(topic, withPartitions, fetchConfig)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to get %s topic metadata", topic)
 		}
-		topicMetadatas[i] = tm
+		if fetchConfig && !filter.matchConfig(tm.Config) {
+			continue
+		}
+		if !withConfig {
+			tm.Config = nil
+		}
+		topicMetadatas = append(topicMetadatas, tm)
 	}
 	sort.Slice(topicMetadatas, func(i, j int) bool { return topicMetadatas[i].Topic < topicMetadatas[j].Topic })
 	return topicMetadatas, nil
@@ -1,13 +1,20 @@
 package admin
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/testhelpers"
 	"github.com/mailgun/kafka-pixy/testhelpers/kafkahelper"
+	"github.com/pkg/errors"
 	. "gopkg.in/check.v1"
 )
 
@@ -78,6 +85,588 @@ func (s *AdminSuite) TestGetOffsetsAfterProduce(c *C) {
 	a.Stop()
 }
 
+// Arbitrary metadata committed with SetGroupOffsets is returned by
+// GetGroupOffsets intact.
+func (s *AdminSuite) TestSetOffsetsMetadataRoundTrip(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	metadata := []string{
+		`{"checkpoint": {"batch": 42, "ids": ["a", "b"]}, "note": "\"quoted\""}`,
+		"",
+		"Ğüñţër ✓ 日本語 🚀",
+		strings.Repeat("x", 1024),
+	}
+
+	// When
+	err = a.SetGroupOffsets("foo", "test.4", []PartitionOffset{
+		{Partition: 0, Offset: 3001, Metadata: metadata[0]},
+		{Partition: 1, Offset: 3002, Metadata: metadata[1]},
+		{Partition: 2, Offset: 3003, Metadata: metadata[2]},
+		{Partition: 3, Offset: 3004, Metadata: metadata[3]},
+	})
+	c.Assert(err, IsNil)
+
+	// Then
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	for i, po := range offsets {
+		c.Assert(po.Offset, Equals, int64(3001+i))
+		c.Assert(po.Metadata, Equals, metadata[i])
+	}
+}
+
+// Offsets committed with SetGroupOffsets are stamped with the admin host name
+// and the commit time.
+func (s *AdminSuite) TestSetOffsetsStamped(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	begin := time.Now().UTC().Add(-time.Second)
+
+	// When
+	err = a.SetGroupOffsets("foo", "test.1", []PartitionOffset{
+		{Partition: 0, Offset: 1001, Metadata: "bar"},
+	})
+	c.Assert(err, IsNil)
+
+	// Then
+	offsets, err := a.GetGroupOffsets("foo", "test.1")
+	c.Assert(err, IsNil)
+	c.Assert(offsets[0].Metadata, Equals, "bar")
+	c.Assert(offsets[0].CommittedBy, Equals, a.hostname)
+	c.Assert(offsets[0].CommittedAt.After(begin), Equals, true)
+}
+
+// Metadata that would exceed the default `offset.metadata.max.bytes` if
+// stamped is committed without a stamp.
+func (s *AdminSuite) TestSetOffsetsLongMetadataNotStamped(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	metadata := strings.Repeat("x", maxStampedMetadataBytes-32)
+
+	// When
+	err = a.SetGroupOffsets("foo", "test.1", []PartitionOffset{
+		{Partition: 0, Offset: 1002, Metadata: metadata},
+	})
+	c.Assert(err, IsNil)
+
+	// Then
+	offsets, err := a.GetGroupOffsets("foo", "test.1")
+	c.Assert(err, IsNil)
+	c.Assert(offsets[0].Metadata, Equals, metadata)
+	c.Assert(offsets[0].CommittedBy, Equals, "")
+}
+
+// Offsets exported from a group can be imported to another group.
+func (s *AdminSuite) TestExportImportGroupOffsets(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	group := fmt.Sprintf("export-%d", time.Now().UnixNano())
+	err = a.SetGroupOffsets(group, "test.4", []PartitionOffset{
+		{Partition: 1, Offset: 1001, Metadata: "foo"},
+		{Partition: 3, Offset: 1003, Metadata: "bar"},
+	})
+	c.Assert(err, IsNil)
+
+	// When
+	data, err := a.ExportGroupOffsets(group)
+	c.Assert(err, IsNil)
+	err = a.ImportGroupOffsets(group+"-copy", data)
+	c.Assert(err, IsNil)
+
+	// Then
+	var export GroupOffsetsExport
+	c.Assert(json.Unmarshal(data, &export), IsNil)
+	c.Assert(export, DeepEquals, GroupOffsetsExport{
+		Version: 1,
+		Group:   group,
+		Offsets: []ExportedOffset{
+			{Topic: "test.4", Partition: 1, Offset: 1001, Metadata: "foo"},
+			{Topic: "test.4", Partition: 3, Offset: 1003, Metadata: "bar"},
+		},
+	})
+	offsets, err := a.GetGroupOffsets(group+"-copy", "test.4")
+	c.Assert(err, IsNil)
+	c.Assert(offsets[1].Offset, Equals, int64(1001))
+	c.Assert(offsets[1].Metadata, Equals, "foo")
+	c.Assert(offsets[3].Offset, Equals, int64(1003))
+	c.Assert(offsets[3].Metadata, Equals, "bar")
+}
+
+func (s *AdminSuite) TestImportGroupOffsetsInvalid(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	err = a.ImportGroupOffsets("foo", []byte(`{"version": 2, "offsets": []}`))
+	c.Assert(err.Error(), Equals, "unsupported offsets export version: 2")
+
+	err = a.ImportGroupOffsets("foo", []byte(`{"version": 1, "offsets": [{"topic": "test.1", "partition": 0, "offset": -1}]}`))
+	c.Assert(err.Error(), Equals, `invalid offset: {Topic:test.1 Partition:0 Offset:-1 Metadata:}`)
+}
+
+// Offsets committed with a retention period can be read back.
+func (s *AdminSuite) TestSetOffsetsRetention(c *C) {
+	// Given
+	cfg := *s.cfg
+	c.Assert(cfg.Kafka.Version.UnmarshalText([]byte("0.10.0.0")), IsNil)
+	a, err := Spawn(s.ns, &cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	// When
+	err = a.SetGroupOffsetsWithOpts("foo", "test.1", []PartitionOffset{
+		{Partition: 0, Offset: 1001, Metadata: "bar"},
+	}, SetOffsetsOpts{Retention: 7 * 24 * time.Hour})
+	c.Assert(err, IsNil)
+
+	// Then
+	offsets, err := a.GetGroupOffsets("foo", "test.1")
+	c.Assert(err, IsNil)
+	c.Assert(offsets[0].Offset, Equals, int64(1001))
+	c.Assert(offsets[0].Metadata, Equals, "bar")
+}
+
+// Retention cannot be set if the configured Kafka version does not support
+// it.
+func (s *AdminSuite) TestSetOffsetsRetentionUnsupported(c *C) {
+	// Given
+	cfg := *s.cfg
+	c.Assert(cfg.Kafka.Version.UnmarshalText([]byte("0.8.2.2")), IsNil)
+	a, err := Spawn(s.ns, &cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	// When
+	err = a.SetGroupOffsetsWithOpts("foo", "test.1", []PartitionOffset{
+		{Partition: 0, Offset: 1001},
+	}, SetOffsetsOpts{Retention: time.Hour})
+
+	// Then
+	c.Assert(err, Equals, ErrRetentionUnsupported)
+}
+
+// With NoDecrease a commit that would move any offset backwards is rejected
+// entirely, while moving offsets forward is allowed.
+func (s *AdminSuite) TestSetOffsetsNoDecrease(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	err = a.SetGroupOffsets("foo", "test.4", []PartitionOffset{
+		{Partition: 0, Offset: 1000}, {Partition: 1, Offset: 1000},
+	})
+	c.Assert(err, IsNil)
+	noDecrease := SetOffsetsOpts{NoDecrease: true}
+
+	// When
+	err = a.SetGroupOffsetsWithOpts("foo", "test.4", []PartitionOffset{
+		{Partition: 0, Offset: 1001}, {Partition: 1, Offset: 999},
+	}, noDecrease)
+
+	// Then
+	c.Assert(errors.Cause(err), Equals, ErrOffsetDecrease)
+	c.Assert(err.Error(), Equals, "partition=1, committed=1000, new=999: new offset is less than the committed one")
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	c.Assert(offsets[0].Offset, Equals, int64(1000))
+	c.Assert(offsets[1].Offset, Equals, int64(1000))
+
+	// When
+	err = a.SetGroupOffsetsWithOpts("foo", "test.4", []PartitionOffset{
+		{Partition: 0, Offset: 1001}, {Partition: 1, Offset: 1000},
+	}, noDecrease)
+
+	// Then
+	c.Assert(err, IsNil)
+	offsets, err = a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	c.Assert(offsets[0].Offset, Equals, int64(1001))
+	c.Assert(offsets[1].Offset, Equals, int64(1000))
+}
+
+// Cluster metadata lists all brokers and the controller is one of them.
+func (s *AdminSuite) TestGetClusterMetadata(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	brokerIDs, err := s.kh.KazooClt().BrokerList()
+	c.Assert(err, IsNil)
+
+	// When
+	cm, err := a.GetClusterMetadata()
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(cm.Brokers, HasLen, len(brokerIDs))
+	controllerFound := false
+	for i, bm := range cm.Brokers {
+		if i > 0 {
+			c.Assert(bm.ID > cm.Brokers[i-1].ID, Equals, true)
+		}
+		c.Assert(bm.Host, Not(Equals), "")
+		c.Assert(bm.Port > 0, Equals, true)
+		controllerFound = controllerFound || bm.ID == cm.ControllerID
+	}
+	c.Assert(controllerFound, Equals, true)
+}
+
+func (s *AdminSuite) TestGetControllerID(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	cm, err := a.GetClusterMetadata()
+	c.Assert(err, IsNil)
+
+	// When
+	controllerID, err := a.GetControllerID()
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(controllerID, Equals, cm.ControllerID)
+}
+
+// Groups that committed offsets are listed regardless of what broker
+// coordinates them.
+func (s *AdminSuite) TestListConsumerGroups(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	groups := []string{"list_groups_1", "list_groups_2", "list_groups_3", "list_groups_4"}
+	for _, group := range groups {
+		err = a.SetGroupOffsets(group, "test.1", []PartitionOffset{{Partition: 0, Offset: 0}})
+		c.Assert(err, IsNil)
+	}
+
+	// When
+	listed, err := a.ListConsumerGroups()
+
+	// Then
+	c.Assert(err, IsNil)
+	var found []string
+	for _, group := range listed {
+		if strings.HasPrefix(group, "list_groups_") {
+			found = append(found, group)
+		}
+	}
+	c.Assert(found, DeepEquals, groups)
+}
+
+// A group that only commits offsets to Kafka is reported as Empty, and a group
+// that does not exist is reported as Dead.
+func (s *AdminSuite) TestDescribeConsumerGroup(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	err = a.SetGroupOffsets("describe_group", "test.1", []PartitionOffset{{Partition: 0, Offset: 0}})
+	c.Assert(err, IsNil)
+
+	// When
+	gd, err := a.DescribeConsumerGroup("describe_group")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(gd, DeepEquals, GroupDescription{
+		Group:   "describe_group",
+		State:   "Empty",
+		Members: []GroupMemberDescription{},
+	})
+
+	// When
+	gd, err = a.DescribeConsumerGroup("no_such_group")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(gd.State, Equals, "Dead")
+	c.Assert(gd.Members, HasLen, 0)
+}
+
+// A group registration can only be deleted when the group has no members.
+func (s *AdminSuite) TestDeleteConsumerGroup(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	kzGroup := s.kh.KazooClt().Consumergroup("delete_group")
+	c.Assert(kzGroup.Create(), IsNil)
+	kzInstance := kzGroup.NewInstance()
+	c.Assert(kzInstance.Register([]string{"test.1"}), IsNil)
+
+	// When/Then
+	c.Assert(a.DeleteConsumerGroup("delete_group"), Equals, ErrGroupNotEmpty)
+
+	// When
+	c.Assert(kzInstance.Deregister(), IsNil)
+	err = a.DeleteConsumerGroup("delete_group")
+
+	// Then
+	c.Assert(err, IsNil)
+	exists, err := kzGroup.Exists()
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, false)
+	c.Assert(a.DeleteConsumerGroup("delete_group"), Equals, ErrGroupNotExist)
+}
+
+// Offsets committed by a group are committed again with the minimal retention
+// time, so that Kafka removes them. Partitions the group has not committed
+// to are left alone.
+func (s *AdminSuite) TestExpireGroupOffsets(c *C) {
+	// Given
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("foo", 1, broker1.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(c).
+			SetOffset("g1", "foo", 0, 5, "bar", sarama.ErrNoError).
+			SetOffset("g1", "foo", 1, -1, "", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c),
+	})
+	cfg := *s.cfg
+	cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	c.Assert(cfg.Kafka.Version.UnmarshalText([]byte("0.10.0.0")), IsNil)
+	a, err := Spawn(s.ns, &cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	// When
+	err = a.expireGroupOffsets("g1")
+
+	// Then
+	c.Assert(err, IsNil)
+	var commitRqs []*sarama.OffsetCommitRequest
+	for _, rr := range broker1.History() {
+		if rq, ok := rr.Request.(*sarama.OffsetCommitRequest); ok {
+			commitRqs = append(commitRqs, rq)
+		}
+	}
+	c.Assert(commitRqs, HasLen, 1)
+	c.Assert(commitRqs[0].Version, Equals, int16(ProtocolVer2))
+	c.Assert(commitRqs[0].RetentionTime, Equals, int64(1))
+	offset, _, err := commitRqs[0].Offset("foo", 0)
+	c.Assert(err, IsNil)
+	c.Assert(offset, Equals, int64(5))
+	_, _, err = commitRqs[0].Offset("foo", 1)
+	c.Assert(err, NotNil)
+}
+
+func (s *AdminSuite) TestCreateTopic(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	topic := fmt.Sprintf("create_topic_%d", time.Now().UnixNano())
+
+	// When
+	err = a.CreateTopic(topic, 3, 1, map[string]string{"retention.ms": "60000"}, false)
+
+	// Then
+	c.Assert(err, IsNil)
+	kzTopic := s.kh.KazooClt().Topic(topic)
+	partitions, err := kzTopic.Partitions()
+	c.Assert(err, IsNil)
+	c.Assert(partitions, HasLen, 3)
+	config, err := kzTopic.Config()
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, map[string]string{"retention.ms": "60000"})
+
+	// When/Then
+	c.Assert(a.CreateTopic(topic, 3, 1, nil, false), Equals, ErrTopicExists)
+	c.Assert(a.CreateTopic(topic, 3, 1, nil, true), IsNil)
+}
+
+// Topics can be filtered by name prefix, name pattern and config entries.
+func (s *AdminSuite) TestListTopicsWithFilter(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	prefix := fmt.Sprintf("list_topics_%d_", time.Now().UnixNano())
+	c.Assert(a.CreateTopic(prefix+"a", 1, 1, map[string]string{"cleanup.policy": "compact"}, false), IsNil)
+	c.Assert(a.CreateTopic(prefix+"b", 1, 1, map[string]string{"cleanup.policy": "delete"}, false), IsNil)
+	c.Assert(a.CreateTopic(prefix+"c", 1, 1, nil, false), IsNil)
+
+	for i, tc := range []struct {
+		filter TopicFilter
+		topics []string
+	}{{
+		filter: TopicFilter{Prefix: prefix},
+		topics: []string{prefix + "a", prefix + "b", prefix + "c"},
+	}, {
+		filter: TopicFilter{Prefix: prefix, Pattern: regexp.MustCompile("_[bc]$")},
+		topics: []string{prefix + "b", prefix + "c"},
+	}, {
+		filter: TopicFilter{Prefix: prefix, Config: map[string]string{"cleanup.policy": "compact"}},
+		topics: []string{prefix + "a"},
+	}, {
+		filter: TopicFilter{Prefix: prefix, Pattern: regexp.MustCompile("_c$"), Config: map[string]string{"cleanup.policy": "compact"}},
+		topics: []string{},
+	}} {
+		// When
+		tms, err := a.ListTopicsWithFilter(false, false, tc.filter)
+
+		// Then
+		c.Assert(err, IsNil, Commentf("case #%d", i))
+		topics := []string{}
+		for _, tm := range tms {
+			c.Assert(tm.Config, IsNil, Commentf("case #%d", i))
+			topics = append(topics, tm.Topic)
+		}
+		c.Assert(topics, DeepEquals, tc.topics, Commentf("case #%d", i))
+	}
+}
+
+func (s *AdminSuite) TestDeleteTopic(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	topic := fmt.Sprintf("delete_topic_%d", time.Now().UnixNano())
+	c.Assert(a.CreateTopic(topic, 1, 1, nil, false), IsNil)
+
+	// When
+	err = a.DeleteTopic(topic)
+
+	// Then
+	c.Assert(err, IsNil)
+	exists, err := s.kh.KazooClt().Topic(topic).Exists()
+	c.Assert(err, IsNil)
+	c.Assert(exists, Equals, false)
+	c.Assert(a.DeleteTopic(topic), Equals, ErrTopicNotExist)
+}
+
+func (s *AdminSuite) TestCreatePartitions(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	topic := fmt.Sprintf("create_partitions_%d", time.Now().UnixNano())
+	c.Assert(a.CreateTopic(topic, 2, 1, nil, false), IsNil)
+	kzTopic := s.kh.KazooClt().Topic(topic)
+	partitionsBefore, err := kzTopic.Partitions()
+	c.Assert(err, IsNil)
+
+	// When
+	err = a.CreatePartitions(topic, 5)
+
+	// Then
+	c.Assert(err, IsNil)
+	partitionsAfter, err := kzTopic.Partitions()
+	c.Assert(err, IsNil)
+	c.Assert(partitionsAfter, HasLen, 5)
+	for i, p := range partitionsBefore {
+		c.Assert(partitionsAfter[i].Replicas, DeepEquals, p.Replicas)
+	}
+	for _, p := range partitionsAfter {
+		c.Assert(p.Replicas, HasLen, 1)
+	}
+}
+
+// The number of partitions cannot be decreased or left the same.
+func (s *AdminSuite) TestCreatePartitionsInvalid(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	c.Assert(a.CreatePartitions("test.4", 4), Equals, ErrPartitionsNotAdded)
+	c.Assert(a.CreatePartitions("test.4", 1), Equals, ErrPartitionsNotAdded)
+	c.Assert(a.CreatePartitions("no_such_topic", 4), Equals, ErrTopicNotExist)
+}
+
+func (s *AdminSuite) TestAlterTopicConfig(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	topic := fmt.Sprintf("alter_topic_config_%d", time.Now().UnixNano())
+	c.Assert(a.CreateTopic(topic, 1, 1, map[string]string{"retention.ms": "60000", "cleanup.policy": "delete"}, false), IsNil)
+
+	// When
+	err = a.AlterTopicConfig(topic, map[string]string{"retention.ms": "", "segment.ms": "3600000"})
+
+	// Then
+	c.Assert(err, IsNil)
+	config, err := s.kh.KazooClt().Topic(topic).Config()
+	c.Assert(err, IsNil)
+	c.Assert(config, DeepEquals, map[string]string{"cleanup.policy": "delete", "segment.ms": "3600000"})
+}
+
+func (s *AdminSuite) TestAlterTopicConfigInvalid(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	c.Assert(a.AlterTopicConfig("test.4", nil), Equals, ErrNoTopicConfig)
+	c.Assert(a.AlterTopicConfig("no_such_topic", map[string]string{"retention.ms": "60000"}), Equals, ErrTopicNotExist)
+	err = a.AlterTopicConfig("test.4", map[string]string{"retention.msec": "60000"})
+	_, ok := err.(ErrInvalidParam)
+	c.Assert(ok, Equals, true)
+	c.Assert(err.Error(), Equals, "unknown topic config: retention.msec")
+	err = a.AlterTopicConfig("test.4", map[string]string{"retention.ms": "abc"})
+	_, ok = err.(ErrInvalidParam)
+	c.Assert(ok, Equals, true)
+	c.Assert(err.Error(), Equals, "bad topic config retention.ms: abc is not a long")
+}
+
+// Values of known topic configs are validated according to their types.
+func (s *AdminSuite) TestTopicConfigValidation(c *C) {
+	for i, tc := range []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{name: "retention.ms", value: "-1"},
+		{name: "retention.ms", value: " 86400000 "},
+		{name: "retention.ms", value: "1d", err: "1d is not a long"},
+		{name: "max.message.bytes", value: "1000000"},
+		{name: "max.message.bytes", value: "10000000000", err: "10000000000 is not an int"},
+		{name: "min.cleanable.dirty.ratio", value: "0.5"},
+		{name: "min.cleanable.dirty.ratio", value: "half", err: "half is not a double"},
+		{name: "preallocate", value: "True"},
+		{name: "preallocate", value: "yes", err: "yes is not a boolean"},
+		{name: "cleanup.policy", value: "compact, delete"},
+		{name: "cleanup.policy", value: "compact,purge", err: "purge is not one of compact, delete"},
+		{name: "compression.type", value: "lz4"},
+		{name: "compression.type", value: "brotli", err: "brotli is not one of uncompressed, zstd, lz4, snappy, gzip, producer"},
+		{name: "message.timestamp.type", value: "LogAppendTime"},
+		{name: "leader.replication.throttled.replicas", value: "*"},
+		{name: "leader.replication.throttled.replicas", value: "0:101, 1:102"},
+		{name: "leader.replication.throttled.replicas", value: "0-101", err: "0-101 is not a list of partition:broker pairs"},
+		{name: "message.format.version", value: "0.10.2"},
+	} {
+		err := knownTopicConfigs[tc.name](tc.value)
+		if tc.err == "" {
+			c.Assert(err, IsNil, Commentf("case #%d", i))
+		} else {
+			c.Assert(err, ErrorMatches, tc.err, Commentf("case #%d", i))
+		}
+	}
+}
+
+func (s *AdminSuite) TestCreateTopicInvalid(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	c.Assert(a.CreateTopic("create_topic_invalid", 0, 1, nil, false), Equals, ErrBadPartitionCount)
+	c.Assert(a.CreateTopic("create_topic_invalid", 1, 0, nil, false), Equals, ErrBadReplicationFactor)
+	c.Assert(a.CreateTopic("create_topic_invalid", 1, 100, nil, false), Equals, ErrBadReplicationFactor)
+}
+
 // It is possible to set offsets for only a subset of group/topic partitions.
 func (s *AdminSuite) TestSetOffsetsPartialUpdate(c *C) {
 	// Given
@@ -110,3 +699,225 @@ func (s *AdminSuite) TestSetOffsetsPartialUpdate(c *C) {
 
 	a.Stop()
 }
+
+func (s *AdminSuite) TestResetOffsets(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	s.kh.PutMessages("reset_offsets", "test.4", map[string]int{"A": 1, "B": 1, "C": 1, "D": 1})
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+
+	// When/Then
+	err = a.ResetGroupOffsets("foo", "test.4", Earliest())
+	c.Assert(err, IsNil)
+	resetOffsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	for i, po := range resetOffsets {
+		c.Assert(po.Offset, Equals, offsets[i].Begin)
+	}
+
+	err = a.ResetGroupOffsets("foo", "test.4", Latest())
+	c.Assert(err, IsNil)
+	resetOffsets, err = a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	for i, po := range resetOffsets {
+		c.Assert(po.Offset, Equals, offsets[i].End)
+	}
+
+	err = a.ResetGroupOffsets("foo", "test.4", ToOffset(map[int32]int64{1: offsets[1].Begin}))
+	c.Assert(err, IsNil)
+	resetOffsets, err = a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	c.Assert(resetOffsets[0].Offset, Equals, offsets[0].End)
+	c.Assert(resetOffsets[1].Offset, Equals, offsets[1].Begin)
+
+	a.Stop()
+}
+
+// If there are no messages produced after the given time, then newest offsets
+// are returned.
+func (s *AdminSuite) TestGetOffsetsForTimeInFuture(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	s.kh.PutMessages("offsets_for_time", "test.4", map[string]int{"A": 1, "B": 1, "C": 1, "D": 1})
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+
+	// When
+	timeOffsets, err := a.GetOffsetsForTime("test.4", time.Now().Add(time.Hour))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(timeOffsets, HasLen, 4)
+	for _, po := range offsets {
+		c.Assert(timeOffsets[po.Partition], Equals, po.End)
+	}
+	offset, err := a.GetOffsetForTime("test.4", 1, time.Now().Add(time.Hour))
+	c.Assert(err, IsNil)
+	c.Assert(offset, Equals, offsets[1].End)
+}
+
+func (s *AdminSuite) TestGetOffsetForTimeInvalidPartition(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	_, err = a.GetOffsetForTime("test.4", 4, time.Now())
+	c.Assert(errors.Cause(err), Equals, sarama.ErrUnknownTopicOrPartition)
+}
+
+func (s *AdminSuite) TestResetOffsetsInvalidPartition(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+
+	// When
+	err = a.ResetGroupOffsets("foo", "test.4", ToOffset(map[int32]int64{4: 0}))
+
+	// Then
+	c.Assert(err, ErrorMatches, "invalid partition: 4")
+
+	a.Stop()
+}
+
+// Negative offsets, e.g. sarama.OffsetNewest passed by mistake, are rejected
+// rather than committed literally.
+func (s *AdminSuite) TestResetOffsetsNegativeOffset(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	// When
+	err = a.ResetGroupOffsets("foo", "test.4", ToOffset(map[int32]int64{1: sarama.OffsetNewest}))
+
+	// Then
+	_, ok := err.(ErrInvalidParam)
+	c.Assert(ok, Equals, true)
+	c.Assert(err, ErrorMatches, "invalid offset: partition=1, offset=-1")
+}
+
+// Offset ranges returned by GetTopicOffsets are the same as those returned by
+// GetGroupOffsets.
+func (s *AdminSuite) TestGetTopicOffsets(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	s.kh.PutMessages("topic_offsets", "test.4", map[string]int{"A": 1, "B": 1, "C": 1, "D": 1})
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+
+	// When
+	ranges, err := a.GetTopicOffsets("test.4")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(len(ranges), Equals, 4)
+	for i, po := range offsets {
+		c.Assert(ranges[i], Equals, PartitionOffsetRange{po.Partition, po.Begin, po.End})
+	}
+}
+
+// GetTopicOffsets fails for a topic that does not exist.
+func (s *AdminSuite) TestGetTopicOffsetsUnknownTopic(c *C) {
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+
+	// When
+	_, err = a.GetTopicOffsets("no-such-topic")
+
+	// Then
+	c.Assert(errors.Cause(err), Equals, sarama.ErrUnknownTopicOrPartition)
+}
+
+// The status combines committed offsets with partition owners. If the group
+// has no members then partitions have no owners.
+func (s *AdminSuite) TestGetGroupStatus(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	a.SetGroupOffsets("foo", "test.4", []PartitionOffset{
+		{Partition: 1, Offset: offsets[1].End},
+	})
+
+	// When
+	status, err := a.GetGroupStatus("foo", "test.4")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(status.Group, Equals, "foo")
+	c.Assert(status.Topic, Equals, "test.4")
+	c.Assert(len(status.Partitions), Equals, 4)
+	c.Assert(status.Partitions[1].Offset, Equals, offsets[1].End)
+	c.Assert(status.Partitions[1].Lag, Equals, int64(0))
+	for _, ps := range status.Partitions {
+		c.Assert(ps.Owner, Equals, "")
+	}
+}
+
+func (s *AdminSuite) TestGetGroupLag(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	s.kh.PutMessages("group_lag", "test.4", map[string]int{"A": 1, "B": 1, "C": 1, "D": 1})
+	offsets, err := a.GetGroupOffsets("foo", "test.4")
+	c.Assert(err, IsNil)
+	a.SetGroupOffsets("foo", "test.4", []PartitionOffset{
+		{Partition: 0, Offset: offsets[0].End},
+		{Partition: 1, Offset: offsets[1].End - 1},
+	})
+
+	// When
+	lags, err := a.GetGroupLag("foo", "test.4")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(len(lags), Equals, 4)
+	c.Assert(lags[0].Lag, Equals, int64(0))
+	c.Assert(lags[0].HighWaterMark, Equals, offsets[0].End)
+	c.Assert(lags[1].Lag, Equals, int64(1))
+	c.Assert(lags[1].Offset, Equals, offsets[1].End-1)
+	c.Assert(lags[1].Topic, Equals, "test.4")
+
+	a.Stop()
+}
+
+func (s *AdminSuite) TestGetLaggingPartitions(c *C) {
+	// Given
+	a, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer a.Stop()
+	group := fmt.Sprintf("lagging-%d", time.Now().UnixNano())
+	s.kh.PutMessages("lagging", "test.4", map[string]int{"A": 5, "B": 5, "C": 5, "D": 5})
+	s.kh.PutMessages("lagging", "test.1", map[string]int{"A": 5})
+	offsets4, err := a.GetGroupOffsets(group, "test.4")
+	c.Assert(err, IsNil)
+	offsets1, err := a.GetGroupOffsets(group, "test.1")
+	c.Assert(err, IsNil)
+	a.SetGroupOffsets(group, "test.4", []PartitionOffset{
+		{Partition: 0, Offset: offsets4[0].End},
+		{Partition: 1, Offset: offsets4[1].End - 1},
+		{Partition: 2, Offset: offsets4[2].End - 3},
+		{Partition: 3, Offset: offsets4[3].End - 4},
+	})
+	a.SetGroupOffsets(group, "test.1", []PartitionOffset{
+		{Partition: 0, Offset: offsets1[0].End - 2},
+	})
+
+	// When
+	lagging, err := a.GetLaggingPartitions(group, 1)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(lagging, DeepEquals, []PartitionLag{
+		{Topic: "test.1", Partition: 0, Offset: offsets1[0].End - 2, HighWaterMark: offsets1[0].End, Lag: 2},
+		{Topic: "test.4", Partition: 2, Offset: offsets4[2].End - 3, HighWaterMark: offsets4[2].End, Lag: 3},
+		{Topic: "test.4", Partition: 3, Offset: offsets4[3].End - 4, HighWaterMark: offsets4[3].End, Lag: 4},
+	})
+}
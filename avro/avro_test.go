@@ -0,0 +1,143 @@
+package avro
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+type AvroSuite struct{}
+
+var _ = Suite(&AvroSuite{})
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+const testSchema = `{
+	"type": "record",
+	"name": "User",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": "long"},
+		{"name": "name", "type": "string"},
+		{"name": "email", "type": ["null", "string"], "default": null},
+		{"name": "score", "type": "double", "default": 0.5},
+		{"name": "active", "type": "boolean"},
+		{"name": "role", "type": {"type": "enum", "name": "Role", "symbols": ["ADMIN", "USER"]}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "map", "values": "int"}},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 2}},
+		{"name": "manager", "type": ["null", "User"], "default": null}
+	]
+}`
+
+func (s *AvroSuite) TestRoundTrip(c *C) {
+	schema, err := ParseSchema(testSchema)
+	c.Assert(err, IsNil)
+
+	// When
+	encoded, err := schema.FromJSON([]byte(`{
+		"id": 42, "name": "Bob", "email": "bob@example.com", "active": true,
+		"role": "USER", "tags": ["a", "b"], "attrs": {"y": 2, "x": -1},
+		"hash": "ÿ\u0001",
+		"manager": {"com.example.User": {
+			"id": 1, "name": "Alice", "active": false, "role": "ADMIN",
+			"tags": [], "attrs": {}, "hash": "ab"}}}`))
+	c.Assert(err, IsNil)
+	decoded, err := schema.ToJSON(encoded)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(string(decoded), Equals, `{"id":42,"name":"Bob","email":{"string":"bob@example.com"},`+
+		`"score":0.5,"active":true,"role":"USER","tags":["a","b"],"attrs":{"x":-1,"y":2},"hash":"ÿ\u0001",`+
+		`"manager":{"com.example.User":{"id":1,"name":"Alice","email":null,"score":0.5,"active":false,`+
+		`"role":"ADMIN","tags":[],"attrs":{},"hash":"ab","manager":null}}}`)
+}
+
+// Binary encoding matches the one defined by the Avro specification.
+func (s *AvroSuite) TestFromJSONEncoding(c *C) {
+	for i, tc := range []struct {
+		schema  string
+		json    string
+		encoded []byte
+	}{
+		{schema: `"null"`, json: `null`, encoded: nil},
+		{schema: `"boolean"`, json: `true`, encoded: []byte{1}},
+		{schema: `"int"`, json: `-64`, encoded: []byte{0x7f}},
+		{schema: `"long"`, json: `64`, encoded: []byte{0x80, 0x01}},
+		{schema: `"float"`, json: `1`, encoded: []byte{0, 0, 0x80, 0x3f}},
+		{schema: `"string"`, json: `"foo"`, encoded: []byte{6, 'f', 'o', 'o'}},
+		{schema: `"bytes"`, json: `"\u0000ÿ"`, encoded: []byte{4, 0, 0xff}},
+		{schema: `["null", "string"]`, json: `"a"`, encoded: []byte{2, 2, 'a'}},
+		{schema: `["null", "string"]`, json: `null`, encoded: []byte{0}},
+		{schema: `["string", "long"]`, json: `{"long": 1}`, encoded: []byte{2, 2}},
+		{schema: `{"type": "array", "items": "int"}`, json: `[3, 27]`, encoded: []byte{4, 6, 0x36, 0}},
+		{schema: `{"type": "int", "logicalType": "date"}`, json: `1`, encoded: []byte{2}},
+	} {
+		schema, err := ParseSchema(tc.schema)
+		c.Assert(err, IsNil, Commentf("case #%d", i))
+
+		// When
+		encoded, err := schema.FromJSON([]byte(tc.json))
+
+		// Then
+		c.Assert(err, IsNil, Commentf("case #%d", i))
+		c.Assert(encoded, DeepEquals, tc.encoded, Commentf("case #%d", i))
+	}
+}
+
+func (s *AvroSuite) TestFromJSONInvalid(c *C) {
+	schema, err := ParseSchema(testSchema)
+	c.Assert(err, IsNil)
+
+	for i, tc := range []struct {
+		json  string
+		error string
+	}{
+		{json: `{"id": 1}`, error: "record com.example.User field name is missing"},
+		{json: `{"id": "1"}`, error: "invalid record com.example.User field id: long expected, got 1"},
+		{json: `{"foo": 1}`, error: "record com.example.User has no field foo"},
+		{json: `[]`, error: "record com.example.User expected, got []"},
+		{json: `{`, error: "invalid JSON: unexpected EOF"},
+	} {
+		// When
+		_, err := schema.FromJSON([]byte(tc.json))
+
+		// Then
+		c.Assert(err, NotNil, Commentf("case #%d", i))
+		c.Assert(err.Error(), Equals, tc.error, Commentf("case #%d", i))
+	}
+}
+
+func (s *AvroSuite) TestToJSONInvalid(c *C) {
+	schema, err := ParseSchema(`{"type": "array", "items": "string"}`)
+	c.Assert(err, IsNil)
+
+	_, err = schema.ToJSON([]byte{2, 6, 'f'})
+	c.Assert(err.Error(), Equals, "unexpected end of data")
+
+	_, err = schema.ToJSON([]byte{0, 0})
+	c.Assert(err.Error(), Equals, "1 trailing bytes")
+}
+
+func (s *AvroSuite) TestParseSchemaInvalid(c *C) {
+	for i, tc := range []struct {
+		schema string
+		error  string
+	}{
+		{schema: `"foo"`, error: "unknown type: foo"},
+		{schema: `{"type": "record", "fields": []}`, error: "record has no name"},
+		{schema: `{"type": "enum", "name": "E", "symbols": []}`, error: "enum E has no symbols"},
+		{schema: `[["null"]]`, error: "unions may not immediately contain other unions"},
+		{schema: `{"type": "record", "name": "R", "fields": [{"name": "f", "type": "bar"}]}`,
+			error: "invalid record R field f: unknown type: bar"},
+	} {
+		// When
+		_, err := ParseSchema(tc.schema)
+
+		// Then
+		c.Assert(err, NotNil, Commentf("case #%d", i))
+		c.Assert(err.Error(), Equals, tc.error, Commentf("case #%d", i))
+	}
+}
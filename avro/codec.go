@@ -0,0 +1,425 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+func encode(buf *bytes.Buffer, s *Schema, value interface{}) error {
+	switch s.typ {
+	case typeNull:
+		if value != nil {
+			return errors.Errorf("null expected, got %v", value)
+		}
+		return nil
+	case typeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return errors.Errorf("boolean expected, got %v", value)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		return nil
+	case typeInt, typeLong:
+		num, ok := value.(json.Number)
+		if !ok {
+			return errors.Errorf("%s expected, got %v", s.typ, value)
+		}
+		n, err := num.Int64()
+		if err != nil || (s.typ == typeInt && (n < math.MinInt32 || n > math.MaxInt32)) {
+			return errors.Errorf("%s expected, got %v", s.typ, value)
+		}
+		writeLong(buf, n)
+		return nil
+	case typeFloat, typeDouble:
+		num, ok := value.(json.Number)
+		if !ok {
+			return errors.Errorf("%s expected, got %v", s.typ, value)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return errors.Errorf("%s expected, got %v", s.typ, value)
+		}
+		if s.typ == typeFloat {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(f)))
+			buf.Write(b[:])
+			return nil
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		buf.Write(b[:])
+		return nil
+	case typeString:
+		str, ok := value.(string)
+		if !ok {
+			return errors.Errorf("string expected, got %v", value)
+		}
+		writeLong(buf, int64(len(str)))
+		buf.WriteString(str)
+		return nil
+	case typeBytes, typeFixed:
+		str, ok := value.(string)
+		if !ok {
+			return errors.Errorf("%s expected, got %v", s.typ, value)
+		}
+		// In JSON bytes are represented by strings whose code points
+		// 0-255 correspond to the byte values.
+		b := make([]byte, 0, len(str))
+		for _, r := range str {
+			if r > 255 {
+				return errors.Errorf("%s expected, got string with code point %U", s.typ, r)
+			}
+			b = append(b, byte(r))
+		}
+		if s.typ == typeFixed {
+			if len(b) != s.size {
+				return errors.Errorf("fixed %s of size %d expected, got %d bytes", s.name, s.size, len(b))
+			}
+		} else {
+			writeLong(buf, int64(len(b)))
+		}
+		buf.Write(b)
+		return nil
+	case typeRecord:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("record %s expected, got %v", s.name, value)
+		}
+		for name := range obj {
+			if s.field(name) == nil {
+				return errors.Errorf("record %s has no field %s", s.name, name)
+			}
+		}
+		for _, f := range s.fields {
+			fieldValue, ok := obj[f.name]
+			if !ok {
+				if !f.hasDefault {
+					return errors.Errorf("record %s field %s is missing", s.name, f.name)
+				}
+				if err := encodeDefault(buf, f.schema, f.def); err != nil {
+					return errors.Wrapf(err, "invalid record %s field %s default", s.name, f.name)
+				}
+				continue
+			}
+			if err := encode(buf, f.schema, fieldValue); err != nil {
+				return errors.Wrapf(err, "invalid record %s field %s", s.name, f.name)
+			}
+		}
+		return nil
+	case typeEnum:
+		symbol, ok := value.(string)
+		if ok {
+			for i, sym := range s.symbols {
+				if sym == symbol {
+					writeLong(buf, int64(i))
+					return nil
+				}
+			}
+		}
+		return errors.Errorf("enum %s symbol expected, got %v", s.name, value)
+	case typeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return errors.Errorf("array expected, got %v", value)
+		}
+		if len(items) > 0 {
+			writeLong(buf, int64(len(items)))
+			for i, item := range items {
+				if err := encode(buf, s.items, item); err != nil {
+					return errors.Wrapf(err, "invalid array item %d", i)
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+	case typeMap:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("map expected, got %v", value)
+		}
+		if len(obj) > 0 {
+			keys := make([]string, 0, len(obj))
+			for key := range obj {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			writeLong(buf, int64(len(keys)))
+			for _, key := range keys {
+				writeLong(buf, int64(len(key)))
+				buf.WriteString(key)
+				if err := encode(buf, s.values, obj[key]); err != nil {
+					return errors.Wrapf(err, "invalid map value %s", key)
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+	case typeUnion:
+		return encodeUnion(buf, s, value)
+	}
+	return errors.Errorf("unsupported type %s", s.typ)
+}
+
+func encodeUnion(buf *bytes.Buffer, s *Schema, value interface{}) error {
+	// A value in the Avro JSON encoding form, e.g. {"string": "foo"}.
+	if obj, ok := value.(map[string]interface{}); ok && len(obj) == 1 {
+		for name, branchValue := range obj {
+			for i, branch := range s.branches {
+				if branch.branchName() == name {
+					writeLong(buf, int64(i))
+					return encode(buf, branch, branchValue)
+				}
+			}
+		}
+	}
+	// Otherwise the first branch that the value conforms to is used.
+	for i, branch := range s.branches {
+		var branchBuf bytes.Buffer
+		if err := encode(&branchBuf, branch, value); err != nil {
+			continue
+		}
+		writeLong(buf, int64(i))
+		buf.Write(branchBuf.Bytes())
+		return nil
+	}
+	return errors.Errorf("value does not conform to any union branch: %v", value)
+}
+
+// encodeDefault encodes a field default value. Defaults of union fields
+// correspond to the first branch of the union.
+func encodeDefault(buf *bytes.Buffer, s *Schema, def interface{}) error {
+	if s.typ != typeUnion {
+		return encode(buf, s, def)
+	}
+	writeLong(buf, 0)
+	return encode(buf, s.branches[0], def)
+}
+
+func (s *Schema) field(name string) *field {
+	for _, f := range s.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// writeLong writes an int or a long value as a zig-zag encoded varint.
+func writeLong(buf *bytes.Buffer, n int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], n)])
+}
+
+// decoder reads Avro binary encoded values from a byte slice, writing them
+// as JSON to a buffer.
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) decode(buf *bytes.Buffer, s *Schema) error {
+	switch s.typ {
+	case typeNull:
+		buf.WriteString("null")
+		return nil
+	case typeBoolean:
+		b, err := d.read(1)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatBool(b[0] != 0))
+		return nil
+	case typeInt, typeLong:
+		n, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatInt(n, 10))
+		return nil
+	case typeFloat:
+		b, err := d.read(4)
+		if err != nil {
+			return err
+		}
+		return writeFloat(buf, float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 32)
+	case typeDouble:
+		b, err := d.read(8)
+		if err != nil {
+			return err
+		}
+		return writeFloat(buf, math.Float64frombits(binary.LittleEndian.Uint64(b)), 64)
+	case typeString:
+		b, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		writeString(buf, string(b))
+		return nil
+	case typeBytes, typeFixed:
+		var b []byte
+		var err error
+		if s.typ == typeFixed {
+			b, err = d.read(s.size)
+		} else {
+			b, err = d.readBytes()
+		}
+		if err != nil {
+			return err
+		}
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		writeString(buf, string(runes))
+		return nil
+	case typeRecord:
+		buf.WriteByte('{')
+		for i, f := range s.fields {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, f.name)
+			buf.WriteByte(':')
+			if err := d.decode(buf, f.schema); err != nil {
+				return errors.Wrapf(err, "invalid record %s field %s", s.name, f.name)
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case typeEnum:
+		i, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return errors.Errorf("enum %s index out of range: %d", s.name, i)
+		}
+		writeString(buf, s.symbols[i])
+		return nil
+	case typeArray, typeMap:
+		openBracket, closeBracket := byte('['), byte(']')
+		if s.typ == typeMap {
+			openBracket, closeBracket = '{', '}'
+		}
+		buf.WriteByte(openBracket)
+		first := true
+		for {
+			count, err := d.readLong()
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				break
+			}
+			// A negative count is followed by the block size in bytes.
+			if count < 0 {
+				count = -count
+				if _, err := d.readLong(); err != nil {
+					return err
+				}
+			}
+			for ; count > 0; count-- {
+				if !first {
+					buf.WriteByte(',')
+				}
+				first = false
+				if s.typ == typeArray {
+					if err := d.decode(buf, s.items); err != nil {
+						return err
+					}
+					continue
+				}
+				key, err := d.readBytes()
+				if err != nil {
+					return err
+				}
+				writeString(buf, string(key))
+				buf.WriteByte(':')
+				if err := d.decode(buf, s.values); err != nil {
+					return err
+				}
+			}
+		}
+		buf.WriteByte(closeBracket)
+		return nil
+	case typeUnion:
+		i, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		if i < 0 || i >= int64(len(s.branches)) {
+			return errors.Errorf("union index out of range: %d", i)
+		}
+		branch := s.branches[i]
+		if branch.typ == typeNull {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('{')
+		writeString(buf, branch.branchName())
+		buf.WriteByte(':')
+		if err := d.decode(buf, branch); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	return errors.Errorf("unsupported type %s", s.typ)
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n > len(d.data) {
+		return nil, errors.New("unexpected end of data")
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+func (d *decoder) readLong() (int64, error) {
+	n, size := binary.Varint(d.data)
+	if size <= 0 {
+		return 0, errors.New("invalid varint")
+	}
+	d.data = d.data[size:]
+	return n, nil
+}
+
+func (d *decoder) readBytes() ([]byte, error) {
+	n, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.Errorf("invalid length: %d", n)
+	}
+	if n > int64(len(d.data)) {
+		return nil, errors.New("unexpected end of data")
+	}
+	return d.read(int(n))
+}
+
+func writeFloat(buf *bytes.Buffer, f float64, bitSize int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return errors.Errorf("%v cannot be represented in JSON", f)
+	}
+	buf.WriteString(strconv.FormatFloat(f, 'g', -1, bitSize))
+	return nil
+}
+
+func writeString(buf *bytes.Buffer, str string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	// Encode terminates the value with a newline.
+	buf.Truncate(buf.Len() - 1)
+}
@@ -0,0 +1,281 @@
+// Package avro implements conversion of JSON values to Avro binary encoding
+// and back, as defined by the Apache Avro specification. It is used to
+// produce and consume messages that are serialized with Avro schemas stored
+// in a Confluent Schema Registry.
+package avro
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	typeNull    = "null"
+	typeBoolean = "boolean"
+	typeInt     = "int"
+	typeLong    = "long"
+	typeFloat   = "float"
+	typeDouble  = "double"
+	typeBytes   = "bytes"
+	typeString  = "string"
+	typeRecord  = "record"
+	typeError   = "error"
+	typeEnum    = "enum"
+	typeArray   = "array"
+	typeMap     = "map"
+	typeFixed   = "fixed"
+	typeUnion   = "union"
+)
+
+// Schema is a parsed Avro schema.
+type Schema struct {
+	typ      string
+	name     string
+	fields   []*field
+	symbols  []string
+	items    *Schema
+	values   *Schema
+	branches []*Schema
+	size     int
+}
+
+type field struct {
+	name       string
+	schema     *Schema
+	def        interface{}
+	hasDefault bool
+}
+
+// ParseSchema parses an Avro schema given in its JSON representation.
+func ParseSchema(schemaJSON string) (*Schema, error) {
+	dec := json.NewDecoder(strings.NewReader(schemaJSON))
+	dec.UseNumber()
+	var def interface{}
+	if err := dec.Decode(&def); err != nil {
+		return nil, errors.Wrap(err, "invalid schema JSON")
+	}
+	p := parser{named: make(map[string]*Schema)}
+	return p.parse(def, "")
+}
+
+// parser keeps track of named types defined while parsing a schema, so that
+// they can be referred to by name further in the schema.
+type parser struct {
+	named map[string]*Schema
+}
+
+func (p *parser) parse(def interface{}, namespace string) (*Schema, error) {
+	switch def := def.(type) {
+	case string:
+		return p.parseRef(def, namespace)
+	case []interface{}:
+		return p.parseUnion(def, namespace)
+	case map[string]interface{}:
+		return p.parseComplex(def, namespace)
+	}
+	return nil, errors.Errorf("invalid schema: %v", def)
+}
+
+func (p *parser) parseRef(name, namespace string) (*Schema, error) {
+	switch name {
+	case typeNull, typeBoolean, typeInt, typeLong, typeFloat, typeDouble, typeBytes, typeString:
+		return &Schema{typ: name}, nil
+	}
+	if schema := p.named[fullName(name, namespace)]; schema != nil {
+		return schema, nil
+	}
+	if schema := p.named[name]; schema != nil {
+		return schema, nil
+	}
+	return nil, errors.Errorf("unknown type: %s", name)
+}
+
+func (p *parser) parseUnion(defs []interface{}, namespace string) (*Schema, error) {
+	schema := &Schema{typ: typeUnion}
+	for _, branchDef := range defs {
+		branch, err := p.parse(branchDef, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if branch.typ == typeUnion {
+			return nil, errors.New("unions may not immediately contain other unions")
+		}
+		schema.branches = append(schema.branches, branch)
+	}
+	if len(schema.branches) == 0 {
+		return nil, errors.New("union must have at least one branch")
+	}
+	return schema, nil
+}
+
+func (p *parser) parseComplex(def map[string]interface{}, namespace string) (*Schema, error) {
+	typ, ok := def["type"].(string)
+	if !ok {
+		// The type attribute can be a schema itself, e.g. a union.
+		if typeDef, ok := def["type"]; ok {
+			return p.parse(typeDef, namespace)
+		}
+		return nil, errors.New("missing type attribute")
+	}
+	switch typ {
+	case typeRecord, typeError:
+		return p.parseRecord(def, namespace)
+	case typeEnum:
+		schema, err := p.define(typeEnum, def, namespace)
+		if err != nil {
+			return nil, err
+		}
+		symbols, _ := def["symbols"].([]interface{})
+		for _, symbol := range symbols {
+			symbolStr, ok := symbol.(string)
+			if !ok {
+				return nil, errors.Errorf("invalid enum %s symbol: %v", schema.name, symbol)
+			}
+			schema.symbols = append(schema.symbols, symbolStr)
+		}
+		if len(schema.symbols) == 0 {
+			return nil, errors.Errorf("enum %s has no symbols", schema.name)
+		}
+		return schema, nil
+	case typeFixed:
+		schema, err := p.define(typeFixed, def, namespace)
+		if err != nil {
+			return nil, err
+		}
+		size, ok := def["size"].(json.Number)
+		if !ok {
+			return nil, errors.Errorf("fixed %s has no size", schema.name)
+		}
+		sizeInt, err := size.Int64()
+		if err != nil || sizeInt < 0 {
+			return nil, errors.Errorf("invalid fixed %s size: %s", schema.name, size)
+		}
+		schema.size = int(sizeInt)
+		return schema, nil
+	case typeArray:
+		items, err := p.parse(def["items"], namespace)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid array items")
+		}
+		return &Schema{typ: typeArray, items: items}, nil
+	case typeMap:
+		values, err := p.parse(def["values"], namespace)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid map values")
+		}
+		return &Schema{typ: typeMap, values: values}, nil
+	}
+	// Primitive types can be given in the complex form too, e.g. to specify
+	// a logical type that does not affect the encoding.
+	return p.parseRef(typ, namespace)
+}
+
+func (p *parser) parseRecord(def map[string]interface{}, namespace string) (*Schema, error) {
+	schema, err := p.define(typeRecord, def, namespace)
+	if err != nil {
+		return nil, err
+	}
+	fieldDefs, ok := def["fields"].([]interface{})
+	if !ok {
+		return nil, errors.Errorf("record %s has no fields", schema.name)
+	}
+	// Names in fields are resolved relative to the record namespace.
+	recordNamespace := ""
+	if i := strings.LastIndex(schema.name, "."); i >= 0 {
+		recordNamespace = schema.name[:i]
+	}
+	for _, fieldDef := range fieldDefs {
+		fieldDef, ok := fieldDef.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid record %s field: %v", schema.name, fieldDef)
+		}
+		name, ok := fieldDef["name"].(string)
+		if !ok {
+			return nil, errors.Errorf("record %s has a field without name", schema.name)
+		}
+		fieldSchema, err := p.parse(fieldDef["type"], recordNamespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid record %s field %s", schema.name, name)
+		}
+		f := &field{name: name, schema: fieldSchema}
+		f.def, f.hasDefault = fieldDef["default"]
+		schema.fields = append(schema.fields, f)
+	}
+	return schema, nil
+}
+
+// define creates a named schema of the given type and registers it, so that
+// it can be referred to by name, including from within itself.
+func (p *parser) define(typ string, def map[string]interface{}, namespace string) (*Schema, error) {
+	name, ok := def["name"].(string)
+	if !ok || name == "" {
+		return nil, errors.Errorf("%s has no name", typ)
+	}
+	if ns, ok := def["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	name = fullName(name, namespace)
+	if _, ok := p.named[name]; ok {
+		return nil, errors.Errorf("type %s is defined more than once", name)
+	}
+	schema := &Schema{typ: typ, name: name}
+	p.named[name] = schema
+	return schema, nil
+}
+
+// fullName returns the full name of a type, that is the name qualified with
+// the namespace, unless the name is already qualified.
+func fullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// branchName returns the name a union branch is identified by in the JSON
+// encoding: the full name for named types and the type name for the others.
+func (s *Schema) branchName() string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.typ
+}
+
+// FromJSON encodes a JSON value with the schema to Avro binary encoding. A
+// value of a union type can be given either as `null` or in the Avro JSON
+// encoding form, e.g. `{"string": "foo"}`, or as a bare value, in which case
+// the first branch that the value conforms to is used.
+func (s *Schema) FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "invalid JSON")
+	}
+	if dec.More() {
+		return nil, errors.New("invalid JSON: more than one value")
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, s, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ToJSON decodes an Avro binary encoded value with the schema to JSON.
+// Values of union types other than `null` are represented in the Avro JSON
+// encoding form, e.g. `{"string": "foo"}`.
+func (s *Schema) ToJSON(data []byte) ([]byte, error) {
+	d := decoder{data: data}
+	var buf bytes.Buffer
+	if err := d.decode(&buf, s); err != nil {
+		return nil, err
+	}
+	if len(d.data) != 0 {
+		return nil, errors.Errorf("%d trailing bytes", len(d.data))
+	}
+	return buf.Bytes(), nil
+}
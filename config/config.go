@@ -2,10 +2,14 @@ package config
 
 import (
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -15,6 +19,14 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+const (
+	saslMechanismPlain = "PLAIN"
+
+	// Fetch requests that the server blocks for longer than the sarama
+	// connection read timeout, that is 30s by default, fail.
+	maxFetchMaxWait = 30 * time.Second
+)
+
 // App defines Kafka-Pixy application configuration. It mirrors the structure
 // of the JSON configuration file.
 type App struct {
@@ -28,6 +40,33 @@ type App struct {
 	// Listening on a unix domain socket is disabled by default.
 	UnixAddr string `yaml:"unix_addr"`
 
+	// If true, then HTTP API responses are compressed with gzip or deflate
+	// for clients that list either in the `Accept-Encoding` header. It is
+	// disabled by default.
+	HTTPCompression bool `yaml:"http_compression"`
+
+	// TLS termination for the gRPC and TCP HTTP API servers. It is enabled
+	// if a certificate is specified. Certificate files are loaded when the
+	// config is parsed.
+	TLS struct {
+
+		// Paths to PEM files with a server certificate and a respective
+		// private key.
+		CertFile string `yaml:"cert_file"`
+		KeyFile  string `yaml:"key_file"`
+
+		// Path to a PEM file with certificates of authorities to verify
+		// client certificates with. If specified, then clients have to
+		// present a valid certificate to connect (mutual TLS).
+		ClientCACertFile string `yaml:"client_ca_cert_file"`
+	} `yaml:"tls"`
+
+	// Authentication and authorization of API requests. If no API keys are
+	// configured, then requests are not authenticated.
+	Auth struct {
+		APIKeys []APIKey `yaml:"api_keys"`
+	} `yaml:"auth"`
+
 	// An arbitrary number of proxies to different Kafka/ZooKeeper clusters can
 	// be configured. Each proxy configuration is identified by a cluster name.
 	Proxies map[string]*Proxy `yaml:"proxies"`
@@ -36,6 +75,27 @@ type App struct {
 	// prefix `/clusters/<cluster>`. If it is not explicitly provided, then the
 	// one mentioned in the `Proxies` section first is assumed.
 	DefaultCluster string `yaml:"default_cluster"`
+
+	// TLS configuration built from TLS parameters on validation.
+	serverTLSCfg *tls.Config
+}
+
+// APIKey defines an API key that clients can authenticate with, and topics
+// and consumer groups that it grants access to. Topics and groups are given
+// as glob patterns, e.g. `orders.*`. If no topics are given, then access to
+// all topics is granted, and the same goes for groups. Administrative
+// operations, e.g. deletion of topics, are only granted if Admin is true.
+type APIKey struct {
+	Key    string   `yaml:"key"`
+	Topics []string `yaml:"topics"`
+	Groups []string `yaml:"groups"`
+	Admin  bool     `yaml:"admin"`
+}
+
+// ServerTLSCfg returns the TLS configuration that API servers should use to
+// accept client connections, or nil if TLS is not enabled.
+func (a *App) ServerTLSCfg() *tls.Config {
+	return a.serverTLSCfg
 }
 
 // Proxy defines configuration of a proxy to a particular Kafka/ZooKeeper
@@ -54,6 +114,50 @@ type Proxy struct {
 
 		// Version of the Kafka cluster. Supported versions are 0.8.2.2 - 0.10.1.0
 		Version KafkaVersion
+
+		// How frequently to refresh the cluster metadata in the background.
+		// Zero disables periodic refresh, then metadata is refreshed only
+		// when an error indicates that it is stale.
+		MetadataRefreshFrequency time.Duration `yaml:"metadata_refresh_frequency"`
+
+		// SASL authentication with Kafka brokers. It is disabled if the
+		// mechanism is not specified.
+		SASL struct {
+
+			// Authentication mechanism. Only `PLAIN` is supported.
+			Mechanism string `yaml:"mechanism"`
+
+			// Credentials to authenticate with.
+			User     string `yaml:"user"`
+			Password string `yaml:"password"`
+		} `yaml:"sasl"`
+
+		// TLS connection to Kafka brokers. Certificate files are loaded when
+		// the config is parsed.
+		TLS struct {
+
+			// Whether TLS should be used to connect to Kafka brokers.
+			Enabled bool `yaml:"enabled"`
+
+			// Path to a PEM file with certificates of authorities to verify
+			// broker certificates with. If not specified then the host root CA
+			// set is used.
+			CACertFile string `yaml:"ca_cert_file"`
+
+			// Paths to PEM files with a client certificate and a respective
+			// private key. They should be specified if brokers require client
+			// authentication.
+			CertFile string `yaml:"cert_file"`
+			KeyFile  string `yaml:"key_file"`
+
+			// Whether broker certificate verification should be skipped. It
+			// should only be used in tests.
+			InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+
+			// Server name to verify broker certificates against. If not
+			// specified then broker host names are used.
+			ServerName string `yaml:"server_name"`
+		} `yaml:"tls"`
 	} `yaml:"kafka"`
 
 	ZooKeeper struct {
@@ -71,6 +175,16 @@ type Proxy struct {
 		// Size of all buffered channels created by the producer module.
 		ChannelBufferSize int `yaml:"channel_buffer_size"`
 
+		// The number of consecutive produce failures to a topic after which
+		// produce requests to the topic are rejected for CircuitBreakerCooldown.
+		// Zero disables the circuit breaker.
+		CircuitBreakerThreshold int `yaml:"circuit_breaker_threshold"`
+
+		// Period of time that produce requests to a topic are rejected after
+		// CircuitBreakerThreshold failures in a row. When it elapses a single
+		// request is let through to probe if the topic has recovered.
+		CircuitBreakerCooldown time.Duration `yaml:"circuit_breaker_cooldown"`
+
 		// The type of compression to use on messages.
 		Compression Compression `yaml:"compression"`
 
@@ -80,9 +194,38 @@ type Proxy struct {
 		// The best-effort frequency of flushes.
 		FlushFrequency time.Duration `yaml:"flush_frequency"`
 
+		// The maximum number of messages submitted with AsyncProduceBounded
+		// that can be in flight at a time. When the limit is reached, new
+		// submissions wait for earlier ones to complete. Zero means no limit.
+		MaxAsyncInFlight int `yaml:"max_async_in_flight"`
+
+		// The maximum total size of a message key and value. Larger messages
+		// are rejected before they are submitted to Kafka. It should not be
+		// greater than `message.max.bytes` of the Kafka brokers.
+		MaxMessageBytes int `yaml:"max_message_bytes"`
+
+		// If positive, then the producer refreshes metadata of all topics as
+		// soon as it gets older than this, even if that happens before
+		// kafka.metadata_refresh_frequency elapses. It bounds the period of
+		// time that messages can be sent to a partition leader that is no
+		// longer there. Zero means rely on kafka.metadata_refresh_frequency.
+		MetadataMaxAge time.Duration `yaml:"metadata_max_age"`
+
+		// The name of a partitioner that selects partitions for messages
+		// that are not produced to a partition explicitly. It is either one
+		// of hash, random, round_robin, or a name that a custom partitioner
+		// was registered with by producer.RegisterPartitioner.
+		Partitioner string `yaml:"partitioner"`
+
 		// How long to wait for the cluster to settle between retries.
 		RetryBackoff time.Duration `yaml:"retry_backoff"`
 
+		// The maximum period of time that Produce waits for a message to be
+		// acknowledged by Kafka before it gives up and returns a timeout
+		// error. The message may still be written to Kafka after that. Zero
+		// means wait until the producer retries are exhausted.
+		ProduceTimeout time.Duration `yaml:"produce_timeout"`
+
 		// The total number of times to retry sending a message.
 		RetryMax int `yaml:"retry_max"`
 
@@ -93,6 +236,12 @@ type Proxy struct {
 		// messages to Kafka. It is recommended to make it large enough to survive
 		// a ZooKeeper leader election in your setup.
 		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+		// If true, then messages produced to topics that do not exist are
+		// rejected before they are submitted to Kafka. It should not be
+		// enabled if topics are expected to be created automatically on
+		// produce, that is if Kafka has `auto.create.topics.enable=true`.
+		ValidateTopic bool `yaml:"validate_topic"`
 	} `yaml:"producer"`
 
 	Consumer struct {
@@ -101,7 +250,8 @@ type Proxy struct {
 		// before retrying.
 		AckTimeout time.Duration `yaml:"ack_timeout"`
 
-		// Size of all buffered channels created by the consumer module.
+		// Size of all buffered channels created by the consumer module,
+		// except for per partition message buffers sized by PrefetchSize.
 		ChannelBufferSize int `yaml:"channel_buffer_size"`
 
 		// The number of bytes of messages to attempt to fetch for each
@@ -113,13 +263,30 @@ type Proxy struct {
 		FetchMaxBytes int `yaml:"fetch_max_bytes"`
 
 		// The maximum amount of time the server will block before answering
-		// the fetch request if there isn't data immediately available.
+		// the fetch request if there isn't FetchMinBytes of data immediately
+		// available.
 		FetchMaxWait time.Duration `yaml:"fetch_max_wait"`
 
+		// The minimum number of bytes of messages that the server should
+		// return for a fetch request. Larger values reduce the fetch request
+		// rate on high-volume topics at the expense of latency of up to
+		// FetchMaxWait.
+		FetchMinBytes int `yaml:"fetch_min_bytes"`
+
 		// Consume request will wait at most this long for a message from a
 		// topic to become available before expiring.
 		LongPollingTimeout time.Duration `yaml:"long_polling_timeout"`
 
+		// The maximum number of consume requests with the same client ID
+		// that can be in flight at a time. Requests in excess of the limit
+		// are rejected. Requests without a client ID share one limit. Zero
+		// means no limit.
+		MaxInFlightPerClient int `yaml:"max_in_flight_per_client"`
+
+		// The upper limit for a long polling timeout that can be requested
+		// for a particular consume request.
+		MaxLongPollingTimeout time.Duration `yaml:"max_long_polling_timeout"`
+
 		// The maximum number of unacknowledged messages allowed for a
 		// particular group-topic-partition at a time. When this number is
 		// reached subsequent consume requests will return long polling timeout
@@ -135,13 +302,63 @@ type Proxy struct {
 		// parameter to -1.
 		MaxRetries int `yaml:"max_retries"`
 
+		// If specified, then messages that exceeded the number of retries
+		// are produced to this topic before they are acknowledged, rather
+		// than just discarded. Requires MaxRetries to be >= 0.
+		DeadLetterTopic string `yaml:"dead_letter_topic"`
+
+		// The maximum number of group/topic/key entries remembered to flag
+		// consumed messages that are likely duplicates, that is messages
+		// with the same key and value as one consumed by the same group from
+		// the same topic within DedupWindow. It is best-effort hinting, not a
+		// guarantee. Zero disables the feature.
+		DedupCacheSize int `yaml:"dedup_cache_size"`
+
+		// How long a consumed message key is remembered for deduplication
+		// hinting. See DedupCacheSize.
+		DedupWindow time.Duration `yaml:"dedup_window"`
+
 		// How frequently to commit offsets to Kafka.
 		OffsetsCommitInterval time.Duration `yaml:"offsets_commit_interval"`
 
+		// If positive, then an offset is committed without waiting for
+		// OffsetsCommitInterval to elapse as soon as it is this many messages
+		// ahead of the last committed one. It bounds the number of messages
+		// that are consumed again after a crash.
+		OffsetsCommitBatchSize int `yaml:"offsets_commit_batch_size"`
+
+		// The maximum number of messages fetched from a partition ahead of
+		// consume requests. Larger values help to absorb bursts of consume
+		// requests at the expense of memory.
+		PrefetchSize int `yaml:"prefetch_size"`
+
+		// The maximum number of messages per second that can be consumed by
+		// a particular group from a particular topic. Requests in excess of
+		// the limit are rejected. Zero means no limit.
+		RateLimit float64 `yaml:"rate_limit"`
+
+		// The maximum number of messages that a group can consume from a
+		// topic in a burst, exceeding RateLimit for a short period of time.
+		RateLimitBurst int `yaml:"rate_limit_burst"`
+
 		// How long to wait for an offset to be committed by Kafka before
 		// retrying.
 		OffsetsCommitTimeout time.Duration `yaml:"offsets_commit_timeout"`
 
+		// If positive, then a partition consumer that has neither delivered
+		// nor had acknowledged a message for this long is stopped, while the
+		// group keeps the partition assigned. It is spawned again on the
+		// next consume request to the topic. It bounds resources held for
+		// sparse topics. It must not be less than AckTimeout. Zero disables
+		// eviction.
+		PartitionIdleTimeout time.Duration `yaml:"partition_idle_timeout"`
+
+		// Per topic position to start consuming from when a group has no
+		// committed offset for a partition of the topic. Topics that are
+		// not listed are consumed from the newest offset. Consume requests
+		// that explicitly specify an initial offset take precedence.
+		OffsetReset map[string]OffsetReset `yaml:"offset_reset"`
+
 		// Kafka-Pixy should wait this long after it gets notification that a
 		// consumer joined/left a consumer group it is a member of before
 		// rebalancing.
@@ -151,10 +368,58 @@ type Proxy struct {
 		// wait this long before retrying.
 		RetryBackoff time.Duration `yaml:"retry_backoff"`
 
+		// Consumer group membership and partition ownership are maintained
+		// with ephemeral ZooKeeper nodes, that are removed, triggering a
+		// rebalance, if Kafka-Pixy does not heartbeat its ZooKeeper session
+		// for this long, e.g. due to a long GC pause or a network glitch.
+		// Heartbeats are sent every third of it. ZooKeeper servers clamp it
+		// to between 2 and 20 times their tickTime.
+		SessionTimeout time.Duration `yaml:"session_timeout"`
+
 		// Period of time that Kafka-Pixy should keep subscription to
 		// a topic by a group in absence of requests from the consumer group.
 		SubscriptionTimeout time.Duration `yaml:"subscription_timeout"`
 	} `yaml:"consumer"`
+
+	SchemaRegistry struct {
+
+		// URL of a Confluent Schema Registry that Avro schemas of messages
+		// are stored in. If it is not set, then Avro encoding of produced
+		// messages and decoding of consumed messages is not available.
+		URL string `yaml:"url"`
+
+		// The maximum amount of time to wait for the schema registry to
+		// respond to a request.
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"schema_registry"`
+
+	Monitoring struct {
+
+		// The maximum number of distinct client IDs that client specific
+		// produce and consume metrics are reported for. Metrics of client
+		// IDs seen after the limit is reached are reported under the
+		// `other` client ID. Zero means no limit.
+		MaxClientMetrics int `yaml:"max_client_metrics"`
+
+		// If enabled, then a heartbeat message is periodically produced to
+		// a topic and read back to measure the end-to-end round-trip
+		// latency of the cluster.
+		Probe struct {
+
+			// Whether the probe is enabled.
+			Enabled bool `yaml:"enabled"`
+
+			// The topic that heartbeat messages are produced to. It has to
+			// exist, and it is recommended to give it a short retention.
+			Topic string `yaml:"topic"`
+
+			// How often heartbeat messages are produced.
+			Interval time.Duration `yaml:"interval"`
+		} `yaml:"probe"`
+	} `yaml:"monitoring"`
+
+	// TLS configuration built from Kafka.TLS parameters on validation.
+	kafkaTLSCfg *tls.Config
 }
 
 type KafkaVersion struct {
@@ -189,35 +454,83 @@ func (kv *KafkaVersion) IsAtLeast(v sarama.KafkaVersion) bool {
 
 type Compression sarama.CompressionCodec
 
+var compressionNames = map[sarama.CompressionCodec]string{
+	sarama.CompressionNone:   "none",
+	sarama.CompressionGZIP:   "gzip",
+	sarama.CompressionSnappy: "snappy",
+	sarama.CompressionLZ4:    "lz4",
+}
+
 func (c *Compression) UnmarshalText(text []byte) error {
 	str := string(text)
-	v, ok := map[string]sarama.CompressionCodec{
-		"none":   sarama.CompressionNone,
-		"gzip":   sarama.CompressionGZIP,
-		"snappy": sarama.CompressionSnappy,
-		"lz4":    sarama.CompressionLZ4,
-	}[str]
-	if !ok {
-		return errors.Errorf("bad compression, %s", str)
+	for v, name := range compressionNames {
+		if name == str {
+			*c = Compression(v)
+			return nil
+		}
 	}
-	*c = Compression(v)
-	return nil
+	if str == "zstd" {
+		return errors.New("bad compression, zstd is not supported by the Kafka client library")
+	}
+	return errors.Errorf("bad compression, %s", str)
+}
+
+func (c Compression) String() string {
+	if name, ok := compressionNames[sarama.CompressionCodec(c)]; ok {
+		return name
+	}
+	return fmt.Sprintf("Compression(%d)", c)
 }
 
 type RequiredAcks sarama.RequiredAcks
 
+var requiredAcksNames = map[sarama.RequiredAcks]string{
+	sarama.NoResponse:   "no_response",
+	sarama.WaitForLocal: "wait_for_local",
+	sarama.WaitForAll:   "wait_for_all",
+}
+
 func (ra *RequiredAcks) UnmarshalText(text []byte) error {
 	str := string(text)
-	v, ok := map[string]sarama.RequiredAcks{
-		"no_response":    sarama.NoResponse,
-		"wait_for_local": sarama.WaitForLocal,
-		"wait_for_all":   sarama.WaitForAll,
-	}[str]
-	if !ok {
-		return errors.Errorf("bad compression, %s", str)
+	for v, name := range requiredAcksNames {
+		if name == str {
+			*ra = RequiredAcks(v)
+			return nil
+		}
 	}
-	*ra = RequiredAcks(v)
-	return nil
+	return errors.Errorf("bad required acks, %s", str)
+}
+
+func (ra RequiredAcks) String() string {
+	if name, ok := requiredAcksNames[sarama.RequiredAcks(ra)]; ok {
+		return name
+	}
+	return fmt.Sprintf("RequiredAcks(%d)", ra)
+}
+
+type OffsetReset int64
+
+var offsetResetNames = map[int64]string{
+	sarama.OffsetOldest: "earliest",
+	sarama.OffsetNewest: "latest",
+}
+
+func (or *OffsetReset) UnmarshalText(text []byte) error {
+	str := string(text)
+	for v, name := range offsetResetNames {
+		if name == str {
+			*or = OffsetReset(v)
+			return nil
+		}
+	}
+	return errors.Errorf("bad offset reset, %s", str)
+}
+
+func (or OffsetReset) String() string {
+	if name, ok := offsetResetNames[int64(or)]; ok {
+		return name
+	}
+	return fmt.Sprintf("OffsetReset(%d)", or)
 }
 
 func (p *Proxy) KazooCfg() *kazoo.Config {
@@ -228,7 +541,7 @@ func (p *Proxy) KazooCfg() *kazoo.Config {
 	// minimum of 2 times the tickTime (as set in the server configuration) and
 	// a maximum of 20 times the tickTime". The default tickTime is 2 seconds.
 	// See http://zookeeper.apache.org/doc/trunk/zookeeperProgrammers.html#ch_zkSessions
-	kazooCfg.Timeout = 15 * time.Second
+	kazooCfg.Timeout = p.Consumer.SessionTimeout
 	return kazooCfg
 }
 
@@ -242,9 +555,11 @@ func (p *Proxy) SaramaProducerCfg() *sarama.Config {
 	saramaCfg.Producer.Compression = sarama.CompressionCodec(p.Producer.Compression)
 	saramaCfg.Producer.Flush.Frequency = p.Producer.FlushFrequency
 	saramaCfg.Producer.Flush.Bytes = p.Producer.FlushBytes
+	saramaCfg.Producer.MaxMessageBytes = p.Producer.MaxMessageBytes
 	saramaCfg.Producer.Retry.Backoff = p.Producer.RetryBackoff
 	saramaCfg.Producer.Retry.Max = p.Producer.RetryMax
 	saramaCfg.Producer.RequiredAcks = sarama.RequiredAcks(p.Producer.RequiredAcks)
+	p.setSaramaNetCfg(saramaCfg)
 	return saramaCfg
 }
 
@@ -253,9 +568,55 @@ func (p *Proxy) SaramaClientCfg() *sarama.Config {
 	saramaCfg.ChannelBufferSize = p.Consumer.ChannelBufferSize
 	saramaCfg.ClientID = p.ClientID
 	saramaCfg.Version = p.Kafka.Version.v
+
+	saramaCfg.Consumer.Fetch.Min = int32(p.Consumer.FetchMinBytes)
+	saramaCfg.Consumer.MaxWaitTime = p.Consumer.FetchMaxWait
+	p.setSaramaNetCfg(saramaCfg)
 	return saramaCfg
 }
 
+// setSaramaNetCfg applies Kafka connection parameters that are common for
+// all sarama clients.
+func (p *Proxy) setSaramaNetCfg(saramaCfg *sarama.Config) {
+	saramaCfg.Metadata.RefreshFrequency = p.Kafka.MetadataRefreshFrequency
+	if p.Kafka.SASL.Mechanism != "" {
+		saramaCfg.Net.SASL.Enable = true
+		saramaCfg.Net.SASL.User = p.Kafka.SASL.User
+		saramaCfg.Net.SASL.Password = p.Kafka.SASL.Password
+	}
+	if p.kafkaTLSCfg != nil {
+		saramaCfg.Net.TLS.Enable = true
+		saramaCfg.Net.TLS.Config = p.kafkaTLSCfg
+	}
+}
+
+// newKafkaTLSCfg creates a TLS config from Kafka.TLS parameters loading all
+// certificate files mentioned there.
+func (p *Proxy) newKafkaTLSCfg() (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: p.Kafka.TLS.InsecureSkipVerify,
+		ServerName:         p.Kafka.TLS.ServerName,
+	}
+	if p.Kafka.TLS.CACertFile != "" {
+		caCertPEM, err := ioutil.ReadFile(p.Kafka.TLS.CACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA certificate")
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(caCertPEM) {
+			return nil, errors.Errorf("no certificates found in %s", p.Kafka.TLS.CACertFile)
+		}
+	}
+	if p.Kafka.TLS.CertFile != "" || p.Kafka.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.Kafka.TLS.CertFile, p.Kafka.TLS.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
 // DefaultApp returns default application configuration where default proxy has
 // the specified cluster.
 func DefaultApp(cluster string) *App {
@@ -323,6 +684,14 @@ func FromYAML(data []byte) (*App, error) {
 			appCfg.DefaultCluster = cluster
 		}
 	}
+	// Top level parameters are parsed on top of proxy configs, that have to
+	// be restored after that, for they get overridden with zero values.
+	proxies := appCfg.Proxies
+	appCfg.Proxies = nil
+	if err := yaml.Unmarshal(data, appCfg); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config")
+	}
+	appCfg.Proxies = proxies
 
 	if err := appCfg.validate(); err != nil {
 		return nil, errors.Wrap(err, "invalid config parameter")
@@ -334,6 +703,28 @@ func (a *App) validate() error {
 	if len(a.Proxies) == 0 {
 		return errors.New("at least on proxy must be configured")
 	}
+	if a.TLS.CertFile != "" || a.TLS.KeyFile != "" || a.TLS.ClientCACertFile != "" {
+		tlsCfg, err := a.newServerTLSCfg()
+		if err != nil {
+			return errors.Wrap(err, "invalid tls")
+		}
+		a.serverTLSCfg = tlsCfg
+	}
+	keys := make(map[string]bool, len(a.Auth.APIKeys))
+	for i, apiKey := range a.Auth.APIKeys {
+		if apiKey.Key == "" {
+			return errors.Errorf("auth.api_keys[%d].key must be provided", i)
+		}
+		if keys[apiKey.Key] {
+			return errors.Errorf("auth.api_keys[%d].key is not unique", i)
+		}
+		keys[apiKey.Key] = true
+		for _, pattern := range append(apiKey.Topics, apiKey.Groups...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.Errorf("auth.api_keys[%d] has invalid pattern: %s", i, pattern)
+			}
+		}
+	}
 	for cluster, proxyCfg := range a.Proxies {
 		if err := proxyCfg.validate(); err != nil {
 			return errors.Wrapf(err, "invalid config, cluster=%s", cluster)
@@ -342,15 +733,74 @@ func (a *App) validate() error {
 	return nil
 }
 
+// newServerTLSCfg creates a TLS config from TLS parameters loading all
+// certificate files.
+func (a *App) newServerTLSCfg() (*tls.Config, error) {
+	if a.TLS.CertFile == "" || a.TLS.KeyFile == "" {
+		return nil, errors.New("both cert_file and key_file must be provided")
+	}
+	cert, err := tls.LoadX509KeyPair(a.TLS.CertFile, a.TLS.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load server certificate")
+	}
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if a.TLS.ClientCACertFile != "" {
+		caCertPEM, err := ioutil.ReadFile(a.TLS.ClientCACertFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read client CA certificate")
+		}
+		tlsCfg.ClientCAs = x509.NewCertPool()
+		if !tlsCfg.ClientCAs.AppendCertsFromPEM(caCertPEM) {
+			return nil, errors.Errorf("no certificates found in %s", a.TLS.ClientCACertFile)
+		}
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
 func (p *Proxy) validate() error {
+	// Validate the Kafka parameters.
+	switch p.Kafka.SASL.Mechanism {
+	case "":
+	case saslMechanismPlain:
+		if p.Kafka.SASL.User == "" || p.Kafka.SASL.Password == "" {
+			return errors.New("kafka.sasl.user and kafka.sasl.password must be provided")
+		}
+	default:
+		return errors.Errorf("kafka.sasl.mechanism %s is not supported", p.Kafka.SASL.Mechanism)
+	}
+	if p.Kafka.MetadataRefreshFrequency < 0 {
+		return errors.New("kafka.metadata_refresh_frequency must be >= 0")
+	}
+	if p.Kafka.TLS.Enabled {
+		tlsCfg, err := p.newKafkaTLSCfg()
+		if err != nil {
+			return errors.Wrap(err, "invalid kafka.tls")
+		}
+		p.kafkaTLSCfg = tlsCfg
+	}
 	// Validate the Producer parameters.
 	switch {
 	case p.Producer.ChannelBufferSize <= 0:
 		return errors.New("producer.channel_buffer_size must be > 0")
+	case p.Producer.CircuitBreakerThreshold < 0:
+		return errors.New("producer.circuit_breaker_threshold must be >= 0")
+	case p.Producer.CircuitBreakerThreshold > 0 && p.Producer.CircuitBreakerCooldown <= 0:
+		return errors.New("producer.circuit_breaker_cooldown must be > 0")
 	case p.Producer.FlushBytes < 0:
 		return errors.New("producer.flush_bytes must be >= 0")
 	case p.Producer.FlushFrequency < 0:
 		return errors.New("producer.flush_frequency must be >= 0")
+	case p.Producer.MaxAsyncInFlight < 0:
+		return errors.New("producer.max_async_in_flight must be >= 0")
+	case p.Producer.MaxMessageBytes <= 0:
+		return errors.New("producer.max_message_bytes must be > 0")
+	case p.Producer.Partitioner == "":
+		return errors.New("producer.partitioner must be specified")
+	case p.Producer.MetadataMaxAge < 0:
+		return errors.New("producer.metadata_max_age must be >= 0")
+	case p.Producer.ProduceTimeout < 0:
+		return errors.New("producer.produce_timeout must be >= 0")
 	case p.Producer.RetryBackoff <= 0:
 		return errors.New("producer.retry_backoff must be > 0")
 	case p.Producer.RetryMax <= 0:
@@ -364,23 +814,76 @@ func (p *Proxy) validate() error {
 		return errors.New("consumer.ack_timeout must be > 0")
 	case p.Consumer.ChannelBufferSize <= 0:
 		return errors.New("consumer.channel_buffer_size must be > 0")
+	case p.Consumer.OffsetsCommitBatchSize < 0:
+		return errors.New("consumer.offsets_commit_batch_size must be >= 0")
+	case p.Consumer.PrefetchSize <= 0:
+		return errors.New("consumer.prefetch_size must be > 0")
 	case p.Consumer.FetchMaxBytes <= 0:
 		return errors.New("consumer.fetch_bytes must be > 0")
+	case p.Consumer.FetchMinBytes <= 0:
+		return errors.New("consumer.fetch_min_bytes must be > 0")
+	case p.Consumer.FetchMinBytes > p.Consumer.FetchMaxBytes:
+		return errors.New("consumer.fetch_min_bytes must be <= consumer.fetch_max_bytes")
+	case p.Consumer.FetchMaxWait < time.Millisecond:
+		return errors.New("consumer.fetch_max_wait must be >= 1ms")
+	case p.Consumer.FetchMaxWait >= maxFetchMaxWait:
+		return errors.Errorf("consumer.fetch_max_wait must be < %v", maxFetchMaxWait)
 	case p.Consumer.LongPollingTimeout <= 0:
 		return errors.New("consumer.long_polling_timeout must be > 0")
+	case p.Consumer.MaxInFlightPerClient < 0:
+		return errors.New("consumer.max_in_flight_per_client must be >= 0")
+	case p.Consumer.MaxLongPollingTimeout < p.Consumer.LongPollingTimeout:
+		return errors.New("consumer.max_long_polling_timeout must be >= consumer.long_polling_timeout")
 	case p.Consumer.MaxPendingMessages <= 0:
 		return errors.New("consumer.max_pending_messages must be > 0")
 	case p.Consumer.MaxRetries < -1:
 		return errors.New("consumer.max_retries must be >= -1")
+	case p.Consumer.DeadLetterTopic != "" && p.Consumer.MaxRetries < 0:
+		return errors.New("consumer.dead_letter_topic requires consumer.max_retries >= 0")
+	case p.Consumer.DedupCacheSize < 0:
+		return errors.New("consumer.dedup_cache_size must be >= 0")
+	case p.Consumer.DedupCacheSize > 0 && p.Consumer.DedupWindow <= 0:
+		return errors.New("consumer.dedup_window must be > 0")
 	case p.Consumer.OffsetsCommitInterval <= 0:
 		return errors.New("consumer.offsets_commit_interval must be > 0")
 	case p.Consumer.OffsetsCommitTimeout <= 0:
 		return errors.New("consumer.offsets_commit_timeout must be > 0")
+	case p.Consumer.PartitionIdleTimeout < 0:
+		return errors.New("consumer.partition_idle_timeout must be >= 0")
+	case p.Consumer.PartitionIdleTimeout > 0 && p.Consumer.PartitionIdleTimeout < p.Consumer.AckTimeout:
+		return errors.New("consumer.partition_idle_timeout must be >= consumer.ack_timeout")
+	case p.Consumer.RateLimit < 0:
+		return errors.New("consumer.rate_limit must be >= 0")
+	case p.Consumer.RateLimit > 0 && p.Consumer.RateLimitBurst <= 0:
+		return errors.New("consumer.rate_limit_burst must be > 0")
+	case p.Consumer.SessionTimeout <= 0:
+		return errors.New("consumer.session_timeout must be > 0")
 	case p.Consumer.SubscriptionTimeout <= 0:
 		return errors.New("consumer.subscription_timeout must be > 0")
 	case p.Consumer.RetryBackoff <= 0:
 		return errors.New("consumer.retry_backoff must be > 0")
 	}
+	// Validate the Monitoring parameters.
+	if p.Monitoring.MaxClientMetrics < 0 {
+		return errors.New("monitoring.max_client_metrics must be >= 0")
+	}
+	if p.Monitoring.Probe.Enabled {
+		switch {
+		case p.Monitoring.Probe.Topic == "":
+			return errors.New("monitoring.probe.topic must be specified")
+		case p.Monitoring.Probe.Interval <= 0:
+			return errors.New("monitoring.probe.interval must be > 0")
+		}
+	}
+	// Validate the SchemaRegistry parameters.
+	if p.SchemaRegistry.URL != "" {
+		if _, err := url.Parse(p.SchemaRegistry.URL); err != nil {
+			return errors.Wrap(err, "invalid schema_registry.url")
+		}
+		if p.SchemaRegistry.Timeout <= 0 {
+			return errors.New("schema_registry.timeout must be > 0")
+		}
+	}
 	return nil
 }
 
@@ -398,6 +901,7 @@ func defaultProxyWithClientID(clientID string) *Proxy {
 	c.ZooKeeper.SeedPeers = []string{"localhost:2181"}
 
 	c.Kafka.SeedPeers = []string{"localhost:9092"}
+	c.Kafka.MetadataRefreshFrequency = 10 * time.Minute
 
 	c.Kafka.Version.v = sarama.V0_8_2_2
 	// If a valid Kafka version provided in an environment variable then use it
@@ -409,9 +913,12 @@ func defaultProxyWithClientID(clientID string) *Proxy {
 	}
 
 	c.Producer.ChannelBufferSize = 4096
+	c.Producer.CircuitBreakerCooldown = 10 * time.Second
 	c.Producer.Compression = Compression(sarama.CompressionSnappy)
 	c.Producer.FlushFrequency = 500 * time.Millisecond
 	c.Producer.FlushBytes = 1024 * 1024
+	c.Producer.MaxMessageBytes = 1000000
+	c.Producer.Partitioner = "hash"
 	c.Producer.RequiredAcks = RequiredAcks(sarama.WaitForAll)
 	c.Producer.RetryBackoff = 10 * time.Second
 	c.Producer.RetryMax = 6
@@ -419,15 +926,27 @@ func defaultProxyWithClientID(clientID string) *Proxy {
 
 	c.Consumer.AckTimeout = 300 * time.Second
 	c.Consumer.ChannelBufferSize = 64
+	c.Consumer.DedupWindow = 60 * time.Second
 	c.Consumer.FetchMaxBytes = 1024 * 1024
+	c.Consumer.PrefetchSize = 64
 	c.Consumer.FetchMaxWait = 250 * time.Millisecond
+	c.Consumer.FetchMinBytes = 1
 	c.Consumer.LongPollingTimeout = 3 * time.Second
+	c.Consumer.MaxLongPollingTimeout = 30 * time.Second
 	c.Consumer.MaxPendingMessages = 300
 	c.Consumer.MaxRetries = -1
 	c.Consumer.OffsetsCommitInterval = 500 * time.Millisecond
 	c.Consumer.OffsetsCommitTimeout = 1500 * time.Millisecond
+	c.Consumer.RateLimitBurst = 10
 	c.Consumer.SubscriptionTimeout = 15 * time.Second
+	c.Consumer.SessionTimeout = 15 * time.Second
 	c.Consumer.RetryBackoff = 500 * time.Millisecond
+
+	c.SchemaRegistry.Timeout = 10 * time.Second
+
+	c.Monitoring.MaxClientMetrics = 100
+	c.Monitoring.Probe.Topic = "kafka-pixy-probe"
+	c.Monitoring.Probe.Interval = 10 * time.Second
 	return c
 }
 
@@ -1,6 +1,17 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -116,3 +127,493 @@ func (s *ConfigSuite) TestFromYAMLFile(c *C) {
 	appCfg.Proxies["default"].ClientID = "ID"
 	c.Assert(appCfg, DeepEquals, expected)
 }
+
+func (s *ConfigSuite) TestFromYAMLSASLPlain(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    kafka:\n" +
+		"      sasl:\n" +
+		"        mechanism: PLAIN\n" +
+		"        user: foo\n" +
+		"        password: bar\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	saramaCfg := appCfg.Proxies["default"].SaramaClientCfg()
+	c.Assert(saramaCfg.Net.SASL.Enable, Equals, true)
+	c.Assert(saramaCfg.Net.SASL.User, Equals, "foo")
+	c.Assert(saramaCfg.Net.SASL.Password, Equals, "bar")
+	saramaCfg = appCfg.Proxies["default"].SaramaProducerCfg()
+	c.Assert(saramaCfg.Net.SASL.Enable, Equals, true)
+}
+
+func (s *ConfigSuite) TestFromYAMLSASLInvalid(c *C) {
+	for i, tc := range []struct {
+		sasl string
+		err  string
+	}{{
+		sasl: "        mechanism: SCRAM-SHA-256\n",
+		err:  "kafka.sasl.mechanism SCRAM-SHA-256 is not supported",
+	}, {
+		sasl: "        mechanism: PLAIN\n        user: foo\n",
+		err:  "kafka.sasl.user and kafka.sasl.password must be provided",
+	}} {
+		data := []byte("" +
+			"proxies:\n" +
+			"  default:\n" +
+			"    kafka:\n" +
+			"      sasl:\n" +
+			tc.sasl)
+
+		// When
+		_, err := FromYAML(data)
+
+		// Then
+		c.Assert(err.Error(), Equals, "invalid config parameter: "+
+			"invalid config, cluster=default: "+tc.err, Commentf("case #%d", i))
+	}
+}
+
+func (s *ConfigSuite) TestFromYAMLTLS(c *C) {
+	dir := c.MkDir()
+	certFile, keyFile := writeSelfSignedCert(c, dir)
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    kafka:\n" +
+		"      tls:\n" +
+		"        enabled: true\n" +
+		"        ca_cert_file: " + certFile + "\n" +
+		"        cert_file: " + certFile + "\n" +
+		"        key_file: " + keyFile + "\n" +
+		"        server_name: kafka.test\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	saramaCfg := appCfg.Proxies["default"].SaramaClientCfg()
+	c.Assert(saramaCfg.Net.TLS.Enable, Equals, true)
+	c.Assert(saramaCfg.Net.TLS.Config.ServerName, Equals, "kafka.test")
+	c.Assert(len(saramaCfg.Net.TLS.Config.Certificates), Equals, 1)
+	c.Assert(saramaCfg.Net.TLS.Config.RootCAs, NotNil)
+	saramaCfg = appCfg.Proxies["default"].SaramaProducerCfg()
+	c.Assert(saramaCfg.Net.TLS.Enable, Equals, true)
+}
+
+func (s *ConfigSuite) TestFromYAMLTLSMissingFile(c *C) {
+	dir := c.MkDir()
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    kafka:\n" +
+		"      tls:\n" +
+		"        enabled: true\n" +
+		"        ca_cert_file: " + filepath.Join(dir, "missing.pem") + "\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, ErrorMatches, "invalid config parameter: invalid config, cluster=default: "+
+		"invalid kafka.tls: failed to read CA certificate: .* no such file or directory")
+}
+
+func (s *ConfigSuite) TestFromYAMLServerTLS(c *C) {
+	dir := c.MkDir()
+	certFile, keyFile := writeSelfSignedCert(c, dir)
+	data := []byte("" +
+		"tls:\n" +
+		"  cert_file: " + certFile + "\n" +
+		"  key_file: " + keyFile + "\n" +
+		"  client_ca_cert_file: " + certFile + "\n" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    kafka:\n" +
+		"      seed_peers:\n" +
+		"        - kafka1:9092\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	tlsCfg := appCfg.ServerTLSCfg()
+	c.Assert(len(tlsCfg.Certificates), Equals, 1)
+	c.Assert(tlsCfg.ClientCAs, NotNil)
+	c.Assert(tlsCfg.ClientAuth, Equals, tls.RequireAndVerifyClientCert)
+}
+
+func (s *ConfigSuite) TestFromYAMLServerTLSInvalid(c *C) {
+	dir := c.MkDir()
+	certFile, _ := writeSelfSignedCert(c, dir)
+	data := []byte("" +
+		"tls:\n" +
+		"  cert_file: " + certFile + "\n" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    kafka:\n" +
+		"      seed_peers:\n" +
+		"        - kafka1:9092\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid tls: both cert_file and key_file must be provided")
+}
+
+// Top level parameters are parsed along with proxy configs.
+func (s *ConfigSuite) TestFromYAMLTopLevel(c *C) {
+	data := []byte("" +
+		"grpc_addr: 1.2.3.4:1234\n" +
+		"http_compression: true\n" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    client_id: foo\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(appCfg.GRPCAddr, Equals, "1.2.3.4:1234")
+	c.Assert(appCfg.TCPAddr, Equals, "0.0.0.0:19092")
+	c.Assert(appCfg.HTTPCompression, Equals, true)
+	c.Assert(appCfg.Proxies["default"].ClientID, Equals, "foo")
+	c.Assert(appCfg.Proxies["default"].Producer.ChannelBufferSize, Equals, 4096)
+}
+
+// Server TLS is disabled by default.
+func (s *ConfigSuite) TestServerTLSDefault(c *C) {
+	appCfg, err := FromYAML([]byte("proxies:\n  default:\n    client_id: foo\n"))
+	c.Assert(err, IsNil)
+	c.Assert(appCfg.ServerTLSCfg(), IsNil)
+}
+
+func writeSelfSignedCert(c *C, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), os.FileMode(0600))
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), os.FileMode(0600))
+	c.Assert(err, IsNil)
+	return certFile, keyFile
+}
+
+func (s *ConfigSuite) TestFromYAMLDeadLetterTopicRetriesIndefinitely(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      dead_letter_topic: foo\n" +
+		"      max_retries: -1\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"consumer.dead_letter_topic requires consumer.max_retries >= 0")
+}
+
+func (s *ConfigSuite) TestRequiredAcks(c *C) {
+	for i, tc := range []struct {
+		name string
+		acks sarama.RequiredAcks
+	}{
+		{name: "no_response", acks: sarama.NoResponse},
+		{name: "wait_for_local", acks: sarama.WaitForLocal},
+		{name: "wait_for_all", acks: sarama.WaitForAll},
+	} {
+		var requiredAcks RequiredAcks
+		err := requiredAcks.UnmarshalText([]byte(tc.name))
+		c.Assert(err, IsNil, Commentf("case #%d", i))
+		c.Assert(sarama.RequiredAcks(requiredAcks), Equals, tc.acks, Commentf("case #%d", i))
+		c.Assert(requiredAcks.String(), Equals, tc.name, Commentf("case #%d", i))
+	}
+}
+
+func (s *ConfigSuite) TestRequiredAcksInvalid(c *C) {
+	var requiredAcks RequiredAcks
+	err := requiredAcks.UnmarshalText([]byte("wait_for_some"))
+	c.Assert(err.Error(), Equals, "bad required acks, wait_for_some")
+}
+
+func (s *ConfigSuite) TestCompression(c *C) {
+	for i, tc := range []struct {
+		name  string
+		codec sarama.CompressionCodec
+	}{
+		{name: "none", codec: sarama.CompressionNone},
+		{name: "gzip", codec: sarama.CompressionGZIP},
+		{name: "snappy", codec: sarama.CompressionSnappy},
+		{name: "lz4", codec: sarama.CompressionLZ4},
+	} {
+		var compression Compression
+		err := compression.UnmarshalText([]byte(tc.name))
+		c.Assert(err, IsNil, Commentf("case #%d", i))
+		c.Assert(sarama.CompressionCodec(compression), Equals, tc.codec, Commentf("case #%d", i))
+		c.Assert(compression.String(), Equals, tc.name, Commentf("case #%d", i))
+	}
+}
+
+func (s *ConfigSuite) TestCompressionInvalid(c *C) {
+	var compression Compression
+	err := compression.UnmarshalText([]byte("zstd"))
+	c.Assert(err.Error(), Equals, "bad compression, zstd is not supported by the Kafka client library")
+	err = compression.UnmarshalText([]byte("brotli"))
+	c.Assert(err.Error(), Equals, "bad compression, brotli")
+}
+
+func (s *ConfigSuite) TestFromYAMLOffsetReset(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      offset_reset:\n" +
+		"        foo: earliest\n" +
+		"        bar: latest\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	offsetReset := appCfg.Proxies["default"].Consumer.OffsetReset
+	c.Assert(offsetReset, DeepEquals, map[string]OffsetReset{
+		"foo": OffsetReset(sarama.OffsetOldest),
+		"bar": OffsetReset(sarama.OffsetNewest),
+	})
+	c.Assert(offsetReset["foo"].String(), Equals, "earliest")
+}
+
+func (s *ConfigSuite) TestFromYAMLOffsetResetInvalid(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      offset_reset:\n" +
+		"        foo: smallest\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, NotNil)
+	c.Assert(err.Error(), Matches, ".*bad offset reset, smallest.*")
+}
+
+func (s *ConfigSuite) TestFromYAMLMaxLongPollingTimeoutTooShort(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      long_polling_timeout: 5s\n" +
+		"      max_long_polling_timeout: 1s\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"consumer.max_long_polling_timeout must be >= consumer.long_polling_timeout")
+}
+
+func (s *ConfigSuite) TestFromYAMLPrefetchSize(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      prefetch_size: 1000\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(appCfg.Proxies["default"].Consumer.PrefetchSize, Equals, 1000)
+	c.Assert(appCfg.Proxies["default"].Consumer.ChannelBufferSize, Equals, 64)
+}
+
+func (s *ConfigSuite) TestMetadataRefreshFrequency(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    kafka:\n" +
+		"      metadata_refresh_frequency: 30s\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	proxyCfg := appCfg.Proxies["default"]
+	c.Assert(proxyCfg.SaramaClientCfg().Metadata.RefreshFrequency, Equals, 30*time.Second)
+	c.Assert(proxyCfg.SaramaProducerCfg().Metadata.RefreshFrequency, Equals, 30*time.Second)
+}
+
+func (s *ConfigSuite) TestFetchMinBytes(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      fetch_min_bytes: 65536\n" +
+		"      fetch_max_wait: 500ms\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	saramaCfg := appCfg.Proxies["default"].SaramaClientCfg()
+	c.Assert(saramaCfg.Consumer.Fetch.Min, Equals, int32(65536))
+	c.Assert(saramaCfg.Consumer.MaxWaitTime, Equals, 500*time.Millisecond)
+}
+
+func (s *ConfigSuite) TestFromYAMLFetchInvalid(c *C) {
+	for i, tc := range []struct {
+		yaml   string
+		errMsg string
+	}{{
+		yaml:   "      fetch_min_bytes: 0\n",
+		errMsg: "consumer.fetch_min_bytes must be > 0",
+	}, {
+		yaml:   "      fetch_max_bytes: 1024\n      fetch_min_bytes: 2048\n",
+		errMsg: "consumer.fetch_min_bytes must be <= consumer.fetch_max_bytes",
+	}, {
+		yaml:   "      fetch_max_wait: 0s\n",
+		errMsg: "consumer.fetch_max_wait must be >= 1ms",
+	}, {
+		yaml:   "      fetch_max_wait: 30s\n",
+		errMsg: "consumer.fetch_max_wait must be < 30s",
+	}} {
+		data := []byte("" +
+			"proxies:\n" +
+			"  default:\n" +
+			"    consumer:\n" +
+			tc.yaml)
+
+		// When
+		_, err := FromYAML(data)
+
+		// Then
+		c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+			tc.errMsg, Commentf("case #%d", i))
+	}
+}
+
+func (s *ConfigSuite) TestFromYAMLSessionTimeout(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      session_timeout: 30s\n")
+
+	// When
+	appCfg, err := FromYAML(data)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(appCfg.Proxies["default"].KazooCfg().Timeout, Equals, 30*time.Second)
+}
+
+func (s *ConfigSuite) TestFromYAMLSessionTimeoutInvalid(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      session_timeout: 0s\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"consumer.session_timeout must be > 0")
+}
+
+func (s *ConfigSuite) TestFromYAMLProbeInvalid(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    monitoring:\n" +
+		"      probe:\n" +
+		"        enabled: true\n" +
+		"        topic: \"\"\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"monitoring.probe.topic must be specified")
+}
+
+func (s *ConfigSuite) TestFromYAMLDedupNoWindow(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      dedup_cache_size: 1000\n" +
+		"      dedup_window: 0s\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"consumer.dedup_window must be > 0")
+}
+
+func (s *ConfigSuite) TestFromYAMLPartitionIdleTimeoutBelowAckTimeout(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      ack_timeout: 5m\n" +
+		"      partition_idle_timeout: 1m\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"consumer.partition_idle_timeout must be >= consumer.ack_timeout")
+}
+
+func (s *ConfigSuite) TestFromYAMLRateLimitNoBurst(c *C) {
+	data := []byte("" +
+		"proxies:\n" +
+		"  default:\n" +
+		"    consumer:\n" +
+		"      rate_limit: 100\n" +
+		"      rate_limit_burst: 0\n")
+
+	// When
+	_, err := FromYAML(data)
+
+	// Then
+	c.Assert(err.Error(), Equals, "invalid config parameter: invalid config, cluster=default: "+
+		"consumer.rate_limit_burst must be > 0")
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
 )
 
 const (
@@ -14,12 +15,29 @@ const (
 	// An event of this type should be sent to the message events channel
 	// when the message is acknowledged by a client.
 	EvAcked
+
+	// An event of this type should be sent to the message events channel
+	// when a client is not going to acknowledge an offered message, e.g.
+	// because it disconnected. The message is then redelivered right away
+	// rather than after `Consumer.AckTimeout`.
+	EvReleased
+
+	// An event of this type should be sent to the message events channel
+	// when a client acknowledges all messages of the partition up to and
+	// including the given offset in one go, e.g. after processing a batch.
+	EvAckedUpTo
 )
 
 var (
 	ErrRequestTimeout  = errors.New("long polling timeout")
 	ErrUnavailable     = errors.New("service is shutting down")
 	ErrTooManyRequests = errors.New("Too many requests. Consider increasing `consumer.channel_buffer_size` (https://github.com/mailgun/kafka-pixy/blob/master/default.yaml#L43)")
+
+	// ErrRebalanceInProgress is returned instead of ErrRequestTimeout if no
+	// message was fetched for a request because partitions of the topic were
+	// being reassigned among consumer group members. The request should be
+	// retried right away.
+	ErrRebalanceInProgress = errors.New("consumer group is rebalancing, retry the request")
 )
 
 type T interface {
@@ -31,15 +49,44 @@ type T interface {
 	//
 	// Note that during state transitions topic subscribe<->unsubscribe and
 	// consumer group register<->deregister the method may return either
-	// `ErrBufferOverflow` or `ErrRequestTimeout` even when there are messages
+	// `ErrTooManyRequests` or `ErrRequestTimeout` even when there are messages
 	// available for consumption. In that case the user should back off a bit
-	// and then repeat the request.
+	// and then repeat the request. If partitions are being reassigned among
+	// group members, then `ErrRebalanceInProgress` is returned instead of
+	// `ErrRequestTimeout`.
 	Consume(group, topic string) (Message, error)
 
 	// AsyncConsume is an asynchronous counterpart of Consume function. It
 	// sends a response down to a buffered channel of the consumer machinery
-	// and returns a channel that a response should be expected from.
-	AsyncConsume(group, topic string) <-chan Response
+	// and returns a channel that a response should be expected from. If
+	// timeout is positive, then it is used instead of
+	// `Config.Consumer.LongPollingTimeout`. If initialOffset is either
+	// sarama.OffsetOldest or sarama.OffsetNewest, then partitions that the
+	// group has no committed offsets for are consumed from that position.
+	AsyncConsume(group, topic string, timeout time.Duration, initialOffset int64) <-chan Response
+
+	// Pause stops delivery of messages from the topic to the consumer group.
+	// While paused, consume requests are replied to with `ErrRequestTimeout`
+	// after their long polling timeout, but the topic subscription does not
+	// expire, so the group membership is kept and no rebalance is triggered.
+	// Since no messages are delivered no offsets are committed either.
+	Pause(group, topic string)
+
+	// Resume undoes Pause.
+	Resume(group, topic string)
+
+	// Metrics returns the registry of consumer group metrics. For every
+	// group it contains:
+	//  * `rebalances-for-group-<group>` counter of rebalances, that is
+	//    periods from a group membership change detected and until
+	//    partitions are reassigned accordingly;
+	//  * `rebalance-duration-ms-for-group-<group>` histogram of rebalance
+	//    durations;
+	//  * `rebalance-triggers-for-group-<group>-reason-<reason>` counters of
+	//    membership changes that triggered rebalances, where <reason> is
+	//    either `join`, `leave` or `subscription`, the latter meaning that a
+	//    member changed the set of topics it consumes.
+	Metrics() metrics.Registry
 
 	// Stop sends a shutdown signal to all internal goroutines and blocks until
 	// they are stopped. It is guaranteed that all last consumed offsets of all
@@ -49,10 +96,17 @@ type T interface {
 
 // Request
 type Request struct {
-	Timestamp  time.Time
-	Group      string
-	Topic      string
-	ResponseCh chan Response
+	Timestamp time.Time
+	Group     string
+	Topic     string
+	// Long polling timeout of the request. If zero, then
+	// `Config.Consumer.LongPollingTimeout` is used.
+	Timeout time.Duration
+	// Either sarama.OffsetOldest or sarama.OffsetNewest to start consuming
+	// partitions that the group has no committed offsets for from. If zero,
+	// then the partitions are consumed from the newest offset.
+	InitialOffset int64
+	ResponseCh    chan Response
 }
 
 // Response defines responses returned upstream by the children.
@@ -69,7 +123,15 @@ type Message struct {
 	Offset        int64
 	Timestamp     time.Time // only set if Kafka is version 0.10+
 	HighWaterMark int64
-	EventsCh      chan<- Event
+	// The last offset committed by the consumer group to the partition at
+	// the time the message was offered, or sarama.OffsetNewest (-1) if
+	// there is none, e.g. when a partition is consumed outside of a group.
+	CommittedOffset int64
+	// Set by the proxy if a message with the same key and value was consumed
+	// by the same group from the same topic shortly before. It is only
+	// a hint, see `consumer.dedup_cache_size`.
+	LikelyDuplicate bool
+	EventsCh        chan<- Event
 }
 
 func NewRequest(group, topic string) Request {
@@ -85,6 +147,14 @@ func Ack(offset int64) Event {
 	return Event{EvAcked, offset}
 }
 
+func Release(offset int64) Event {
+	return Event{EvReleased, offset}
+}
+
+func AckUpTo(offset int64) Event {
+	return Event{EvAckedUpTo, offset}
+}
+
 type Event struct {
 	T      eventType
 	Offset int64
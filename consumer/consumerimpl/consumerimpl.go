@@ -1,15 +1,21 @@
 package consumerimpl
 
 import (
+	"sync"
+	"time"
+
 	"github.com/Shopify/sarama"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/consumer"
 	"github.com/mailgun/kafka-pixy/consumer/dispatcher"
 	"github.com/mailgun/kafka-pixy/consumer/groupcsm"
+	"github.com/mailgun/kafka-pixy/none"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
+	"github.com/mailgun/kafka-pixy/producer"
 	"github.com/mailgun/kazoo-go"
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
 )
 
 // T is a Kafka consumer implementation that automatically maintains consumer
@@ -31,6 +37,20 @@ type t struct {
 	kafkaClt   sarama.Client
 	kazooClt   *kazoo.Kazoo
 	offsetMgrF offsetmgr.Factory
+
+	// Consumer group metrics, see consumer.T.Metrics.
+	metricRegistry metrics.Registry
+
+	// Produces messages to Consumer.DeadLetterTopic if it is configured.
+	deadLetterP *producer.T
+
+	pausedMu sync.RWMutex
+	paused   map[pausedID]none.T
+}
+
+type pausedID struct {
+	group string
+	topic string
 }
 
 // Spawn creates a consumer instance with the specified configuration and
@@ -52,6 +72,14 @@ func Spawn(parentActDesc *actor.Descriptor, cfg *config.Proxy, offsetMgrF offset
 		kafkaClt:   kafkaClt,
 		offsetMgrF: offsetMgrF,
 		kazooClt:   kazooClt,
+		paused:     make(map[pausedID]none.T),
+
+		metricRegistry: metrics.NewRegistry(),
+	}
+	if cfg.Consumer.DeadLetterTopic != "" {
+		if c.deadLetterP, err = producer.Spawn(c.actDesc, cfg); err != nil {
+			return nil, errors.Wrap(err, "failed to spawn dead letter producer")
+		}
 	}
 	c.dispatcher = dispatcher.Spawn(c.actDesc, c, c.cfg)
 	return c, nil
@@ -59,20 +87,51 @@ func Spawn(parentActDesc *actor.Descriptor, cfg *config.Proxy, offsetMgrF offset
 
 // implements `consumer.T`
 func (c *t) Consume(group, topic string) (consumer.Message, error) {
-	rs := <-c.AsyncConsume(group, topic)
+	rs := <-c.AsyncConsume(group, topic, 0, 0)
 	return rs.Msg, rs.Err
 }
 
 // implements `consumer.T`
-func (c *t) AsyncConsume(group, topic string) <-chan consumer.Response {
+func (c *t) AsyncConsume(group, topic string, timeout time.Duration, initialOffset int64) <-chan consumer.Response {
 	rq := consumer.NewRequest(group, topic)
+	rq.Timeout = timeout
+	rq.InitialOffset = initialOffset
 	c.dispatcher.Requests() <- rq
 	return rq.ResponseCh
 }
 
+// implements `consumer.T`
+func (c *t) Pause(group, topic string) {
+	c.pausedMu.Lock()
+	c.paused[pausedID{group, topic}] = none.V
+	c.pausedMu.Unlock()
+}
+
+// implements `consumer.T`
+func (c *t) Resume(group, topic string) {
+	c.pausedMu.Lock()
+	delete(c.paused, pausedID{group, topic})
+	c.pausedMu.Unlock()
+}
+
+func (c *t) isPaused(group, topic string) bool {
+	c.pausedMu.RLock()
+	_, ok := c.paused[pausedID{group, topic}]
+	c.pausedMu.RUnlock()
+	return ok
+}
+
+// implements `consumer.T`
+func (c *t) Metrics() metrics.Registry {
+	return c.metricRegistry
+}
+
 // implements `consumer.T`
 func (c *t) Stop() {
 	c.dispatcher.Stop()
+	if c.deadLetterP != nil {
+		c.deadLetterP.Stop()
+	}
 	c.kazooClt.Close()
 	c.kafkaClt.Close()
 }
@@ -84,7 +143,9 @@ func (c *t) KeyOf(rq consumer.Request) dispatcher.Key {
 
 // implements `dispatcher.Factory`.
 func (c *t) SpawnChild(childSpec dispatcher.ChildSpec) {
-	groupcsm.Spawn(c.actDesc, childSpec, c.cfg, c.kafkaClt, c.kazooClt, c.offsetMgrF)
+	group := string(childSpec.Key())
+	groupcsm.Spawn(c.actDesc, childSpec, c.cfg, c.kafkaClt, c.kazooClt, c.offsetMgrF, c.deadLetterP,
+		func(topic string) bool { return c.isPaused(group, topic) }, c.metricRegistry)
 }
 
 // String returns a string ID of this instance to be used in logs.
@@ -15,6 +15,7 @@ import (
 	"github.com/mailgun/kafka-pixy/offsetmgr"
 	"github.com/mailgun/kafka-pixy/testhelpers"
 	"github.com/mailgun/kafka-pixy/testhelpers/kafkahelper"
+	"github.com/mailgun/kazoo-go"
 	log "github.com/sirupsen/logrus"
 	. "gopkg.in/check.v1"
 )
@@ -203,6 +204,39 @@ func (s *ConsumerSuite) TestMultipleGroups(c *C) {
 	c.Assert(consumed1, DeepEquals, consumed2)
 }
 
+// When a consumer is stopped it leaves all groups it is a member of, so that
+// partitions are reassigned to the remaining members right away.
+func (s *ConsumerSuite) TestLeaveGroupsOnStop(c *C) {
+	// Given
+	s.kh.ResetOffsets("g1", "test.4")
+	s.kh.ResetOffsets("g2", "test.4")
+	s.kh.PutMessages("leave", "test.4", map[string]int{"A": 1, "B": 1, "C": 1})
+
+	kazooClt, err := kazoo.NewKazoo(testhelpers.ZookeeperPeers, kazoo.NewConfig())
+	c.Assert(err, IsNil)
+	defer kazooClt.Close()
+
+	cons, err := Spawn(s.ns, s.cfg, s.omf)
+	c.Assert(err, IsNil)
+	consume(c, cons, "g1", "test.4", 1, 5*time.Second)
+	consume(c, cons, "g2", "test.4", 1, 5*time.Second)
+	for _, group := range []string{"g1", "g2"} {
+		registered, err := kazooClt.Consumergroup(group).Instance(s.cfg.ClientID).Registered()
+		c.Assert(err, IsNil)
+		c.Assert(registered, Equals, true)
+	}
+
+	// When
+	cons.Stop()
+
+	// Then
+	for _, group := range []string{"g1", "g2"} {
+		registered, err := kazooClt.Consumergroup(group).Instance(s.cfg.ClientID).Registered()
+		c.Assert(err, IsNil)
+		c.Assert(registered, Equals, false)
+	}
+}
+
 // When there are more consumers in a group then partitions in a topic then
 // some consumers get assigned no partitions and their consume requests timeout.
 func (s *ConsumerSuite) TestTooFewPartitions(c *C) {
@@ -2,8 +2,11 @@ package groupcsm
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Shopify/sarama"
@@ -16,10 +19,27 @@ import (
 	"github.com/mailgun/kafka-pixy/consumer/partitioncsm"
 	"github.com/mailgun/kafka-pixy/consumer/subscriber"
 	"github.com/mailgun/kafka-pixy/consumer/topiccsm"
+	"github.com/mailgun/kafka-pixy/none"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
 	"github.com/mailgun/kafka-pixy/prettyfmt"
+	"github.com/mailgun/kafka-pixy/producer"
 	"github.com/mailgun/kazoo-go"
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	metricRebalances        = "rebalances"
+	metricRebalanceDuration = "rebalance-duration-ms"
+	metricRebalanceTriggers = "rebalance-triggers"
+
+	metricsReservoirSize = 1028
+	metricsAlphaFactor   = 0.015
+
+	// Reasons of group membership changes that trigger rebalances.
+	reasonJoin         = "join"
+	reasonLeave        = "leave"
+	reasonSubscription = "subscription"
 )
 
 // groupConsumer manages a fleet of topic consumers and disposes of those that
@@ -36,30 +56,50 @@ type T struct {
 	kazooClt    *kazoo.Kazoo
 	msgFetcherF msgfetcher.Factory
 	offsetMgrF  offsetmgr.Factory
+	deadLetterP *producer.T
+	isPausedFn  func(topic string) bool
 	subscriber  *subscriber.T
 	topicCsmCh  chan *topiccsm.T
 	wg          sync.WaitGroup
 
+	// Rebalance metrics, see consumer.T.Metrics.
+	metricRegistry metrics.Registry
+
 	multiplexersMu sync.Mutex
 	multiplexers   map[string]*multiplexer.T
+
+	// Topics that partition consumers were evicted from for being idle.
+	// Multiplexers of these topics are revived on the next consume request.
+	evictedTopicsMu sync.Mutex
+	evictedTopics   map[string]bool
+
+	// It is 1 from the moment a group membership change is detected and
+	// until partitions are reassigned accordingly. Accessed atomically.
+	rebalancing int32
 }
 
 func Spawn(parentActDesc *actor.Descriptor, childSpec dispatcher.ChildSpec,
 	cfg *config.Proxy, kafkaClt sarama.Client, kazooClt *kazoo.Kazoo,
-	offsetMgrF offsetmgr.Factory,
+	offsetMgrF offsetmgr.Factory, deadLetterP *producer.T, isPausedFn func(topic string) bool,
+	metricRegistry metrics.Registry,
 ) *T {
 	group := string(childSpec.Key())
 	actDesc := parentActDesc.NewChild(fmt.Sprintf("%s", group))
 	actDesc.AddLogField("kafka.group", group)
 	gc := &T{
-		actDesc:      actDesc,
-		cfg:          cfg,
-		group:        group,
-		kafkaClt:     kafkaClt,
-		kazooClt:     kazooClt,
-		offsetMgrF:   offsetMgrF,
-		multiplexers: make(map[string]*multiplexer.T),
-		topicCsmCh:   make(chan *topiccsm.T, cfg.Consumer.ChannelBufferSize),
+		actDesc:       actDesc,
+		cfg:           cfg,
+		group:         group,
+		kafkaClt:      kafkaClt,
+		kazooClt:      kazooClt,
+		offsetMgrF:    offsetMgrF,
+		deadLetterP:   deadLetterP,
+		isPausedFn:    isPausedFn,
+		multiplexers:  make(map[string]*multiplexer.T),
+		evictedTopics: make(map[string]bool),
+		topicCsmCh:    make(chan *topiccsm.T, cfg.Consumer.ChannelBufferSize),
+
+		metricRegistry: metricRegistry,
 	}
 
 	gc.subscriber = subscriber.Spawn(gc.actDesc, gc.group, gc.cfg, gc.kazooClt)
@@ -89,7 +129,9 @@ func (gc *T) KeyOf(req consumer.Request) dispatcher.Key {
 func (gc *T) SpawnChild(childSpec dispatcher.ChildSpec) {
 	topic := string(childSpec.Key())
 	topiccsm.Spawn(gc.actDesc, gc.group, childSpec, gc.cfg, gc.topicCsmCh,
-		func() bool { return gc.isSafe2Stop(topic) })
+		func() bool { return gc.isSafe2Stop(topic) }, gc.isRebalancing,
+		func() bool { return gc.isPausedFn(topic) },
+		func() { gc.revive(topic) })
 }
 
 // String return string ID of this group consumer to be posted in logs.
@@ -97,6 +139,10 @@ func (gc *T) String() string {
 	return gc.actDesc.String()
 }
 
+func (gc *T) isRebalancing() bool {
+	return atomic.LoadInt32(&gc.rebalancing) == 1
+}
+
 func (gc *T) isSafe2Stop(topic string) bool {
 	gc.multiplexersMu.Lock()
 	mux := gc.multiplexers[topic]
@@ -107,6 +153,56 @@ func (gc *T) isSafe2Stop(topic string) bool {
 	return mux.IsSafe2Stop()
 }
 
+// runIdleEvictor periodically stops partition consumers that have been idle
+// for `Consumer.PartitionIdleTimeout` until stopCh is closed.
+func (gc *T) runIdleEvictor(stopCh <-chan none.T) {
+	ticker := time.NewTicker(gc.cfg.Consumer.PartitionIdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			gc.evictIdle()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (gc *T) evictIdle() {
+	gc.multiplexersMu.Lock()
+	defer gc.multiplexersMu.Unlock()
+	for topic, mux := range gc.multiplexers {
+		evicted := mux.EvictIdle()
+		if len(evicted) == 0 {
+			continue
+		}
+		gc.actDesc.Log().Infof("Evicted idle partitions: topic=%s, partitions=%v", topic, evicted)
+		gc.evictedTopicsMu.Lock()
+		gc.evictedTopics[topic] = true
+		gc.evictedTopicsMu.Unlock()
+	}
+}
+
+// revive spawns partition consumers of the topic that were evicted for being
+// idle. It is called on every consume request, so it returns right away
+// unless there are evicted partitions of the topic.
+func (gc *T) revive(topic string) {
+	gc.evictedTopicsMu.Lock()
+	evicted := gc.evictedTopics[topic]
+	delete(gc.evictedTopics, topic)
+	gc.evictedTopicsMu.Unlock()
+	if !evicted {
+		return
+	}
+	gc.multiplexersMu.Lock()
+	defer gc.multiplexersMu.Unlock()
+	if mux := gc.multiplexers[topic]; mux != nil {
+		if revived := mux.Revive(); len(revived) > 0 {
+			gc.actDesc.Log().Infof("Revived partitions: topic=%s, partitions=%v", topic, revived)
+		}
+	}
+}
+
 func (gc *T) run() {
 	var (
 		topicConsumers          = make(map[string]*topiccsm.T)
@@ -120,7 +216,15 @@ func (gc *T) run() {
 		rebalanceScheduled      = false
 		stopped                 = false
 		rebalanceResultCh       = make(chan error, 1)
+		rebalanceBeganAt        time.Time
+		prevSubscriptions       map[string][]string
+		idleEvictorStopCh       = make(chan none.T)
 	)
+	if gc.cfg.Consumer.PartitionIdleTimeout > 0 {
+		actor.Spawn(gc.actDesc.NewChild("idle_evictor"), &gc.wg, func() {
+			gc.runIdleEvictor(idleEvictorStopCh)
+		})
+	}
 	for {
 		select {
 		case tc := <-gc.topicCsmCh:
@@ -149,10 +253,21 @@ func (gc *T) run() {
 				stopped = true
 				continue
 			}
+			gc.countRebalanceTriggers(prevSubscriptions, subscriptions)
+			prevSubscriptions = subscriptions
 			rebalanceRequired = true
+			if atomic.SwapInt32(&gc.rebalancing, 1) == 0 {
+				rebalanceBeganAt = time.Now()
+				metrics.GetOrRegisterCounter(gc.metricName(metricRebalances), gc.metricRegistry).Inc(1)
+			}
 
 		case err := <-rebalanceResultCh:
 			rebalancePending = false
+			if err == nil && !rebalanceRequired {
+				atomic.StoreInt32(&gc.rebalancing, 0)
+				took := time.Now().Sub(rebalanceBeganAt)
+				gc.getOrRegisterHistogram(gc.metricName(metricRebalanceDuration)).Update(int64(took / time.Millisecond))
+			}
 			if err != nil {
 				gc.actDesc.Log().WithError(err).Error("rebalancing failed")
 				if stopped {
@@ -185,6 +300,7 @@ func (gc *T) run() {
 		}
 	}
 done:
+	close(idleEvictorStopCh)
 	var wg sync.WaitGroup
 	gc.multiplexersMu.Lock()
 	for _, mux := range gc.multiplexers {
@@ -225,8 +341,13 @@ func (gc *T) rebalance(actDesc *actor.Descriptor, topicConsumers map[string]*top
 		}
 		topic := topic
 		spawnInFn := func(partition int32) multiplexer.In {
+			initialOffset := tc.InitialOffset()
+			if initialOffset == 0 {
+				initialOffset = int64(gc.cfg.Consumer.OffsetReset[topic])
+			}
 			return partitioncsm.Spawn(gc.actDesc, gc.group, topic, partition,
-				gc.cfg, gc.subscriber, gc.msgFetcherF, gc.offsetMgrF)
+				gc.cfg, gc.subscriber, gc.msgFetcherF, gc.offsetMgrF, gc.deadLetterP,
+				initialOffset)
 		}
 		mux = multiplexer.New(gc.actDesc, spawnInFn)
 		gc.rewireMuxAsync(topic, &wg, mux, tc, assignedTopicPartitions)
@@ -325,6 +446,58 @@ func assignTopicPartitions(partitions []int32, subscribers []string) map[string]
 	return subscribersToPartitions
 }
 
+// countRebalanceTriggers updates rebalance trigger counters with reasons of
+// the difference between the previous and the current group subscriptions.
+func (gc *T) countRebalanceTriggers(prev, curr map[string][]string) {
+	for _, reason := range rebalanceReasons(prev, curr) {
+		name := fmt.Sprintf("%s-reason-%s", gc.metricName(metricRebalanceTriggers), reason)
+		metrics.GetOrRegisterCounter(name, gc.metricRegistry).Inc(1)
+	}
+}
+
+// metricName returns a group specific metric name following the naming
+// convention of sarama.
+func (gc *T) metricName(name string) string {
+	return fmt.Sprintf("%s-for-group-%s", name, strings.Replace(gc.group, ".", "_", -1))
+}
+
+func (gc *T) getOrRegisterHistogram(name string) metrics.Histogram {
+	return gc.metricRegistry.GetOrRegister(name, func() metrics.Histogram {
+		return metrics.NewHistogram(metrics.NewExpDecaySample(metricsReservoirSize, metricsAlphaFactor))
+	}).(metrics.Histogram)
+}
+
+// rebalanceReasons returns sorted reasons of the difference between two
+// subscriptions of a group, that is member ID to topics maps: some members
+// joined, some left, or some changed their subscribed topics.
+func rebalanceReasons(prev, curr map[string][]string) []string {
+	var reasons []string
+	joined, changed := false, false
+	for memberID, topics := range curr {
+		prevTopics, ok := prev[memberID]
+		if !ok {
+			joined = true
+			continue
+		}
+		if !reflect.DeepEqual(prevTopics, topics) {
+			changed = true
+		}
+	}
+	if joined {
+		reasons = append(reasons, reasonJoin)
+	}
+	for memberID := range prev {
+		if _, ok := curr[memberID]; !ok {
+			reasons = append(reasons, reasonLeave)
+			break
+		}
+	}
+	if changed {
+		reasons = append(reasons, reasonSubscription)
+	}
+	return reasons
+}
+
 func listTopics(topicConsumers map[string]*topiccsm.T) []string {
 	topics := make([]string, 0, len(topicConsumers))
 	for topic := range topicConsumers {
@@ -7,6 +7,7 @@ import (
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/testhelpers"
+	"github.com/rcrowley/go-metrics"
 	. "gopkg.in/check.v1"
 )
 
@@ -155,3 +156,39 @@ func (s *GroupConsumerSuite) TestResolvePartitionsError(c *C) {
 	c.Assert(err.Error(), Equals, "failed to get partition list, topic=t1: Kaboom!")
 	c.Assert(topicsToPartitions, IsNil)
 }
+
+// Members that joined, left or changed their topics are reported as reasons.
+func (s *GroupConsumerSuite) TestRebalanceReasons(c *C) {
+	for i, tc := range []struct {
+		prev    map[string][]string
+		curr    map[string][]string
+		reasons []string
+	}{
+		{prev: nil, curr: map[string][]string{"a": {"t1"}}, reasons: []string{"join"}},
+		{prev: map[string][]string{"a": {"t1"}, "b": {"t1"}}, curr: map[string][]string{"a": {"t1"}}, reasons: []string{"leave"}},
+		{prev: map[string][]string{"a": {"t1"}}, curr: map[string][]string{"a": {"t1", "t2"}}, reasons: []string{"subscription"}},
+		{prev: map[string][]string{"a": {"t1"}, "b": {"t1"}}, curr: map[string][]string{"a": {"t2"}, "c": {"t1"}},
+			reasons: []string{"join", "leave", "subscription"}},
+		{prev: map[string][]string{"a": {"t1"}}, curr: map[string][]string{"a": {"t1"}}, reasons: nil},
+	} {
+		c.Assert(rebalanceReasons(tc.prev, tc.curr), DeepEquals, tc.reasons, Commentf("case #%d", i))
+	}
+}
+
+// Rebalance triggers are counted per group and reason.
+func (s *GroupConsumerSuite) TestCountRebalanceTriggers(c *C) {
+	gc := T{group: "foo.bar", metricRegistry: metrics.NewRegistry()}
+
+	// When
+	gc.countRebalanceTriggers(nil, map[string][]string{"a": {"t1"}})
+	gc.countRebalanceTriggers(map[string][]string{"a": {"t1"}}, map[string][]string{"a": {"t1"}, "b": {"t1"}})
+	gc.countRebalanceTriggers(map[string][]string{"a": {"t1"}, "b": {"t1"}}, map[string][]string{"b": {"t1"}})
+
+	// Then
+	counter := func(name string) int64 {
+		return gc.metricRegistry.Get(name).(metrics.Counter).Count()
+	}
+	c.Assert(counter("rebalance-triggers-for-group-foo_bar-reason-join"), Equals, int64(2))
+	c.Assert(counter("rebalance-triggers-for-group-foo_bar-reason-leave"), Equals, int64(1))
+	c.Assert(gc.metricRegistry.Get("rebalance-triggers-for-group-foo_bar-reason-subscription"), IsNil)
+}
@@ -110,7 +110,7 @@ func (f *factory) Spawn(parentActDesc *actor.Descriptor, topic string, partition
 		f:            f,
 		id:           id,
 		assignmentCh: make(chan mapper.Executor, 1),
-		messagesCh:   make(chan consumer.Message, f.cfg.Consumer.ChannelBufferSize),
+		messagesCh:   make(chan consumer.Message, f.cfg.Consumer.PrefetchSize),
 		stopCh:       make(chan none.T, 1),
 		offset:       realOffset,
 	}
@@ -460,7 +460,7 @@ func (be *brokerExecutor) runExecutor() {
 		}
 		// Make a batch fetch request for all hungry message streams.
 		req := &sarama.FetchRequest{
-			MinBytes:    1,
+			MinBytes:    int32(be.cfg.Consumer.FetchMinBytes),
 			MaxWaitTime: int32(be.cfg.Consumer.FetchMaxWait / time.Millisecond),
 		}
 		if be.cfg.Kafka.Version.IsAtLeast(sarama.V0_10_0_0) {
@@ -18,6 +18,7 @@ type T struct {
 	actDesc   *actor.Descriptor
 	spawnInFn SpawnInFn
 	inputs    map[int32]*input
+	evicted   map[int32]bool
 	output    Out
 	isRunning bool
 	stopCh    chan none.T
@@ -37,6 +38,10 @@ type In interface {
 	// loss nor duplication of messages for the clients, false otherwise.
 	IsSafe2Stop() bool
 
+	// IsIdle returns true if the input has been inactive long enough to be
+	// stopped until it is needed again. An idle input must be safe to stop.
+	IsIdle() bool
+
 	// Stop signals the input to stop and blocks waiting for its goroutines to
 	// complete.
 	Stop()
@@ -57,6 +62,7 @@ func New(parentActDesc *actor.Descriptor, spawnInFn SpawnInFn) *T {
 	return &T{
 		actDesc:   parentActDesc.NewChild("mux"),
 		inputs:    make(map[int32]*input),
+		evicted:   make(map[int32]bool),
 		spawnInFn: spawnInFn,
 		stopCh:    make(chan none.T),
 	}
@@ -102,6 +108,10 @@ func (m *T) IsSafe2Stop() bool {
 func (m *T) WireUp(output Out, assigned []int32) {
 	var wg sync.WaitGroup
 
+	// Inputs of evicted partitions that are still assigned are spawned below
+	// along with newly assigned ones.
+	m.evicted = make(map[int32]bool)
+
 	if m.output != output {
 		m.stopIfRunning()
 		m.output = output
@@ -147,6 +157,68 @@ func (m *T) WireUp(output Out, assigned []int32) {
 	wg.Wait()
 }
 
+// EvictIdle stops inputs that report being idle, and returns their
+// partitions. The partitions are still considered to be assigned, and their
+// inputs are spawned again by Revive or by WireUp.
+func (m *T) EvictIdle() []int32 {
+	hasIdle := false
+	for _, in := range m.inputs {
+		if in.IsIdle() {
+			hasIdle = true
+			break
+		}
+	}
+	if !hasIdle {
+		return nil
+	}
+	// Stop the multiplexer first to make sure that no message is pulled from
+	// inputs while they are checked again and stopped.
+	m.stopIfRunning()
+	var (
+		wg      sync.WaitGroup
+		evicted []int32
+	)
+	for p, in := range m.inputs {
+		if !in.IsIdle() {
+			continue
+		}
+		wg.Add(1)
+		go func(in *input) {
+			defer wg.Done()
+			in.Stop()
+		}(in)
+		delete(m.inputs, p)
+		m.evicted[p] = true
+		evicted = append(evicted, p)
+	}
+	m.refreshSortedIns()
+	if len(m.inputs) > 0 {
+		m.start()
+	}
+	wg.Wait()
+	sort.Slice(evicted, func(i, j int) bool { return evicted[i] < evicted[j] })
+	return evicted
+}
+
+// Revive spawns inputs for partitions evicted by EvictIdle, and returns the
+// partitions.
+func (m *T) Revive() []int32 {
+	if len(m.evicted) == 0 || m.output == nil {
+		return nil
+	}
+	m.stopIfRunning()
+	revived := make([]int32, 0, len(m.evicted))
+	for p := range m.evicted {
+		m.inputs[p] = &input{In: m.spawnInFn(p), partition: p}
+		revived = append(revived, p)
+	}
+	m.evicted = make(map[int32]bool)
+	m.refreshSortedIns()
+	m.start()
+	sort.Slice(revived, func(i, j int) bool { return revived[i] < revived[j] })
+	return revived
+}
+
 // Stop synchronously stops the multiplexer.
 func (m *T) Stop() {
 	m.WireUp(nil, nil)
@@ -580,9 +580,86 @@ func (s *MultiplexerSuite) TestIsSafe2Stop(c *C) {
 	}
 }
 
+// Idle inputs are stopped by EvictIdle and spawned again by Revive, while
+// other inputs keep being multiplexed.
+func (s *MultiplexerSuite) TestEvictIdleAndRevive(c *C) {
+	spawned := make(map[int32][]*mockIn)
+	spawnInFn := func(p int32) In {
+		in := newSafeMockIn(msg(int64(p)*1000+int64(len(spawned[p]))+1, 1))
+		spawned[p] = append(spawned[p], in)
+		return in
+	}
+	out := newMockOut(0)
+	m := New(s.ns, spawnInFn)
+	m.WireUp(out, []int32{1, 2, 3})
+	defer m.Stop()
+	checkMsg(c, out.messagesCh, msg(1001, 1))
+	checkMsg(c, out.messagesCh, msg(2001, 1))
+	checkMsg(c, out.messagesCh, msg(3001, 1))
+	spawned[1][0].idle = true
+	spawned[3][0].idle = true
+
+	// When
+	evicted := m.EvictIdle()
+
+	// Then
+	c.Assert(evicted, DeepEquals, []int32{1, 3})
+	c.Assert(spawned[1][0].stopped, Equals, true)
+	c.Assert(spawned[2][0].stopped, Equals, false)
+	c.Assert(spawned[3][0].stopped, Equals, true)
+	c.Assert(m.IsRunning(), Equals, true)
+	c.Assert(m.EvictIdle(), IsNil)
+
+	// When
+	revived := m.Revive()
+
+	// Then
+	c.Assert(revived, DeepEquals, []int32{1, 3})
+	c.Assert(len(spawned[1]), Equals, 2)
+	c.Assert(len(spawned[2]), Equals, 1)
+	c.Assert(len(spawned[3]), Equals, 2)
+	checkMsg(c, out.messagesCh, msg(1002, 1))
+	checkMsg(c, out.messagesCh, msg(3002, 1))
+	c.Assert(m.Revive(), IsNil)
+}
+
+// If all inputs are evicted the multiplexer stops, and WireUp spawns inputs
+// of evicted partitions that are still assigned.
+func (s *MultiplexerSuite) TestEvictIdleAll(c *C) {
+	spawnCount := 0
+	ins := make(map[int32]*mockIn)
+	spawnInFn := func(p int32) In {
+		spawnCount++
+		ins[p] = newSafeMockIn()
+		ins[p].idle = true
+		return ins[p]
+	}
+	out := newMockOut(0)
+	m := New(s.ns, spawnInFn)
+	m.WireUp(out, []int32{1, 2})
+	defer m.Stop()
+
+	// When
+	evicted := m.EvictIdle()
+
+	// Then
+	c.Assert(evicted, DeepEquals, []int32{1, 2})
+	c.Assert(m.IsRunning(), Equals, false)
+
+	// When
+	m.WireUp(out, []int32{2})
+
+	// Then
+	c.Assert(m.IsRunning(), Equals, true)
+	c.Assert(spawnCount, Equals, 3)
+	c.Assert(m.Revive(), IsNil)
+}
+
 type mockIn struct {
 	messagesCh chan consumer.Message
 	safe2Stop  bool
+	idle       bool
+	stopped    bool
 }
 
 func newMockIn(messages ...consumer.Message) *mockIn {
@@ -611,8 +688,14 @@ func (mi *mockIn) IsSafe2Stop() bool {
 	return mi.safe2Stop
 }
 
+// implements `In`
+func (mi *mockIn) IsIdle() bool {
+	return mi.idle
+}
+
 // implements `In`
 func (mi *mockIn) Stop() {
+	mi.stopped = true
 }
 
 type mockOut struct {
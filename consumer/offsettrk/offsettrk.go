@@ -41,6 +41,10 @@ type T struct {
 	offset       offsetmgr.Offset
 	ackedRanges  []offsetRange
 	offers       []offer
+
+	// Offsets of offered messages released by consumers, that should be
+	// retried ahead of offers with expired deadlines.
+	released []int64
 }
 
 // SparseAcks2Str returns human readable representation of sparsely committed
@@ -136,6 +140,54 @@ func (ot *T) OnAcked(offset int64) (offsetmgr.Offset, int) {
 	return ot.offset, len(ot.offers)
 }
 
+// OnAckedUpTo should be called when a consumer acknowledges all messages up
+// to and including the specified offset at once. Offers of those messages are
+// removed and the tracked offset is moved past the specified one. An offset
+// beyond the highest offered one is clamped to it, so that messages that have
+// never been offered are not skipped. It returns an offset to be submitted
+// and a total number of offered messages.
+func (ot *T) OnAckedUpTo(offset int64) (offsetmgr.Offset, int) {
+	if maxOffered := ot.maxOffered(); offset > maxOffered {
+		ot.actDesc.Log().Errorf("Bad ack up to: offset=%d, maxOffered=%d", offset, maxOffered)
+		offset = maxOffered
+	}
+	drop := sort.Search(len(ot.offers), func(i int) bool {
+		return ot.offers[i].offset > offset
+	})
+	ot.trimOffers(drop)
+	if offset >= ot.offset.Val {
+		ot.correctOffset(offset + 1)
+	}
+	return ot.offset, len(ot.offers)
+}
+
+// maxOffered returns the highest offset among messages that are offered or
+// acknowledged. If there are none, then it returns the offset preceding the
+// tracked one.
+func (ot *T) maxOffered() int64 {
+	maxOffered := ot.offset.Val - 1
+	if count := len(ot.ackedRanges); count > 0 && ot.ackedRanges[count-1].to-1 > maxOffered {
+		maxOffered = ot.ackedRanges[count-1].to - 1
+	}
+	if count := len(ot.offers); count > 0 && ot.offers[count-1].offset > maxOffered {
+		maxOffered = ot.offers[count-1].offset
+	}
+	return maxOffered
+}
+
+// OnReleased should be called when a consumer gives up on an offered message
+// without acknowledging it, e.g. because it disconnected. The message is then
+// returned by the next NextRetry call regardless of its offer deadline. It
+// returns false if there is no offer with the specified offset.
+func (ot *T) OnReleased(offset int64) bool {
+	if ot.findOffer(offset) == nil {
+		ot.actDesc.Log().Errorf("Bad release: offset=%d", offset)
+		return false
+	}
+	ot.released = append(ot.released, offset)
+	return true
+}
+
 // IsAcked checks if an offset has already been acknowledged. The second
 // returned value is the smallest not acked offset that is greater than the
 // specified offset.
@@ -163,6 +215,16 @@ func (ot *T) NextRetry() (consumer.Message, int, bool) {
 	return ot.nextRetry(time.Now())
 }
 func (ot *T) nextRetry(now time.Time) (consumer.Message, int, bool) {
+	// Released offers may have been acknowledged since, then they are gone.
+	for len(ot.released) > 0 {
+		o := ot.findOffer(ot.released[0])
+		ot.released = ot.released[1:]
+		if o != nil {
+			o.deadline = now.Add(ot.offerTimeout)
+			o.retryNo += 1
+			return o.msg, o.retryNo, true
+		}
+	}
 	for i := range ot.offers {
 		o := &ot.offers[i]
 		if o.deadline.Before(now) {
@@ -215,6 +277,18 @@ func (ot *T) newOffer(msg consumer.Message) offer {
 	return offer{msg, msg.Offset, 0, time.Now().Add(ot.offerTimeout)}
 }
 
+// findOffer returns an offer with the specified offset, or nil if there is no
+// such offer.
+func (ot *T) findOffer(offset int64) *offer {
+	i := sort.Search(len(ot.offers), func(i int) bool {
+		return ot.offers[i].msg.Offset >= offset
+	})
+	if i >= len(ot.offers) || ot.offers[i].msg.Offset != offset {
+		return nil
+	}
+	return &ot.offers[i]
+}
+
 // removeOffer if there is an offer with the specified offset in the list, then
 // it is removed and true is returned, otherwise it returns false.
 func (ot *T) removeOffer(offset int64) bool {
@@ -242,6 +316,12 @@ func (ot *T) dropOffersBefore(offset int64) {
 		drop = i + 1
 		ot.actDesc.Log().Errorf("Offer dropped: offset=%d", offer.offset)
 	}
+	ot.trimOffers(drop)
+}
+
+// trimOffers removes the specified number of offers from the beginning of the
+// offer list.
+func (ot *T) trimOffers(drop int) {
 	if drop > 0 {
 		left := len(ot.offers) - drop
 		copy(ot.offers[:left], ot.offers[drop:])
@@ -337,6 +417,11 @@ func encodeAckedRanges(base int64, ackedRanges []offsetRange) string {
 }
 
 func decodeAckedRanges(base int64, encoded string) ([]offsetRange, error) {
+	// Offsets committed by admin operations may wrap sparse acks committed
+	// by consumers before, e.g. when they are imported from another group.
+	if stamp, ok := offsetmgr.DecodeAdminStamp(encoded); ok {
+		encoded = stamp.Meta
+	}
 	if encoded == "" {
 		return nil, nil
 	}
@@ -210,6 +210,20 @@ func (s *OffsetTrkSuite) TestNew(c *C) {
 	}
 }
 
+// Sparse acks wrapped in an admin stamp are decoded.
+func (s *OffsetTrkSuite) TestNewAdminStamp(c *C) {
+	meta := encodeAckedRanges(301, []offsetRange{{302, 305}, {307, 309}})
+	stamp := offsetmgr.AdminStamp{Meta: meta, CommittedBy: "foo", CommittedAt: time.Now()}
+	offset := offsetmgr.Offset{Val: 301, Meta: stamp.Encode()}
+
+	// When
+	ot := New(s.ns, offset, -1)
+
+	// Then
+	c.Assert(ot.ackedRanges, DeepEquals, []offsetRange{{302, 305}, {307, 309}})
+	c.Assert(SparseAcks2Str(offset), Equals, "1-4,6-8")
+}
+
 func (s *OffsetTrkSuite) TestIsAcked(c *C) {
 	meta := encodeAckedRanges(301, []offsetRange{
 		{302, 305}, {307, 309}, {310, 313}})
@@ -360,6 +374,79 @@ func (s *OffsetTrkSuite) TestNextRetry(c *C) {
 	}
 }
 
+// Acking up to an offset acks all messages up to and including it, whether
+// they were acked individually before or not, but not beyond the highest
+// offered one.
+func (s *OffsetTrkSuite) TestOnAckedUpTo(c *C) {
+	for i, tc := range []struct {
+		upTo    int64
+		offset  int64
+		ranges  string
+		offered int
+	}{
+		0: {upTo: 299, offset: 300, ranges: "2-4,5-7", offered: 4},
+		1: {upTo: 300, offset: 301, ranges: "1-3,4-6", offered: 3},
+		2: {upTo: 302, offset: 304, ranges: "1-3", offered: 2},
+		3: {upTo: 305, offset: 307, ranges: "", offered: 1},
+		4: {upTo: 307, offset: 308, ranges: "", offered: 0},
+		// Messages that have never been offered are not acked.
+		5: {upTo: 310, offset: 308, ranges: "", offered: 0},
+	} {
+		ot := New(s.ns, offsetmgr.Offset{Val: 300}, -1)
+		for j, acked := range []int{0, 0, 1, 1, 0, 1, 1, 0} {
+			offset := int64(300 + j)
+			ot.OnOffered(consumer.Message{Offset: offset})
+			if acked == 1 {
+				ot.OnAcked(offset)
+			}
+		}
+		c.Assert(SparseAcks2Str(ot.offset), Equals, "2-4,5-7")
+
+		// When
+		offset, offered := ot.OnAckedUpTo(tc.upTo)
+
+		// Then
+		c.Assert(offset.Val, Equals, tc.offset, Commentf("case #%d", i))
+		c.Assert(SparseAcks2Str(ot.offset), Equals, tc.ranges, Commentf("case #%d", i))
+		c.Assert(offered, Equals, tc.offered, Commentf("case #%d", i))
+	}
+	ot := New(s.ns, offsetmgr.Offset{Val: 300}, -1)
+	offset, offered := ot.OnAckedUpTo(310)
+	c.Assert(offset.Val, Equals, int64(300))
+	c.Assert(offered, Equals, 0)
+}
+
+// Released offers are retried right away ahead of expired ones, unless they
+// have been acknowledged since.
+func (s *OffsetTrkSuite) TestNextRetryReleased(c *C) {
+	ot := New(s.ns, offsetmgr.Offset{Val: 300}, 5*time.Second)
+	begin := time.Now()
+	for _, msg := range []consumer.Message{{Offset: 300}, {Offset: 301}, {Offset: 302}} {
+		ot.OnOffered(msg)
+	}
+	ot.offers[0].deadline = begin.Add(5 * time.Second)
+	ot.offers[1].deadline = begin.Add(7 * time.Second)
+	ot.offers[2].deadline = begin.Add(9 * time.Second)
+
+	// When
+	c.Assert(ot.OnReleased(302), Equals, true)
+	c.Assert(ot.OnReleased(301), Equals, true)
+	c.Assert(ot.OnReleased(303), Equals, false)
+	ot.OnAcked(301)
+
+	// Then
+	msg, retryCount, ok := ot.nextRetry(begin)
+	c.Assert(ok, Equals, true)
+	c.Assert(msg.Offset, Equals, int64(302))
+	c.Assert(retryCount, Equals, 1)
+	_, _, ok = ot.nextRetry(begin)
+	c.Assert(ok, Equals, false)
+	msg, retryCount, ok = ot.nextRetry(begin.Add(5001 * time.Millisecond))
+	c.Assert(ok, Equals, true)
+	c.Assert(msg.Offset, Equals, int64(300))
+	c.Assert(retryCount, Equals, 1)
+}
+
 func (s *OffsetTrkSuite) TestMaxOfferTimeout(c *C) {
 	ot := New(s.ns, offsetmgr.Offset{Val: 300}, -1)
 	msgs := []consumer.Message{
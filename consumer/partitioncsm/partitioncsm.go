@@ -2,11 +2,13 @@ package partitioncsm
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/consumer"
@@ -15,6 +17,7 @@ import (
 	"github.com/mailgun/kafka-pixy/consumer/subscriber"
 	"github.com/mailgun/kafka-pixy/none"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
+	"github.com/mailgun/kafka-pixy/producer"
 	"github.com/pkg/errors"
 )
 
@@ -43,11 +46,19 @@ type T struct {
 	groupMember *subscriber.T
 	msgFetcherF msgfetcher.Factory
 	offsetMgrF  offsetmgr.Factory
+	deadLetterP *producer.T
+	initOffset  int64
 	messagesCh  chan consumer.Message
 	eventsCh    chan consumer.Event
 	stopCh      chan none.T
+	doneCh      chan none.T
 	wg          sync.WaitGroup
 
+	// Offsets of messages being produced to the dead letter topic, and a
+	// channel that their produce results are reported to.
+	deadLetters  map[int64]none.T
+	deadLetterCh chan deadLetterResult
+
 	offsetMgr       offsetmgr.T
 	committedOffset offsetmgr.Offset
 	submittedOffset offsetmgr.Offset
@@ -55,13 +66,43 @@ type T struct {
 	offsetTrk       *offsettrk.T
 	offerCount      int32
 
+	// Time, in nanoseconds since epoch, when a message was last delivered
+	// or acknowledged. Accessed atomically.
+	lastActiveAt int64
+
 	// For tests only!
 	firstMsgFetched bool
 }
 
-// Spawn creates a partition consumer instance and starts its goroutines.
+// DeadLetter is the value of messages produced to `Consumer.DeadLetterTopic`.
+// It is encoded as a JSON object, where the original message key and value
+// are base64 encoded. Dead letters are produced with the original message
+// key.
+type DeadLetter struct {
+	Group     string `json:"group"`
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Retries   int    `json:"retries"`
+	Key       []byte `json:"key"`
+	Value     []byte `json:"value"`
+}
+
+type deadLetterResult struct {
+	offset  int64
+	prodMsg *sarama.ProducerMessage
+	err     error
+}
+
+// Spawn creates a partition consumer instance and starts its goroutines. If
+// deadLetterP is not nil, then it is used to produce messages that exceeded
+// the number of retries to `Consumer.DeadLetterTopic`. If the group has no
+// committed offset for the partition and initialOffset is either
+// sarama.OffsetOldest or sarama.OffsetNewest, then the partition is consumed
+// from that position.
 func Spawn(parentActDesc *actor.Descriptor, group, topic string, partition int32, cfg *config.Proxy,
 	groupMember *subscriber.T, msgFetcherF msgfetcher.Factory, offsetMgrF offsetmgr.Factory,
+	deadLetterP *producer.T, initialOffset int64,
 ) *T {
 	actDesc := parentActDesc.NewChild(fmt.Sprintf("%s.p%d", topic, partition))
 	actDesc.AddLogField("kafka.group", group)
@@ -76,10 +117,18 @@ func Spawn(parentActDesc *actor.Descriptor, group, topic string, partition int32
 		groupMember: groupMember,
 		msgFetcherF: msgFetcherF,
 		offsetMgrF:  offsetMgrF,
+		deadLetterP: deadLetterP,
+		initOffset:  initialOffset,
 		messagesCh:  make(chan consumer.Message, 1),
 		eventsCh:    make(chan consumer.Event, 1),
 		stopCh:      make(chan none.T),
+		doneCh:      make(chan none.T),
 	}
+	if deadLetterP != nil {
+		pc.deadLetters = make(map[int64]none.T)
+		pc.deadLetterCh = make(chan deadLetterResult)
+	}
+	pc.touch()
 	actor.Spawn(pc.actDesc, &pc.wg, pc.run)
 	return pc
 }
@@ -99,6 +148,16 @@ func (pc *T) IsSafe2Stop() bool {
 	return atomic.LoadInt32(&pc.offerCount) == 0
 }
 
+// implements `multiplexer.In`
+func (pc *T) IsIdle() bool {
+	idleTimeout := pc.cfg.Consumer.PartitionIdleTimeout
+	if idleTimeout <= 0 || !pc.IsSafe2Stop() {
+		return false
+	}
+	sinceLastActive := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&pc.lastActiveAt))
+	return sinceLastActive >= idleTimeout
+}
+
 // implements `multiplexer.In`
 func (pc *T) Stop() {
 	close(pc.stopCh)
@@ -106,6 +165,7 @@ func (pc *T) Stop() {
 }
 
 func (pc *T) run() {
+	defer close(pc.doneCh)
 	defer close(pc.messagesCh)
 	defer pc.groupMember.ClaimPartition(pc.actDesc, pc.topic, pc.partition, pc.stopCh)()
 
@@ -121,6 +181,10 @@ func (pc *T) run() {
 	case <-pc.stopCh:
 		return
 	}
+	// Kafka reports sarama.OffsetNewest if there is no committed offset.
+	if pc.committedOffset.Val == sarama.OffsetNewest && pc.initOffset != 0 {
+		pc.committedOffset.Val = pc.initOffset
+	}
 	pc.actDesc.Log().Infof("Initial offset: %s", offsetRepr(pc.committedOffset))
 	pc.offsetTrk = offsettrk.New(pc.actDesc, pc.committedOffset, pc.cfg.Consumer.AckTimeout)
 	pc.submittedOffset = pc.committedOffset
@@ -135,12 +199,19 @@ func (pc *T) run() {
 	for timeout := pc.offsetTrk.ShouldWait4Ack(); timeout > 0; timeout = pc.offsetTrk.ShouldWait4Ack() {
 		select {
 		case event := <-pc.eventsCh:
-			if event.T == consumer.EvAcked {
-				var offerCount int
+			var offerCount int
+			switch event.T {
+			case consumer.EvAcked:
 				pc.submittedOffset, offerCount = pc.offsetTrk.OnAcked(event.Offset)
-				atomic.StoreInt32(&pc.offerCount, int32(offerCount))
-				pc.offsetMgr.SubmitOffset(pc.submittedOffset)
+			case consumer.EvAckedUpTo:
+				pc.submittedOffset, offerCount = pc.offsetTrk.OnAckedUpTo(event.Offset)
+			default:
+				continue
 			}
+			atomic.StoreInt32(&pc.offerCount, int32(offerCount))
+			pc.offsetMgr.SubmitOffset(pc.submittedOffset)
+		case dlr := <-pc.deadLetterCh:
+			pc.onDeadLetterProduced(dlr)
 		case <-time.After(timeout):
 			continue
 		}
@@ -176,6 +247,9 @@ func (pc *T) runFetchLoop() bool {
 	)
 	defer retryTicker.Stop()
 	for {
+		// Offered messages carry the committed offset as of the moment
+		// they are offered.
+		msg.CommittedOffset = pc.committedOffset.Val
 		select {
 		case msg, msgOk = <-nilOrMsgInCh:
 			// If the fetcher terminated due to failure, then quit the fetch
@@ -204,8 +278,10 @@ func (pc *T) runFetchLoop() bool {
 			}
 		case nilOrMsgOutCh <- msg:
 			nilOrMsgOutCh = nil
+			pc.touch()
 
 		case event := <-pc.eventsCh:
+			pc.touch()
 			switch event.T {
 			case consumer.EvOffered:
 				if !msgOk || event.Offset != msg.Offset {
@@ -225,13 +301,35 @@ func (pc *T) runFetchLoop() bool {
 				}
 				nilOrMsgInCh = mf.Messages()
 
-			case consumer.EvAcked:
-				pc.submittedOffset, offerCount = pc.offsetTrk.OnAcked(event.Offset)
+			case consumer.EvAcked, consumer.EvAckedUpTo:
+				if event.T == consumer.EvAcked {
+					pc.submittedOffset, offerCount = pc.offsetTrk.OnAcked(event.Offset)
+				} else {
+					pc.submittedOffset, offerCount = pc.offsetTrk.OnAckedUpTo(event.Offset)
+				}
 				atomic.StoreInt32(&pc.offerCount, int32(offerCount))
 				pc.offsetMgr.SubmitOffset(pc.submittedOffset)
 				if !msgOk && offerCount <= pc.cfg.Consumer.MaxPendingMessages {
 					nilOrMsgInCh = mf.Messages()
 				}
+
+			case consumer.EvReleased:
+				// If a message is pending, then the released one is retried
+				// as soon as the pending one is offered.
+				if pc.offsetTrk.OnReleased(event.Offset) && !msgOk {
+					if msg, msgOk = pc.nextRetry(); msgOk {
+						nilOrMsgInCh = nil
+						nilOrMsgOutCh = pc.messagesCh
+					}
+				}
+			}
+		case dlr := <-pc.deadLetterCh:
+			if !pc.onDeadLetterProduced(dlr) {
+				continue
+			}
+			offerCount = int(atomic.LoadInt32(&pc.offerCount))
+			if !msgOk && offerCount <= pc.cfg.Consumer.MaxPendingMessages {
+				nilOrMsgInCh = mf.Messages()
 			}
 		case pc.committedOffset = <-pc.offsetMgr.CommittedOffsets():
 		case <-pc.stopCh:
@@ -240,19 +338,32 @@ func (pc *T) runFetchLoop() bool {
 	}
 }
 
+// touch records that the partition consumer is active now.
+func (pc *T) touch() {
+	atomic.StoreInt64(&pc.lastActiveAt, time.Now().UnixNano())
+}
+
 // nextRetry checks with the offset tracker if there is a message ready to be
 // retried. If it gets a message that has already been retried maxRetries times,
 // then it acks the message and asks the offset tracker for another one. It
 // continues doing that until either a message with less then maxRetries is
 // returned or there are no more messages to be retried.
+//
+// If a dead letter producer is configured, then a message is produced to the
+// dead letter topic asynchronously, and it is acked only when the produce
+// result is reported to `deadLetterCh`. If producing fails then the message
+// is left unacked to be given another try when it expires again.
 func (pc *T) nextRetry() (consumer.Message, bool) {
 	msg, retryNo, ok := pc.offsetTrk.NextRetry()
 	for ok && pc.cfg.Consumer.MaxRetries >= 0 && retryNo > pc.cfg.Consumer.MaxRetries {
 		pc.actDesc.Log().Errorf("Too many retries: retryNo=%d, offset=%d, key=%s, msg=%s",
 			retryNo, msg.Offset, string(msg.Key), base64.StdEncoding.EncodeToString(msg.Value))
-		pc.submittedOffset, _ = pc.offsetTrk.OnAcked(msg.Offset)
-		pc.offsetMgr.SubmitOffset(pc.submittedOffset)
-		// TODO: Dump expired messages to a long term storage?
+		if pc.deadLetterP != nil {
+			pc.produceDeadLetter(msg, retryNo)
+		} else {
+			pc.submittedOffset, _ = pc.offsetTrk.OnAcked(msg.Offset)
+			pc.offsetMgr.SubmitOffset(pc.submittedOffset)
+		}
 		msg, retryNo, ok = pc.offsetTrk.NextRetry()
 	}
 	if ok {
@@ -262,6 +373,62 @@ func (pc *T) nextRetry() (consumer.Message, bool) {
 	return msg, ok
 }
 
+// produceDeadLetter starts producing a message wrapped in a DeadLetter to the
+// dead letter topic. The produce result is reported to `deadLetterCh`, unless
+// the partition consumer is done by then. A message that is already being
+// produced is skipped.
+func (pc *T) produceDeadLetter(msg consumer.Message, retryNo int) {
+	if _, ok := pc.deadLetters[msg.Offset]; ok {
+		return
+	}
+	pc.deadLetters[msg.Offset] = none.V
+	var key sarama.Encoder
+	if msg.Key != nil {
+		key = sarama.ByteEncoder(msg.Key)
+	}
+	// Marshaling of a struct of strings, numbers and byte slices never fails.
+	value, _ := json.Marshal(DeadLetter{
+		Group:     pc.group,
+		Topic:     pc.topic,
+		Partition: pc.partition,
+		Offset:    msg.Offset,
+		Retries:   retryNo - 1,
+		Key:       msg.Key,
+		Value:     msg.Value,
+	})
+	responseCh := pc.deadLetterP.AsyncProduce(pc.cfg.Consumer.DeadLetterTopic, key, sarama.ByteEncoder(value))
+	go func() {
+		rs := <-responseCh
+		select {
+		case pc.deadLetterCh <- deadLetterResult{offset: msg.Offset, prodMsg: rs.Msg, err: rs.Err}:
+		case <-pc.doneCh:
+		}
+	}()
+}
+
+// onDeadLetterProduced acks a message that has been produced to the dead
+// letter topic. If producing failed, then the message is left unacked to be
+// given another try when it expires again. It returns true if the message
+// has been acked.
+func (pc *T) onDeadLetterProduced(dlr deadLetterResult) bool {
+	delete(pc.deadLetters, dlr.offset)
+	if dlr.err != nil {
+		pc.actDesc.Log().WithError(dlr.err).Errorf("Failed to produce dead letter: offset=%d", dlr.offset)
+		return false
+	}
+	pc.actDesc.Log().Infof("Dead letter produced: offset=%d, dlPartition=%d, dlOffset=%d",
+		dlr.offset, dlr.prodMsg.Partition, dlr.prodMsg.Offset)
+	// The message could have been acked by a client in the meantime.
+	if ok, _ := pc.offsetTrk.IsAcked(dlr.offset); ok {
+		return false
+	}
+	var offerCount int
+	pc.submittedOffset, offerCount = pc.offsetTrk.OnAcked(dlr.offset)
+	atomic.StoreInt32(&pc.offerCount, int32(offerCount))
+	pc.offsetMgr.SubmitOffset(pc.submittedOffset)
+	return true
+}
+
 func (pc *T) stopOffsetMgr() {
 	pc.offsetMgr.Stop()
 	if !pc.offsetsOk {
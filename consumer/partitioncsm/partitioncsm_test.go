@@ -1,6 +1,7 @@
 package partitioncsm
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/mailgun/kafka-pixy/consumer/offsettrk"
 	"github.com/mailgun/kafka-pixy/consumer/subscriber"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
+	"github.com/mailgun/kafka-pixy/producer"
 	"github.com/mailgun/kafka-pixy/testhelpers"
 	"github.com/mailgun/kafka-pixy/testhelpers/kafkahelper"
 	log "github.com/sirupsen/logrus"
@@ -74,7 +76,7 @@ func (s *PartitionCsmSuite) TestOldestOffset(c *C) {
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{sarama.OffsetOldest, ""}})
 	offsets := s.kh.GetCommittedOffsets(group, topic)
 	c.Assert(offsets[partition], Equals, offsetmgr.Offset{sarama.OffsetOldest, ""})
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	// When
 	<-pc.Messages()
@@ -94,7 +96,7 @@ func (s *PartitionCsmSuite) TestInitialOffsetTooLarge(c *C) {
 	newestOffsets := s.kh.GetNewestOffsets(topic)
 	log.Infof("*** test.1 offsets: oldest=%v, newest=%v", oldestOffsets, newestOffsets)
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{newestOffsets[partition] + 3, ""}})
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 	// Wait for the partition consumer to initialize.
 	initialOffset := <-s.initOffsetCh
@@ -112,11 +114,27 @@ func (s *PartitionCsmSuite) TestInitialOffsetTooLarge(c *C) {
 	c.Assert(msg.Offset, Equals, initialOffset.Val)
 }
 
+// Messages carry the offset committed by the group to the partition.
+func (s *PartitionCsmSuite) TestCommittedOffset(c *C) {
+	newestOffsets := s.kh.GetNewestOffsets(topic)
+	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{newestOffsets[partition], ""}})
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
+	defer pc.Stop()
+
+	// When
+	messages := s.kh.PutMessages("pc", topic, map[string]int{"": 1})
+	msg := <-pc.Messages()
+
+	// Then
+	c.Assert(msg.Offset, Equals, messages[""][0].Offset)
+	c.Assert(msg.CommittedOffset, Equals, newestOffsets[partition])
+}
+
 // A new message becomes available in the Messages() channel only after the
 // previous one is reported as offered.
 func (s *PartitionCsmSuite) TestMustBeOfferedToProceed(c *C) {
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{sarama.OffsetOldest, ""}})
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 
 	// When
@@ -159,7 +177,7 @@ func (s *PartitionCsmSuite) TestSparseAckedNotRead(c *C) {
 	c.Assert(offsettrk.SparseAcks2Str(initOffset), Equals, "1-4,6-7")
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{initOffset})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 
 	// When/Then: only messages that has not been acked previously are returned.
@@ -181,7 +199,7 @@ func (s *PartitionCsmSuite) TestSparseAckedNotRead(c *C) {
 // Messages() channel is ignored.
 func (s *PartitionCsmSuite) TestOfferInvalid(c *C) {
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{sarama.OffsetOldest, ""}})
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 
 	msg, ok := <-pc.Messages()
@@ -205,7 +223,7 @@ func (s *PartitionCsmSuite) TestOfferedTooMany(c *C) {
 	s.cfg.Consumer.AckTimeout = 500 * time.Millisecond
 	s.cfg.Consumer.MaxPendingMessages = 3
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{sarama.OffsetOldest, ""}})
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 	var msg consumer.Message
 
@@ -260,7 +278,7 @@ func (s *PartitionCsmSuite) TestSparseAckedCommitted(c *C) {
 	}
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	// When
 	for _, shouldAck := range acks {
@@ -286,7 +304,7 @@ func (s *PartitionCsmSuite) TestSparseAckedAfterStop(c *C) {
 	s.cfg.Consumer.AckTimeout = 300 * time.Millisecond
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	var messages []consumer.Message
 	for i := 0; i < 10; i++ {
@@ -332,7 +350,7 @@ func (s *PartitionCsmSuite) TestZeroRetries(c *C) {
 	s.cfg.Consumer.MaxRetries = 0
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	msg0 := <-pc.Messages()
 	log.Infof("*** First: offset=%v", msg0.Offset)
@@ -356,6 +374,61 @@ func (s *PartitionCsmSuite) TestZeroRetries(c *C) {
 	c.Assert(offsettrk.SparseAcks2Str(offsetsAfter[partition]), Equals, "")
 }
 
+// If a dead letter producer is given, then messages that exceeded the max
+// retries limit are produced to the dead letter topic before they are acked.
+// Dead letters identify the source of the message they wrap.
+func (s *PartitionCsmSuite) TestDeadLetter(c *C) {
+	offsetsBefore := s.kh.GetOldestOffsets(topic)
+	s.cfg.Consumer.AckTimeout = 100 * time.Millisecond
+	s.cfg.Consumer.MaxRetries = 0
+	s.cfg.Consumer.DeadLetterTopic = "test.4"
+	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
+	deadLetterP, err := producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer deadLetterP.Stop()
+	dlOffsetsBefore := s.kh.GetNewestOffsets("test.4")
+
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, deadLetterP, 0)
+
+	msg0 := <-pc.Messages()
+	sendEvOffered(msg0)
+
+	// Wait for the retry timeout to expire.
+	time.Sleep(200 * time.Millisecond)
+
+	// When
+	msg := <-pc.Messages()
+	c.Assert(msg.Offset, Equals, msg0.Offset+1)
+	sendEvOffered(msg)
+	sendEvAcked(msg)
+	pc.Stop()
+
+	// Then
+	offsetsAfter := s.kh.GetCommittedOffsets(group, topic)
+	c.Assert(offsetsAfter[partition].Val, Equals, offsetsBefore[partition]+2)
+	dlOffsetsAfter := s.kh.GetNewestOffsets("test.4")
+	dlCount := int64(0)
+	for i := range dlOffsetsAfter {
+		dlCount += dlOffsetsAfter[i] - dlOffsetsBefore[i]
+	}
+	c.Assert(dlCount, Equals, int64(1))
+	var dl DeadLetter
+	for _, values := range s.kh.GetMessages("test.4", dlOffsetsBefore, dlOffsetsAfter) {
+		for _, value := range values {
+			c.Assert(json.Unmarshal([]byte(value), &dl), IsNil)
+		}
+	}
+	c.Assert(dl, DeepEquals, DeadLetter{
+		Group:     group,
+		Topic:     topic,
+		Partition: partition,
+		Offset:    msg0.Offset,
+		Retries:   0,
+		Key:       msg0.Key,
+		Value:     msg0.Value,
+	})
+}
+
 // If the max retries limit is set to -1 then retries never stop.
 func (s *PartitionCsmSuite) TestIndefiniteRetries(c *C) {
 	offsetsBefore := s.kh.GetOldestOffsets(topic)
@@ -363,7 +436,7 @@ func (s *PartitionCsmSuite) TestIndefiniteRetries(c *C) {
 	s.cfg.Consumer.MaxRetries = -1
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	msg0 := <-pc.Messages()
 	sendEvOffered(msg0)
@@ -400,7 +473,7 @@ func (s *PartitionCsmSuite) TestMaxRetriesReached(c *C) {
 	s.cfg.Consumer.MaxRetries = 3
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	var messages []consumer.Message
 	for i := 0; i < 3; i++ {
@@ -457,7 +530,7 @@ func (s *PartitionCsmSuite) TestSeveralMessageReties(c *C) {
 	s.cfg.Consumer.AckTimeout = 100 * time.Millisecond
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: sarama.OffsetOldest}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 
 	// Read and confirm offered several messages, but do not ack them.
@@ -488,7 +561,7 @@ func (s *PartitionCsmSuite) TestRetryNoMoreMessages(c *C) {
 	s.cfg.Consumer.MaxRetries = 3
 	s.kh.SetOffsets(group, topic, []offsetmgr.Offset{{Val: offsetBefore}})
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, s.msgFetcherF, s.offsetMgrF, nil, 0)
 
 	// Read and confirm offer of 4 messages
 	var messages []consumer.Message
@@ -552,7 +625,7 @@ func (s *PartitionCsmSuite) TestFetcherDeath(c *C) {
 	msgFetcherF := msgfetcher.SpawnFactory(s.ns, s.cfg, kafkaClt)
 	defer msgFetcherF.Stop()
 
-	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, msgFetcherF, s.offsetMgrF)
+	pc := Spawn(s.ns, group, topic, partition, s.cfg, s.groupMember, msgFetcherF, s.offsetMgrF, nil, 0)
 	defer pc.Stop()
 
 	// When/Then
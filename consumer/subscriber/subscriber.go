@@ -131,7 +131,9 @@ func (ss *T) ClaimPartition(claimerActDesc *actor.Descriptor, topic string, part
 }
 
 // Stop signals the consumer group member to stop and blocks until its
-// goroutines are over.
+// goroutines are over. The member leaves the group before Stop returns, so
+// that other members of the group rebalance immediately rather than waiting
+// for the ZooKeeper session of this one to expire.
 func (ss *T) Stop() {
 	close(ss.stopCh)
 	ss.wg.Wait()
@@ -161,6 +163,7 @@ func (ss *T) run() {
 			<-time.After(ss.cfg.Consumer.RetryBackoff)
 			err = ss.groupMemberZNode.Deregister()
 		}
+		ss.actDesc.Log().Info("Left group")
 	}()
 
 	var (
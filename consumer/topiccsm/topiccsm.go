@@ -3,6 +3,7 @@ package topiccsm
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mailgun/holster/clock"
@@ -14,6 +15,7 @@ import (
 
 var (
 	requestTimeoutRs         = consumer.Response{Err: consumer.ErrRequestTimeout}
+	rebalanceInProgressRs    = consumer.Response{Err: consumer.ErrRebalanceInProgress}
 	safe2StopPollingInterval = 100 * time.Millisecond
 )
 
@@ -25,35 +27,57 @@ var (
 // * there has been no requests for max value of Consumer.SubscriptionTimeout
 //   and Consumer.AckTimeout
 //
+// If no message is available for a request, then it is replied to with either
+// consumer.ErrRequestTimeout or with consumer.ErrRebalanceInProgress if
+// isRebalancingFn returns true at that time.
+//
+// While isPausedFn returns true no messages are offered, requests are replied
+// to with consumer.ErrRequestTimeout once they expire, and the subscription
+// does not expire even if no requests are coming.
+//
+// Before a request is served reviveFn is called to spawn partition consumers
+// that were evicted for being idle, if any.
+//
 // implements `multiplexer.Out`.
 type T struct {
-	actDesc       *actor.Descriptor
-	childSpec     dispatcher.ChildSpec
-	cfg           *config.Proxy
-	group         string
-	topic         string
-	lifespanCh    chan<- *T
-	isSafe2StopFn func() bool
-	messagesCh    chan consumer.Message
-	wg            sync.WaitGroup
+	actDesc         *actor.Descriptor
+	childSpec       dispatcher.ChildSpec
+	cfg             *config.Proxy
+	group           string
+	topic           string
+	lifespanCh      chan<- *T
+	isSafe2StopFn   func() bool
+	isRebalancingFn func() bool
+	isPausedFn      func() bool
+	reviveFn        func()
+	messagesCh      chan consumer.Message
+	wg              sync.WaitGroup
+
+	// Initial offset requested by the latest consume request that specified
+	// one. Accessed atomically.
+	initialOffset int64
 }
 
 // Spawn creates and starts a topic consumer instance.
 func Spawn(parentActDesc *actor.Descriptor, group string, childSpec dispatcher.ChildSpec,
-	cfg *config.Proxy, lifespanCh chan<- *T, isSafe2StopFn func() bool,
+	cfg *config.Proxy, lifespanCh chan<- *T, isSafe2StopFn, isRebalancingFn, isPausedFn func() bool,
+	reviveFn func(),
 ) *T {
 	topic := string(childSpec.Key())
 	actDesc := parentActDesc.NewChild(fmt.Sprintf("%s", topic))
 	actDesc.AddLogField("kafka.group", group)
 	actDesc.AddLogField("kafka.topic", topic)
 	tc := T{
-		actDesc:       actDesc,
-		childSpec:     childSpec,
-		cfg:           cfg,
-		group:         group,
-		topic:         topic,
-		lifespanCh:    lifespanCh,
-		isSafe2StopFn: isSafe2StopFn,
+		actDesc:         actDesc,
+		childSpec:       childSpec,
+		cfg:             cfg,
+		group:           group,
+		topic:           topic,
+		lifespanCh:      lifespanCh,
+		isSafe2StopFn:   isSafe2StopFn,
+		isRebalancingFn: isRebalancingFn,
+		isPausedFn:      isPausedFn,
+		reviveFn:        reviveFn,
 
 		// Messages channel must be non-buffered. Otherwise we might end up
 		// buffering a message from a partition that no longer belongs to this
@@ -74,6 +98,13 @@ func (tc *T) Messages() chan<- consumer.Message {
 	return tc.messagesCh
 }
 
+// InitialOffset returns the initial offset requested by the latest consume
+// request that specified one, or zero if there has been no such request.
+// Partition consumers start from it if the group has no committed offsets.
+func (tc *T) InitialOffset() int64 {
+	return atomic.LoadInt64(&tc.initialOffset)
+}
+
 func (tc *T) run() {
 	defer tc.childSpec.Dispose()
 	tc.lifespanCh <- tc
@@ -96,6 +127,11 @@ func (tc *T) run() {
 				}
 				latestRqTime = tc.serveRequest(consumeRq)
 			case <-expireTimer.C():
+				// A paused subscription is kept alive as if it was receiving
+				// requests, so that pausing does not trigger a rebalance.
+				if tc.isPausedFn() {
+					latestRqTime = clock.Now().UTC()
+				}
 				sinceLatestRq := clock.Now().UTC().Sub(latestRqTime)
 				subscriptionTTL := tc.cfg.Consumer.SubscriptionTimeout - sinceLatestRq
 				if subscriptionTTL <= 0 {
@@ -140,23 +176,45 @@ func (tc *T) String() string {
 }
 
 func (tc *T) serveRequest(consumeRq consumer.Request) time.Time {
+	if consumeRq.InitialOffset != 0 {
+		atomic.StoreInt64(&tc.initialOffset, consumeRq.InitialOffset)
+	}
 	latestRqTime := clock.Now().UTC()
 	requestAge := latestRqTime.Sub(consumeRq.Timestamp)
-	requestTTL := tc.cfg.Consumer.LongPollingTimeout - requestAge
+	timeout := tc.cfg.Consumer.LongPollingTimeout
+	if consumeRq.Timeout > 0 {
+		timeout = consumeRq.Timeout
+	}
+	requestTTL := timeout - requestAge
 	// The request has been waiting in the buffer for too long. If we
 	// reply with a fetched message, then there is a good chance that the
 	// client won't receive it due to the client HTTP timeout. Therefore
 	// we reject the request to avoid message loss.
 	if requestTTL <= 0 {
+		consumeRq.ResponseCh <- tc.noMessageRs()
+		return latestRqTime
+	}
+	if tc.isPausedFn() {
+		<-clock.After(requestTTL)
 		consumeRq.ResponseCh <- requestTimeoutRs
 		return latestRqTime
 	}
+	tc.reviveFn()
 	select {
 	case msg := <-tc.messagesCh:
 		msg.EventsCh <- consumer.Event{consumer.EvOffered, msg.Offset}
 		consumeRq.ResponseCh <- consumer.Response{Msg: msg}
 	case <-clock.After(requestTTL):
-		consumeRq.ResponseCh <- requestTimeoutRs
+		consumeRq.ResponseCh <- tc.noMessageRs()
 	}
 	return latestRqTime
 }
+
+// noMessageRs returns a response to a request that no message is available
+// for.
+func (tc *T) noMessageRs() consumer.Response {
+	if tc.isRebalancingFn() {
+		return rebalanceInProgressRs
+	}
+	return requestTimeoutRs
+}
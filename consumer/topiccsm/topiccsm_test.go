@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/mailgun/holster/clock"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
@@ -30,9 +31,12 @@ type TopicCsmSuite struct {
 	childSpec  dispatcher.ChildSpec
 	lifespanCh chan *T
 
-	mu         sync.Mutex
-	safe2Stop  bool
-	checkCount int
+	mu          sync.Mutex
+	safe2Stop   bool
+	checkCount  int
+	rebalancing bool
+	paused      bool
+	reviveCount int
 }
 
 var _ = Suite(&TopicCsmSuite{})
@@ -53,7 +57,10 @@ func (s *TopicCsmSuite) SetUpTest(c *C) {
 	s.lifespanCh = make(chan *T, 2)
 
 	s.safe2Stop = true
+	s.reviveCount = 0
 	s.checkCount = 0
+	s.rebalancing = false
+	s.paused = false
 }
 
 func (s *TopicCsmSuite) TearDownTest(c *C) {
@@ -73,10 +80,46 @@ func (s *TopicCsmSuite) setSafe2Stop(safe2Stop bool) {
 	s.safe2Stop = safe2Stop
 }
 
+func (s *TopicCsmSuite) isRebalancing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rebalancing
+}
+
+func (s *TopicCsmSuite) setRebalancing(rebalancing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rebalancing = rebalancing
+}
+
+func (s *TopicCsmSuite) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+func (s *TopicCsmSuite) revive() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reviveCount++
+}
+
+func (s *TopicCsmSuite) getReviveCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reviveCount
+}
+
+func (s *TopicCsmSuite) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
 // Requests are processed in first come first served fashion. When a message is
 // is send in response to a requests it is also reported as Offered downstream.
 func (s *TopicCsmSuite) TestRequestResponse(c *C) {
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 	defer func() {
 		close(s.requestsCh) // Signal to stop.
@@ -106,6 +149,8 @@ func (s *TopicCsmSuite) TestRequestResponse(c *C) {
 		c.Assert(<-eventsChs[i], DeepEquals,
 			consumer.Event{consumer.EvOffered, messages[i].Offset})
 	}
+	// Evicted partition consumers are revived before each request is served.
+	c.Assert(s.getReviveCount(), Equals, 10)
 }
 
 // If request has been waiting for a message longer than
@@ -113,7 +158,7 @@ func (s *TopicCsmSuite) TestRequestResponse(c *C) {
 func (s *TopicCsmSuite) TestLongPollingExpires(c *C) {
 	s.cfg.Consumer.LongPollingTimeout = 300
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 	defer func() {
 		close(s.requestsCh) // Signal to stop.
@@ -145,11 +190,79 @@ func (s *TopicCsmSuite) TestLongPollingExpires(c *C) {
 	assertResponse(c, rq3, consumer.Response{Msg: msg2}, time.Second)
 }
 
+// If a request expires while the group is rebalancing, then it is rejected with
+// ErrRebalanceInProgress rather than with ErrRequestTimeout.
+func (s *TopicCsmSuite) TestLongPollingExpiresRebalancing(c *C) {
+	s.cfg.Consumer.LongPollingTimeout = 300
+
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
+	c.Assert(<-s.lifespanCh, Equals, tc)
+	defer func() {
+		close(s.requestsCh) // Signal to stop.
+		<-s.lifespanCh      // Wait for it to do so.
+	}()
+
+	rq1 := newRequest() // expires at 300
+	c.Assert(clock.Advance(1), Equals, time.Duration(1))
+	rq2 := newRequest() // expires at 301
+	s.requestsCh <- rq1
+	s.requestsCh <- rq2
+
+	// When
+	s.setRebalancing(true)
+	c.Assert(clock.Advance(299), Equals, time.Duration(300))
+
+	// Then
+	assertResponse(c, rq1, rebalanceInProgressRs, time.Second)
+
+	// When
+	s.setRebalancing(false)
+	c.Assert(clock.Advance(1), Equals, time.Duration(301))
+
+	// Then
+	assertResponse(c, rq2, requestTimeoutRs, time.Second)
+}
+
+// If a request specifies a timeout, then it is used instead of
+// Consumer.LongPollingTimeout.
+func (s *TopicCsmSuite) TestLongPollingTimeoutOverride(c *C) {
+	s.cfg.Consumer.LongPollingTimeout = 300
+
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
+	c.Assert(<-s.lifespanCh, Equals, tc)
+	defer func() {
+		close(s.requestsCh) // Signal to stop.
+		<-s.lifespanCh      // Wait for it to do so.
+	}()
+
+	rq1 := newRequest()
+	rq1.Timeout = 100 // expires at 100
+	rq2 := newRequest()
+	rq2.Timeout = 500 // expires at 500
+	msg, _ := newMessage(42)
+
+	s.requestsCh <- rq1
+	s.requestsCh <- rq2
+
+	// When
+	c.Assert(clock.Advance(100), Equals, time.Duration(100))
+
+	// Then
+	assertResponse(c, rq1, requestTimeoutRs, time.Second)
+
+	// When: rq2 outlives the configured timeout.
+	c.Assert(clock.Advance(399), Equals, time.Duration(499))
+	tc.Messages() <- msg
+
+	// Then
+	assertResponse(c, rq2, consumer.Response{Msg: msg}, time.Second)
+}
+
 // Stale requests are rejected immediately.
 func (s *TopicCsmSuite) TestStaleRequest(c *C) {
 	s.cfg.Consumer.LongPollingTimeout = 300
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 	defer func() {
 		close(s.requestsCh) // Signal to stop.
@@ -172,7 +285,7 @@ func (s *TopicCsmSuite) TestSubscriptionExpires(c *C) {
 	s.cfg.Consumer.SubscriptionTimeout = 500
 	s.cfg.Consumer.AckTimeout = 300
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 
 	c.Assert(clock.Advance(499), Equals, time.Duration(499))
@@ -189,6 +302,66 @@ func (s *TopicCsmSuite) TestSubscriptionExpires(c *C) {
 	assertStopped(c, s.lifespanCh, time.Second)
 }
 
+// While paused, requests are not offered messages and expire with
+// ErrRequestTimeout. Once resumed messages are offered again.
+func (s *TopicCsmSuite) TestPaused(c *C) {
+	s.cfg.Consumer.LongPollingTimeout = 300
+
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
+	c.Assert(<-s.lifespanCh, Equals, tc)
+	defer func() {
+		close(s.requestsCh) // Signal to stop.
+		<-s.lifespanCh      // Wait for it to do so.
+	}()
+	msg, eventsCh := newMessage(42)
+	go func() {
+		tc.Messages() <- msg
+	}()
+	s.setPaused(true)
+
+	// When
+	rq1 := newRequest()
+	s.requestsCh <- rq1
+	time.Sleep(50 * time.Millisecond)
+	c.Assert(clock.Advance(300), Equals, time.Duration(300))
+
+	// Then
+	assertResponse(c, rq1, requestTimeoutRs, time.Second)
+
+	// When
+	s.setPaused(false)
+	rq2 := newRequest()
+	s.requestsCh <- rq2
+
+	// Then
+	assertResponse(c, rq2, consumer.Response{Msg: msg}, time.Second)
+	c.Assert(<-eventsCh, Equals, consumer.Event{consumer.EvOffered, msg.Offset})
+}
+
+// A paused subscription does not expire even though no requests are coming.
+func (s *TopicCsmSuite) TestPausedSubscriptionKept(c *C) {
+	s.cfg.Consumer.SubscriptionTimeout = 500
+	s.cfg.Consumer.AckTimeout = 300
+
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
+	c.Assert(<-s.lifespanCh, Equals, tc)
+	s.setPaused(true)
+
+	// When
+	c.Assert(clock.Advance(500), Equals, time.Duration(500))
+	c.Assert(clock.Advance(500), Equals, time.Duration(1000))
+
+	// Then
+	assertRunning(c, s.lifespanCh, 50*time.Millisecond)
+
+	// When
+	s.setPaused(false)
+	c.Assert(clock.Advance(500), Equals, time.Duration(1500))
+
+	// Then
+	assertStopped(c, s.lifespanCh, time.Second)
+}
+
 // If there has been no requests for SubscriptionTimeout, but it is not safe to
 // stop then the topic consumer waits until AckTimeout expires as well before
 // terminating.
@@ -198,7 +371,7 @@ func (s *TopicCsmSuite) TestAckTimeoutExpires(c *C) {
 	s.setSafe2Stop(false)
 	safe2StopPollingInterval = 5
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 
 	// When/Then
@@ -224,7 +397,7 @@ func (s *TopicCsmSuite) TestAckTimeoutRequest(c *C) {
 	s.setSafe2Stop(false)
 	safe2StopPollingInterval = 5
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 
 	c.Assert(clock.Advance(500), Equals, time.Duration(500))
@@ -255,7 +428,7 @@ func (s *TopicCsmSuite) TestAckTimeoutStop(c *C) {
 	s.setSafe2Stop(false)
 	safe2StopPollingInterval = 5
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 
 	c.Assert(clock.Advance(500), Equals, time.Duration(500))
@@ -278,7 +451,7 @@ func (s *TopicCsmSuite) TestAckTimeoutSafe(c *C) {
 	s.setSafe2Stop(false)
 	safe2StopPollingInterval = 5
 
-	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop)
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
 	c.Assert(<-s.lifespanCh, Equals, tc)
 
 	c.Assert(clock.Advance(500), Equals, time.Duration(500))
@@ -296,6 +469,37 @@ func (s *TopicCsmSuite) TestAckTimeoutSafe(c *C) {
 	assertStopped(c, s.lifespanCh, time.Second)
 }
 
+// The initial offset specified by a request is remembered, and requests that
+// do not specify one do not reset it.
+func (s *TopicCsmSuite) TestInitialOffset(c *C) {
+	tc := Spawn(s.ns, group, s.childSpec, s.cfg, s.lifespanCh, s.isSafe2Stop, s.isRebalancing, s.isPaused, s.revive)
+	c.Assert(<-s.lifespanCh, Equals, tc)
+	defer func() {
+		close(s.requestsCh) // Signal to stop.
+		<-s.lifespanCh      // Wait for it to do so.
+	}()
+	c.Assert(tc.InitialOffset(), Equals, int64(0))
+
+	// When
+	rq1 := newRequest()
+	rq1.InitialOffset = sarama.OffsetOldest
+	s.requestsCh <- rq1
+	msg1, eventsCh1 := newMessage(1)
+	tc.Messages() <- msg1
+	<-eventsCh1
+	assertResponse(c, rq1, consumer.Response{Msg: msg1}, 100*time.Millisecond)
+
+	rq2 := newRequest()
+	s.requestsCh <- rq2
+	msg2, eventsCh2 := newMessage(2)
+	tc.Messages() <- msg2
+	<-eventsCh2
+	assertResponse(c, rq2, consumer.Response{Msg: msg2}, 100*time.Millisecond)
+
+	// Then
+	c.Assert(tc.InitialOffset(), Equals, sarama.OffsetOldest)
+}
+
 func newRequest() consumer.Request {
 	return consumer.Request{
 		Timestamp:  clock.Now().UTC(),
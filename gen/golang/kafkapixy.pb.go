@@ -12,11 +12,15 @@ It has these top-level messages:
 	ProdRs
 	ConsNAckRq
 	ConsRs
+	ConsStreamRq
 	AckRq
 	AckRs
 	PartitionOffset
 	GetOffsetsRq
 	GetOffsetsRs
+	GetTopicOffsetsRq
+	PartitionOffsetRange
+	GetTopicOffsetsRs
 	PartitionMetadata
 	GetTopicMetadataRq
 	GetTopicMetadataRs
@@ -26,6 +30,11 @@ It has these top-level messages:
 	ConsumerPartitions
 	ConsumerGroups
 	ListConsumersRs
+	ListGroupsRq
+	ListGroupsRs
+	DescribeGroupRq
+	GroupMember
+	DescribeGroupRs
 	SetOffsetsRq
 	SetOffsetsRs
 */
@@ -77,6 +86,29 @@ type ProdRq struct {
 	//  * wait_for_all:   the response is returned after all in-sync replicas
 	//                    have data committed to disk.
 	AsyncMode bool `protobuf:"varint,6,opt,name=async_mode,json=asyncMode" json:"async_mode,omitempty"`
+	// Overrides producer.required_acks for this particular message. It can be
+	// one of no_response, wait_for_local, wait_for_all. By default the value
+	// from the config is used. It is ignored if async_mode is true.
+	RequiredAcks string `protobuf:"bytes,7,opt,name=required_acks,json=requiredAcks" json:"required_acks,omitempty"`
+	// Overrides producer.compression for this particular message. It can be
+	// one of none, gzip, snappy, lz4. By default the value from the config is
+	// used. It is ignored if async_mode is true.
+	Compression string `protobuf:"bytes,8,opt,name=compression" json:"compression,omitempty"`
+	// If not zero, then the message is stored in Kafka with this timestamp in
+	// milliseconds since epoch instead of the current time. It requires
+	// kafka.version 0.10.0.0 or later and is only kept by Kafka if the topic
+	// has message.timestamp.type=CreateTime, that is the default. It is
+	// ignored if async_mode is true.
+	TimestampMs int64 `protobuf:"varint,9,opt,name=timestamp_ms,json=timestampMs" json:"timestamp_ms,omitempty"`
+	// Logical ID of the service that produces the message. If not empty,
+	// then produce latency and errors are additionally reported to client
+	// specific metrics, and produce failures are logged with it.
+	ClientId string `protobuf:"bytes,10,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
+	// If positive, then it overrides producer.retry_max for this particular
+	// message. It can only lower the configured number, larger values are
+	// clamped to it. Zero means the value from the config is used, and
+	// negative values are rejected. It is ignored if async_mode is true.
+	RetryMax int32 `protobuf:"varint,11,opt,name=retry_max,json=retryMax" json:"retry_max,omitempty"`
 }
 
 func (m *ProdRq) Reset()                    { *m = ProdRq{} }
@@ -126,6 +158,41 @@ func (m *ProdRq) GetAsyncMode() bool {
 	return false
 }
 
+func (m *ProdRq) GetRequiredAcks() string {
+	if m != nil {
+		return m.RequiredAcks
+	}
+	return ""
+}
+
+func (m *ProdRq) GetCompression() string {
+	if m != nil {
+		return m.Compression
+	}
+	return ""
+}
+
+func (m *ProdRq) GetTimestampMs() int64 {
+	if m != nil {
+		return m.TimestampMs
+	}
+	return 0
+}
+
+func (m *ProdRq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *ProdRq) GetRetryMax() int32 {
+	if m != nil {
+		return m.RetryMax
+	}
+	return 0
+}
+
 type ProdRs struct {
 	// Partition the message was written to. The value only makes sense if
 	// ProdReq.async_mode was false.
@@ -133,6 +200,19 @@ type ProdRs struct {
 	// Offset the message was written to. The value only makes sense if
 	// ProdReq.async_mode was false.
 	Offset int64 `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	// Acknowledgement level that was satisfied when the message was written,
+	// one of no_response, wait_for_local, wait_for_all. It is empty if
+	// ProdReq.async_mode was true.
+	RequiredAcks string `protobuf:"bytes,3,opt,name=required_acks,json=requiredAcks" json:"required_acks,omitempty"`
+	// Size of the message in the Kafka message format before compression.
+	// Kafka compresses messages in batches, so the compressed size of an
+	// individual message is not known. It is zero if ProdReq.async_mode was
+	// true.
+	SerializedSize int32 `protobuf:"varint,4,opt,name=serialized_size,json=serializedSize" json:"serialized_size,omitempty"`
+	// Compression codec that the batch containing the message was compressed
+	// with, one of none, gzip, snappy, lz4. It is empty if ProdReq.async_mode
+	// was true.
+	Compression string `protobuf:"bytes,5,opt,name=compression" json:"compression,omitempty"`
 }
 
 func (m *ProdRs) Reset()                    { *m = ProdRs{} }
@@ -154,6 +234,27 @@ func (m *ProdRs) GetOffset() int64 {
 	return 0
 }
 
+func (m *ProdRs) GetRequiredAcks() string {
+	if m != nil {
+		return m.RequiredAcks
+	}
+	return ""
+}
+
+func (m *ProdRs) GetSerializedSize() int32 {
+	if m != nil {
+		return m.SerializedSize
+	}
+	return 0
+}
+
+func (m *ProdRs) GetCompression() string {
+	if m != nil {
+		return m.Compression
+	}
+	return ""
+}
+
 type ConsNAckRq struct {
 	// Name of a Kafka cluster to operate on.
 	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
@@ -171,6 +272,17 @@ type ConsNAckRq struct {
 	// should be acknowledged by the request.
 	AckPartition int32 `protobuf:"varint,6,opt,name=ack_partition,json=ackPartition" json:"ack_partition,omitempty"`
 	AckOffset    int64 `protobuf:"varint,7,opt,name=ack_offset,json=ackOffset" json:"ack_offset,omitempty"`
+	// If positive then it overrides consumer.long_polling_timeout for this
+	// particular request. It is clamped to consumer.max_long_polling_timeout.
+	LongPollingTimeoutMs int64 `protobuf:"varint,8,opt,name=long_polling_timeout_ms,json=longPollingTimeoutMs" json:"long_polling_timeout_ms,omitempty"`
+	// Either `earliest` or `latest`. Determines where to start consuming
+	// partitions that the group has no committed offsets for, that is
+	// normally the case with a new group. If empty, then such partitions are
+	// consumed from the latest offset.
+	InitialOffset string `protobuf:"bytes,9,opt,name=initial_offset,json=initialOffset" json:"initial_offset,omitempty"`
+	// Logical ID of the service that consumes. If not empty, then the request
+	// is accounted for in client specific consume metrics.
+	ClientId string `protobuf:"bytes,10,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
 }
 
 func (m *ConsNAckRq) Reset()                    { *m = ConsNAckRq{} }
@@ -227,6 +339,27 @@ func (m *ConsNAckRq) GetAckOffset() int64 {
 	return 0
 }
 
+func (m *ConsNAckRq) GetLongPollingTimeoutMs() int64 {
+	if m != nil {
+		return m.LongPollingTimeoutMs
+	}
+	return 0
+}
+
+func (m *ConsNAckRq) GetInitialOffset() string {
+	if m != nil {
+		return m.InitialOffset
+	}
+	return ""
+}
+
+func (m *ConsNAckRq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
 type ConsRs struct {
 	// Partition the message was read from.
 	Partition int32 `protobuf:"varint,1,opt,name=partition" json:"partition,omitempty"`
@@ -239,6 +372,18 @@ type ConsRs struct {
 	KeyUndefined bool `protobuf:"varint,4,opt,name=key_undefined,json=keyUndefined" json:"key_undefined,omitempty"`
 	// Message body
 	Message []byte `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	// High water mark of the partition at the time the message was read. It
+	// is the offset that will be assigned to the next message produced to the
+	// partition, so if it equals offset + 1 then the consumer has caught up.
+	HighWaterMark int64 `protobuf:"varint,6,opt,name=high_water_mark,json=highWaterMark" json:"high_water_mark,omitempty"`
+	// The last offset committed by the consumer group to the partition at the
+	// time the message was read, or -1 if there is none. Together with offset
+	// and high_water_mark it tells how far the group has progressed.
+	CommittedOffset int64 `protobuf:"varint,7,opt,name=committed_offset,json=committedOffset" json:"committed_offset,omitempty"`
+	// True if a message with the same key and value was consumed by the same
+	// group from the same topic within `consumer.dedup_window`. It is only a
+	// hint and it is never set unless `consumer.dedup_cache_size` is positive.
+	LikelyDuplicate bool `protobuf:"varint,8,opt,name=likely_duplicate,json=likelyDuplicate" json:"likely_duplicate,omitempty"`
 }
 
 func (m *ConsRs) Reset()                    { *m = ConsRs{} }
@@ -281,6 +426,101 @@ func (m *ConsRs) GetMessage() []byte {
 	return nil
 }
 
+func (m *ConsRs) GetHighWaterMark() int64 {
+	if m != nil {
+		return m.HighWaterMark
+	}
+	return 0
+}
+
+func (m *ConsRs) GetCommittedOffset() int64 {
+	if m != nil {
+		return m.CommittedOffset
+	}
+	return 0
+}
+
+func (m *ConsRs) GetLikelyDuplicate() bool {
+	if m != nil {
+		return m.LikelyDuplicate
+	}
+	return false
+}
+
+type ConsStreamRq struct {
+	// Name of a Kafka cluster to operate on. Only used in the first request.
+	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
+	// Name of a topic to consume from. Only used in the first request.
+	Topic string `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+	// Name of a consumer group. Only used in the first request.
+	Group string `protobuf:"bytes,3,opt,name=group" json:"group,omitempty"`
+	// If true then messages are acknowledged as soon as they are sent to the
+	// client. Only used in the first request.
+	AutoAck bool `protobuf:"varint,4,opt,name=auto_ack,json=autoAck" json:"auto_ack,omitempty"`
+	// Partition and offset of a message to be acknowledged. Ignored in the
+	// first request.
+	AckPartition int32 `protobuf:"varint,5,opt,name=ack_partition,json=ackPartition" json:"ack_partition,omitempty"`
+	AckOffset    int64 `protobuf:"varint,6,opt,name=ack_offset,json=ackOffset" json:"ack_offset,omitempty"`
+	// Logical ID of the service that consumes. If not empty, then consume
+	// requests of the stream are accounted for in client specific consume
+	// metrics. Only used in the first request.
+	ClientId string `protobuf:"bytes,7,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
+}
+
+func (m *ConsStreamRq) Reset()                    { *m = ConsStreamRq{} }
+func (m *ConsStreamRq) String() string            { return proto.CompactTextString(m) }
+func (*ConsStreamRq) ProtoMessage()               {}
+func (*ConsStreamRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *ConsStreamRq) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *ConsStreamRq) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *ConsStreamRq) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *ConsStreamRq) GetAutoAck() bool {
+	if m != nil {
+		return m.AutoAck
+	}
+	return false
+}
+
+func (m *ConsStreamRq) GetAckPartition() int32 {
+	if m != nil {
+		return m.AckPartition
+	}
+	return 0
+}
+
+func (m *ConsStreamRq) GetAckOffset() int64 {
+	if m != nil {
+		return m.AckOffset
+	}
+	return 0
+}
+
+func (m *ConsStreamRq) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
 type AckRq struct {
 	// Name of a Kafka cluster to operate on.
 	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
@@ -292,12 +532,16 @@ type AckRq struct {
 	Partition int32 `protobuf:"varint,4,opt,name=partition" json:"partition,omitempty"`
 	// Offset in the partition that the acknowledged message was consumed from.
 	Offset int64 `protobuf:"varint,5,opt,name=offset" json:"offset,omitempty"`
+	// If true, then all messages consumed from the partition up to and
+	// including offset are acknowledged at once, e.g. after a batch is
+	// processed.
+	UpTo bool `protobuf:"varint,6,opt,name=up_to,json=upTo" json:"up_to,omitempty"`
 }
 
 func (m *AckRq) Reset()                    { *m = AckRq{} }
 func (m *AckRq) String() string            { return proto.CompactTextString(m) }
 func (*AckRq) ProtoMessage()               {}
-func (*AckRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*AckRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *AckRq) GetCluster() string {
 	if m != nil {
@@ -334,13 +578,20 @@ func (m *AckRq) GetOffset() int64 {
 	return 0
 }
 
+func (m *AckRq) GetUpTo() bool {
+	if m != nil {
+		return m.UpTo
+	}
+	return false
+}
+
 type AckRs struct {
 }
 
 func (m *AckRs) Reset()                    { *m = AckRs{} }
 func (m *AckRs) String() string            { return proto.CompactTextString(m) }
 func (*AckRs) ProtoMessage()               {}
-func (*AckRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*AckRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 type PartitionOffset struct {
 	// The Partition this structure describes
@@ -359,12 +610,18 @@ type PartitionOffset struct {
 	Metadata string `protobuf:"bytes,7,opt,name=metadata" json:"metadata,omitempty"`
 	// human readable representation of sparsely committed ranges
 	SparseAcks string `protobuf:"bytes,8,opt,name=sparse_acks,json=sparseAcks" json:"sparse_acks,omitempty"`
+	// Host name of the Kafka-Pixy that committed the offset, if it was
+	// committed by SetOffsets rather than by a consumer.
+	CommittedBy string `protobuf:"bytes,9,opt,name=committed_by,json=committedBy" json:"committed_by,omitempty"`
+	// Time in milliseconds since epoch when the offset was committed, if it
+	// was committed by SetOffsets rather than by a consumer.
+	CommittedAtMs int64 `protobuf:"varint,10,opt,name=committed_at_ms,json=committedAtMs" json:"committed_at_ms,omitempty"`
 }
 
 func (m *PartitionOffset) Reset()                    { *m = PartitionOffset{} }
 func (m *PartitionOffset) String() string            { return proto.CompactTextString(m) }
 func (*PartitionOffset) ProtoMessage()               {}
-func (*PartitionOffset) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*PartitionOffset) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *PartitionOffset) GetPartition() int32 {
 	if m != nil {
@@ -422,6 +679,20 @@ func (m *PartitionOffset) GetSparseAcks() string {
 	return ""
 }
 
+func (m *PartitionOffset) GetCommittedBy() string {
+	if m != nil {
+		return m.CommittedBy
+	}
+	return ""
+}
+
+func (m *PartitionOffset) GetCommittedAtMs() int64 {
+	if m != nil {
+		return m.CommittedAtMs
+	}
+	return 0
+}
+
 type GetOffsetsRq struct {
 	// Name of a Kafka cluster
 	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
@@ -434,7 +705,7 @@ type GetOffsetsRq struct {
 func (m *GetOffsetsRq) Reset()                    { *m = GetOffsetsRq{} }
 func (m *GetOffsetsRq) String() string            { return proto.CompactTextString(m) }
 func (*GetOffsetsRq) ProtoMessage()               {}
-func (*GetOffsetsRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*GetOffsetsRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *GetOffsetsRq) GetCluster() string {
 	if m != nil {
@@ -464,7 +735,7 @@ type GetOffsetsRs struct {
 func (m *GetOffsetsRs) Reset()                    { *m = GetOffsetsRs{} }
 func (m *GetOffsetsRs) String() string            { return proto.CompactTextString(m) }
 func (*GetOffsetsRs) ProtoMessage()               {}
-func (*GetOffsetsRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*GetOffsetsRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *GetOffsetsRs) GetOffsets() []*PartitionOffset {
 	if m != nil {
@@ -473,6 +744,92 @@ func (m *GetOffsetsRs) GetOffsets() []*PartitionOffset {
 	return nil
 }
 
+type GetTopicOffsetsRq struct {
+	// Name of a Kafka cluster
+	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
+	// Name of a topic
+	Topic string `protobuf:"bytes,2,opt,name=topic" json:"topic,omitempty"`
+}
+
+func (m *GetTopicOffsetsRq) Reset()                    { *m = GetTopicOffsetsRq{} }
+func (m *GetTopicOffsetsRq) String() string            { return proto.CompactTextString(m) }
+func (*GetTopicOffsetsRq) ProtoMessage()               {}
+func (*GetTopicOffsetsRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *GetTopicOffsetsRq) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *GetTopicOffsetsRq) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+type PartitionOffsetRange struct {
+	// The Partition this structure describes
+	Partition int32 `protobuf:"varint,1,opt,name=partition" json:"partition,omitempty"`
+	// The log start offset of the partition
+	Begin int64 `protobuf:"varint,2,opt,name=begin" json:"begin,omitempty"`
+	// The high water mark of the partition
+	End int64 `protobuf:"varint,3,opt,name=end" json:"end,omitempty"`
+	// The number of messages in the partition, equals to end - begin
+	Count int64 `protobuf:"varint,4,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *PartitionOffsetRange) Reset()                    { *m = PartitionOffsetRange{} }
+func (m *PartitionOffsetRange) String() string            { return proto.CompactTextString(m) }
+func (*PartitionOffsetRange) ProtoMessage()               {}
+func (*PartitionOffsetRange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *PartitionOffsetRange) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *PartitionOffsetRange) GetBegin() int64 {
+	if m != nil {
+		return m.Begin
+	}
+	return 0
+}
+
+func (m *PartitionOffsetRange) GetEnd() int64 {
+	if m != nil {
+		return m.End
+	}
+	return 0
+}
+
+func (m *PartitionOffsetRange) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+type GetTopicOffsetsRs struct {
+	Ranges []*PartitionOffsetRange `protobuf:"bytes,1,rep,name=ranges" json:"ranges,omitempty"`
+}
+
+func (m *GetTopicOffsetsRs) Reset()                    { *m = GetTopicOffsetsRs{} }
+func (m *GetTopicOffsetsRs) String() string            { return proto.CompactTextString(m) }
+func (*GetTopicOffsetsRs) ProtoMessage()               {}
+func (*GetTopicOffsetsRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GetTopicOffsetsRs) GetRanges() []*PartitionOffsetRange {
+	if m != nil {
+		return m.Ranges
+	}
+	return nil
+}
+
 // Partition metadata as retrieved from kafka
 type PartitionMetadata struct {
 	// The Partition this structure describes
@@ -489,7 +846,7 @@ type PartitionMetadata struct {
 func (m *PartitionMetadata) Reset()                    { *m = PartitionMetadata{} }
 func (m *PartitionMetadata) String() string            { return proto.CompactTextString(m) }
 func (*PartitionMetadata) ProtoMessage()               {}
-func (*PartitionMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*PartitionMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *PartitionMetadata) GetPartition() int32 {
 	if m != nil {
@@ -531,7 +888,7 @@ type GetTopicMetadataRq struct {
 func (m *GetTopicMetadataRq) Reset()                    { *m = GetTopicMetadataRq{} }
 func (m *GetTopicMetadataRq) String() string            { return proto.CompactTextString(m) }
 func (*GetTopicMetadataRq) ProtoMessage()               {}
-func (*GetTopicMetadataRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*GetTopicMetadataRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *GetTopicMetadataRq) GetCluster() string {
 	if m != nil {
@@ -566,7 +923,7 @@ type GetTopicMetadataRs struct {
 func (m *GetTopicMetadataRs) Reset()                    { *m = GetTopicMetadataRs{} }
 func (m *GetTopicMetadataRs) String() string            { return proto.CompactTextString(m) }
 func (*GetTopicMetadataRs) ProtoMessage()               {}
-func (*GetTopicMetadataRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*GetTopicMetadataRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *GetTopicMetadataRs) GetVersion() int32 {
 	if m != nil {
@@ -596,7 +953,7 @@ type ListTopicRs struct {
 func (m *ListTopicRs) Reset()                    { *m = ListTopicRs{} }
 func (m *ListTopicRs) String() string            { return proto.CompactTextString(m) }
 func (*ListTopicRs) ProtoMessage()               {}
-func (*ListTopicRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*ListTopicRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *ListTopicRs) GetTopics() map[string]*GetTopicMetadataRs {
 	if m != nil {
@@ -610,12 +967,21 @@ type ListTopicRq struct {
 	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
 	// Should include partition metadata
 	WithPartitions bool `protobuf:"varint,2,opt,name=with_partitions,json=withPartitions" json:"with_partitions,omitempty"`
+	// If not empty, then only topics with names starting with it are listed.
+	Prefix string `protobuf:"bytes,3,opt,name=prefix" json:"prefix,omitempty"`
+	// If not empty, then only topics with names matching this regular
+	// expression are listed.
+	Pattern string `protobuf:"bytes,4,opt,name=pattern" json:"pattern,omitempty"`
+	// If not empty, then only topics that have all these configuration
+	// entries overridden with the given values are listed, e.g.
+	// cleanup.policy: compact.
+	Config map[string]string `protobuf:"bytes,5,rep,name=config" json:"config,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *ListTopicRq) Reset()                    { *m = ListTopicRq{} }
 func (m *ListTopicRq) String() string            { return proto.CompactTextString(m) }
 func (*ListTopicRq) ProtoMessage()               {}
-func (*ListTopicRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*ListTopicRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
 func (m *ListTopicRq) GetCluster() string {
 	if m != nil {
@@ -631,6 +997,27 @@ func (m *ListTopicRq) GetWithPartitions() bool {
 	return false
 }
 
+func (m *ListTopicRq) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+func (m *ListTopicRq) GetPattern() string {
+	if m != nil {
+		return m.Pattern
+	}
+	return ""
+}
+
+func (m *ListTopicRq) GetConfig() map[string]string {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
 type ListConsumersRq struct {
 	// Name of a Kafka cluster
 	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
@@ -643,7 +1030,7 @@ type ListConsumersRq struct {
 func (m *ListConsumersRq) Reset()                    { *m = ListConsumersRq{} }
 func (m *ListConsumersRq) String() string            { return proto.CompactTextString(m) }
 func (*ListConsumersRq) ProtoMessage()               {}
-func (*ListConsumersRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*ListConsumersRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
 func (m *ListConsumersRq) GetCluster() string {
 	if m != nil {
@@ -673,7 +1060,7 @@ type ConsumerPartitions struct {
 func (m *ConsumerPartitions) Reset()                    { *m = ConsumerPartitions{} }
 func (m *ConsumerPartitions) String() string            { return proto.CompactTextString(m) }
 func (*ConsumerPartitions) ProtoMessage()               {}
-func (*ConsumerPartitions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*ConsumerPartitions) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
 
 func (m *ConsumerPartitions) GetPartitions() []int32 {
 	if m != nil {
@@ -689,7 +1076,7 @@ type ConsumerGroups struct {
 func (m *ConsumerGroups) Reset()                    { *m = ConsumerGroups{} }
 func (m *ConsumerGroups) String() string            { return proto.CompactTextString(m) }
 func (*ConsumerGroups) ProtoMessage()               {}
-func (*ConsumerGroups) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*ConsumerGroups) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
 func (m *ConsumerGroups) GetConsumers() map[string]*ConsumerPartitions {
 	if m != nil {
@@ -705,7 +1092,7 @@ type ListConsumersRs struct {
 func (m *ListConsumersRs) Reset()                    { *m = ListConsumersRs{} }
 func (m *ListConsumersRs) String() string            { return proto.CompactTextString(m) }
 func (*ListConsumersRs) ProtoMessage()               {}
-func (*ListConsumersRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*ListConsumersRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
 
 func (m *ListConsumersRs) GetGroups() map[string]*ConsumerGroups {
 	if m != nil {
@@ -714,6 +1101,168 @@ func (m *ListConsumersRs) GetGroups() map[string]*ConsumerGroups {
 	return nil
 }
 
+type ListGroupsRq struct {
+	// Name of a Kafka cluster
+	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
+}
+
+func (m *ListGroupsRq) Reset()                    { *m = ListGroupsRq{} }
+func (m *ListGroupsRq) String() string            { return proto.CompactTextString(m) }
+func (*ListGroupsRq) ProtoMessage()               {}
+func (*ListGroupsRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *ListGroupsRq) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+type ListGroupsRs struct {
+	// Sorted list of consumer group names
+	Groups []string `protobuf:"bytes,1,rep,name=groups" json:"groups,omitempty"`
+}
+
+func (m *ListGroupsRs) Reset()                    { *m = ListGroupsRs{} }
+func (m *ListGroupsRs) String() string            { return proto.CompactTextString(m) }
+func (*ListGroupsRs) ProtoMessage()               {}
+func (*ListGroupsRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *ListGroupsRs) GetGroups() []string {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
+type DescribeGroupRq struct {
+	// Name of a Kafka cluster
+	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
+	// Name of a consumer group
+	Group string `protobuf:"bytes,2,opt,name=group" json:"group,omitempty"`
+}
+
+func (m *DescribeGroupRq) Reset()                    { *m = DescribeGroupRq{} }
+func (m *DescribeGroupRq) String() string            { return proto.CompactTextString(m) }
+func (*DescribeGroupRq) ProtoMessage()               {}
+func (*DescribeGroupRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *DescribeGroupRq) GetCluster() string {
+	if m != nil {
+		return m.Cluster
+	}
+	return ""
+}
+
+func (m *DescribeGroupRq) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+type GroupMember struct {
+	MemberId   string `protobuf:"bytes,1,opt,name=member_id,json=memberId" json:"member_id,omitempty"`
+	ClientId   string `protobuf:"bytes,2,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
+	ClientHost string `protobuf:"bytes,3,opt,name=client_host,json=clientHost" json:"client_host,omitempty"`
+	// Topics the member is subscribed to. Only reported for groups of the
+	// consumer protocol type.
+	Topics []string `protobuf:"bytes,4,rep,name=topics" json:"topics,omitempty"`
+	// Partitions assigned to the member by topic. Only reported for groups
+	// of the consumer protocol type.
+	Assignment map[string]*ConsumerPartitions `protobuf:"bytes,5,rep,name=assignment" json:"assignment,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *GroupMember) Reset()                    { *m = GroupMember{} }
+func (m *GroupMember) String() string            { return proto.CompactTextString(m) }
+func (*GroupMember) ProtoMessage()               {}
+func (*GroupMember) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *GroupMember) GetMemberId() string {
+	if m != nil {
+		return m.MemberId
+	}
+	return ""
+}
+
+func (m *GroupMember) GetClientId() string {
+	if m != nil {
+		return m.ClientId
+	}
+	return ""
+}
+
+func (m *GroupMember) GetClientHost() string {
+	if m != nil {
+		return m.ClientHost
+	}
+	return ""
+}
+
+func (m *GroupMember) GetTopics() []string {
+	if m != nil {
+		return m.Topics
+	}
+	return nil
+}
+
+func (m *GroupMember) GetAssignment() map[string]*ConsumerPartitions {
+	if m != nil {
+		return m.Assignment
+	}
+	return nil
+}
+
+type DescribeGroupRs struct {
+	Group string `protobuf:"bytes,1,opt,name=group" json:"group,omitempty"`
+	// One of Stable, PreparingRebalance, AwaitingSync, Empty, Dead. The
+	// state of a group that does not exist is reported as Dead.
+	State        string         `protobuf:"bytes,2,opt,name=state" json:"state,omitempty"`
+	ProtocolType string         `protobuf:"bytes,3,opt,name=protocol_type,json=protocolType" json:"protocol_type,omitempty"`
+	Protocol     string         `protobuf:"bytes,4,opt,name=protocol" json:"protocol,omitempty"`
+	Members      []*GroupMember `protobuf:"bytes,5,rep,name=members" json:"members,omitempty"`
+}
+
+func (m *DescribeGroupRs) Reset()                    { *m = DescribeGroupRs{} }
+func (m *DescribeGroupRs) String() string            { return proto.CompactTextString(m) }
+func (*DescribeGroupRs) ProtoMessage()               {}
+func (*DescribeGroupRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *DescribeGroupRs) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *DescribeGroupRs) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *DescribeGroupRs) GetProtocolType() string {
+	if m != nil {
+		return m.ProtocolType
+	}
+	return ""
+}
+
+func (m *DescribeGroupRs) GetProtocol() string {
+	if m != nil {
+		return m.Protocol
+	}
+	return ""
+}
+
+func (m *DescribeGroupRs) GetMembers() []*GroupMember {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
 type SetOffsetsRq struct {
 	// Name of a Kafka cluster
 	Cluster string `protobuf:"bytes,1,opt,name=cluster" json:"cluster,omitempty"`
@@ -722,12 +1271,20 @@ type SetOffsetsRq struct {
 	// Name of a consumer group.
 	Group   string             `protobuf:"bytes,3,opt,name=group" json:"group,omitempty"`
 	Offsets []*PartitionOffset `protobuf:"bytes,4,rep,name=offsets" json:"offsets,omitempty"`
+	// If positive then Kafka retains the committed offsets for this long
+	// rather than for offsets.retention.minutes of the cluster. Requires
+	// config.yaml:proxies.<cluster>.kafka.version 0.9.0.0 or later.
+	RetentionMs int64 `protobuf:"varint,5,opt,name=retention_ms,json=retentionMs" json:"retention_ms,omitempty"`
+	// If true then the request fails with FailedPrecondition, and nothing is
+	// committed, if any of the offsets is less than the one currently
+	// committed by the group for the respective partition.
+	NoDecrease bool `protobuf:"varint,6,opt,name=no_decrease,json=noDecrease" json:"no_decrease,omitempty"`
 }
 
 func (m *SetOffsetsRq) Reset()                    { *m = SetOffsetsRq{} }
 func (m *SetOffsetsRq) String() string            { return proto.CompactTextString(m) }
 func (*SetOffsetsRq) ProtoMessage()               {}
-func (*SetOffsetsRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*SetOffsetsRq) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
 func (m *SetOffsetsRq) GetCluster() string {
 	if m != nil {
@@ -757,24 +1314,42 @@ func (m *SetOffsetsRq) GetOffsets() []*PartitionOffset {
 	return nil
 }
 
+func (m *SetOffsetsRq) GetRetentionMs() int64 {
+	if m != nil {
+		return m.RetentionMs
+	}
+	return 0
+}
+
+func (m *SetOffsetsRq) GetNoDecrease() bool {
+	if m != nil {
+		return m.NoDecrease
+	}
+	return false
+}
+
 type SetOffsetsRs struct {
 }
 
 func (m *SetOffsetsRs) Reset()                    { *m = SetOffsetsRs{} }
 func (m *SetOffsetsRs) String() string            { return proto.CompactTextString(m) }
 func (*SetOffsetsRs) ProtoMessage()               {}
-func (*SetOffsetsRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*SetOffsetsRs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
 func init() {
 	proto.RegisterType((*ProdRq)(nil), "ProdRq")
 	proto.RegisterType((*ProdRs)(nil), "ProdRs")
 	proto.RegisterType((*ConsNAckRq)(nil), "ConsNAckRq")
 	proto.RegisterType((*ConsRs)(nil), "ConsRs")
+	proto.RegisterType((*ConsStreamRq)(nil), "ConsStreamRq")
 	proto.RegisterType((*AckRq)(nil), "AckRq")
 	proto.RegisterType((*AckRs)(nil), "AckRs")
 	proto.RegisterType((*PartitionOffset)(nil), "PartitionOffset")
 	proto.RegisterType((*GetOffsetsRq)(nil), "GetOffsetsRq")
 	proto.RegisterType((*GetOffsetsRs)(nil), "GetOffsetsRs")
+	proto.RegisterType((*GetTopicOffsetsRq)(nil), "GetTopicOffsetsRq")
+	proto.RegisterType((*PartitionOffsetRange)(nil), "PartitionOffsetRange")
+	proto.RegisterType((*GetTopicOffsetsRs)(nil), "GetTopicOffsetsRs")
 	proto.RegisterType((*PartitionMetadata)(nil), "PartitionMetadata")
 	proto.RegisterType((*GetTopicMetadataRq)(nil), "GetTopicMetadataRq")
 	proto.RegisterType((*GetTopicMetadataRs)(nil), "GetTopicMetadataRs")
@@ -784,6 +1359,11 @@ func init() {
 	proto.RegisterType((*ConsumerPartitions)(nil), "ConsumerPartitions")
 	proto.RegisterType((*ConsumerGroups)(nil), "ConsumerGroups")
 	proto.RegisterType((*ListConsumersRs)(nil), "ListConsumersRs")
+	proto.RegisterType((*ListGroupsRq)(nil), "ListGroupsRq")
+	proto.RegisterType((*ListGroupsRs)(nil), "ListGroupsRs")
+	proto.RegisterType((*DescribeGroupRq)(nil), "DescribeGroupRq")
+	proto.RegisterType((*GroupMember)(nil), "GroupMember")
+	proto.RegisterType((*DescribeGroupRs)(nil), "DescribeGroupRs")
 	proto.RegisterType((*SetOffsetsRq)(nil), "SetOffsetsRq")
 	proto.RegisterType((*SetOffsetsRs)(nil), "SetOffsetsRs")
 }
@@ -820,6 +1400,9 @@ type KafkaPixyClient interface {
 	//
 	// gRPC error codes:
 	//  * Invalid Argument (3): see the status description for details;
+	//  * Resource Exhausted (8): in async mode, the call deadline expired
+	//    while waiting for the number of messages in flight to drop below
+	//    config.yaml:proxies.<cluster>.producer.max_async_in_flight;
 	//  * Internal (13): see the status description and logs for details;
 	//  * Unavailable (14): the service is shutting down.
 	Produce(ctx context.Context, in *ProdRq, opts ...grpc.CallOption) (*ProdRs, error)
@@ -853,10 +1436,14 @@ type KafkaPixyClient interface {
 	//    in a loop;
 	//  * Resource Exhausted (8): too many consume requests. Either reduce the
 	//    number of consuming threads or increase
-	//    config.yaml:proxies.<cluster>.consumer.channel_buffer_size;
+	//    config.yaml:proxies.<cluster>.consumer.channel_buffer_size. It is
+	//    also returned if the group exceeded
+	//    config.yaml:proxies.<cluster>.consumer.rate_limit for the topic, then
+	//    back off for a while;
 	//  * Invalid Argument (3): see the status description for details;
 	//  * Internal (13): see the status description and logs for details;
-	//  * Unavailable (14): the service is shutting down.
+	//  * Unavailable (14): the service is shutting down, or the consumer group
+	//    is rebalancing, in which case the call should be retried right away.
 	ConsumeNAck(ctx context.Context, in *ConsNAckRq, opts ...grpc.CallOption) (*ConsRs, error)
 	// Ack acknowledges a message earlier consumed from a topic.
 	//
@@ -875,6 +1462,14 @@ type KafkaPixyClient interface {
 	//  * Internal (13): If Kafka returns an error on offset request
 	//  * NotFound (5): If the group and or topic does not exist
 	GetOffsets(ctx context.Context, in *GetOffsetsRq, opts ...grpc.CallOption) (*GetOffsetsRs, error)
+	// Fetches the range of available offsets for every partition of the
+	// specified topic.
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//  * Internal (13): If Kafka returns an error on offset request
+	//  * NotFound (5): If the topic does not exist
+	GetTopicOffsets(ctx context.Context, in *GetTopicOffsetsRq, opts ...grpc.CallOption) (*GetTopicOffsetsRs, error)
 	// Sets partition offsets for the specified topic and group.
 	// NOTE: Although the request accepts the PartitionOffset object i
 	// only 'Partition', 'Offset' and 'Metadata' are set by this method
@@ -883,11 +1478,14 @@ type KafkaPixyClient interface {
 	//  * Invalid Argument (3): If unable to find the cluster named in the request
 	//  * Internal (13): If Kafka returns an error on offset request
 	//  * NotFound (5): If the group and or topic does not exist
+	//  * FailedPrecondition (9): If no_decrease is set and an offset would
+	//    move backwards
 	SetOffsets(ctx context.Context, in *SetOffsetsRq, opts ...grpc.CallOption) (*SetOffsetsRs, error)
 	// Lists all topics and metadata with optional metadata for the partitions of the topic
 	//
 	// gRPC error codes:
 	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//    or the pattern is not a valid regular expression
 	//  * Internal (13): If Kafka returns an error on request
 	ListTopics(ctx context.Context, in *ListTopicRq, opts ...grpc.CallOption) (*ListTopicRs, error)
 	// Lists all consumers of a topic
@@ -903,6 +1501,40 @@ type KafkaPixyClient interface {
 	//  * Internal (13): If Kafka returns an error on request
 	//  * NotFound (5): If the topic does not exist
 	GetTopicMetadata(ctx context.Context, in *GetTopicMetadataRq, opts ...grpc.CallOption) (*GetTopicMetadataRs, error)
+	// Lists all consumer groups known to the Kafka cluster
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//  * Internal (13): If Kafka returns an error on request
+	ListGroups(ctx context.Context, in *ListGroupsRq, opts ...grpc.CallOption) (*ListGroupsRs, error)
+	// Describes the state and members of a consumer group as seen by its
+	// coordinator broker
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//  * Internal (13): If Kafka returns an error on request
+	DescribeGroup(ctx context.Context, in *DescribeGroupRq, opts ...grpc.CallOption) (*DescribeGroupRs, error)
+	// ConsumeStream streams messages consumed from a topic as a member of a
+	// consumer group. The first request sent by the client determines the
+	// cluster, the topic, and the group, all subsequent requests acknowledge
+	// messages previously consumed from the stream. Messages are consumed from
+	// the topic only as fast as the client receives them, so a slow client
+	// does not cause messages to pile up in Kafka-Pixy.
+	//
+	// The stream is terminated by the server on error only. If there are no
+	// messages in the topic then the stream just waits for them to arrive.
+	//
+	// When the stream ends, e.g. because the client crashed, messages sent
+	// over it but not acknowledged are redelivered right away, rather than
+	// after config.yaml:proxies.<cluster>.consumer.ack_timeout.
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): see the status description for details;
+	//  * Resource Exhausted (8): too many consume requests, or the group
+	//    exceeded config.yaml:proxies.<cluster>.consumer.rate_limit;
+	//  * Internal (13): see the status description and logs for details;
+	//  * Unavailable (14): the service is shutting down.
+	ConsumeStream(ctx context.Context, opts ...grpc.CallOption) (KafkaPixy_ConsumeStreamClient, error)
 }
 
 type kafkaPixyClient struct {
@@ -949,6 +1581,15 @@ func (c *kafkaPixyClient) GetOffsets(ctx context.Context, in *GetOffsetsRq, opts
 	return out, nil
 }
 
+func (c *kafkaPixyClient) GetTopicOffsets(ctx context.Context, in *GetTopicOffsetsRq, opts ...grpc.CallOption) (*GetTopicOffsetsRs, error) {
+	out := new(GetTopicOffsetsRs)
+	err := grpc.Invoke(ctx, "/KafkaPixy/GetTopicOffsets", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *kafkaPixyClient) SetOffsets(ctx context.Context, in *SetOffsetsRq, opts ...grpc.CallOption) (*SetOffsetsRs, error) {
 	out := new(SetOffsetsRs)
 	err := grpc.Invoke(ctx, "/KafkaPixy/SetOffsets", in, out, c.cc, opts...)
@@ -985,6 +1626,55 @@ func (c *kafkaPixyClient) GetTopicMetadata(ctx context.Context, in *GetTopicMeta
 	return out, nil
 }
 
+func (c *kafkaPixyClient) ListGroups(ctx context.Context, in *ListGroupsRq, opts ...grpc.CallOption) (*ListGroupsRs, error) {
+	out := new(ListGroupsRs)
+	err := grpc.Invoke(ctx, "/KafkaPixy/ListGroups", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kafkaPixyClient) DescribeGroup(ctx context.Context, in *DescribeGroupRq, opts ...grpc.CallOption) (*DescribeGroupRs, error) {
+	out := new(DescribeGroupRs)
+	err := grpc.Invoke(ctx, "/KafkaPixy/DescribeGroup", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kafkaPixyClient) ConsumeStream(ctx context.Context, opts ...grpc.CallOption) (KafkaPixy_ConsumeStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_KafkaPixy_serviceDesc.Streams[0], c.cc, "/KafkaPixy/ConsumeStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kafkaPixyConsumeStreamClient{stream}
+	return x, nil
+}
+
+type KafkaPixy_ConsumeStreamClient interface {
+	Send(*ConsStreamRq) error
+	Recv() (*ConsRs, error)
+	grpc.ClientStream
+}
+
+type kafkaPixyConsumeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kafkaPixyConsumeStreamClient) Send(m *ConsStreamRq) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kafkaPixyConsumeStreamClient) Recv() (*ConsRs, error) {
+	m := new(ConsRs)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for KafkaPixy service
 
 type KafkaPixyServer interface {
@@ -1009,6 +1699,9 @@ type KafkaPixyServer interface {
 	//
 	// gRPC error codes:
 	//  * Invalid Argument (3): see the status description for details;
+	//  * Resource Exhausted (8): in async mode, the call deadline expired
+	//    while waiting for the number of messages in flight to drop below
+	//    config.yaml:proxies.<cluster>.producer.max_async_in_flight;
 	//  * Internal (13): see the status description and logs for details;
 	//  * Unavailable (14): the service is shutting down.
 	Produce(context.Context, *ProdRq) (*ProdRs, error)
@@ -1042,10 +1735,14 @@ type KafkaPixyServer interface {
 	//    in a loop;
 	//  * Resource Exhausted (8): too many consume requests. Either reduce the
 	//    number of consuming threads or increase
-	//    config.yaml:proxies.<cluster>.consumer.channel_buffer_size;
+	//    config.yaml:proxies.<cluster>.consumer.channel_buffer_size. It is
+	//    also returned if the group exceeded
+	//    config.yaml:proxies.<cluster>.consumer.rate_limit for the topic, then
+	//    back off for a while;
 	//  * Invalid Argument (3): see the status description for details;
 	//  * Internal (13): see the status description and logs for details;
-	//  * Unavailable (14): the service is shutting down.
+	//  * Unavailable (14): the service is shutting down, or the consumer group
+	//    is rebalancing, in which case the call should be retried right away.
 	ConsumeNAck(context.Context, *ConsNAckRq) (*ConsRs, error)
 	// Ack acknowledges a message earlier consumed from a topic.
 	//
@@ -1064,6 +1761,14 @@ type KafkaPixyServer interface {
 	//  * Internal (13): If Kafka returns an error on offset request
 	//  * NotFound (5): If the group and or topic does not exist
 	GetOffsets(context.Context, *GetOffsetsRq) (*GetOffsetsRs, error)
+	// Fetches the range of available offsets for every partition of the
+	// specified topic.
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//  * Internal (13): If Kafka returns an error on offset request
+	//  * NotFound (5): If the topic does not exist
+	GetTopicOffsets(context.Context, *GetTopicOffsetsRq) (*GetTopicOffsetsRs, error)
 	// Sets partition offsets for the specified topic and group.
 	// NOTE: Although the request accepts the PartitionOffset object i
 	// only 'Partition', 'Offset' and 'Metadata' are set by this method
@@ -1072,11 +1777,14 @@ type KafkaPixyServer interface {
 	//  * Invalid Argument (3): If unable to find the cluster named in the request
 	//  * Internal (13): If Kafka returns an error on offset request
 	//  * NotFound (5): If the group and or topic does not exist
+	//  * FailedPrecondition (9): If no_decrease is set and an offset would
+	//    move backwards
 	SetOffsets(context.Context, *SetOffsetsRq) (*SetOffsetsRs, error)
 	// Lists all topics and metadata with optional metadata for the partitions of the topic
 	//
 	// gRPC error codes:
 	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//    or the pattern is not a valid regular expression
 	//  * Internal (13): If Kafka returns an error on request
 	ListTopics(context.Context, *ListTopicRq) (*ListTopicRs, error)
 	// Lists all consumers of a topic
@@ -1092,6 +1800,40 @@ type KafkaPixyServer interface {
 	//  * Internal (13): If Kafka returns an error on request
 	//  * NotFound (5): If the topic does not exist
 	GetTopicMetadata(context.Context, *GetTopicMetadataRq) (*GetTopicMetadataRs, error)
+	// Lists all consumer groups known to the Kafka cluster
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//  * Internal (13): If Kafka returns an error on request
+	ListGroups(context.Context, *ListGroupsRq) (*ListGroupsRs, error)
+	// Describes the state and members of a consumer group as seen by its
+	// coordinator broker
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): If unable to find the cluster named in the request
+	//  * Internal (13): If Kafka returns an error on request
+	DescribeGroup(context.Context, *DescribeGroupRq) (*DescribeGroupRs, error)
+	// ConsumeStream streams messages consumed from a topic as a member of a
+	// consumer group. The first request sent by the client determines the
+	// cluster, the topic, and the group, all subsequent requests acknowledge
+	// messages previously consumed from the stream. Messages are consumed from
+	// the topic only as fast as the client receives them, so a slow client
+	// does not cause messages to pile up in Kafka-Pixy.
+	//
+	// The stream is terminated by the server on error only. If there are no
+	// messages in the topic then the stream just waits for them to arrive.
+	//
+	// When the stream ends, e.g. because the client crashed, messages sent
+	// over it but not acknowledged are redelivered right away, rather than
+	// after config.yaml:proxies.<cluster>.consumer.ack_timeout.
+	//
+	// gRPC error codes:
+	//  * Invalid Argument (3): see the status description for details;
+	//  * Resource Exhausted (8): too many consume requests, or the group
+	//    exceeded config.yaml:proxies.<cluster>.consumer.rate_limit;
+	//  * Internal (13): see the status description and logs for details;
+	//  * Unavailable (14): the service is shutting down.
+	ConsumeStream(KafkaPixy_ConsumeStreamServer) error
 }
 
 func RegisterKafkaPixyServer(s *grpc.Server, srv KafkaPixyServer) {
@@ -1170,6 +1912,24 @@ func _KafkaPixy_GetOffsets_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KafkaPixy_GetTopicOffsets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTopicOffsetsRq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KafkaPixyServer).GetTopicOffsets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/KafkaPixy/GetTopicOffsets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KafkaPixyServer).GetTopicOffsets(ctx, req.(*GetTopicOffsetsRq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _KafkaPixy_SetOffsets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SetOffsetsRq)
 	if err := dec(in); err != nil {
@@ -1242,6 +2002,68 @@ func _KafkaPixy_GetTopicMetadata_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KafkaPixy_ListGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListGroupsRq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KafkaPixyServer).ListGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/KafkaPixy/ListGroups",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KafkaPixyServer).ListGroups(ctx, req.(*ListGroupsRq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KafkaPixy_DescribeGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeGroupRq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KafkaPixyServer).DescribeGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/KafkaPixy/DescribeGroup",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KafkaPixyServer).DescribeGroup(ctx, req.(*DescribeGroupRq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KafkaPixy_ConsumeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KafkaPixyServer).ConsumeStream(&kafkaPixyConsumeStreamServer{stream})
+}
+
+type KafkaPixy_ConsumeStreamServer interface {
+	Send(*ConsRs) error
+	Recv() (*ConsStreamRq, error)
+	grpc.ServerStream
+}
+
+type kafkaPixyConsumeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kafkaPixyConsumeStreamServer) Send(m *ConsRs) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kafkaPixyConsumeStreamServer) Recv() (*ConsStreamRq, error) {
+	m := new(ConsStreamRq)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _KafkaPixy_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "KafkaPixy",
 	HandlerType: (*KafkaPixyServer)(nil),
@@ -1262,6 +2084,10 @@ var _KafkaPixy_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetOffsets",
 			Handler:    _KafkaPixy_GetOffsets_Handler,
 		},
+		{
+			MethodName: "GetTopicOffsets",
+			Handler:    _KafkaPixy_GetTopicOffsets_Handler,
+		},
 		{
 			MethodName: "SetOffsets",
 			Handler:    _KafkaPixy_SetOffsets_Handler,
@@ -1278,73 +2104,130 @@ var _KafkaPixy_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetTopicMetadata",
 			Handler:    _KafkaPixy_GetTopicMetadata_Handler,
 		},
+		{
+			MethodName: "ListGroups",
+			Handler:    _KafkaPixy_ListGroups_Handler,
+		},
+		{
+			MethodName: "DescribeGroup",
+			Handler:    _KafkaPixy_DescribeGroup_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ConsumeStream",
+			Handler:       _KafkaPixy_ConsumeStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "kafkapixy.proto",
 }
 
 func init() { proto.RegisterFile("kafkapixy.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 954 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x56, 0xdd, 0x6e, 0xe3, 0x44,
-	0x14, 0xee, 0xc4, 0xb1, 0x13, 0x9f, 0xa4, 0x4d, 0x19, 0x0a, 0x18, 0xb3, 0x3f, 0x91, 0x57, 0x2b,
-	0xc2, 0x0a, 0x59, 0xa8, 0x2c, 0x02, 0x56, 0x68, 0xa5, 0xb2, 0x42, 0x95, 0x80, 0x5d, 0xca, 0x74,
-	0x01, 0x89, 0x9b, 0x68, 0xea, 0x4c, 0x82, 0xe5, 0xc4, 0x4e, 0x3d, 0xce, 0xee, 0xe6, 0x0e, 0x89,
-	0x07, 0xe0, 0x82, 0x3b, 0xee, 0x78, 0x06, 0x5e, 0x82, 0x07, 0x40, 0xbc, 0x03, 0x6f, 0x81, 0xce,
-	0x8c, 0x1d, 0xdb, 0xa9, 0x97, 0xa2, 0xaa, 0x5c, 0xc5, 0xdf, 0x39, 0x67, 0x66, 0xbe, 0xef, 0x3b,
-	0x33, 0x93, 0x81, 0x41, 0xc4, 0xa7, 0x11, 0x5f, 0x86, 0x2f, 0xd6, 0xfe, 0x32, 0x4d, 0xb2, 0xc4,
-	0xfb, 0x9d, 0x80, 0x75, 0x92, 0x26, 0x13, 0x76, 0x4e, 0x1d, 0xe8, 0x04, 0xf3, 0x95, 0xcc, 0x44,
-	0xea, 0x90, 0x21, 0x19, 0xd9, 0xac, 0x80, 0xf4, 0x00, 0xcc, 0x2c, 0x59, 0x86, 0x81, 0xd3, 0x52,
-	0x71, 0x0d, 0xe8, 0x5b, 0x60, 0x47, 0x62, 0x3d, 0x7e, 0xc6, 0xe7, 0x2b, 0xe1, 0x18, 0x43, 0x32,
-	0xea, 0xb3, 0x6e, 0x24, 0xd6, 0xdf, 0x22, 0xa6, 0x77, 0x60, 0x17, 0x93, 0xab, 0x78, 0x22, 0xa6,
-	0x61, 0x2c, 0x26, 0x4e, 0x7b, 0x48, 0x46, 0x5d, 0xd6, 0x8f, 0xc4, 0xfa, 0x9b, 0x22, 0x86, 0x2b,
-	0x2e, 0x84, 0x94, 0x7c, 0x26, 0x1c, 0x53, 0x8d, 0x2f, 0x20, 0xbd, 0x09, 0xc0, 0xe5, 0x3a, 0x0e,
-	0xc6, 0x8b, 0x64, 0x22, 0x1c, 0x4b, 0x8d, 0xb5, 0x55, 0xe4, 0x71, 0x32, 0x11, 0xde, 0xc3, 0x9c,
-	0xb4, 0xa4, 0x37, 0xc0, 0x5e, 0xf2, 0x34, 0x0b, 0xb3, 0x30, 0x89, 0x15, 0x6d, 0x93, 0x95, 0x01,
-	0xfa, 0x3a, 0x58, 0xc9, 0x74, 0x2a, 0x45, 0xa6, 0x98, 0x1b, 0x2c, 0x47, 0xde, 0x1f, 0x04, 0xe0,
-	0x51, 0x12, 0xcb, 0x27, 0x47, 0x41, 0x74, 0x05, 0xe5, 0x07, 0x60, 0xce, 0xd2, 0x64, 0xb5, 0x54,
-	0xaa, 0x6d, 0xa6, 0x01, 0x7d, 0x0d, 0xac, 0x38, 0x19, 0xf3, 0x20, 0xca, 0xb5, 0x9a, 0x71, 0x72,
-	0x14, 0x44, 0xf4, 0x4d, 0xe8, 0xf2, 0x55, 0xa6, 0x13, 0xa6, 0x4a, 0x74, 0x10, 0x63, 0xea, 0x0e,
-	0xec, 0xf2, 0x20, 0x1a, 0x97, 0x02, 0x2c, 0x25, 0xa0, 0xcf, 0x83, 0xe8, 0x64, 0xa3, 0x01, 0xad,
-	0x08, 0xa2, 0x71, 0xae, 0xa3, 0xa3, 0x74, 0xd8, 0x3c, 0x88, 0xbe, 0xd2, 0x52, 0x7e, 0x25, 0x60,
-	0xa1, 0x94, 0xab, 0x7a, 0xf1, 0x7f, 0xb6, 0xd1, 0xfb, 0x89, 0x80, 0x79, 0x9d, 0x16, 0xd7, 0x14,
-	0xb6, 0x5f, 0xae, 0xd0, 0xac, 0x75, 0xbb, 0xa3, 0x49, 0x48, 0xef, 0x4f, 0x02, 0x83, 0x8d, 0xb1,
-	0xda, 0xbf, 0x4b, 0x4c, 0x3b, 0x00, 0xf3, 0x4c, 0xcc, 0xc2, 0x38, 0xf7, 0x4c, 0x03, 0xba, 0x0f,
-	0x86, 0x88, 0x27, 0x8a, 0x9a, 0xc1, 0xf0, 0x13, 0xeb, 0x82, 0x64, 0x15, 0x67, 0x8a, 0x94, 0xc1,
-	0x34, 0x78, 0x19, 0x21, 0x1c, 0x3f, 0xe7, 0x33, 0xd5, 0x6d, 0x83, 0xe1, 0x27, 0x75, 0xa1, 0xbb,
-	0x10, 0x19, 0x9f, 0xf0, 0x8c, 0xab, 0x16, 0xdb, 0x6c, 0x83, 0xe9, 0x6d, 0xe8, 0xc9, 0x25, 0x4f,
-	0xa5, 0xc0, 0x2d, 0x24, 0x9d, 0xae, 0x4a, 0x83, 0x0e, 0x1d, 0x05, 0x91, 0xf4, 0x9e, 0x42, 0xff,
-	0x58, 0x64, 0x5a, 0x8f, 0xbc, 0x2e, 0xaf, 0xbd, 0x07, 0xb5, 0x59, 0x25, 0xbd, 0x07, 0x1d, 0x4d,
-	0x5f, 0x3a, 0x64, 0x68, 0x8c, 0x7a, 0x87, 0xfb, 0xfe, 0x96, 0x97, 0xac, 0x28, 0xf0, 0x9e, 0xc3,
-	0x2b, 0x9b, 0xdc, 0xe3, 0x42, 0xc7, 0xa5, 0xdb, 0x73, 0x2e, 0xf8, 0x44, 0xa4, 0x8a, 0x9b, 0xc9,
-	0x72, 0x84, 0xce, 0xa4, 0x62, 0x39, 0x0f, 0x03, 0x2e, 0x1d, 0x63, 0x68, 0x8c, 0x4c, 0xb6, 0xc1,
-	0xe8, 0x63, 0x28, 0x53, 0xa7, 0xad, 0xc2, 0xf8, 0xe9, 0x2d, 0x80, 0x1e, 0x8b, 0xec, 0x29, 0xca,
-	0x2a, 0xd6, 0xbd, 0x82, 0x21, 0x6f, 0xc3, 0xe0, 0x79, 0x98, 0xfd, 0x50, 0x1e, 0x4c, 0xa9, 0xac,
-	0xe9, 0xb2, 0x3d, 0x0c, 0x6f, 0x94, 0x49, 0xef, 0x2f, 0xd2, 0xb0, 0x9e, 0xc4, 0xf5, 0x9e, 0x89,
-	0x54, 0x96, 0x3a, 0x0b, 0x48, 0x3f, 0x04, 0x2b, 0x48, 0xe2, 0x69, 0x38, 0x73, 0x5a, 0xca, 0xc3,
-	0xdb, 0xfe, 0xc5, 0xe1, 0xfe, 0x23, 0x55, 0xf1, 0x59, 0x9c, 0xa5, 0x6b, 0x96, 0x97, 0xd3, 0x43,
-	0x80, 0x1a, 0x1b, 0x1c, 0x4c, 0xfd, 0x0b, 0x26, 0xb3, 0x4a, 0x95, 0xfb, 0x31, 0xf4, 0x2a, 0x53,
-	0xa1, 0x5b, 0x91, 0x58, 0xe7, 0x0e, 0xe0, 0x27, 0xaa, 0xd7, 0xc7, 0x3e, 0x57, 0xaf, 0xc0, 0x83,
-	0xd6, 0x47, 0xc4, 0xfb, 0x99, 0x40, 0xef, 0xcb, 0x50, 0x6a, 0x6a, 0x4c, 0xd2, 0xf7, 0xc0, 0x52,
-	0xd6, 0x14, 0xbd, 0x77, 0xfc, 0x4a, 0xd6, 0x57, 0xbf, 0x32, 0x27, 0xac, 0xeb, 0xdc, 0x27, 0xd0,
-	0xab, 0x84, 0x1b, 0x16, 0x7f, 0xa7, 0xba, 0x78, 0xef, 0xf0, 0xd5, 0x06, 0x27, 0xaa, 0x8c, 0x4e,
-	0xaa, 0x84, 0xfe, 0xad, 0xa5, 0x0d, 0xcd, 0x6b, 0x35, 0x36, 0xef, 0x3b, 0x18, 0xe0, 0x8c, 0x78,
-	0x79, 0xae, 0x16, 0x22, 0xbd, 0xbe, 0x93, 0x73, 0x1f, 0x68, 0x31, 0x69, 0xb9, 0x1c, 0xbd, 0x55,
-	0xeb, 0x20, 0x51, 0x7b, 0xb6, 0x12, 0xf1, 0x7e, 0x23, 0xb0, 0x57, 0x0c, 0x3b, 0xc6, 0x79, 0x24,
-	0xfd, 0x04, 0xec, 0xa0, 0x60, 0x97, 0x1b, 0x7f, 0xcb, 0xaf, 0xd7, 0x6c, 0x60, 0x6e, 0x7f, 0x39,
-	0xc0, 0xfd, 0xba, 0x9c, 0xef, 0xbf, 0x37, 0xe1, 0x22, 0xf1, 0x6a, 0x13, 0x7e, 0x21, 0xdb, 0x9e,
-	0x49, 0x7a, 0x1f, 0x2c, 0x25, 0xbb, 0x60, 0x78, 0xc3, 0xdf, 0xaa, 0xf0, 0x35, 0xd3, 0x7c, 0x7b,
-	0xe8, 0x5a, 0xf7, 0x73, 0xe8, 0x55, 0xc2, 0x0d, 0xcc, 0xee, 0xd6, 0x99, 0x0d, 0xb6, 0x74, 0x57,
-	0x59, 0xfd, 0x48, 0xa0, 0x7f, 0x7a, 0xed, 0x17, 0x60, 0xf5, 0xc2, 0x6b, 0x5f, 0x76, 0xe1, 0xed,
-	0xd5, 0x18, 0xc8, 0xc3, 0xbf, 0x5b, 0x60, 0x7f, 0x81, 0x4f, 0xad, 0x93, 0xf0, 0xc5, 0x9a, 0xde,
-	0x84, 0x0e, 0x3e, 0x57, 0x56, 0x81, 0xa0, 0x1d, 0x5f, 0xbf, 0xb6, 0xdc, 0xfc, 0x43, 0x7a, 0x3b,
-	0xf4, 0xae, 0x3a, 0xa7, 0x28, 0x0e, 0xdf, 0x23, 0xb4, 0xe7, 0x97, 0x4f, 0x13, 0xb7, 0xe3, 0xeb,
-	0x3f, 0x77, 0x6f, 0x87, 0xbe, 0x01, 0x06, 0xa6, 0x2d, 0x5f, 0x67, 0xf4, 0x2f, 0x26, 0xde, 0x05,
-	0x28, 0x6f, 0x6a, 0xba, 0xeb, 0x57, 0xff, 0x0c, 0xdc, 0x1a, 0xcc, 0xab, 0x4f, 0xab, 0xd5, 0xa7,
-	0xf5, 0xea, 0xd3, 0x7a, 0xf5, 0x3d, 0x80, 0xcd, 0xb1, 0x93, 0xb4, 0x5f, 0x39, 0xf6, 0xe7, 0x6e,
-	0x15, 0x61, 0xed, 0x07, 0xb0, 0x5b, 0x6b, 0x3d, 0xdd, 0xdf, 0xda, 0x0a, 0xe7, 0xee, 0x76, 0x04,
-	0x87, 0x3d, 0x84, 0xfd, 0xed, 0xa3, 0x4f, 0x1b, 0x6e, 0x83, 0x73, 0xb7, 0xe9, 0x8a, 0xf0, 0x76,
-	0x3e, 0x6d, 0x7f, 0xdf, 0x5a, 0x9e, 0x9d, 0x59, 0xea, 0x3d, 0xfb, 0xfe, 0x3f, 0x01, 0x00, 0x00,
-	0xff, 0xff, 0xde, 0xa5, 0xf4, 0xbc, 0xe2, 0x0a, 0x00, 0x00,
+	// 1623 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x58, 0xcd, 0x8e, 0xdb, 0x46,
+	0x12, 0x36, 0x25, 0x51, 0x3f, 0x25, 0x69, 0x24, 0xb7, 0xc7, 0x6b, 0xae, 0xd6, 0x3f, 0x63, 0x1a,
+	0xb6, 0x65, 0x63, 0x4d, 0x18, 0xb3, 0x36, 0x76, 0xd7, 0x6b, 0x2c, 0x30, 0xb6, 0x17, 0xde, 0xfc,
+	0xc8, 0x99, 0x70, 0x26, 0x31, 0x90, 0x0b, 0xd1, 0x43, 0xf6, 0x68, 0x08, 0x8a, 0x3f, 0xc3, 0x6e,
+	0xd9, 0x23, 0xbf, 0x44, 0x80, 0xe4, 0x92, 0x5b, 0x90, 0x4b, 0x8e, 0x79, 0x80, 0x5c, 0x93, 0x07,
+	0xc8, 0x29, 0x79, 0x8c, 0xbc, 0x42, 0xd0, 0x3f, 0x94, 0x48, 0x4a, 0x9e, 0x09, 0xc6, 0xe3, 0x93,
+	0x58, 0x5f, 0x15, 0xbb, 0xab, 0xbe, 0x2a, 0x56, 0x57, 0x0b, 0x7a, 0x01, 0xde, 0x0f, 0x70, 0xe2,
+	0x1f, 0xcd, 0xac, 0x24, 0x8d, 0x59, 0x6c, 0xfe, 0x56, 0x81, 0xfa, 0x76, 0x1a, 0x7b, 0xf6, 0x21,
+	0x32, 0xa0, 0xe1, 0x4e, 0xa6, 0x94, 0x91, 0xd4, 0xd0, 0x36, 0xb4, 0x61, 0xcb, 0xce, 0x44, 0xb4,
+	0x0e, 0x3a, 0x8b, 0x13, 0xdf, 0x35, 0x2a, 0x02, 0x97, 0x02, 0xfa, 0x1b, 0xb4, 0x02, 0x32, 0x73,
+	0x5e, 0xe1, 0xc9, 0x94, 0x18, 0xd5, 0x0d, 0x6d, 0xd8, 0xb1, 0x9b, 0x01, 0x99, 0x7d, 0xce, 0x65,
+	0x74, 0x03, 0xba, 0x5c, 0x39, 0x8d, 0x3c, 0xb2, 0xef, 0x47, 0xc4, 0x33, 0x6a, 0x1b, 0xda, 0xb0,
+	0x69, 0x77, 0x02, 0x32, 0xfb, 0x2c, 0xc3, 0xf8, 0x8e, 0x21, 0xa1, 0x14, 0x8f, 0x89, 0xa1, 0x8b,
+	0xf7, 0x33, 0x11, 0x5d, 0x01, 0xc0, 0x74, 0x16, 0xb9, 0x4e, 0x18, 0x7b, 0xc4, 0xa8, 0x8b, 0x77,
+	0x5b, 0x02, 0x19, 0xc5, 0x9e, 0x58, 0x3d, 0x25, 0x87, 0x53, 0x3f, 0x25, 0x9e, 0x83, 0xdd, 0x80,
+	0x1a, 0x0d, 0xe1, 0x58, 0x27, 0x03, 0xb7, 0xdc, 0x80, 0xa2, 0x0d, 0x68, 0xbb, 0x71, 0x98, 0xa4,
+	0x84, 0x52, 0x3f, 0x8e, 0x8c, 0xa6, 0x30, 0xc9, 0x43, 0xe8, 0x3a, 0x74, 0x98, 0x1f, 0x12, 0xca,
+	0x70, 0x98, 0x38, 0x21, 0x35, 0x5a, 0x1b, 0xda, 0xb0, 0x6a, 0xb7, 0xe7, 0xd8, 0x88, 0xf2, 0x20,
+	0xdd, 0x89, 0x4f, 0x22, 0xe6, 0xf8, 0x9e, 0x01, 0x62, 0x89, 0xa6, 0x04, 0x3e, 0xf0, 0xb8, 0x32,
+	0x25, 0x2c, 0x9d, 0x39, 0x21, 0x3e, 0x32, 0xda, 0x1b, 0xda, 0x50, 0xb7, 0x9b, 0x02, 0x18, 0xe1,
+	0x23, 0xf3, 0x07, 0x4d, 0x31, 0x4b, 0xd1, 0x65, 0x68, 0x25, 0x38, 0x65, 0x3e, 0xe3, 0x7e, 0x68,
+	0xc2, 0x6e, 0x01, 0xa0, 0xbf, 0x40, 0x3d, 0xde, 0xdf, 0xa7, 0x84, 0x09, 0x7a, 0xab, 0xb6, 0x92,
+	0x96, 0x83, 0xac, 0xae, 0x08, 0xf2, 0x36, 0xf4, 0x28, 0x49, 0x7d, 0x3c, 0xf1, 0xdf, 0x10, 0xcf,
+	0xa1, 0xfe, 0x1b, 0x22, 0x98, 0xd6, 0xed, 0xb5, 0x05, 0xbc, 0xe3, 0xbf, 0x21, 0x65, 0x36, 0xf4,
+	0x25, 0x36, 0xcc, 0x9f, 0x2a, 0x00, 0x4f, 0xe3, 0x88, 0xbe, 0xd8, 0x72, 0x83, 0x53, 0x94, 0xc3,
+	0x3a, 0xe8, 0xe3, 0x34, 0x9e, 0x26, 0xca, 0x4d, 0x29, 0xa0, 0x8b, 0x50, 0x8f, 0x62, 0xee, 0xbe,
+	0x2a, 0x00, 0x3d, 0x8a, 0xb7, 0xdc, 0x00, 0xfd, 0x15, 0x9a, 0x78, 0xca, 0xa4, 0x42, 0x17, 0x8a,
+	0x06, 0x97, 0xb9, 0xea, 0x06, 0x74, 0xb1, 0x1b, 0x38, 0x0b, 0xc2, 0xea, 0x22, 0x9e, 0x0e, 0x76,
+	0x83, 0xed, 0x39, 0x67, 0xbc, 0x3e, 0xdc, 0xc0, 0x51, 0xbc, 0x35, 0x04, 0x6f, 0x2d, 0xec, 0x06,
+	0x9f, 0x48, 0xea, 0x1e, 0xc2, 0xa5, 0x49, 0x1c, 0x8d, 0x9d, 0x24, 0x9e, 0x4c, 0xfc, 0x68, 0xec,
+	0xf0, 0x8c, 0xc6, 0x53, 0xc6, 0x73, 0xdc, 0x14, 0xb6, 0xeb, 0x5c, 0xbd, 0x2d, 0xb5, 0xbb, 0x52,
+	0x39, 0xa2, 0xe8, 0x26, 0xac, 0xf9, 0x91, 0xcf, 0x7c, 0x3c, 0xc9, 0x56, 0x6e, 0x89, 0x58, 0xba,
+	0x0a, 0x55, 0xab, 0x1f, 0x57, 0x13, 0xe6, 0x37, 0x15, 0xa8, 0x73, 0x16, 0x4f, 0x9d, 0xf6, 0xf7,
+	0xf9, 0x59, 0xdd, 0x82, 0xde, 0x81, 0x3f, 0x3e, 0x70, 0x5e, 0x63, 0x46, 0x52, 0x27, 0xc4, 0x69,
+	0x20, 0xd8, 0xad, 0xda, 0x5d, 0x0e, 0xbf, 0xe4, 0xe8, 0x08, 0xa7, 0x01, 0xba, 0x03, 0x7d, 0x37,
+	0x0e, 0x43, 0x9f, 0x31, 0xe2, 0x15, 0x49, 0xee, 0xcd, 0x71, 0x45, 0xc6, 0x1d, 0xe8, 0x4f, 0xfc,
+	0x80, 0x4c, 0x66, 0x8e, 0x37, 0x4d, 0x26, 0xbe, 0x8b, 0x19, 0x11, 0x1c, 0x37, 0xed, 0x9e, 0xc4,
+	0x9f, 0x65, 0xb0, 0xf9, 0x8b, 0x06, 0x1d, 0x4e, 0xcd, 0x0e, 0x4b, 0x09, 0x0e, 0xcf, 0xac, 0xc4,
+	0xf2, 0xb5, 0x54, 0x3b, 0xa1, 0x96, 0xf4, 0x13, 0x6b, 0xa9, 0x5e, 0xae, 0xa5, 0x42, 0xb6, 0x1b,
+	0xe5, 0x6c, 0x6b, 0xa0, 0x9f, 0xe5, 0xe7, 0x52, 0x28, 0x99, 0xda, 0xdb, 0x4b, 0x46, 0x2f, 0x94,
+	0xcc, 0x05, 0xd0, 0xa7, 0x89, 0xc3, 0x62, 0xd5, 0x28, 0x6b, 0xd3, 0x64, 0x37, 0x36, 0x1b, 0xd2,
+	0x33, 0x6a, 0x7e, 0x5b, 0x81, 0xde, 0x3c, 0x5a, 0x15, 0xd4, 0xf1, 0xa5, 0xb9, 0x0e, 0xfa, 0x1e,
+	0x19, 0xfb, 0x91, 0xaa, 0x4c, 0x29, 0xa0, 0x3e, 0x54, 0x49, 0xe4, 0x09, 0x7f, 0xab, 0x36, 0x7f,
+	0xe4, 0x76, 0x6e, 0x3c, 0x8d, 0x98, 0xf0, 0xb4, 0x6a, 0x4b, 0xe1, 0xad, 0x5e, 0xf6, 0xa1, 0x3a,
+	0xc1, 0x63, 0x45, 0x30, 0x7f, 0x44, 0x03, 0x68, 0x86, 0x84, 0x61, 0x0f, 0x33, 0x9c, 0x31, 0x9b,
+	0xc9, 0xe8, 0x1a, 0xb4, 0x69, 0x82, 0x53, 0x4a, 0x64, 0xef, 0x93, 0xdd, 0x1b, 0x24, 0x24, 0x3a,
+	0xdf, 0x75, 0xe8, 0x2c, 0x6a, 0x74, 0x6f, 0x66, 0xb4, 0xe6, 0x1d, 0x4d, 0x62, 0x4f, 0x66, 0xbc,
+	0xdc, 0x17, 0x26, 0x58, 0x7c, 0xfe, 0x20, 0xcb, 0x7d, 0x0e, 0x6f, 0xb1, 0x11, 0x35, 0x77, 0xa1,
+	0xf3, 0x9c, 0x30, 0x49, 0x0d, 0x3d, 0xab, 0x5c, 0x9a, 0x8f, 0x0a, 0xab, 0x52, 0x74, 0x17, 0x1a,
+	0x92, 0x09, 0x6a, 0x68, 0x1b, 0xd5, 0x61, 0x7b, 0xb3, 0x6f, 0x95, 0xd2, 0x62, 0x67, 0x06, 0xe6,
+	0x53, 0x38, 0xff, 0x9c, 0xb0, 0x5d, 0xbe, 0xfa, 0xa9, 0xdd, 0x32, 0x53, 0x58, 0x2f, 0x6f, 0x80,
+	0xa3, 0x31, 0x79, 0x9f, 0xc9, 0x37, 0x9f, 0x2c, 0x3b, 0x4e, 0xd1, 0x3d, 0xa8, 0xa7, 0x7c, 0xe7,
+	0x2c, 0xf0, 0x8b, 0xd6, 0x2a, 0xbf, 0x6c, 0x65, 0x64, 0xbe, 0x86, 0xf3, 0x73, 0xfd, 0x28, 0xab,
+	0x87, 0x13, 0x9b, 0xe9, 0x84, 0x60, 0x8f, 0xa4, 0xc2, 0x6b, 0xdd, 0x56, 0x12, 0xaf, 0xb0, 0x94,
+	0x88, 0xfe, 0xc3, 0x8f, 0xcf, 0xaa, 0x3c, 0xa0, 0xa5, 0xcc, 0x43, 0xf2, 0x69, 0x6a, 0xd4, 0x04,
+	0xcc, 0x1f, 0xcd, 0x10, 0x50, 0xe6, 0x7c, 0xb6, 0xef, 0x29, 0xaa, 0xe1, 0x36, 0xf4, 0x5e, 0xfb,
+	0xec, 0x60, 0xd1, 0x75, 0xe4, 0xc9, 0xdd, 0xb4, 0xd7, 0x38, 0x3c, 0x8f, 0x8c, 0x9a, 0xbf, 0x6a,
+	0x2b, 0xf6, 0xa3, 0x7c, 0xbf, 0x57, 0x24, 0xa5, 0x8b, 0x38, 0x33, 0x11, 0xfd, 0x13, 0xea, 0x6e,
+	0x1c, 0xed, 0xfb, 0x63, 0xa3, 0x22, 0x78, 0xbc, 0x66, 0x2d, 0xbf, 0x6e, 0x3d, 0x15, 0x16, 0xff,
+	0x8b, 0x58, 0x3a, 0xb3, 0x95, 0x39, 0xda, 0x04, 0x28, 0x78, 0xc3, 0x5f, 0x46, 0xd6, 0x12, 0xc9,
+	0x76, 0xce, 0x6a, 0xf0, 0x6f, 0x68, 0xe7, 0x96, 0xe2, 0x6c, 0x05, 0x64, 0xa6, 0x18, 0xe0, 0x8f,
+	0x3c, 0x7a, 0x79, 0x48, 0xa9, 0xe8, 0x85, 0xf0, 0xa8, 0xf2, 0x2f, 0xcd, 0xfc, 0x52, 0x83, 0xf6,
+	0xc7, 0x3e, 0x95, 0xae, 0xd9, 0x14, 0xdd, 0x87, 0xba, 0xa0, 0x26, 0xcb, 0xbf, 0x61, 0xe5, 0xb4,
+	0x96, 0xf8, 0xa5, 0xca, 0x61, 0x69, 0x37, 0x78, 0x01, 0xed, 0x1c, 0xbc, 0x62, 0xf3, 0x3b, 0xf9,
+	0xcd, 0xdb, 0x9b, 0x17, 0x56, 0x30, 0x91, 0xf7, 0xe8, 0xf7, 0x82, 0x47, 0xc7, 0xe5, 0x74, 0x45,
+	0xf6, 0x2a, 0xab, 0xb2, 0xc7, 0x4b, 0x2e, 0x49, 0xc9, 0xbe, 0x7f, 0xa4, 0xbe, 0x7a, 0x25, 0xf1,
+	0xa5, 0x13, 0xcc, 0x18, 0x49, 0x65, 0x03, 0x6f, 0xd9, 0x99, 0xc8, 0x69, 0x50, 0xe9, 0xd3, 0x97,
+	0x68, 0x38, 0x5c, 0x95, 0xb7, 0x77, 0xc9, 0xc1, 0x4b, 0xe8, 0xf1, 0xd5, 0xf9, 0x79, 0x3b, 0x0d,
+	0x49, 0x7a, 0x76, 0x6d, 0xed, 0x01, 0xa0, 0x6c, 0xd1, 0x1c, 0x1b, 0x57, 0x0b, 0x15, 0xa6, 0x89,
+	0x6f, 0x2a, 0x87, 0x98, 0xdf, 0x69, 0xb0, 0x96, 0xbd, 0xf6, 0x9c, 0xaf, 0x43, 0xd1, 0x63, 0x68,
+	0xb9, 0x99, 0x77, 0xaa, 0x30, 0xae, 0x5a, 0x45, 0x9b, 0xb9, 0xa8, 0xca, 0x63, 0xf1, 0xc2, 0xe0,
+	0x53, 0x58, 0x2b, 0x2a, 0xff, 0x4c, 0x91, 0x2c, 0x3b, 0x9e, 0xa7, 0xec, 0x6b, 0xad, 0xcc, 0x19,
+	0x45, 0x0f, 0xa0, 0x2e, 0xc2, 0xce, 0x3c, 0xbc, 0x6c, 0x95, 0x2c, 0x2c, 0xe9, 0xa9, 0xca, 0x9b,
+	0xb4, 0x1d, 0x7c, 0x08, 0xed, 0x1c, 0xbc, 0xc2, 0xb3, 0x9b, 0x45, 0xcf, 0x7a, 0xa5, 0xb8, 0xf3,
+	0x5e, 0x0d, 0xa1, 0xc3, 0xb7, 0x54, 0x8a, 0x63, 0xb2, 0x68, 0xde, 0x2a, 0x58, 0x8a, 0x0a, 0xcd,
+	0xf9, 0xde, 0xca, 0xbc, 0x33, 0xb7, 0xa0, 0xf7, 0x8c, 0x50, 0x37, 0xf5, 0xf7, 0x88, 0xb0, 0x3d,
+	0xa9, 0x34, 0x64, 0x11, 0x54, 0xf2, 0x45, 0xf0, 0x55, 0x45, 0x45, 0x38, 0x22, 0xe1, 0x1e, 0x49,
+	0xf9, 0x90, 0x14, 0x8a, 0x27, 0x3e, 0x24, 0x69, 0xd9, 0x51, 0xce, 0x01, 0x79, 0x4d, 0x5a, 0x4c,
+	0x50, 0x95, 0xd2, 0x1d, 0xea, 0x1a, 0xb4, 0x95, 0xf2, 0x20, 0xa6, 0x4c, 0x95, 0x1a, 0x48, 0xe8,
+	0xff, 0x31, 0x15, 0xe3, 0x84, 0x6a, 0x1e, 0x35, 0x19, 0x85, 0x94, 0xd0, 0x63, 0x7e, 0x45, 0xa4,
+	0xfe, 0x38, 0x0a, 0x49, 0xc4, 0xd4, 0x17, 0x75, 0xd9, 0xca, 0x39, 0x65, 0x6d, 0xcd, 0xd5, 0x32,
+	0x3b, 0x39, 0xfb, 0x81, 0x0d, 0xbd, 0x92, 0xfa, 0xdd, 0xeb, 0xe7, 0x7b, 0xad, 0x4c, 0x2c, 0x5d,
+	0xd0, 0xa7, 0xe5, 0xc7, 0xbc, 0x75, 0xd0, 0x29, 0xc3, 0x4c, 0x2e, 0xdc, 0xb2, 0xa5, 0xc0, 0xa7,
+	0x55, 0x71, 0x29, 0x77, 0xe3, 0x89, 0xc3, 0x66, 0x09, 0xc9, 0x2e, 0x7c, 0x19, 0xb8, 0x3b, 0x4b,
+	0x08, 0x3f, 0xd1, 0x32, 0x59, 0xf5, 0x97, 0xb9, 0x8c, 0x6e, 0xf1, 0xc1, 0x9f, 0x87, 0x4e, 0x15,
+	0x1f, 0x9d, 0x3c, 0x1f, 0x76, 0xa6, 0x34, 0x7f, 0xd6, 0xa0, 0xb3, 0x73, 0xe6, 0x03, 0x4f, 0x7e,
+	0xc0, 0xa9, 0x9d, 0x30, 0xe0, 0xf0, 0xe9, 0x2d, 0x25, 0x8c, 0x44, 0x5c, 0xc7, 0xe7, 0x32, 0x39,
+	0x2a, 0xb6, 0xe7, 0xd8, 0x88, 0xf2, 0xca, 0x88, 0x62, 0xc7, 0x23, 0x6e, 0x4a, 0x30, 0xcd, 0xfe,
+	0x04, 0x80, 0x28, 0x7e, 0xa6, 0x10, 0x73, 0xad, 0x10, 0x05, 0xdd, 0xfc, 0xb1, 0x06, 0xad, 0x8f,
+	0xf8, 0xff, 0x1b, 0xdb, 0xfe, 0xd1, 0x0c, 0x5d, 0x81, 0x06, 0xbf, 0x7e, 0x4f, 0x5d, 0x82, 0x1a,
+	0x96, 0xfc, 0x8b, 0x63, 0xa0, 0x1e, 0xa8, 0x79, 0x0e, 0xdd, 0x84, 0xb6, 0xca, 0x26, 0xbf, 0xef,
+	0xa2, 0xb6, 0xb5, 0xb8, 0xfa, 0x0e, 0x1a, 0x96, 0xbc, 0xc1, 0x99, 0xe7, 0xd0, 0x25, 0xa8, 0x72,
+	0x75, 0xdd, 0x92, 0x1a, 0xf9, 0xcb, 0x15, 0x7f, 0x07, 0x58, 0x4c, 0x77, 0xa8, 0x6b, 0xe5, 0x07,
+	0xc8, 0x41, 0x41, 0xe4, 0xd6, 0xff, 0x81, 0x5e, 0x69, 0x2c, 0x42, 0xc8, 0x5a, 0x9a, 0xf0, 0x06,
+	0xcb, 0x98, 0xda, 0x6a, 0x27, 0xbf, 0xd5, 0x4e, 0x71, 0xab, 0x9d, 0xe2, 0x56, 0x77, 0x01, 0xe6,
+	0xc7, 0x0a, 0x45, 0x9d, 0xfc, 0x19, 0x33, 0xc8, 0x4b, 0xdc, 0xf6, 0x21, 0x74, 0x0b, 0xed, 0x0c,
+	0xf5, 0x4b, 0xed, 0xed, 0x70, 0x50, 0x46, 0xf8, 0x6b, 0xff, 0x85, 0x7e, 0xf9, 0xb8, 0x45, 0x2b,
+	0x4e, 0xe0, 0xc3, 0xc1, 0x0a, 0x50, 0x05, 0xb4, 0x68, 0x54, 0xa8, 0x6b, 0xe5, 0xfb, 0xdb, 0xa0,
+	0x20, 0x2a, 0x27, 0x0b, 0x5f, 0x15, 0xea, 0x5b, 0xa5, 0xf6, 0x35, 0x28, 0x23, 0xfc, 0xb5, 0x7b,
+	0xd0, 0x55, 0x5e, 0xcb, 0xfb, 0x26, 0xea, 0x5a, 0xf9, 0xcb, 0x67, 0x2e, 0xc9, 0x43, 0xed, 0xbe,
+	0xf6, 0xa4, 0xf6, 0x45, 0x25, 0xd9, 0xdb, 0xab, 0x8b, 0x6f, 0xe9, 0x1f, 0x7f, 0x0c, 0x00, 0x3d,
+	0x97, 0xc8, 0x4b, 0x28, 0x13, 0x00, 0x00,
 }
@@ -19,7 +19,7 @@ DESCRIPTOR = _descriptor.FileDescriptor(
   name='kafkapixy.proto',
   package='',
   syntax='proto3',
-  serialized_pb=_b('\n\x0fkafkapixy.proto\"w\n\x06ProdRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\x11\n\tkey_value\x18\x03 \x01(\x0c\x12\x15\n\rkey_undefined\x18\x04 \x01(\x08\x12\x0f\n\x07message\x18\x05 \x01(\x0c\x12\x12\n\nasync_mode\x18\x06 \x01(\x08\"+\n\x06ProdRs\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\x0e\n\x06offset\x18\x02 \x01(\x03\"\x88\x01\n\nConsNAckRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12\x0e\n\x06no_ack\x18\x04 \x01(\x08\x12\x10\n\x08\x61uto_ack\x18\x05 \x01(\x08\x12\x15\n\rack_partition\x18\x06 \x01(\x05\x12\x12\n\nack_offset\x18\x07 \x01(\x03\"f\n\x06\x43onsRs\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\x0e\n\x06offset\x18\x02 \x01(\x03\x12\x11\n\tkey_value\x18\x03 \x01(\x0c\x12\x15\n\rkey_undefined\x18\x04 \x01(\x08\x12\x0f\n\x07message\x18\x05 \x01(\x0c\"Y\n\x05\x41\x63kRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12\x11\n\tpartition\x18\x04 \x01(\x05\x12\x0e\n\x06offset\x18\x05 \x01(\x03\"\x07\n\x05\x41\x63kRs\"\x93\x01\n\x0fPartitionOffset\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\r\n\x05\x62\x65gin\x18\x02 \x01(\x03\x12\x0b\n\x03\x65nd\x18\x03 \x01(\x03\x12\r\n\x05\x63ount\x18\x04 \x01(\x03\x12\x0e\n\x06offset\x18\x05 \x01(\x03\x12\x0b\n\x03lag\x18\x06 \x01(\x03\x12\x10\n\x08metadata\x18\x07 \x01(\t\x12\x13\n\x0bsparse_acks\x18\x08 \x01(\t\"=\n\x0cGetOffsetsRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\"1\n\x0cGetOffsetsRs\x12!\n\x07offsets\x18\x01 \x03(\x0b\x32\x10.PartitionOffset\"U\n\x11PartitionMetadata\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\x0e\n\x06leader\x18\x02 \x01(\x05\x12\x10\n\x08replicas\x18\x03 \x03(\x05\x12\x0b\n\x03isr\x18\x04 \x03(\x05\"M\n\x12GetTopicMetadataRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\x17\n\x0fwith_partitions\x18\x03 \x01(\x08\"\xad\x01\n\x12GetTopicMetadataRs\x12\x0f\n\x07version\x18\x01 \x01(\x05\x12/\n\x06\x63onfig\x18\x02 \x03(\x0b\x32\x1f.GetTopicMetadataRs.ConfigEntry\x12&\n\npartitions\x18\x03 \x03(\x0b\x32\x12.PartitionMetadata\x1a-\n\x0b\x43onfigEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"{\n\x0bListTopicRs\x12(\n\x06topics\x18\x01 \x03(\x0b\x32\x18.ListTopicRs.TopicsEntry\x1a\x42\n\x0bTopicsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\"\n\x05value\x18\x02 \x01(\x0b\x32\x13.GetTopicMetadataRs:\x02\x38\x01\"7\n\x0bListTopicRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\x17\n\x0fwith_partitions\x18\x02 \x01(\x08\"@\n\x0fListConsumersRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\"(\n\x12\x43onsumerPartitions\x12\x12\n\npartitions\x18\x01 \x03(\x05\"\x8a\x01\n\x0e\x43onsumerGroups\x12\x31\n\tconsumers\x18\x01 \x03(\x0b\x32\x1e.ConsumerGroups.ConsumersEntry\x1a\x45\n\x0e\x43onsumersEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\"\n\x05value\x18\x02 \x01(\x0b\x32\x13.ConsumerPartitions:\x02\x38\x01\"\x7f\n\x0fListConsumersRs\x12,\n\x06groups\x18\x01 \x03(\x0b\x32\x1c.ListConsumersRs.GroupsEntry\x1a>\n\x0bGroupsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\x1e\n\x05value\x18\x02 \x01(\x0b\x32\x0f.ConsumerGroups:\x02\x38\x01\"`\n\x0cSetOffsetsRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12!\n\x07offsets\x18\x04 \x03(\x0b\x32\x10.PartitionOffset\"\x0e\n\x0cSetOffsetsRs2\xe9\x02\n\tKafkaPixy\x12\x1d\n\x07Produce\x12\x07.ProdRq\x1a\x07.ProdRs\"\x00\x12%\n\x0b\x43onsumeNAck\x12\x0b.ConsNAckRq\x1a\x07.ConsRs\"\x00\x12\x17\n\x03\x41\x63k\x12\x06.AckRq\x1a\x06.AckRs\"\x00\x12,\n\nGetOffsets\x12\r.GetOffsetsRq\x1a\r.GetOffsetsRs\"\x00\x12,\n\nSetOffsets\x12\r.SetOffsetsRq\x1a\r.SetOffsetsRs\"\x00\x12*\n\nListTopics\x12\x0c.ListTopicRq\x1a\x0c.ListTopicRs\"\x00\x12\x35\n\rListConsumers\x12\x10.ListConsumersRq\x1a\x10.ListConsumersRs\"\x00\x12>\n\x10GetTopicMetadata\x12\x13.GetTopicMetadataRq\x1a\x13.GetTopicMetadataRs\"\x00\x42\x04Z\x02pbb\x06proto3')
+  serialized_pb=_b('\n\x0fkafkapixy.proto\"\xdf\x01\n\x06ProdRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\x11\n\tkey_value\x18\x03 \x01(\x0c\x12\x15\n\rkey_undefined\x18\x04 \x01(\x08\x12\x0f\n\x07message\x18\x05 \x01(\x0c\x12\x12\n\nasync_mode\x18\x06 \x01(\x08\x12\x15\n\rrequired_acks\x18\x07 \x01(\t\x12\x13\n\x0b\x63ompression\x18\x08 \x01(\t\x12\x14\n\x0ctimestamp_ms\x18\t \x01(\x03\x12\x11\n\tclient_id\x18\n \x01(\t\x12\x11\n\tretry_max\x18\x0b \x01(\x05\"p\n\x06ProdRs\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\x0e\n\x06offset\x18\x02 \x01(\x03\x12\x15\n\rrequired_acks\x18\x03 \x01(\t\x12\x17\n\x0fserialized_size\x18\x04 \x01(\x05\x12\x13\n\x0b\x63ompression\x18\x05 \x01(\t\"\xd4\x01\n\nConsNAckRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12\x0e\n\x06no_ack\x18\x04 \x01(\x08\x12\x10\n\x08\x61uto_ack\x18\x05 \x01(\x08\x12\x15\n\rack_partition\x18\x06 \x01(\x05\x12\x12\n\nack_offset\x18\x07 \x01(\x03\x12\x1f\n\x17long_polling_timeout_ms\x18\x08 \x01(\x03\x12\x16\n\x0einitial_offset\x18\t \x01(\t\x12\x11\n\tclient_id\x18\n \x01(\t\"\xb3\x01\n\x06\x43onsRs\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\x0e\n\x06offset\x18\x02 \x01(\x03\x12\x11\n\tkey_value\x18\x03 \x01(\x0c\x12\x15\n\rkey_undefined\x18\x04 \x01(\x08\x12\x0f\n\x07message\x18\x05 \x01(\x0c\x12\x17\n\x0fhigh_water_mark\x18\x06 \x01(\x03\x12\x18\n\x10\x63ommitted_offset\x18\x07 \x01(\x03\x12\x18\n\x10likely_duplicate\x18\x08 \x01(\x08\"\x8d\x01\n\x0c\x43onsStreamRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12\x10\n\x08\x61uto_ack\x18\x04 \x01(\x08\x12\x15\n\rack_partition\x18\x05 \x01(\x05\x12\x12\n\nack_offset\x18\x06 \x01(\x03\x12\x11\n\tclient_id\x18\x07 \x01(\t\"h\n\x05\x41\x63kRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12\x11\n\tpartition\x18\x04 \x01(\x05\x12\x0e\n\x06offset\x18\x05 \x01(\x03\x12\r\n\x05up_to\x18\x06 \x01(\x08\"\x07\n\x05\x41\x63kRs\"\xc2\x01\n\x0fPartitionOffset\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\r\n\x05\x62\x65gin\x18\x02 \x01(\x03\x12\x0b\n\x03\x65nd\x18\x03 \x01(\x03\x12\r\n\x05\x63ount\x18\x04 \x01(\x03\x12\x0e\n\x06offset\x18\x05 \x01(\x03\x12\x0b\n\x03lag\x18\x06 \x01(\x03\x12\x10\n\x08metadata\x18\x07 \x01(\t\x12\x13\n\x0bsparse_acks\x18\x08 \x01(\t\x12\x14\n\x0c\x63ommitted_by\x18\t \x01(\t\x12\x17\n\x0f\x63ommitted_at_ms\x18\n \x01(\x03\"=\n\x0cGetOffsetsRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\"1\n\x0cGetOffsetsRs\x12!\n\x07offsets\x18\x01 \x03(\x0b\x32\x10.PartitionOffset\"3\n\x11GetTopicOffsetsRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\"T\n\x14PartitionOffsetRange\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\r\n\x05\x62\x65gin\x18\x02 \x01(\x03\x12\x0b\n\x03\x65nd\x18\x03 \x01(\x03\x12\r\n\x05\x63ount\x18\x04 \x01(\x03\":\n\x11GetTopicOffsetsRs\x12%\n\x06ranges\x18\x01 \x03(\x0b\x32\x15.PartitionOffsetRange\"U\n\x11PartitionMetadata\x12\x11\n\tpartition\x18\x01 \x01(\x05\x12\x0e\n\x06leader\x18\x02 \x01(\x05\x12\x10\n\x08replicas\x18\x03 \x03(\x05\x12\x0b\n\x03isr\x18\x04 \x03(\x05\"M\n\x12GetTopicMetadataRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\x17\n\x0fwith_partitions\x18\x03 \x01(\x08\"\xad\x01\n\x12GetTopicMetadataRs\x12\x0f\n\x07version\x18\x01 \x01(\x05\x12/\n\x06\x63onfig\x18\x02 \x03(\x0b\x32\x1f.GetTopicMetadataRs.ConfigEntry\x12&\n\npartitions\x18\x03 \x03(\x0b\x32\x12.PartitionMetadata\x1a-\n\x0b\x43onfigEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"{\n\x0bListTopicRs\x12(\n\x06topics\x18\x01 \x03(\x0b\x32\x18.ListTopicRs.TopicsEntry\x1a\x42\n\x0bTopicsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\"\n\x05value\x18\x02 \x01(\x0b\x32\x13.GetTopicMetadataRs:\x02\x38\x01\"\xb1\x01\n\x0bListTopicRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\x17\n\x0fwith_partitions\x18\x02 \x01(\x08\x12\x0e\n\x06prefix\x18\x03 \x01(\t\x12\x0f\n\x07pattern\x18\x04 \x01(\t\x12(\n\x06\x63onfig\x18\x05 \x03(\x0b\x32\x18.ListTopicRq.ConfigEntry\x1a-\n\x0b\x43onfigEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\t:\x02\x38\x01\"@\n\x0fListConsumersRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\"(\n\x12\x43onsumerPartitions\x12\x12\n\npartitions\x18\x01 \x03(\x05\"\x8a\x01\n\x0e\x43onsumerGroups\x12\x31\n\tconsumers\x18\x01 \x03(\x0b\x32\x1e.ConsumerGroups.ConsumersEntry\x1a\x45\n\x0e\x43onsumersEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\"\n\x05value\x18\x02 \x01(\x0b\x32\x13.ConsumerPartitions:\x02\x38\x01\"\x7f\n\x0fListConsumersRs\x12,\n\x06groups\x18\x01 \x03(\x0b\x32\x1c.ListConsumersRs.GroupsEntry\x1a>\n\x0bGroupsEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\x1e\n\x05value\x18\x02 \x01(\x0b\x32\x0f.ConsumerGroups:\x02\x38\x01\"\x1f\n\x0cListGroupsRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\"\x1e\n\x0cListGroupsRs\x12\x0e\n\x06groups\x18\x01 \x03(\t\"1\n\x0f\x44\x65scribeGroupRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05group\x18\x02 \x01(\t\"\xd2\x01\n\x0bGroupMember\x12\x11\n\tmember_id\x18\x01 \x01(\t\x12\x11\n\tclient_id\x18\x02 \x01(\t\x12\x13\n\x0b\x63lient_host\x18\x03 \x01(\t\x12\x0e\n\x06topics\x18\x04 \x03(\t\x12\x30\n\nassignment\x18\x05 \x03(\x0b\x32\x1c.GroupMember.AssignmentEntry\x1a\x46\n\x0f\x41ssignmentEntry\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\"\n\x05value\x18\x02 \x01(\x0b\x32\x13.ConsumerPartitions:\x02\x38\x01\"w\n\x0f\x44\x65scribeGroupRs\x12\r\n\x05group\x18\x01 \x01(\t\x12\r\n\x05state\x18\x02 \x01(\t\x12\x15\n\rprotocol_type\x18\x03 \x01(\t\x12\x10\n\x08protocol\x18\x04 \x01(\t\x12\x1d\n\x07members\x18\x05 \x03(\x0b\x32\x0c.GroupMember\"\x8b\x01\n\x0cSetOffsetsRq\x12\x0f\n\x07\x63luster\x18\x01 \x01(\t\x12\r\n\x05topic\x18\x02 \x01(\t\x12\r\n\x05group\x18\x03 \x01(\t\x12!\n\x07offsets\x18\x04 \x03(\x0b\x32\x10.PartitionOffset\x12\x14\n\x0cretention_ms\x18\x05 \x01(\x03\x12\x13\n\x0bno_decrease\x18\x06 \x01(\x08\"\x0e\n\x0cSetOffsetsRs2\xba\x04\n\tKafkaPixy\x12\x1d\n\x07Produce\x12\x07.ProdRq\x1a\x07.ProdRs\"\x00\x12%\n\x0b\x43onsumeNAck\x12\x0b.ConsNAckRq\x1a\x07.ConsRs\"\x00\x12\x17\n\x03\x41\x63k\x12\x06.AckRq\x1a\x06.AckRs\"\x00\x12,\n\nGetOffsets\x12\r.GetOffsetsRq\x1a\r.GetOffsetsRs\"\x00\x12;\n\x0fGetTopicOffsets\x12\x12.GetTopicOffsetsRq\x1a\x12.GetTopicOffsetsRs\"\x00\x12,\n\nSetOffsets\x12\r.SetOffsetsRq\x1a\r.SetOffsetsRs\"\x00\x12*\n\nListTopics\x12\x0c.ListTopicRq\x1a\x0c.ListTopicRs\"\x00\x12\x35\n\rListConsumers\x12\x10.ListConsumersRq\x1a\x10.ListConsumersRs\"\x00\x12>\n\x10GetTopicMetadata\x12\x13.GetTopicMetadataRq\x1a\x13.GetTopicMetadataRs\"\x00\x12,\n\nListGroups\x12\r.ListGroupsRq\x1a\r.ListGroupsRs\"\x00\x12\x35\n\rDescribeGroup\x12\x10.DescribeGroupRq\x1a\x10.DescribeGroupRs\"\x00\x12-\n\rConsumeStream\x12\r.ConsStreamRq\x1a\x07.ConsRs\"\x00(\x01\x30\x01\x42\x04Z\x02pbb\x06proto3')
 )
 
 
@@ -74,6 +74,41 @@ _PRODRQ = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='required_acks', full_name='ProdRq.required_acks', index=6,
+      number=7, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='compression', full_name='ProdRq.compression', index=7,
+      number=8, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='timestamp_ms', full_name='ProdRq.timestamp_ms', index=8,
+      number=9, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='client_id', full_name='ProdRq.client_id', index=9,
+      number=10, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='retry_max', full_name='ProdRq.retry_max', index=10,
+      number=11, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -86,8 +121,8 @@ _PRODRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=19,
-  serialized_end=138,
+  serialized_start=20,
+  serialized_end=243,
 )
 
 
@@ -112,6 +147,27 @@ _PRODRS = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='required_acks', full_name='ProdRs.required_acks', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='serialized_size', full_name='ProdRs.serialized_size', index=3,
+      number=4, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='compression', full_name='ProdRs.compression', index=4,
+      number=5, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -124,8 +180,8 @@ _PRODRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=140,
-  serialized_end=183,
+  serialized_start=245,
+  serialized_end=357,
 )
 
 
@@ -185,6 +241,27 @@ _CONSNACKRQ = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='long_polling_timeout_ms', full_name='ConsNAckRq.long_polling_timeout_ms', index=7,
+      number=8, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='initial_offset', full_name='ConsNAckRq.initial_offset', index=8,
+      number=9, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='client_id', full_name='ConsNAckRq.client_id', index=9,
+      number=10, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -197,8 +274,8 @@ _CONSNACKRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=186,
-  serialized_end=322,
+  serialized_start=360,
+  serialized_end=572,
 )
 
 
@@ -244,6 +321,100 @@ _CONSRS = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='high_water_mark', full_name='ConsRs.high_water_mark', index=5,
+      number=6, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='committed_offset', full_name='ConsRs.committed_offset', index=6,
+      number=7, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='likely_duplicate', full_name='ConsRs.likely_duplicate', index=7,
+      number=8, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=575,
+  serialized_end=754,
+)
+
+
+_CONSSTREAMRQ = _descriptor.Descriptor(
+  name='ConsStreamRq',
+  full_name='ConsStreamRq',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='cluster', full_name='ConsStreamRq.cluster', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='topic', full_name='ConsStreamRq.topic', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='group', full_name='ConsStreamRq.group', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='auto_ack', full_name='ConsStreamRq.auto_ack', index=3,
+      number=4, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='ack_partition', full_name='ConsStreamRq.ack_partition', index=4,
+      number=5, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='ack_offset', full_name='ConsStreamRq.ack_offset', index=5,
+      number=6, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='client_id', full_name='ConsStreamRq.client_id', index=6,
+      number=7, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -256,8 +427,8 @@ _CONSRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=324,
-  serialized_end=426,
+  serialized_start=757,
+  serialized_end=898,
 )
 
 
@@ -303,6 +474,13 @@ _ACKRQ = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='up_to', full_name='AckRq.up_to', index=5,
+      number=6, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -315,8 +493,8 @@ _ACKRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=428,
-  serialized_end=517,
+  serialized_start=900,
+  serialized_end=1004,
 )
 
 
@@ -339,8 +517,8 @@ _ACKRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=519,
-  serialized_end=526,
+  serialized_start=1006,
+  serialized_end=1013,
 )
 
 
@@ -407,6 +585,20 @@ _PARTITIONOFFSET = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='committed_by', full_name='PartitionOffset.committed_by', index=8,
+      number=9, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='committed_at_ms', full_name='PartitionOffset.committed_at_ms', index=9,
+      number=10, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -419,8 +611,8 @@ _PARTITIONOFFSET = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=529,
-  serialized_end=676,
+  serialized_start=1016,
+  serialized_end=1210,
 )
 
 
@@ -464,8 +656,8 @@ _GETOFFSETSRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=678,
-  serialized_end=739,
+  serialized_start=1212,
+  serialized_end=1273,
 )
 
 
@@ -495,8 +687,129 @@ _GETOFFSETSRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=741,
-  serialized_end=790,
+  serialized_start=1275,
+  serialized_end=1324,
+)
+
+
+_GETTOPICOFFSETSRQ = _descriptor.Descriptor(
+  name='GetTopicOffsetsRq',
+  full_name='GetTopicOffsetsRq',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='cluster', full_name='GetTopicOffsetsRq.cluster', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='topic', full_name='GetTopicOffsetsRq.topic', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1326,
+  serialized_end=1377,
+)
+
+
+_PARTITIONOFFSETRANGE = _descriptor.Descriptor(
+  name='PartitionOffsetRange',
+  full_name='PartitionOffsetRange',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='partition', full_name='PartitionOffsetRange.partition', index=0,
+      number=1, type=5, cpp_type=1, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='begin', full_name='PartitionOffsetRange.begin', index=1,
+      number=2, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='end', full_name='PartitionOffsetRange.end', index=2,
+      number=3, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='count', full_name='PartitionOffsetRange.count', index=3,
+      number=4, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1379,
+  serialized_end=1463,
+)
+
+
+_GETTOPICOFFSETSRS = _descriptor.Descriptor(
+  name='GetTopicOffsetsRs',
+  full_name='GetTopicOffsetsRs',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='ranges', full_name='GetTopicOffsetsRs.ranges', index=0,
+      number=1, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=1465,
+  serialized_end=1523,
 )
 
 
@@ -547,8 +860,8 @@ _PARTITIONMETADATA = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=792,
-  serialized_end=877,
+  serialized_start=1525,
+  serialized_end=1610,
 )
 
 
@@ -592,8 +905,8 @@ _GETTOPICMETADATARQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=879,
-  serialized_end=956,
+  serialized_start=1612,
+  serialized_end=1689,
 )
 
 
@@ -630,8 +943,8 @@ _GETTOPICMETADATARS_CONFIGENTRY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1087,
-  serialized_end=1132,
+  serialized_start=1820,
+  serialized_end=1865,
 )
 
 _GETTOPICMETADATARS = _descriptor.Descriptor(
@@ -674,8 +987,8 @@ _GETTOPICMETADATARS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=959,
-  serialized_end=1132,
+  serialized_start=1692,
+  serialized_end=1865,
 )
 
 
@@ -712,8 +1025,8 @@ _LISTTOPICRS_TOPICSENTRY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1191,
-  serialized_end=1257,
+  serialized_start=1924,
+  serialized_end=1990,
 )
 
 _LISTTOPICRS = _descriptor.Descriptor(
@@ -742,20 +1055,57 @@ _LISTTOPICRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1134,
-  serialized_end=1257,
+  serialized_start=1867,
+  serialized_end=1990,
 )
 
 
-_LISTTOPICRQ = _descriptor.Descriptor(
-  name='ListTopicRq',
-  full_name='ListTopicRq',
+_LISTTOPICRQ_CONFIGENTRY = _descriptor.Descriptor(
+  name='ConfigEntry',
+  full_name='ListTopicRq.ConfigEntry',
   filename=None,
   file=DESCRIPTOR,
   containing_type=None,
   fields=[
     _descriptor.FieldDescriptor(
-      name='cluster', full_name='ListTopicRq.cluster', index=0,
+      name='key', full_name='ListTopicRq.ConfigEntry.key', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='value', full_name='ListTopicRq.ConfigEntry.value', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=_descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001')),
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2125,
+  serialized_end=2170,
+)
+
+_LISTTOPICRQ = _descriptor.Descriptor(
+  name='ListTopicRq',
+  full_name='ListTopicRq',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='cluster', full_name='ListTopicRq.cluster', index=0,
       number=1, type=9, cpp_type=9, label=1,
       has_default_value=False, default_value=_b("").decode('utf-8'),
       message_type=None, enum_type=None, containing_type=None,
@@ -768,10 +1118,31 @@ _LISTTOPICRQ = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='prefix', full_name='ListTopicRq.prefix', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='pattern', full_name='ListTopicRq.pattern', index=3,
+      number=4, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='config', full_name='ListTopicRq.config', index=4,
+      number=5, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
-  nested_types=[],
+  nested_types=[_LISTTOPICRQ_CONFIGENTRY, ],
   enum_types=[
   ],
   options=None,
@@ -780,8 +1151,8 @@ _LISTTOPICRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1259,
-  serialized_end=1314,
+  serialized_start=1993,
+  serialized_end=2170,
 )
 
 
@@ -825,8 +1196,8 @@ _LISTCONSUMERSRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1316,
-  serialized_end=1380,
+  serialized_start=2172,
+  serialized_end=2236,
 )
 
 
@@ -856,8 +1227,8 @@ _CONSUMERPARTITIONS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1382,
-  serialized_end=1422,
+  serialized_start=2238,
+  serialized_end=2278,
 )
 
 
@@ -894,8 +1265,8 @@ _CONSUMERGROUPS_CONSUMERSENTRY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1494,
-  serialized_end=1563,
+  serialized_start=2350,
+  serialized_end=2419,
 )
 
 _CONSUMERGROUPS = _descriptor.Descriptor(
@@ -924,8 +1295,8 @@ _CONSUMERGROUPS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1425,
-  serialized_end=1563,
+  serialized_start=2281,
+  serialized_end=2419,
 )
 
 
@@ -962,8 +1333,8 @@ _LISTCONSUMERSRS_GROUPSENTRY = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1630,
-  serialized_end=1692,
+  serialized_start=2486,
+  serialized_end=2548,
 )
 
 _LISTCONSUMERSRS = _descriptor.Descriptor(
@@ -992,8 +1363,263 @@ _LISTCONSUMERSRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1565,
-  serialized_end=1692,
+  serialized_start=2421,
+  serialized_end=2548,
+)
+
+
+_LISTGROUPSRQ = _descriptor.Descriptor(
+  name='ListGroupsRq',
+  full_name='ListGroupsRq',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='cluster', full_name='ListGroupsRq.cluster', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2550,
+  serialized_end=2581,
+)
+
+
+_LISTGROUPSRS = _descriptor.Descriptor(
+  name='ListGroupsRs',
+  full_name='ListGroupsRs',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='groups', full_name='ListGroupsRs.groups', index=0,
+      number=1, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2583,
+  serialized_end=2613,
+)
+
+
+_DESCRIBEGROUPRQ = _descriptor.Descriptor(
+  name='DescribeGroupRq',
+  full_name='DescribeGroupRq',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='cluster', full_name='DescribeGroupRq.cluster', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='group', full_name='DescribeGroupRq.group', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2615,
+  serialized_end=2664,
+)
+
+
+_GROUPMEMBER_ASSIGNMENTENTRY = _descriptor.Descriptor(
+  name='AssignmentEntry',
+  full_name='GroupMember.AssignmentEntry',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='key', full_name='GroupMember.AssignmentEntry.key', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='value', full_name='GroupMember.AssignmentEntry.value', index=1,
+      number=2, type=11, cpp_type=10, label=1,
+      has_default_value=False, default_value=None,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=_descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001')),
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2807,
+  serialized_end=2877,
+)
+
+_GROUPMEMBER = _descriptor.Descriptor(
+  name='GroupMember',
+  full_name='GroupMember',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='member_id', full_name='GroupMember.member_id', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='client_id', full_name='GroupMember.client_id', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='client_host', full_name='GroupMember.client_host', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='topics', full_name='GroupMember.topics', index=3,
+      number=4, type=9, cpp_type=9, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='assignment', full_name='GroupMember.assignment', index=4,
+      number=5, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[_GROUPMEMBER_ASSIGNMENTENTRY, ],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2667,
+  serialized_end=2877,
+)
+
+
+_DESCRIBEGROUPRS = _descriptor.Descriptor(
+  name='DescribeGroupRs',
+  full_name='DescribeGroupRs',
+  filename=None,
+  file=DESCRIPTOR,
+  containing_type=None,
+  fields=[
+    _descriptor.FieldDescriptor(
+      name='group', full_name='DescribeGroupRs.group', index=0,
+      number=1, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='state', full_name='DescribeGroupRs.state', index=1,
+      number=2, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='protocol_type', full_name='DescribeGroupRs.protocol_type', index=2,
+      number=3, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='protocol', full_name='DescribeGroupRs.protocol', index=3,
+      number=4, type=9, cpp_type=9, label=1,
+      has_default_value=False, default_value=_b("").decode('utf-8'),
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='members', full_name='DescribeGroupRs.members', index=4,
+      number=5, type=11, cpp_type=10, label=3,
+      has_default_value=False, default_value=[],
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+  ],
+  extensions=[
+  ],
+  nested_types=[],
+  enum_types=[
+  ],
+  options=None,
+  is_extendable=False,
+  syntax='proto3',
+  extension_ranges=[],
+  oneofs=[
+  ],
+  serialized_start=2879,
+  serialized_end=2998,
 )
 
 
@@ -1032,6 +1658,20 @@ _SETOFFSETSRQ = _descriptor.Descriptor(
       message_type=None, enum_type=None, containing_type=None,
       is_extension=False, extension_scope=None,
       options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='retention_ms', full_name='SetOffsetsRq.retention_ms', index=4,
+      number=5, type=3, cpp_type=2, label=1,
+      has_default_value=False, default_value=0,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
+    _descriptor.FieldDescriptor(
+      name='no_decrease', full_name='SetOffsetsRq.no_decrease', index=5,
+      number=6, type=8, cpp_type=7, label=1,
+      has_default_value=False, default_value=False,
+      message_type=None, enum_type=None, containing_type=None,
+      is_extension=False, extension_scope=None,
+      options=None, file=DESCRIPTOR),
   ],
   extensions=[
   ],
@@ -1044,8 +1684,8 @@ _SETOFFSETSRQ = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1694,
-  serialized_end=1790,
+  serialized_start=3001,
+  serialized_end=3140,
 )
 
 
@@ -1068,33 +1708,44 @@ _SETOFFSETSRS = _descriptor.Descriptor(
   extension_ranges=[],
   oneofs=[
   ],
-  serialized_start=1792,
-  serialized_end=1806,
+  serialized_start=3142,
+  serialized_end=3156,
 )
 
 _GETOFFSETSRS.fields_by_name['offsets'].message_type = _PARTITIONOFFSET
+_GETTOPICOFFSETSRS.fields_by_name['ranges'].message_type = _PARTITIONOFFSETRANGE
 _GETTOPICMETADATARS_CONFIGENTRY.containing_type = _GETTOPICMETADATARS
 _GETTOPICMETADATARS.fields_by_name['config'].message_type = _GETTOPICMETADATARS_CONFIGENTRY
 _GETTOPICMETADATARS.fields_by_name['partitions'].message_type = _PARTITIONMETADATA
 _LISTTOPICRS_TOPICSENTRY.fields_by_name['value'].message_type = _GETTOPICMETADATARS
 _LISTTOPICRS_TOPICSENTRY.containing_type = _LISTTOPICRS
 _LISTTOPICRS.fields_by_name['topics'].message_type = _LISTTOPICRS_TOPICSENTRY
+_LISTTOPICRQ_CONFIGENTRY.containing_type = _LISTTOPICRQ
+_LISTTOPICRQ.fields_by_name['config'].message_type = _LISTTOPICRQ_CONFIGENTRY
 _CONSUMERGROUPS_CONSUMERSENTRY.fields_by_name['value'].message_type = _CONSUMERPARTITIONS
 _CONSUMERGROUPS_CONSUMERSENTRY.containing_type = _CONSUMERGROUPS
 _CONSUMERGROUPS.fields_by_name['consumers'].message_type = _CONSUMERGROUPS_CONSUMERSENTRY
 _LISTCONSUMERSRS_GROUPSENTRY.fields_by_name['value'].message_type = _CONSUMERGROUPS
 _LISTCONSUMERSRS_GROUPSENTRY.containing_type = _LISTCONSUMERSRS
 _LISTCONSUMERSRS.fields_by_name['groups'].message_type = _LISTCONSUMERSRS_GROUPSENTRY
+_GROUPMEMBER_ASSIGNMENTENTRY.fields_by_name['value'].message_type = _CONSUMERPARTITIONS
+_GROUPMEMBER_ASSIGNMENTENTRY.containing_type = _GROUPMEMBER
+_GROUPMEMBER.fields_by_name['assignment'].message_type = _GROUPMEMBER_ASSIGNMENTENTRY
+_DESCRIBEGROUPRS.fields_by_name['members'].message_type = _GROUPMEMBER
 _SETOFFSETSRQ.fields_by_name['offsets'].message_type = _PARTITIONOFFSET
 DESCRIPTOR.message_types_by_name['ProdRq'] = _PRODRQ
 DESCRIPTOR.message_types_by_name['ProdRs'] = _PRODRS
 DESCRIPTOR.message_types_by_name['ConsNAckRq'] = _CONSNACKRQ
 DESCRIPTOR.message_types_by_name['ConsRs'] = _CONSRS
+DESCRIPTOR.message_types_by_name['ConsStreamRq'] = _CONSSTREAMRQ
 DESCRIPTOR.message_types_by_name['AckRq'] = _ACKRQ
 DESCRIPTOR.message_types_by_name['AckRs'] = _ACKRS
 DESCRIPTOR.message_types_by_name['PartitionOffset'] = _PARTITIONOFFSET
 DESCRIPTOR.message_types_by_name['GetOffsetsRq'] = _GETOFFSETSRQ
 DESCRIPTOR.message_types_by_name['GetOffsetsRs'] = _GETOFFSETSRS
+DESCRIPTOR.message_types_by_name['GetTopicOffsetsRq'] = _GETTOPICOFFSETSRQ
+DESCRIPTOR.message_types_by_name['PartitionOffsetRange'] = _PARTITIONOFFSETRANGE
+DESCRIPTOR.message_types_by_name['GetTopicOffsetsRs'] = _GETTOPICOFFSETSRS
 DESCRIPTOR.message_types_by_name['PartitionMetadata'] = _PARTITIONMETADATA
 DESCRIPTOR.message_types_by_name['GetTopicMetadataRq'] = _GETTOPICMETADATARQ
 DESCRIPTOR.message_types_by_name['GetTopicMetadataRs'] = _GETTOPICMETADATARS
@@ -1104,6 +1755,11 @@ DESCRIPTOR.message_types_by_name['ListConsumersRq'] = _LISTCONSUMERSRQ
 DESCRIPTOR.message_types_by_name['ConsumerPartitions'] = _CONSUMERPARTITIONS
 DESCRIPTOR.message_types_by_name['ConsumerGroups'] = _CONSUMERGROUPS
 DESCRIPTOR.message_types_by_name['ListConsumersRs'] = _LISTCONSUMERSRS
+DESCRIPTOR.message_types_by_name['ListGroupsRq'] = _LISTGROUPSRQ
+DESCRIPTOR.message_types_by_name['ListGroupsRs'] = _LISTGROUPSRS
+DESCRIPTOR.message_types_by_name['DescribeGroupRq'] = _DESCRIBEGROUPRQ
+DESCRIPTOR.message_types_by_name['GroupMember'] = _GROUPMEMBER
+DESCRIPTOR.message_types_by_name['DescribeGroupRs'] = _DESCRIBEGROUPRS
 DESCRIPTOR.message_types_by_name['SetOffsetsRq'] = _SETOFFSETSRQ
 DESCRIPTOR.message_types_by_name['SetOffsetsRs'] = _SETOFFSETSRS
 _sym_db.RegisterFileDescriptor(DESCRIPTOR)
@@ -1136,6 +1792,13 @@ ConsRs = _reflection.GeneratedProtocolMessageType('ConsRs', (_message.Message,),
   ))
 _sym_db.RegisterMessage(ConsRs)
 
+ConsStreamRq = _reflection.GeneratedProtocolMessageType('ConsStreamRq', (_message.Message,), dict(
+  DESCRIPTOR = _CONSSTREAMRQ,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:ConsStreamRq)
+  ))
+_sym_db.RegisterMessage(ConsStreamRq)
+
 AckRq = _reflection.GeneratedProtocolMessageType('AckRq', (_message.Message,), dict(
   DESCRIPTOR = _ACKRQ,
   __module__ = 'kafkapixy_pb2'
@@ -1171,6 +1834,27 @@ GetOffsetsRs = _reflection.GeneratedProtocolMessageType('GetOffsetsRs', (_messag
   ))
 _sym_db.RegisterMessage(GetOffsetsRs)
 
+GetTopicOffsetsRq = _reflection.GeneratedProtocolMessageType('GetTopicOffsetsRq', (_message.Message,), dict(
+  DESCRIPTOR = _GETTOPICOFFSETSRQ,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:GetTopicOffsetsRq)
+  ))
+_sym_db.RegisterMessage(GetTopicOffsetsRq)
+
+PartitionOffsetRange = _reflection.GeneratedProtocolMessageType('PartitionOffsetRange', (_message.Message,), dict(
+  DESCRIPTOR = _PARTITIONOFFSETRANGE,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:PartitionOffsetRange)
+  ))
+_sym_db.RegisterMessage(PartitionOffsetRange)
+
+GetTopicOffsetsRs = _reflection.GeneratedProtocolMessageType('GetTopicOffsetsRs', (_message.Message,), dict(
+  DESCRIPTOR = _GETTOPICOFFSETSRS,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:GetTopicOffsetsRs)
+  ))
+_sym_db.RegisterMessage(GetTopicOffsetsRs)
+
 PartitionMetadata = _reflection.GeneratedProtocolMessageType('PartitionMetadata', (_message.Message,), dict(
   DESCRIPTOR = _PARTITIONMETADATA,
   __module__ = 'kafkapixy_pb2'
@@ -1216,11 +1900,19 @@ _sym_db.RegisterMessage(ListTopicRs)
 _sym_db.RegisterMessage(ListTopicRs.TopicsEntry)
 
 ListTopicRq = _reflection.GeneratedProtocolMessageType('ListTopicRq', (_message.Message,), dict(
+
+  ConfigEntry = _reflection.GeneratedProtocolMessageType('ConfigEntry', (_message.Message,), dict(
+    DESCRIPTOR = _LISTTOPICRQ_CONFIGENTRY,
+    __module__ = 'kafkapixy_pb2'
+    # @@protoc_insertion_point(class_scope:ListTopicRq.ConfigEntry)
+    ))
+  ,
   DESCRIPTOR = _LISTTOPICRQ,
   __module__ = 'kafkapixy_pb2'
   # @@protoc_insertion_point(class_scope:ListTopicRq)
   ))
 _sym_db.RegisterMessage(ListTopicRq)
+_sym_db.RegisterMessage(ListTopicRq.ConfigEntry)
 
 ListConsumersRq = _reflection.GeneratedProtocolMessageType('ListConsumersRq', (_message.Message,), dict(
   DESCRIPTOR = _LISTCONSUMERSRQ,
@@ -1266,6 +1958,49 @@ ListConsumersRs = _reflection.GeneratedProtocolMessageType('ListConsumersRs', (_
 _sym_db.RegisterMessage(ListConsumersRs)
 _sym_db.RegisterMessage(ListConsumersRs.GroupsEntry)
 
+ListGroupsRq = _reflection.GeneratedProtocolMessageType('ListGroupsRq', (_message.Message,), dict(
+  DESCRIPTOR = _LISTGROUPSRQ,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:ListGroupsRq)
+  ))
+_sym_db.RegisterMessage(ListGroupsRq)
+
+ListGroupsRs = _reflection.GeneratedProtocolMessageType('ListGroupsRs', (_message.Message,), dict(
+  DESCRIPTOR = _LISTGROUPSRS,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:ListGroupsRs)
+  ))
+_sym_db.RegisterMessage(ListGroupsRs)
+
+DescribeGroupRq = _reflection.GeneratedProtocolMessageType('DescribeGroupRq', (_message.Message,), dict(
+  DESCRIPTOR = _DESCRIBEGROUPRQ,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:DescribeGroupRq)
+  ))
+_sym_db.RegisterMessage(DescribeGroupRq)
+
+GroupMember = _reflection.GeneratedProtocolMessageType('GroupMember', (_message.Message,), dict(
+
+  AssignmentEntry = _reflection.GeneratedProtocolMessageType('AssignmentEntry', (_message.Message,), dict(
+    DESCRIPTOR = _GROUPMEMBER_ASSIGNMENTENTRY,
+    __module__ = 'kafkapixy_pb2'
+    # @@protoc_insertion_point(class_scope:GroupMember.AssignmentEntry)
+    ))
+  ,
+  DESCRIPTOR = _GROUPMEMBER,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:GroupMember)
+  ))
+_sym_db.RegisterMessage(GroupMember)
+_sym_db.RegisterMessage(GroupMember.AssignmentEntry)
+
+DescribeGroupRs = _reflection.GeneratedProtocolMessageType('DescribeGroupRs', (_message.Message,), dict(
+  DESCRIPTOR = _DESCRIBEGROUPRS,
+  __module__ = 'kafkapixy_pb2'
+  # @@protoc_insertion_point(class_scope:DescribeGroupRs)
+  ))
+_sym_db.RegisterMessage(DescribeGroupRs)
+
 SetOffsetsRq = _reflection.GeneratedProtocolMessageType('SetOffsetsRq', (_message.Message,), dict(
   DESCRIPTOR = _SETOFFSETSRQ,
   __module__ = 'kafkapixy_pb2'
@@ -1287,10 +2022,14 @@ _GETTOPICMETADATARS_CONFIGENTRY.has_options = True
 _GETTOPICMETADATARS_CONFIGENTRY._options = _descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001'))
 _LISTTOPICRS_TOPICSENTRY.has_options = True
 _LISTTOPICRS_TOPICSENTRY._options = _descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001'))
+_LISTTOPICRQ_CONFIGENTRY.has_options = True
+_LISTTOPICRQ_CONFIGENTRY._options = _descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001'))
 _CONSUMERGROUPS_CONSUMERSENTRY.has_options = True
 _CONSUMERGROUPS_CONSUMERSENTRY._options = _descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001'))
 _LISTCONSUMERSRS_GROUPSENTRY.has_options = True
 _LISTCONSUMERSRS_GROUPSENTRY._options = _descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001'))
+_GROUPMEMBER_ASSIGNMENTENTRY.has_options = True
+_GROUPMEMBER_ASSIGNMENTENTRY._options = _descriptor._ParseOptions(descriptor_pb2.MessageOptions(), _b('8\001'))
 
 _KAFKAPIXY = _descriptor.ServiceDescriptor(
   name='KafkaPixy',
@@ -1298,8 +2037,8 @@ _KAFKAPIXY = _descriptor.ServiceDescriptor(
   file=DESCRIPTOR,
   index=0,
   options=None,
-  serialized_start=1809,
-  serialized_end=2170,
+  serialized_start=3159,
+  serialized_end=3729,
   methods=[
   _descriptor.MethodDescriptor(
     name='Produce',
@@ -1337,10 +2076,19 @@ _KAFKAPIXY = _descriptor.ServiceDescriptor(
     output_type=_GETOFFSETSRS,
     options=None,
   ),
+  _descriptor.MethodDescriptor(
+    name='GetTopicOffsets',
+    full_name='KafkaPixy.GetTopicOffsets',
+    index=4,
+    containing_service=None,
+    input_type=_GETTOPICOFFSETSRQ,
+    output_type=_GETTOPICOFFSETSRS,
+    options=None,
+  ),
   _descriptor.MethodDescriptor(
     name='SetOffsets',
     full_name='KafkaPixy.SetOffsets',
-    index=4,
+    index=5,
     containing_service=None,
     input_type=_SETOFFSETSRQ,
     output_type=_SETOFFSETSRS,
@@ -1349,7 +2097,7 @@ _KAFKAPIXY = _descriptor.ServiceDescriptor(
   _descriptor.MethodDescriptor(
     name='ListTopics',
     full_name='KafkaPixy.ListTopics',
-    index=5,
+    index=6,
     containing_service=None,
     input_type=_LISTTOPICRQ,
     output_type=_LISTTOPICRS,
@@ -1358,7 +2106,7 @@ _KAFKAPIXY = _descriptor.ServiceDescriptor(
   _descriptor.MethodDescriptor(
     name='ListConsumers',
     full_name='KafkaPixy.ListConsumers',
-    index=6,
+    index=7,
     containing_service=None,
     input_type=_LISTCONSUMERSRQ,
     output_type=_LISTCONSUMERSRS,
@@ -1367,12 +2115,39 @@ _KAFKAPIXY = _descriptor.ServiceDescriptor(
   _descriptor.MethodDescriptor(
     name='GetTopicMetadata',
     full_name='KafkaPixy.GetTopicMetadata',
-    index=7,
+    index=8,
     containing_service=None,
     input_type=_GETTOPICMETADATARQ,
     output_type=_GETTOPICMETADATARS,
     options=None,
   ),
+  _descriptor.MethodDescriptor(
+    name='ListGroups',
+    full_name='KafkaPixy.ListGroups',
+    index=9,
+    containing_service=None,
+    input_type=_LISTGROUPSRQ,
+    output_type=_LISTGROUPSRS,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='DescribeGroup',
+    full_name='KafkaPixy.DescribeGroup',
+    index=10,
+    containing_service=None,
+    input_type=_DESCRIBEGROUPRQ,
+    output_type=_DESCRIBEGROUPRS,
+    options=None,
+  ),
+  _descriptor.MethodDescriptor(
+    name='ConsumeStream',
+    full_name='KafkaPixy.ConsumeStream',
+    index=11,
+    containing_service=None,
+    input_type=_CONSSTREAMRQ,
+    output_type=_CONSRS,
+    options=None,
+  ),
 ])
 _sym_db.RegisterServiceDescriptor(_KAFKAPIXY)
 
@@ -2,8 +2,10 @@ package logging
 
 import (
 	"encoding/json"
+	"io"
 	"io/ioutil"
 	"log/syslog"
+	"os"
 
 	"github.com/Shopify/sarama"
 	"github.com/mailgun/kafka-pixy/config"
@@ -15,6 +17,33 @@ import (
 	"github.com/sirupsen/logrus/hooks/syslog"
 )
 
+var (
+	// accessLogger is nil unless an `access` logger is configured.
+	accessLogger *log.Logger
+	// accessLogFile is nil unless the access log is written to a file.
+	accessLogFile *os.File
+)
+
+// AccessLogger returns a logger that records of individual produce and
+// consume requests should be written to, or nil if access logging is not
+// configured. Access logging is enabled by the `access` logger in the logging
+// config, independently of the severity of other loggers. Records are written
+// in JSON to the file given by the `path` parameter, or to stdout.
+func AccessLogger() *log.Logger {
+	return accessLogger
+}
+
+// Close closes the access log file, if there is one. It should be called
+// after all proxies are stopped, for records written after that are lost.
+func Close() error {
+	if accessLogFile == nil {
+		return nil
+	}
+	err := accessLogFile.Close()
+	accessLogFile = nil
+	return errors.Wrap(err, "failed to close access log")
+}
+
 // Init initializes sirupsen/logrus hooks from the JSON config string. It also
 // sets the sirupsen/logrus as a logger for 3rd party libraries.
 func Init(jsonCfg string, cfg *config.App) error {
@@ -22,6 +51,10 @@ func Init(jsonCfg string, cfg *config.App) error {
 	if err := json.Unmarshal([]byte(jsonCfg), &loggingCfg); err != nil {
 		return errors.Wrap(err, "failed to parse logger config")
 	}
+	if err := Close(); err != nil {
+		return err
+	}
+	accessLogger = nil
 
 	formatter := &textFormatter{}
 	log.SetFormatter(formatter)
@@ -66,6 +99,19 @@ func Init(jsonCfg string, cfg *config.App) error {
 			}
 			hooks = append(hooks, levelfilter.New(h, loggerCfg.level()))
 			nonStdoutEnabled = true
+		case "access":
+			var out io.Writer = os.Stdout
+			if path := loggerCfg.Params["path"]; path != "" {
+				f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+				if err != nil {
+					return errors.Wrap(err, "failed to open access log")
+				}
+				out = f
+				accessLogFile = f
+			}
+			accessLogger = log.New()
+			accessLogger.Out = out
+			accessLogger.Formatter = &log.JSONFormatter{}
 		}
 	}
 
@@ -90,8 +136,8 @@ func Init(jsonCfg string, cfg *config.App) error {
 
 // loggerCfg represents a configuration of an individual logger.
 type loggerCfg struct {
-	// Name defines a logger to be used. It can be one of: console, syslog, or
-	// udplog.
+	// Name defines a logger to be used. It can be one of: console, syslog,
+	// udplog, or access.
 	Name string `json:"name"`
 
 	// Severity indicates the minimum severity a logger will be logging messages at.
@@ -85,6 +85,9 @@ func main() {
 	// Wait for a quit signal and terminate the service when it is received.
 	<-osSigCh
 	svc.Stop()
+	if err := logging.Close(); err != nil {
+		log.Errorf("Failed to close logging: err=(%s)", err)
+	}
 }
 
 func makeConfig() (*config.App, error) {
@@ -0,0 +1,41 @@
+package offsetmgr
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// AdminStamp is metadata of offsets committed by administrative operations,
+// e.g. `admin.T.SetGroupOffsets`, rather than by consumers. It records where
+// and when the offsets were committed to provide an audit trail of offset
+// manipulations, and wraps metadata supplied by the caller, if any. It is
+// stored in Kafka as a JSON object.
+type AdminStamp struct {
+	Meta        string    `json:"meta,omitempty"`
+	CommittedBy string    `json:"committed_by"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// Encode returns the stamp as an offset metadata string.
+func (as AdminStamp) Encode() string {
+	// Marshaling of a struct of strings and a time never fails.
+	encoded, _ := json.Marshal(as)
+	return string(encoded)
+}
+
+// DecodeAdminStamp returns an admin stamp encoded in an offset metadata
+// string. False is returned if the metadata is not an admin stamp, e.g. it
+// was committed by a consumer.
+func DecodeAdminStamp(meta string) (AdminStamp, bool) {
+	// Sparse acks committed by consumers are base64 encoded, so they never
+	// look like a JSON object.
+	if !strings.HasPrefix(meta, "{") {
+		return AdminStamp{}, false
+	}
+	var as AdminStamp
+	if err := json.Unmarshal([]byte(meta), &as); err != nil || as.CommittedBy == "" {
+		return AdminStamp{}, false
+	}
+	return as, true
+}
@@ -2,6 +2,7 @@ package offsetmgr
 
 import (
 	"math"
+	"sort"
 	"sync"
 	"time"
 
@@ -31,12 +32,47 @@ type Factory interface {
 	// stopped a new one can be started.
 	Spawn(parentActDesc *actor.Descriptor, group, topic string, partition int32) (T, error)
 
+	// CommitErrors returns errors of the latest failed commits for those
+	// partitions of the group that running offset managers have not managed
+	// to commit offsets to since. Errors are forgotten when respective offset
+	// managers stop.
+	CommitErrors(group string) []CommitError
+
+	// PendingCommits returns offsets that have been submitted to running
+	// offset managers but not committed yet, one per group-topic-partition.
+	// Offsets are reported until committed, so an empty result after all
+	// consumers have stopped means that no offsets are lost.
+	PendingCommits() []PendingCommit
+
 	// Stop waits for the spawned offset managers to stop and then terminates. Note
 	// that all spawned offset managers has to be explicitly stopped by calling
 	// their Stop method.
 	Stop()
 }
 
+// CommitError describes a persisting failure to commit offsets of a
+// group-topic-partition.
+type CommitError struct {
+	Topic     string
+	Partition int32
+	// The error of the latest failed commit.
+	Err error
+	// When the latest commit failed.
+	FailedAt time.Time
+	// The number of failed commits in a row.
+	Failures int
+}
+
+// PendingCommit describes an offset of a group-topic-partition that has been
+// submitted for commit but not committed yet.
+type PendingCommit struct {
+	Group     string
+	Topic     string
+	Partition int32
+	// The latest submitted offset.
+	Offset int64
+}
+
 // T provides interface to store and retrieve offsets for a particular
 // group-topic-partition in Kafka.
 type T interface {
@@ -84,10 +120,12 @@ var (
 // SpawnFactory creates a new offset manager factory from the given client.
 func SpawnFactory(parentActDesc *actor.Descriptor, cfg *config.Proxy, kafkaClt sarama.Client) Factory {
 	f := &factory{
-		actDesc:  parentActDesc.NewChild("offset_mgr_f"),
-		kafkaClt: kafkaClt,
-		cfg:      cfg,
-		children: make(map[instanceID]*offsetMgr),
+		actDesc:      parentActDesc.NewChild("offset_mgr_f"),
+		kafkaClt:     kafkaClt,
+		cfg:          cfg,
+		children:     make(map[instanceID]*offsetMgr),
+		commitErrors: make(map[instanceID]*CommitError),
+		pending:      make(map[instanceID]int64),
 	}
 	f.mapper = mapper.Spawn(f.actDesc, cfg, f)
 	return f
@@ -103,6 +141,12 @@ type factory struct {
 
 	childrenMu sync.Mutex
 	children   map[instanceID]*offsetMgr
+
+	commitErrorsMu sync.Mutex
+	commitErrors   map[instanceID]*CommitError
+
+	pendingMu sync.Mutex
+	pending   map[instanceID]int64
 }
 
 type instanceID struct {
@@ -167,6 +211,7 @@ func (f *factory) SpawnExecutor(brokerConn *sarama.Broker) mapper.Executor {
 		conn:             brokerConn,
 		requestsCh:       make(chan submitRq),
 		requestBatchesCh: make(chan map[string]map[instanceID]submitRq),
+		flushCh:          make(chan none.T, 1),
 		execStopCh:       make(chan none.T),
 	}
 	actor.Spawn(be.aggrActDesc, &be.wg, be.runAggregator)
@@ -174,16 +219,93 @@ func (f *factory) SpawnExecutor(brokerConn *sarama.Broker) mapper.Executor {
 	return be
 }
 
+// implements `Factory`
+func (f *factory) CommitErrors(group string) []CommitError {
+	f.commitErrorsMu.Lock()
+	defer f.commitErrorsMu.Unlock()
+	var commitErrors []CommitError
+	for id, commitErr := range f.commitErrors {
+		if id.group == group {
+			commitErrors = append(commitErrors, *commitErr)
+		}
+	}
+	sort.Slice(commitErrors, func(i, j int) bool {
+		if commitErrors[i].Topic != commitErrors[j].Topic {
+			return commitErrors[i].Topic < commitErrors[j].Topic
+		}
+		return commitErrors[i].Partition < commitErrors[j].Partition
+	})
+	return commitErrors
+}
+
+// implements `Factory`
+func (f *factory) PendingCommits() []PendingCommit {
+	f.pendingMu.Lock()
+	defer f.pendingMu.Unlock()
+	var pendingCommits []PendingCommit
+	for id, offset := range f.pending {
+		pendingCommits = append(pendingCommits, PendingCommit{
+			Group:     id.group,
+			Topic:     id.topic,
+			Partition: id.partition,
+			Offset:    offset,
+		})
+	}
+	sort.Slice(pendingCommits, func(i, j int) bool {
+		if pendingCommits[i].Group != pendingCommits[j].Group {
+			return pendingCommits[i].Group < pendingCommits[j].Group
+		}
+		if pendingCommits[i].Topic != pendingCommits[j].Topic {
+			return pendingCommits[i].Topic < pendingCommits[j].Topic
+		}
+		return pendingCommits[i].Partition < pendingCommits[j].Partition
+	})
+	return pendingCommits
+}
+
 // implements `Factory.Stop()`
 func (f *factory) Stop() {
 	f.mapper.Stop()
 }
 
+func (f *factory) onCommitFailed(id instanceID, err error) {
+	f.commitErrorsMu.Lock()
+	defer f.commitErrorsMu.Unlock()
+	commitErr := f.commitErrors[id]
+	if commitErr == nil {
+		commitErr = &CommitError{Topic: id.topic, Partition: id.partition}
+		f.commitErrors[id] = commitErr
+	}
+	commitErr.Err = err
+	commitErr.FailedAt = time.Now().UTC()
+	commitErr.Failures++
+}
+
+func (f *factory) onCommitSucceeded(id instanceID) {
+	f.commitErrorsMu.Lock()
+	delete(f.commitErrors, id)
+	f.commitErrorsMu.Unlock()
+}
+
+// onOffsetsUpdated records whether the latest submitted offset of an offset
+// manager differs from the committed one.
+func (f *factory) onOffsetsUpdated(id instanceID, submitted, committed Offset) {
+	f.pendingMu.Lock()
+	defer f.pendingMu.Unlock()
+	if submitted == committed || submitted == undefinedOffset {
+		delete(f.pending, id)
+		return
+	}
+	f.pending[id] = submitted.Val
+}
+
 func (f *factory) onOffsetMgrSpawned(om *offsetMgr) {
 	f.mapper.OnWorkerSpawned(om)
 }
 
 func (f *factory) onOffsetMgrStopped(om *offsetMgr) {
+	f.onCommitSucceeded(om.id)
+	f.onOffsetsUpdated(om.id, undefinedOffset, undefinedOffset)
 	f.childrenMu.Lock()
 	delete(f.children, om.id)
 	f.childrenMu.Unlock()
@@ -283,6 +405,7 @@ func (om *offsetMgr) run() {
 			}
 			receivedRq = rq
 			receivedRq.resultCh = responseCh
+			om.f.onOffsetsUpdated(om.id, receivedRq.offset, initialOffset)
 		}
 	}
 handleRequests:
@@ -293,6 +416,7 @@ handleRequests:
 	if receivedRq.offset != committedOffset {
 		om.nilOrBrokerRequestsCh = om.brokerRequestsCh
 	}
+	om.f.onOffsetsUpdated(om.id, receivedRq.offset, committedOffset)
 	var handedOffRq submitRq
 	var handOffTime time.Time
 	for {
@@ -317,7 +441,9 @@ handleRequests:
 			}
 			receivedRq = rq
 			receivedRq.resultCh = responseCh
+			receivedRq.flush = om.shouldFlush(receivedRq.offset, committedOffset)
 			om.nilOrBrokerRequestsCh = om.brokerRequestsCh
+			om.f.onOffsetsUpdated(om.id, receivedRq.offset, committedOffset)
 
 		case om.nilOrBrokerRequestsCh <- receivedRq:
 			om.nilOrBrokerRequestsCh = nil
@@ -330,10 +456,13 @@ handleRequests:
 		case rs := <-responseCh:
 			if err := om.getCommitError(rs.kafkaRs); err != nil {
 				om.actDesc.Log().WithError(err).Error("Request failed")
+				om.f.onCommitFailed(om.id, err)
 				om.triggerReassign(err)
 				continue
 			}
+			om.f.onCommitSucceeded(om.id)
 			committedOffset = rs.rq.offset
+			om.f.onOffsetsUpdated(om.id, receivedRq.offset, committedOffset)
 			om.committedOffsetsCh <- committedOffset
 			if stopped && receivedRq.offset == committedOffset {
 				return
@@ -346,6 +475,7 @@ handleRequests:
 					continue
 				}
 				om.actDesc.Log().Errorf("Request timeout %v", sinceHandOff)
+				om.f.onCommitFailed(om.id, errRequestTimeout)
 				om.triggerReassign(errRequestTimeout)
 				continue
 			}
@@ -356,6 +486,14 @@ handleRequests:
 	}
 }
 
+// shouldFlush tells whether the submitted offset is far enough ahead of the
+// committed one to be committed right away, rather than on the next commit
+// interval tick.
+func (om *offsetMgr) shouldFlush(submitted, committed Offset) bool {
+	batchSize := om.f.cfg.Consumer.OffsetsCommitBatchSize
+	return batchSize > 0 && submitted.Val-committed.Val >= int64(batchSize)
+}
+
 func (om *offsetMgr) stopRetryTimer() {
 	if om.nilOrRetryTimerCh == nil {
 		return
@@ -419,6 +557,9 @@ type submitRq struct {
 	id       instanceID
 	offset   Offset
 	resultCh chan<- submitRs
+	// If true then the offset should be committed without waiting for the
+	// next commit interval tick.
+	flush bool
 }
 
 type submitRs struct {
@@ -437,6 +578,7 @@ type brokerExecutor struct {
 	conn             *sarama.Broker
 	requestsCh       chan submitRq
 	requestBatchesCh chan map[string]map[instanceID]submitRq
+	flushCh          chan none.T
 	execStopCh       chan none.T
 	wg               sync.WaitGroup
 }
@@ -470,6 +612,12 @@ func (be *brokerExecutor) runAggregator() {
 			}
 			groupRequests[rq.id] = rq
 			nilOrOffsetBatchesCh = be.requestBatchesCh
+			if rq.flush {
+				select {
+				case be.flushCh <- none.V:
+				default:
+				}
+			}
 		case nilOrOffsetBatchesCh <- requestBatch:
 			nilOrOffsetBatchesCh = nil
 			requestBatch = make(map[string]map[instanceID]submitRq)
@@ -520,6 +668,14 @@ offsetCommitLoop:
 			}
 			nilOrRequestBatchesCh = be.requestBatchesCh
 
+		case <-be.flushCh:
+			// An offset manager accumulated enough uncommitted messages to
+			// commit right away, but connection failure backoff still applies.
+			if time.Now().UTC().Sub(lastErrTime) < be.cfg.Consumer.RetryBackoff {
+				continue offsetCommitLoop
+			}
+			nilOrRequestBatchesCh = be.requestBatchesCh
+
 		case <-be.execStopCh:
 			return
 		}
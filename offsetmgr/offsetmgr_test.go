@@ -185,6 +185,113 @@ func (s *OffsetMgrSuite) TestCommitError(c *C) {
 	c.Assert(committedOffset, DeepEquals, Offset{1000, "foo"})
 }
 
+// Partitions that offsets fail to be committed for are reported by the
+// factory until a commit succeeds.
+func (s *OffsetMgrSuite) TestCommitErrors(c *C) {
+	// Given
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(c).
+			SetOffset("g1", "t1", 7, 1234, "foo", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c).
+			SetError("g1", "t1", 7, sarama.ErrNotLeaderForPartition),
+	})
+
+	cfg := testhelpers.NewTestProxyCfg("c1")
+	cfg.Consumer.RetryBackoff = 100 * time.Millisecond
+	cfg.Consumer.OffsetsCommitInterval = 50 * time.Millisecond
+	client, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+
+	f := SpawnFactory(s.ns.NewChild(), cfg, client)
+	defer f.Stop()
+
+	om, err := f.Spawn(s.ns.NewChild("g1", "t1", 7), "g1", "t1", 7)
+	c.Assert(err, IsNil)
+	defer om.Stop()
+	c.Assert(f.CommitErrors("g1"), IsNil)
+
+	// When
+	om.SubmitOffset(Offset{1000, "foo"})
+	<-om.(*offsetMgr).testErrorsCh
+	<-om.(*offsetMgr).testErrorsCh
+
+	// Then
+	commitErrors := f.CommitErrors("g1")
+	c.Assert(len(commitErrors), Equals, 1)
+	c.Assert(commitErrors[0].Topic, Equals, "t1")
+	c.Assert(commitErrors[0].Partition, Equals, int32(7))
+	c.Assert(commitErrors[0].Err, Equals, sarama.ErrNotLeaderForPartition)
+	c.Assert(commitErrors[0].Failures >= 2, Equals, true)
+	c.Assert(f.CommitErrors("g2"), IsNil)
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c).
+			SetError("g1", "t1", 7, sarama.ErrNoError),
+	})
+	c.Assert(<-om.CommittedOffsets(), DeepEquals, Offset{1234, "foo"})
+	c.Assert(<-om.CommittedOffsets(), DeepEquals, Offset{1000, "foo"})
+	c.Assert(f.CommitErrors("g1"), IsNil)
+}
+
+// Submitted offsets are reported as pending by the factory until committed.
+func (s *OffsetMgrSuite) TestPendingCommits(c *C) {
+	// Given
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(c).
+			SetOffset("g1", "t1", 7, 1234, "foo", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c).
+			SetError("g1", "t1", 7, sarama.ErrNotLeaderForPartition),
+	})
+
+	cfg := testhelpers.NewTestProxyCfg("c1")
+	cfg.Consumer.RetryBackoff = 100 * time.Millisecond
+	cfg.Consumer.OffsetsCommitInterval = 50 * time.Millisecond
+	client, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+
+	f := SpawnFactory(s.ns.NewChild(), cfg, client)
+	defer f.Stop()
+
+	om, err := f.Spawn(s.ns.NewChild("g1", "t1", 7), "g1", "t1", 7)
+	c.Assert(err, IsNil)
+	c.Assert(<-om.CommittedOffsets(), DeepEquals, Offset{1234, "foo"})
+	c.Assert(f.PendingCommits(), IsNil)
+
+	// When
+	om.SubmitOffset(Offset{1000, "foo"})
+	<-om.(*offsetMgr).testErrorsCh
+
+	// Then
+	c.Assert(f.PendingCommits(), DeepEquals, []PendingCommit{
+		{Group: "g1", Topic: "t1", Partition: 7, Offset: 1000}})
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c).
+			SetError("g1", "t1", 7, sarama.ErrNoError),
+	})
+	c.Assert(<-om.CommittedOffsets(), DeepEquals, Offset{1000, "foo"})
+	c.Assert(f.PendingCommits(), IsNil)
+	om.Stop()
+}
+
 // If offset a response received from Kafka for an offset commit request does
 // not contain information for a submitted offset, then offset manager keeps,
 // retrying until it succeeds.
@@ -483,6 +590,62 @@ func (s *OffsetMgrSuite) TestCommittedChannel(c *C) {
 	c.Assert(lastCommittedOffset, Equals, Offset{1005, "bar5"})
 }
 
+// If an offset is at least OffsetsCommitBatchSize messages ahead of the
+// committed one, then it is committed without waiting for the commit interval.
+func (s *OffsetMgrSuite) TestCommitBatchSize(c *C) {
+	// Given
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(c).
+			SetOffset("g1", "t1", 7, 1000, "foo1", sarama.ErrNoError),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c).
+			SetError("g1", "t1", 7, sarama.ErrNoError),
+	})
+
+	cfg := testhelpers.NewTestProxyCfg("c1")
+	cfg.Consumer.OffsetsCommitInterval = time.Hour
+	cfg.Consumer.OffsetsCommitBatchSize = 10
+	client, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	f := SpawnFactory(s.ns.NewChild(), cfg, client)
+	defer f.Stop()
+	om, err := f.Spawn(s.ns.NewChild("g1", "t1", 7), "g1", "t1", 7)
+	c.Assert(err, IsNil)
+	defer om.Stop()
+	<-om.CommittedOffsets() // Ignore initial offset.
+	// The first submitted offset is committed right away regardless of the
+	// commit interval.
+	om.SubmitOffset(Offset{1001, "bar1"})
+	c.Assert(<-om.CommittedOffsets(), Equals, Offset{1001, "bar1"})
+
+	// When
+	om.SubmitOffset(Offset{1010, "bar2"})
+
+	// Then
+	select {
+	case committedOffset := <-om.CommittedOffsets():
+		c.Errorf("Unexpected commit: %v", committedOffset)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// When
+	om.SubmitOffset(Offset{1011, "bar3"})
+
+	// Then
+	select {
+	case committedOffset := <-om.CommittedOffsets():
+		c.Assert(committedOffset, Equals, Offset{1011, "bar3"})
+	case <-time.After(3 * time.Second):
+		c.Error("Offset is not committed")
+	}
+}
+
 // Test for issue https://github.com/mailgun/kafka-pixy/issues/29. The problem
 // was that if a connection to the broker was broken on the Kafka side while a
 // partition manager tried to retrieve an initial commit, the later would never
@@ -632,3 +795,25 @@ func lastCommittedOffset(mb *sarama.MockBroker, group, topic string, partition i
 	}
 	return Offset{}
 }
+
+func (s *OffsetMgrSuite) TestAdminStamp(c *C) {
+	stamp := AdminStamp{
+		Meta:        `{"foo": "bar"}`,
+		CommittedBy: "host1",
+		CommittedAt: time.Date(2017, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	// When
+	decoded, ok := DecodeAdminStamp(stamp.Encode())
+
+	// Then
+	c.Assert(ok, Equals, true)
+	c.Assert(decoded, DeepEquals, stamp)
+
+	// Metadata committed by consumers or by older versions of the admin API is
+	// not mistaken for an admin stamp.
+	for i, meta := range []string{"", "abra1234+/P", `{"foo": "bar"}`, "{bad json"} {
+		_, ok := DecodeAdminStamp(meta)
+		c.Assert(ok, Equals, false, Commentf("case #%d", i))
+	}
+}
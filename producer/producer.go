@@ -1,18 +1,53 @@
 package producer
 
 import (
+	"container/list"
+	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/mailgun/holster/clock"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
+	"github.com/mailgun/kafka-pixy/none"
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
 )
 
 const (
 	maxEncoderReprLength = 4096
+
+	// Parameters of the histogram reservoirs, the same as sarama uses.
+	metricsReservoirSize = 1028
+	metricsAlphaFactor   = 0.015
+
+	metricProduceLatency = "produce-latency-in-ms"
+	metricProduceErrors  = "produce-errors"
+	metricProducedMsgs   = "produced-messages"
+	metricProducedBytes  = "produced-bytes"
+	metricMetadataAge    = "metadata-age-ms"
+
+	// The maximum number of topics waiting for their metadata to be
+	// refreshed after a message failed to be produced to a stale leader.
+	staleTopicsChSize = 64
+
+	// The overhead of a message in a message set, that is its offset, size,
+	// CRC, magic byte, attributes and key/value lengths. Messages in the v1
+	// format, used with Kafka 0.10.0.0 and later, also include a timestamp.
+	msgOverheadV0 = 26
+	msgOverheadV1 = msgOverheadV0 + 8
+)
+
+var (
+	ErrPartitionOutOfRange = errors.New("partition out of range")
+	ErrMessageTooLarge     = errors.New("message is too large")
+	ErrQueueFull           = errors.New("too many async messages in flight")
+	ErrFlushTimeout        = errors.New("timeout waiting for messages to be flushed")
+	ErrProduceTimeout      = errors.New("timeout waiting for produce result")
 )
 
 // T builds on top of `sarama.AsyncProducer` to improve the shutdown handling.
@@ -26,13 +61,42 @@ const (
 type T struct {
 	mergActDesc     *actor.Descriptor
 	dispActDesc     *actor.Descriptor
+	refrActDesc     *actor.Descriptor
 	saramaClient    sarama.Client
 	saramaProducer  sarama.AsyncProducer
+	metricRegistry  metrics.Registry
+	clientMetrics   *ClientMetricNames
 	shutdownTimeout time.Duration
+	produceTimeout  time.Duration
+	maxMessageBytes int
+	msgOverhead     int
+	compression     sarama.CompressionCodec
 	dispatcherCh    chan *sarama.ProducerMessage
 	responseCh      chan Response
+	stopCh          chan none.T
 	wg              sync.WaitGroup
 
+	// Metadata of all topics is refreshed by the refresher goroutine rather
+	// than by the sarama client in the background, so that its age is known.
+	// It is refreshed as soon as it gets older than metadataMaxAge, unless
+	// that is zero. The time of the last refresh is stored in UnixNano and
+	// accessed atomically.
+	metadataMaxAge      time.Duration
+	metadataRefreshedAt int64
+	retryBackoff        time.Duration
+
+	// Topics that messages failed to be produced to because their partition
+	// leaders have moved. The refresher refreshes their metadata right away.
+	staleTopicsCh chan string
+
+	// Holds a value for every message submitted with AsyncProduceBounded
+	// that is still in flight. It is nil if the number is not limited.
+	asyncSlotsCh chan none.T
+
+	// Messages in flight and pending flush requests in the order they were
+	// received by the dispatcher. Accessed by the dispatcher goroutine only.
+	inFlight *list.List
+
 	// To be used in tests only
 	testDroppedMsgCh chan<- *sarama.ProducerMessage
 }
@@ -42,11 +106,108 @@ type Response struct {
 	Err error
 }
 
+// msgMeta is attached to every message submitted to sarama.AsyncProducer as
+// sarama.ProducerMessage.Metadata.
+type msgMeta struct {
+	responseCh chan Response
+	enqueuedAt time.Time
+	// If true then the message goes to sarama.ProducerMessage.Partition
+	// rather than to a partition selected by the key hash.
+	partitionSet bool
+	// If true then the message holds a slot in T.asyncSlotsCh.
+	bounded bool
+	// Logical ID of the client that submitted the message, if known.
+	clientID string
+	info     MsgInfo
+	// The element of T.inFlight that the message is tracked by.
+	inFlightElem *list.Element
+}
+
+// flushRq is submitted to the dispatcher as sarama.ProducerMessage.Metadata
+// of a message that is never produced. It marks the position in the message
+// stream that Flush waits for.
+type flushRq struct {
+	doneCh chan none.T
+}
+
+// MsgInfo describes how a produced message was encoded.
+type MsgInfo struct {
+	// The size of the message in the Kafka message format, including the
+	// message set entry overhead, before compression. Kafka compresses
+	// messages in batches, therefore the compressed size of an individual
+	// message is not known. Batch compression ratios of a topic are reported
+	// by `compression-ratio-for-topic-<topic>` producer metrics.
+	SerializedBytes int
+
+	// The codec that the batch containing the message was compressed with.
+	Compression sarama.CompressionCodec
+}
+
+// MessageInfo returns encoding details of a message produced by T. A zero
+// value is returned for messages produced by other means.
+func MessageInfo(prodMsg *sarama.ProducerMessage) MsgInfo {
+	if prodMsg == nil {
+		return MsgInfo{}
+	}
+	if meta, ok := prodMsg.Metadata.(*msgMeta); ok {
+		return meta.info
+	}
+	return MsgInfo{}
+}
+
+// Opts defines optional properties of a produced message.
+type Opts struct {
+	// If not zero, then the message is produced with this timestamp instead
+	// of the current time. See AsyncProduceAt for details.
+	Timestamp time.Time
+
+	// If not empty, then a logical ID of the client that submitted the
+	// message. Produce latency and errors are additionally reported to
+	// `-for-client-<id>` metrics, and failures are logged with it.
+	ClientID string
+}
+
+var (
+	partitionersMu sync.Mutex
+	partitioners   = map[string]sarama.PartitionerConstructor{
+		"hash":        sarama.NewHashPartitioner,
+		"random":      sarama.NewRandomPartitioner,
+		"round_robin": sarama.NewRoundRobinPartitioner,
+	}
+)
+
+// RegisterPartitioner makes a custom partitioner available to be selected by
+// name with `producer.partitioner` config parameter. It is intended to be
+// called by applications that embed Kafka-Pixy before producers are spawned.
+// Messages produced to explicit partitions bypass the partitioner.
+func RegisterPartitioner(name string, constructor sarama.PartitionerConstructor) {
+	partitionersMu.Lock()
+	defer partitionersMu.Unlock()
+	partitioners[name] = constructor
+}
+
 // Spawn creates a producer instance and starts its internal goroutines.
 func Spawn(parentActDesc *actor.Descriptor, cfg *config.Proxy) (*T, error) {
+	partitionersMu.Lock()
+	partitionerCtor := partitioners[cfg.Producer.Partitioner]
+	partitionersMu.Unlock()
+	if partitionerCtor == nil {
+		return nil, errors.Errorf("unknown partitioner: %s", cfg.Producer.Partitioner)
+	}
 	saramaCfg := cfg.SaramaProducerCfg()
+	metadataMaxAge := saramaCfg.Metadata.RefreshFrequency
+	if cfg.Producer.MetadataMaxAge > 0 && (metadataMaxAge == 0 || cfg.Producer.MetadataMaxAge < metadataMaxAge) {
+		metadataMaxAge = cfg.Producer.MetadataMaxAge
+	}
+	saramaCfg.Metadata.RefreshFrequency = 0
 	saramaCfg.Producer.Return.Successes = true
 	saramaCfg.Producer.Return.Errors = true
+	// Partitioners are created by the sarama producer on demand, by then the
+	// client is initialized.
+	var saramaClient sarama.Client
+	saramaCfg.Producer.Partitioner = func(topic string) sarama.Partitioner {
+		return &partitioner{keyPartitioner: partitionerCtor(topic), partitionLister: saramaClient}
+	}
 
 	saramaClient, err := sarama.NewClient(cfg.Kafka.SeedPeers, saramaCfg)
 	if err != nil {
@@ -60,21 +221,75 @@ func Spawn(parentActDesc *actor.Descriptor, cfg *config.Proxy) (*T, error) {
 	p := &T{
 		mergActDesc:     parentActDesc.NewChild("prod_merg"),
 		dispActDesc:     parentActDesc.NewChild("prod_disp"),
+		refrActDesc:     parentActDesc.NewChild("prod_refr"),
 		saramaClient:    saramaClient,
 		saramaProducer:  saramaProducer,
+		metricRegistry:  saramaCfg.MetricRegistry,
+		clientMetrics:   NewClientMetricNames(cfg.Monitoring.MaxClientMetrics),
 		shutdownTimeout: cfg.Producer.ShutdownTimeout,
+		produceTimeout:  cfg.Producer.ProduceTimeout,
+		maxMessageBytes: cfg.Producer.MaxMessageBytes,
+		msgOverhead:     msgOverheadV0,
+		compression:     saramaCfg.Producer.Compression,
 		dispatcherCh:    make(chan *sarama.ProducerMessage, cfg.Producer.ChannelBufferSize),
 		responseCh:      make(chan Response, cfg.Producer.ChannelBufferSize),
+		inFlight:        list.New(),
+		stopCh:          make(chan none.T),
+		metadataMaxAge:  metadataMaxAge,
+		retryBackoff:    cfg.Producer.RetryBackoff,
+		staleTopicsCh:   make(chan string, staleTopicsChSize),
+	}
+	// The client fetches metadata when it is created.
+	p.metadataRefreshedAt = clock.Now().UnixNano()
+	p.metricRegistry.Register(metricMetadataAge, metrics.NewFunctionalGauge(func() int64 {
+		return int64(p.MetadataAge() / time.Millisecond)
+	}))
+	if saramaCfg.Version.IsAtLeast(sarama.V0_10_0_0) {
+		p.msgOverhead = msgOverheadV1
+	}
+	if cfg.Producer.MaxAsyncInFlight > 0 {
+		p.asyncSlotsCh = make(chan none.T, cfg.Producer.MaxAsyncInFlight)
 	}
 	actor.Spawn(p.mergActDesc, &p.wg, p.runMerger)
 	actor.Spawn(p.dispActDesc, &p.wg, p.runDispatcher)
+	actor.Spawn(p.refrActDesc, &p.wg, p.runMetadataRefresher)
 	return p, nil
 }
 
-// Stop shuts down all producer goroutines and releases all resources.
+// Stop shuts down all producer goroutines and releases all resources. No new
+// messages can be submitted after Stop is called, but messages that are
+// already in flight are given `Producer.ShutdownTimeout` to be acknowledged
+// by Kafka. Messages that could not be committed within that time are logged.
+// The underlying sarama client is closed only after that.
 func (p *T) Stop() {
+	close(p.stopCh)
 	close(p.dispatcherCh)
 	p.wg.Wait()
+	if err := p.saramaClient.Close(); err != nil {
+		p.dispActDesc.Log().WithError(err).Error("Failed to close sarama client")
+	}
+}
+
+// Metrics returns the registry where both the producer and the underlying
+// sarama producer report metrics to. Besides sarama metrics, e.g.
+// `batch-size-for-topic-<topic>`, it contains:
+//  * `produce-latency-in-ms` and `produce-latency-in-ms-for-topic-<topic>`
+//    histograms of time between a message is submitted to the producer and
+//    a response for it is received from Kafka;
+//  * `produce-errors` and `produce-errors-for-code-<code>` counters of failed
+//    messages, where <code> is a Kafka error code or -1 if the error does not
+//    come from Kafka;
+//  * `produced-messages` and `produced-bytes` counters of messages written to
+//    Kafka and the total size of their keys and values, broken down by topic
+//    `-for-topic-<topic>` and by partition
+//    `-for-topic-<topic>-partition-<partition>`;
+//  * `produce-latency-in-ms-for-client-<id>` histograms and
+//    `produce-errors-for-client-<id>` counters of messages submitted with
+//    Opts.ClientID;
+//  * `metadata-age-ms` gauge of time since metadata of all topics was last
+//    refreshed, see MetadataAge.
+func (p *T) Metrics() metrics.Registry {
+	return p.metricRegistry
 }
 
 // Produce submits a message to the specified `topic` of the Kafka cluster
@@ -84,26 +299,226 @@ func (p *T) Stop() {
 // into a random partition.
 //
 // Errors usually indicate a catastrophic failure of the Kafka cluster, or
-// missing topic if there cluster is not configured to auto create topics.
+// missing topic if there cluster is not configured to auto create topics. If
+// the result is not known within `Producer.ProduceTimeout`, then
+// ErrProduceTimeout is returned, but the message may still be written to
+// Kafka after that.
 func (p *T) Produce(topic string, key, message sarama.Encoder) (*sarama.ProducerMessage, error) {
-	rs := <-p.AsyncProduce(topic, key, message)
-	return rs.Msg, rs.Err
+	return p.wait(p.AsyncProduce(topic, key, message))
 }
 
 // AsyncProduce is an asynchronously counterpart of the `Produce` function.
 // Errors are silently ignored.
 func (p *T) AsyncProduce(topic string, key, message sarama.Encoder) <-chan Response {
+	return p.AsyncProduceAt(topic, key, message, time.Time{})
+}
+
+// AsyncProduceAt is the same as `AsyncProduce` but the message is produced
+// with the specified timestamp instead of the current time. Kafka only keeps
+// the timestamp if the topic is configured with
+// `message.timestamp.type=CreateTime`, that is the default, and `kafka.version`
+// is 0.10.0.0 or later. If timestamp is zero then the current time is used.
+func (p *T) AsyncProduceAt(topic string, key, message sarama.Encoder, timestamp time.Time) <-chan Response {
+	return p.AsyncProduceWithOpts(topic, key, message, Opts{Timestamp: timestamp})
+}
+
+// AsyncProduceWithOpts is the same as `AsyncProduce` but the message is
+// produced with the specified optional properties.
+func (p *T) AsyncProduceWithOpts(topic string, key, message sarama.Encoder, opts Opts) <-chan Response {
+	return p.asyncProduce(topic, key, message, opts, false)
+}
+
+// AsyncProduceBounded is the same as `AsyncProduce`, but the number of
+// messages submitted with it that can be in flight at a time is limited by
+// `Producer.MaxAsyncInFlight`. If the limit is reached, then it blocks until
+// an earlier message is acknowledged by Kafka or fails, or until ctx is done.
+// In the latter case ErrQueueFull is returned and the message is not
+// submitted.
+func (p *T) AsyncProduceBounded(ctx context.Context, topic string, key, message sarama.Encoder, opts Opts) (<-chan Response, error) {
+	if p.asyncSlotsCh == nil {
+		return p.AsyncProduceWithOpts(topic, key, message, opts), nil
+	}
+	select {
+	case p.asyncSlotsCh <- none.V:
+	case <-ctx.Done():
+		return nil, ErrQueueFull
+	}
+	return p.asyncProduce(topic, key, message, opts, true), nil
+}
+
+func (p *T) asyncProduce(topic string, key, message sarama.Encoder, opts Opts, bounded bool) <-chan Response {
 	responseCh := make(chan Response, 1)
 	prodMsg := &sarama.ProducerMessage{
-		Topic:    topic,
-		Key:      key,
-		Value:    message,
-		Metadata: responseCh,
+		Topic:     topic,
+		Key:       key,
+		Value:     message,
+		Timestamp: opts.Timestamp,
+		Metadata: &msgMeta{
+			responseCh: responseCh,
+			enqueuedAt: time.Now(),
+			bounded:    bounded,
+			clientID:   opts.ClientID,
+		},
 	}
-	p.dispatcherCh <- prodMsg
+	p.submit(prodMsg, responseCh)
 	return responseCh
 }
 
+// AsyncProduceCallback is the same as `AsyncProduce`, but the produce result
+// is passed to `cb` instead of a channel. The callback is invoked on a
+// goroutine dedicated to the message, therefore a slow callback never blocks
+// the producer. But that also means that callbacks of different messages can
+// run concurrently and in an order different from that of message submission.
+func (p *T) AsyncProduceCallback(topic string, key, message sarama.Encoder, cb func(*sarama.ProducerMessage, error)) {
+	responseCh := p.AsyncProduce(topic, key, message)
+	go func() {
+		rs := <-responseCh
+		cb(rs.Msg, rs.Err)
+	}()
+}
+
+// Flush blocks until all messages submitted before the call are either
+// acknowledged by Kafka or failed, or until timeout elapses, in which case
+// ErrFlushTimeout is returned. Note that messages may linger in the
+// underlying sarama producer for up to `Producer.FlushFrequency` before they
+// are sent to Kafka.
+func (p *T) Flush(timeout time.Duration) error {
+	rq := &flushRq{doneCh: make(chan none.T)}
+	timeoutCh := time.After(timeout)
+	select {
+	case p.dispatcherCh <- &sarama.ProducerMessage{Metadata: rq}:
+	case <-timeoutCh:
+		return ErrFlushTimeout
+	}
+	select {
+	case <-rq.doneCh:
+		return nil
+	case <-timeoutCh:
+		return ErrFlushTimeout
+	}
+}
+
+// RefreshMetadata forces refresh of the metadata of the specified topics, or of
+// all topics if none is specified.
+func (p *T) RefreshMetadata(topics ...string) error {
+	if err := p.saramaClient.RefreshMetadata(topics...); err != nil {
+		return err
+	}
+	if len(topics) == 0 {
+		atomic.StoreInt64(&p.metadataRefreshedAt, clock.Now().UnixNano())
+	}
+	return nil
+}
+
+// MetadataAge returns time since metadata of all topics was last refreshed.
+// Metadata of particular topics can be refreshed more recently than that.
+func (p *T) MetadataAge() time.Duration {
+	return clock.Now().Sub(time.Unix(0, atomic.LoadInt64(&p.metadataRefreshedAt)))
+}
+
+// runMetadataRefresher refreshes metadata of all topics as soon as it gets
+// older than `metadataMaxAge`, and metadata of topics reported to
+// `staleTopicsCh` right away. If a refresh of all topics fails it is retried
+// after `Producer.RetryBackoff`.
+func (p *T) runMetadataRefresher() {
+	var nilOrTimeoutCh <-chan time.Time
+	var timer clock.Timer
+	if p.metadataMaxAge > 0 {
+		timer = clock.NewTimer(p.metadataMaxAge)
+		defer timer.Stop()
+		nilOrTimeoutCh = timer.C()
+	}
+	for {
+		select {
+		case <-nilOrTimeoutCh:
+			if err := p.RefreshMetadata(); err != nil {
+				p.refrActDesc.Log().WithError(err).Warn("Failed to refresh metadata")
+				timer.Reset(p.retryBackoff)
+				continue
+			}
+			timer.Reset(p.metadataMaxAge)
+		case topic := <-p.staleTopicsCh:
+			if err := p.saramaClient.RefreshMetadata(topic); err != nil {
+				p.refrActDesc.Log().WithError(err).Warnf("Failed to refresh metadata: topic=%s", topic)
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// ProduceToPartition submits a message to a particular partition of the
+// specified `topic` regardless of the `key` value. If the partition does not
+// exist then ErrPartitionOutOfRange is returned.
+//
+// The key can be `nil`, e.g. to keep an ordered stream of messages that have
+// no natural key in a single partition. Such messages are produced with a null
+// key, and the configured partitioner is not consulted at all, so a nil key
+// never results in a random partition here.
+func (p *T) ProduceToPartition(topic string, partition int32, key, message sarama.Encoder) (*sarama.ProducerMessage, error) {
+	return p.wait(p.AsyncProduceToPartition(topic, partition, key, message))
+}
+
+// wait blocks until a produce result is received from responseCh, or until
+// `Producer.ProduceTimeout` elapses, in which case ErrProduceTimeout is
+// returned. Response channels are buffered, so the dispatcher never blocks on
+// a result that nobody waits for anymore, and the channel is garbage
+// collected along with it.
+func (p *T) wait(responseCh <-chan Response) (*sarama.ProducerMessage, error) {
+	if p.produceTimeout <= 0 {
+		rs := <-responseCh
+		return rs.Msg, rs.Err
+	}
+	timer := time.NewTimer(p.produceTimeout)
+	defer timer.Stop()
+	select {
+	case rs := <-responseCh:
+		return rs.Msg, rs.Err
+	case <-timer.C:
+		return nil, ErrProduceTimeout
+	}
+}
+
+// AsyncProduceToPartition is an asynchronously counterpart of the
+// `ProduceToPartition` function.
+func (p *T) AsyncProduceToPartition(topic string, partition int32, key, message sarama.Encoder) <-chan Response {
+	responseCh := make(chan Response, 1)
+	prodMsg := &sarama.ProducerMessage{
+		Topic:     topic,
+		Key:       key,
+		Value:     message,
+		Partition: partition,
+		Metadata:  &msgMeta{responseCh: responseCh, enqueuedAt: time.Now(), partitionSet: true},
+	}
+	p.submit(prodMsg, responseCh)
+	return responseCh
+}
+
+// submit hands a message over to the dispatcher, unless it is larger than
+// `Producer.MaxMessageBytes`. Then ErrMessageTooLarge is sent to responseCh
+// right away, for there is no point to submit it to Kafka only to be rejected.
+func (p *T) submit(prodMsg *sarama.ProducerMessage, responseCh chan<- Response) {
+	size := msgSize(prodMsg)
+	if meta, ok := prodMsg.Metadata.(*msgMeta); ok {
+		meta.info = MsgInfo{SerializedBytes: size + p.msgOverhead, Compression: p.compression}
+	}
+	if size > p.maxMessageBytes {
+		p.releaseAsyncSlot(prodMsg)
+		responseCh <- Response{Msg: prodMsg, Err: errors.Wrapf(ErrMessageTooLarge,
+			"%d bytes exceed producer.max_message_bytes=%d", size, p.maxMessageBytes)}
+		return
+	}
+	p.dispatcherCh <- prodMsg
+}
+
+// releaseAsyncSlot frees the in flight slot held by a message submitted with
+// AsyncProduceBounded, so that another message can take it.
+func (p *T) releaseAsyncSlot(prodMsg *sarama.ProducerMessage) {
+	if meta, ok := prodMsg.Metadata.(*msgMeta); ok && meta.bounded {
+		<-p.asyncSlotsCh
+	}
+}
+
 // merge receives both message acknowledgements and producer errors from the
 // respective `sarama.AsyncProducer` channels, constructs `ProducerResult`s out
 // of them and sends the constructed `ProducerResult` instances to `responseCh`
@@ -161,6 +576,14 @@ func (p *T) runDispatcher() {
 			if !channelOpened {
 				goto gracefulShutdown
 			}
+			if rq, ok := prodMsg.Metadata.(*flushRq); ok {
+				p.inFlight.PushBack(rq)
+				p.completeFlushes()
+				continue
+			}
+			if meta, ok := prodMsg.Metadata.(*msgMeta); ok {
+				meta.inFlightElem = p.inFlight.PushBack(meta)
+			}
 			pendingMsgCount += 1
 			nilOrDispatcherCh = nil
 			nilOrProdInputCh = p.saramaProducer.Input()
@@ -188,28 +611,95 @@ gracefulShutdown:
 shutdownNow:
 	p.dispActDesc.Log().Infof("Stopping producer: pendingMsgCount=%d", pendingMsgCount)
 	p.saramaProducer.AsyncClose()
+	droppedMsgCount := 0
 	for prodResult := range p.responseCh {
+		if prodResult.Err != nil {
+			droppedMsgCount += 1
+		}
 		p.handleProduceResult(prodResult)
 	}
+	p.dispActDesc.Log().Infof("Producer stopped: droppedMsgCount=%d", droppedMsgCount)
 }
 
 // handleProduceResult inspects a production results and if it is an error
 // then logs it.
 func (p *T) handleProduceResult(result Response) {
-	if replyCh, ok := result.Msg.Metadata.(chan Response); ok {
-		replyCh <- result
+	clientID := ""
+	if meta, ok := result.Msg.Metadata.(*msgMeta); ok {
+		clientID = meta.clientID
+		latency := int64(time.Since(meta.enqueuedAt) / time.Millisecond)
+		getOrRegisterHistogram(metricProduceLatency, p.metricRegistry).Update(latency)
+		getOrRegisterHistogram(getMetricNameForTopic(metricProduceLatency, result.Msg.Topic), p.metricRegistry).Update(latency)
+		if clientID != "" {
+			getOrRegisterHistogram(p.clientMetrics.Get(metricProduceLatency, clientID), p.metricRegistry).Update(latency)
+		}
+		p.releaseAsyncSlot(result.Msg)
+		meta.responseCh <- result
+		if meta.inFlightElem != nil {
+			p.inFlight.Remove(meta.inFlightElem)
+			p.completeFlushes()
+		}
 	}
 	if result.Err == nil {
+		p.countProduced(result.Msg)
 		return
 	}
+	errorCode := -1
+	if kafkaErr, ok := result.Err.(sarama.KError); ok {
+		errorCode = int(kafkaErr)
+	}
+	switch result.Err {
+	case sarama.ErrLeaderNotAvailable, sarama.ErrNotLeaderForPartition:
+		// Sarama refreshes metadata between retries itself, but by the time
+		// the retries are exhausted the leader may have moved again. Make
+		// sure that a retry by the client goes to the current leader.
+		select {
+		case p.staleTopicsCh <- result.Msg.Topic:
+		default:
+		}
+	}
+	metrics.GetOrRegisterCounter(metricProduceErrors, p.metricRegistry).Inc(1)
+	metrics.GetOrRegisterCounter(fmt.Sprintf("%s-for-code-%d", metricProduceErrors, errorCode), p.metricRegistry).Inc(1)
 	prodMsgRepr := fmt.Sprintf(`{Topic: "%s", Key: "%s", Value: "%s"}`,
 		result.Msg.Topic, encoderRepr(result.Msg.Key), encoderRepr(result.Msg.Value))
-	p.dispActDesc.Log().WithError(result.Err).Errorf("Failed to submit message: msg=%v", prodMsgRepr)
+	logEntry := p.dispActDesc.Log().WithError(result.Err)
+	if clientID != "" {
+		metrics.GetOrRegisterCounter(p.clientMetrics.Get(metricProduceErrors, clientID), p.metricRegistry).Inc(1)
+		logEntry = logEntry.WithField("client_id", clientID)
+	}
+	logEntry.Errorf("Failed to submit message: msg=%v", prodMsgRepr)
 	if p.testDroppedMsgCh != nil {
 		p.testDroppedMsgCh <- result.Msg
 	}
 }
 
+// countProduced updates produced messages and bytes metrics of the topic and
+// partition that a message was written to.
+func (p *T) countProduced(prodMsg *sarama.ProducerMessage) {
+	size := int64(msgSize(prodMsg))
+	topicMsgs := getMetricNameForTopic(metricProducedMsgs, prodMsg.Topic)
+	topicBytes := getMetricNameForTopic(metricProducedBytes, prodMsg.Topic)
+	metrics.GetOrRegisterCounter(metricProducedMsgs, p.metricRegistry).Inc(1)
+	metrics.GetOrRegisterCounter(metricProducedBytes, p.metricRegistry).Inc(size)
+	metrics.GetOrRegisterCounter(topicMsgs, p.metricRegistry).Inc(1)
+	metrics.GetOrRegisterCounter(topicBytes, p.metricRegistry).Inc(size)
+	metrics.GetOrRegisterCounter(getMetricNameForPartition(topicMsgs, prodMsg.Partition), p.metricRegistry).Inc(1)
+	metrics.GetOrRegisterCounter(getMetricNameForPartition(topicBytes, prodMsg.Partition), p.metricRegistry).Inc(size)
+}
+
+// completeFlushes notifies flush requests that all messages received by the
+// dispatcher before them are done with.
+func (p *T) completeFlushes() {
+	for elem := p.inFlight.Front(); elem != nil; elem = p.inFlight.Front() {
+		rq, ok := elem.Value.(*flushRq)
+		if !ok {
+			return
+		}
+		close(rq.doneCh)
+		p.inFlight.Remove(elem)
+	}
+}
+
 // encoderRepr returns the string representation of an encoder value. The value
 // is truncated to `maxEncoderReprLength`.
 func encoderRepr(e sarama.Encoder) string {
@@ -228,3 +718,136 @@ func encoderRepr(e sarama.Encoder) string {
 	}
 	return repr
 }
+
+func getOrRegisterHistogram(name string, r metrics.Registry) metrics.Histogram {
+	return r.GetOrRegister(name, func() metrics.Histogram {
+		return metrics.NewHistogram(metrics.NewExpDecaySample(metricsReservoirSize, metricsAlphaFactor))
+	}).(metrics.Histogram)
+}
+
+// getMetricNameForTopic returns a topic specific metric name following the
+// naming convention of sarama.
+func getMetricNameForTopic(name string, topic string) string {
+	return fmt.Sprintf("%s-for-topic-%s", name, strings.Replace(topic, ".", "_", -1))
+}
+
+// getMetricNameForPartition returns a partition specific version of a topic
+// specific metric name.
+func getMetricNameForPartition(topicMetricName string, partition int32) string {
+	return fmt.Sprintf("%s-partition-%d", topicMetricName, partition)
+}
+
+// msgSize returns the total size of a message key and value.
+func msgSize(prodMsg *sarama.ProducerMessage) int {
+	size := 0
+	if prodMsg.Key != nil {
+		size += prodMsg.Key.Length()
+	}
+	if prodMsg.Value != nil {
+		size += prodMsg.Value.Length()
+	}
+	return size
+}
+
+// OtherClientID is the client ID that metrics of clients in excess of
+// `monitoring.max_client_metrics` are reported under.
+const OtherClientID = "other"
+
+// ClientMetricNames makes client specific metric names. Only the first
+// `limit` distinct client IDs get metrics of their own, and the rest share
+// the metrics of OtherClientID. Client IDs are chosen by clients, so without
+// the limit a misbehaving client could make the number of metrics grow
+// without bound.
+type ClientMetricNames struct {
+	mu    sync.Mutex
+	limit int
+	known map[string]none.T
+}
+
+// NewClientMetricNames creates a ClientMetricNames instance that gives
+// metrics of their own to at most limit client IDs, or to any number of them
+// if limit is zero.
+func NewClientMetricNames(limit int) *ClientMetricNames {
+	return &ClientMetricNames{limit: limit, known: make(map[string]none.T)}
+}
+
+// Get returns a client specific metric name following the naming convention
+// of sarama.
+func (cmn *ClientMetricNames) Get(name string, clientID string) string {
+	clientID = strings.Replace(clientID, ".", "_", -1)
+	cmn.mu.Lock()
+	if _, ok := cmn.known[clientID]; !ok {
+		if cmn.limit > 0 && len(cmn.known) >= cmn.limit {
+			clientID = OtherClientID
+		} else {
+			cmn.known[clientID] = none.V
+		}
+	}
+	cmn.mu.Unlock()
+	return fmt.Sprintf("%s-for-client-%s", name, clientID)
+}
+
+// partitionLister is the subset of sarama.Client that partitioner needs.
+type partitionLister interface {
+	Partitions(topic string) ([]int32, error)
+	WritablePartitions(topic string) ([]int32, error)
+}
+
+// partitioner sends messages produced with ProduceToPartition to the
+// partition explicitly specified by the caller, and all other messages to
+// partitions selected by the configured partitioner.
+type partitioner struct {
+	keyPartitioner  sarama.Partitioner
+	partitionLister partitionLister
+}
+
+// Partition implements sarama.Partitioner.
+func (p *partitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	if meta, ok := msg.Metadata.(*msgMeta); ok && meta.partitionSet {
+		if p.keyPartitioner.RequiresConsistency() {
+			if msg.Partition < 0 || msg.Partition >= numPartitions {
+				return -1, ErrPartitionOutOfRange
+			}
+			return msg.Partition, nil
+		}
+		return p.writableIndex(msg.Topic, msg.Partition, numPartitions)
+	}
+	return p.keyPartitioner.Partition(msg, numPartitions)
+}
+
+// RequiresConsistency implements sarama.Partitioner. It is delegated to the
+// configured partitioner, so that partitioners that do not require
+// consistency keep skipping partitions that have no leader.
+func (p *partitioner) RequiresConsistency() bool {
+	return p.keyPartitioner.RequiresConsistency()
+}
+
+// writableIndex returns the index of an explicitly specified partition among
+// the writable partitions of a topic, for that is what sarama selects from if
+// the configured partitioner does not require consistency. If the partition
+// is not writable at the moment, then sarama.ErrLeaderNotAvailable is
+// returned.
+func (p *partitioner) writableIndex(topic string, partition, numPartitions int32) (int32, error) {
+	partitions, err := p.partitionLister.Partitions(topic)
+	if err != nil {
+		return -1, err
+	}
+	if partition < 0 || partition >= int32(len(partitions)) {
+		return -1, ErrPartitionOutOfRange
+	}
+	writable, err := p.partitionLister.WritablePartitions(topic)
+	if err != nil {
+		return -1, err
+	}
+	// Metadata could have been refreshed since sarama listed the writable
+	// partitions, then indexes do not match.
+	if int32(len(writable)) != numPartitions {
+		return -1, sarama.ErrLeaderNotAvailable
+	}
+	for i, writablePartition := range writable {
+		if writablePartition == partition {
+			return int32(i), nil
+		}
+	}
+	return -1, sarama.ErrLeaderNotAvailable
+}
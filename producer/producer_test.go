@@ -1,15 +1,20 @@
 package producer
 
 import (
+	"context"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/mailgun/holster/clock"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
+	"github.com/mailgun/kafka-pixy/none"
 	"github.com/mailgun/kafka-pixy/testhelpers"
 	"github.com/mailgun/kafka-pixy/testhelpers/kafkahelper"
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
 	. "gopkg.in/check.v1"
 )
 
@@ -68,6 +73,222 @@ func (s *ProducerSuite) TestProduce(c *C) {
 	p.Stop()
 }
 
+// If a message is produced with a nil value, then it is written to Kafka as
+// a tombstone, a message with null value, whereas an empty value is preserved.
+func (s *ProducerSuite) TestProduceTombstone(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	// When
+	tombstone, err := p.Produce("test.1", sarama.StringEncoder("1"), nil)
+	c.Assert(err, IsNil)
+	empty, err := p.Produce("test.1", sarama.StringEncoder("1"), sarama.ByteEncoder([]byte{}))
+	c.Assert(err, IsNil)
+
+	// Then
+	cons, err := sarama.NewConsumerFromClient(s.kh.KafkaClt())
+	c.Assert(err, IsNil)
+	defer cons.Close()
+	pc, err := cons.ConsumePartition("test.1", 0, tombstone.Offset)
+	c.Assert(err, IsNil)
+	defer pc.Close()
+	consMsg := <-pc.Messages()
+	c.Assert(consMsg.Offset, Equals, tombstone.Offset)
+	c.Assert(consMsg.Value, IsNil)
+	consMsg = <-pc.Messages()
+	c.Assert(consMsg.Offset, Equals, empty.Offset)
+	c.Assert(consMsg.Value, NotNil)
+	c.Assert(len(consMsg.Value), Equals, 0)
+}
+
+// If a message is produced with a timestamp, then it is stored in Kafka with
+// the timestamp instead of the current time.
+func (s *ProducerSuite) TestProduceTimestamp(c *C) {
+	s.cfg.Kafka.Version.Set(sarama.V0_10_0_0)
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+	timestamp := time.Date(2009, 2, 19, 0, 0, 0, 0, time.UTC)
+
+	// When
+	rs := <-p.AsyncProduceAt("test.1", sarama.StringEncoder("1"), sarama.StringEncoder("foo"), timestamp)
+	c.Assert(rs.Err, IsNil)
+
+	// Then
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Version = sarama.V0_10_0_0
+	cons, err := sarama.NewConsumer(testhelpers.KafkaPeers, saramaCfg)
+	c.Assert(err, IsNil)
+	defer cons.Close()
+	pc, err := cons.ConsumePartition("test.1", 0, rs.Msg.Offset)
+	c.Assert(err, IsNil)
+	defer pc.Close()
+	consMsg := <-pc.Messages()
+	c.Assert(consMsg.Offset, Equals, rs.Msg.Offset)
+	c.Assert(consMsg.Timestamp.Equal(timestamp), Equals, true)
+}
+
+// When Flush returns all messages submitted before it are done with.
+func (s *ProducerSuite) TestFlush(c *C) {
+	s.cfg.Producer.FlushFrequency = 100 * time.Millisecond
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+	var responseChs []<-chan Response
+	for i := 0; i < 10; i++ {
+		responseChs = append(responseChs, p.AsyncProduce("test.4", sarama.StringEncoder(strconv.Itoa(i)), sarama.StringEncoder("foo")))
+	}
+
+	// When
+	err := p.Flush(3 * time.Second)
+
+	// Then
+	c.Assert(err, IsNil)
+	for i, responseCh := range responseChs {
+		select {
+		case rs := <-responseCh:
+			c.Assert(rs.Err, IsNil, Commentf("message #%d", i))
+		default:
+			c.Errorf("message #%d is still in flight", i)
+		}
+	}
+}
+
+// If nothing is in flight, then Flush returns right away.
+func (s *ProducerSuite) TestFlushIdle(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	// When
+	err := p.Flush(time.Millisecond * 100)
+
+	// Then
+	c.Assert(err, IsNil)
+}
+
+// Produced messages report their serialized size and compression codec, that
+// depend on the Kafka version and the producer configuration.
+func (s *ProducerSuite) TestMessageInfo(c *C) {
+	s.cfg.Kafka.Version.Set(sarama.V0_10_0_0)
+	s.cfg.Producer.Compression = config.Compression(sarama.CompressionGZIP)
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	// When
+	prodMsg, err := p.Produce("test.1", sarama.StringEncoder("12"), sarama.StringEncoder("345"))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(MessageInfo(prodMsg), Equals, MsgInfo{SerializedBytes: 5 + msgOverheadV1, Compression: sarama.CompressionGZIP})
+	c.Assert(MessageInfo(nil), Equals, MsgInfo{})
+	c.Assert(MessageInfo(&sarama.ProducerMessage{}), Equals, MsgInfo{})
+}
+
+// Messages with the same key are distributed evenly among partitions by the
+// round robin partitioner.
+func (s *ProducerSuite) TestRoundRobinPartitioner(c *C) {
+	s.cfg.Producer.Partitioner = "round_robin"
+	p, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.Stop()
+
+	// When
+	partitions := make(map[int32]bool)
+	for i := 0; i < 4; i++ {
+		prodMsg, err := p.Produce("test.4", sarama.StringEncoder("foo"), sarama.StringEncoder(strconv.Itoa(i)))
+		c.Assert(err, IsNil)
+		partitions[prodMsg.Partition] = true
+	}
+
+	// Then
+	c.Assert(len(partitions), Equals, 4)
+}
+
+// A registered custom partitioner can be selected in config, and it is not
+// used for messages produced to explicit partitions.
+func (s *ProducerSuite) TestCustomPartitioner(c *C) {
+	RegisterPartitioner("test_last", func(topic string) sarama.Partitioner {
+		return lastPartitioner{}
+	})
+	s.cfg.Producer.Partitioner = "test_last"
+	p, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.Stop()
+
+	// When
+	prodMsg1, err1 := p.Produce("test.4", sarama.StringEncoder("foo"), sarama.StringEncoder("1"))
+	prodMsg2, err2 := p.ProduceToPartition("test.4", 1, sarama.StringEncoder("foo"), sarama.StringEncoder("2"))
+
+	// Then
+	c.Assert(err1, IsNil)
+	c.Assert(prodMsg1.Partition, Equals, int32(3))
+	c.Assert(err2, IsNil)
+	c.Assert(prodMsg2.Partition, Equals, int32(1))
+}
+
+func (s *ProducerSuite) TestUnknownPartitioner(c *C) {
+	s.cfg.Producer.Partitioner = "bogus"
+
+	// When
+	_, err := Spawn(s.ns, s.cfg)
+
+	// Then
+	c.Assert(err.Error(), Equals, "unknown partitioner: bogus")
+}
+
+// Messages larger than Producer.MaxMessageBytes are rejected without being
+// submitted to Kafka.
+func (s *ProducerSuite) TestMessageTooLarge(c *C) {
+	s.cfg.Producer.MaxMessageBytes = 10
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	// When
+	_, err1 := p.Produce("test.1", sarama.StringEncoder("12345"), sarama.StringEncoder("123456"))
+	_, err2 := p.ProduceToPartition("test.1", 0, nil, sarama.StringEncoder("12345678901"))
+	_, err3 := p.Produce("test.1", sarama.StringEncoder("12345"), sarama.StringEncoder("12345"))
+
+	// Then
+	c.Assert(errors.Cause(err1), Equals, ErrMessageTooLarge)
+	c.Assert(err1.Error(), Equals, "11 bytes exceed producer.max_message_bytes=10: message is too large")
+	c.Assert(errors.Cause(err2), Equals, ErrMessageTooLarge)
+	c.Assert(err3, IsNil)
+}
+
+// When the max number of bounded async messages is in flight, submission of
+// another one waits for a slot to be released, or fails when the context is
+// done.
+func (s *ProducerSuite) TestAsyncProduceBounded(c *C) {
+	s.cfg.Producer.MaxAsyncInFlight = 1
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+	p.asyncSlotsCh <- none.V // Take the only slot.
+
+	// When
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := p.AsyncProduceBounded(ctx, "test.1", sarama.StringEncoder("1"), sarama.StringEncoder("Foo"), Opts{})
+
+	// Then
+	c.Assert(err, Equals, ErrQueueFull)
+
+	// When
+	<-p.asyncSlotsCh // Release the slot.
+	responseCh, err := p.AsyncProduceBounded(context.Background(), "test.1", sarama.StringEncoder("1"), sarama.StringEncoder("Bar"), Opts{})
+
+	// Then
+	c.Assert(err, IsNil)
+	rs := <-responseCh
+	c.Assert(rs.Err, IsNil)
+	c.Assert(len(p.asyncSlotsCh), Equals, 0)
+}
+
+func (s *ProducerSuite) TestRefreshMetadata(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	c.Assert(p.RefreshMetadata("test.4"), IsNil)
+	c.Assert(p.RefreshMetadata("no-such-topic"), Equals, sarama.ErrUnknownTopicOrPartition)
+}
+
 func (s *ProducerSuite) TestProduceInvalidTopic(c *C) {
 	p, _ := Spawn(s.ns, s.cfg)
 
@@ -81,6 +302,266 @@ func (s *ProducerSuite) TestProduceInvalidTopic(c *C) {
 	p.Stop()
 }
 
+// Produce latency and errors of messages submitted with a client ID are
+// reported to client specific metrics.
+func (s *ProducerSuite) TestClientMetrics(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	// When
+	rs1 := <-p.AsyncProduceWithOpts("test.1", sarama.StringEncoder("1"), sarama.StringEncoder("Foo"), Opts{ClientID: "svc.a"})
+	rs2 := <-p.AsyncProduceWithOpts("no-such-topic", sarama.StringEncoder("1"), sarama.StringEncoder("Bar"), Opts{ClientID: "svc.a"})
+
+	// Then
+	c.Assert(rs1.Err, IsNil)
+	c.Assert(rs2.Err, Equals, sarama.ErrUnknownTopicOrPartition)
+	latency := p.Metrics().Get("produce-latency-in-ms-for-client-svc_a").(metrics.Histogram)
+	c.Assert(latency.Count(), Equals, int64(2))
+	errorCount := p.Metrics().Get("produce-errors-for-client-svc_a").(metrics.Counter)
+	c.Assert(errorCount.Count(), Equals, int64(1))
+}
+
+// Only the first client IDs up to the limit get metrics of their own, and
+// client IDs that differ only in dots and underscores share metrics.
+func (s *ProducerSuite) TestClientMetricNames(c *C) {
+	cmn := NewClientMetricNames(2)
+
+	// When/Then
+	c.Assert(cmn.Get("foo", "svc.a"), Equals, "foo-for-client-svc_a")
+	c.Assert(cmn.Get("foo", "svc.b"), Equals, "foo-for-client-svc_b")
+	c.Assert(cmn.Get("foo", "svc.c"), Equals, "foo-for-client-other")
+	c.Assert(cmn.Get("bar", "svc_a"), Equals, "bar-for-client-svc_a")
+	c.Assert(NewClientMetricNames(0).Get("foo", "svc.c"), Equals, "foo-for-client-svc_c")
+}
+
+// Produced messages and bytes are counted per topic and partition.
+func (s *ProducerSuite) TestProducedMetrics(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+
+	// When
+	rs1 := <-p.AsyncProduceToPartition("test.4", 2, sarama.StringEncoder("1"), sarama.StringEncoder("Foo"))
+	rs2 := <-p.AsyncProduceToPartition("test.4", 2, nil, sarama.StringEncoder("Bazz"))
+	rs3 := <-p.AsyncProduceToPartition("test.4", 3, sarama.StringEncoder("2"), sarama.StringEncoder("Bar"))
+
+	// Then
+	c.Assert(rs1.Err, IsNil)
+	c.Assert(rs2.Err, IsNil)
+	c.Assert(rs3.Err, IsNil)
+	counts := make(map[string]int64)
+	for _, name := range []string{
+		"produced-messages-for-topic-test_4",
+		"produced-bytes-for-topic-test_4",
+		"produced-messages-for-topic-test_4-partition-2",
+		"produced-bytes-for-topic-test_4-partition-2",
+		"produced-messages-for-topic-test_4-partition-3",
+		"produced-bytes-for-topic-test_4-partition-3",
+	} {
+		counts[name] = p.Metrics().Get(name).(metrics.Counter).Count()
+	}
+	c.Assert(counts, DeepEquals, map[string]int64{
+		"produced-messages-for-topic-test_4":             3,
+		"produced-bytes-for-topic-test_4":                12,
+		"produced-messages-for-topic-test_4-partition-2": 2,
+		"produced-bytes-for-topic-test_4-partition-2":    8,
+		"produced-messages-for-topic-test_4-partition-3": 1,
+		"produced-bytes-for-topic-test_4-partition-3":    4,
+	})
+}
+
+// The callback passed to AsyncProduceCallback gets the partition and offset
+// that a message was written to.
+func (s *ProducerSuite) TestAsyncProduceCallback(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+	offsetsBefore := s.kh.GetNewestOffsets("test.4")
+	type result struct {
+		prodMsg *sarama.ProducerMessage
+		err     error
+	}
+	resultCh := make(chan result, 1)
+
+	// When
+	p.AsyncProduceCallback("test.4", sarama.StringEncoder("1"), sarama.StringEncoder("Foo"),
+		func(prodMsg *sarama.ProducerMessage, err error) {
+			resultCh <- result{prodMsg, err}
+		})
+
+	// Then
+	rs := <-resultCh
+	c.Assert(rs.err, IsNil)
+	c.Assert(rs.prodMsg.Partition, Equals, int32(0))
+	c.Assert(rs.prodMsg.Offset, Equals, offsetsBefore[0])
+}
+
+// A slow callback does not block production of other messages.
+func (s *ProducerSuite) TestAsyncProduceCallbackSlow(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+	releaseCh := make(chan struct{})
+	defer close(releaseCh)
+
+	// When
+	p.AsyncProduceCallback("test.4", sarama.StringEncoder("1"), sarama.StringEncoder("Foo"),
+		func(prodMsg *sarama.ProducerMessage, err error) {
+			<-releaseCh
+		})
+	_, err := p.Produce("test.4", sarama.StringEncoder("1"), sarama.StringEncoder("Bar"))
+
+	// Then
+	c.Assert(err, IsNil)
+}
+
+// A message produced with ProduceToPartition lands in the specified
+// partition regardless of the key.
+func (s *ProducerSuite) TestProduceToPartition(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	offsetsBefore := s.kh.GetNewestOffsets("test.4")
+
+	// When
+	prodMsg, err := p.ProduceToPartition("test.4", 2, sarama.StringEncoder("1"), sarama.StringEncoder("Foo"))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(prodMsg.Partition, Equals, int32(2))
+	offsetsAfter := s.kh.GetNewestOffsets("test.4")
+	c.Assert(offsetsAfter[0], Equals, offsetsBefore[0])
+	c.Assert(offsetsAfter[2], Equals, offsetsBefore[2]+1)
+
+	// Cleanup
+	p.Stop()
+}
+
+// A message with a nil key produced with ProduceToPartition lands in the
+// specified partition with a null key.
+func (s *ProducerSuite) TestProduceToPartitionNilKey(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+	defer p.Stop()
+	offsetsBefore := s.kh.GetNewestOffsets("test.4")
+
+	// When
+	for i := 0; i < 10; i++ {
+		prodMsg, err := p.ProduceToPartition("test.4", 3, nil, sarama.StringEncoder(strconv.Itoa(i)))
+		c.Assert(err, IsNil)
+		c.Assert(prodMsg.Partition, Equals, int32(3))
+	}
+
+	// Then
+	offsetsAfter := s.kh.GetNewestOffsets("test.4")
+	c.Assert(offsetsAfter[3], Equals, offsetsBefore[3]+10)
+	for _, partition := range []int32{0, 1, 2} {
+		c.Assert(offsetsAfter[partition], Equals, offsetsBefore[partition])
+	}
+}
+
+// The configured partitioner is not invoked for messages produced to
+// explicit partitions, even if they have no key.
+func (s *ProducerSuite) TestPartitionerExplicitPartition(c *C) {
+	ptr := partitioner{
+		keyPartitioner:  panicPartitioner{},
+		partitionLister: &mockPartitionLister{partitions: []int32{0, 1, 2, 3}, writable: []int32{0, 1, 2, 3}},
+	}
+	msg := &sarama.ProducerMessage{
+		Topic:     "foo",
+		Partition: 2,
+		Metadata:  &msgMeta{partitionSet: true},
+	}
+
+	// When
+	partition, err := ptr.Partition(msg, 4)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(partition, Equals, int32(2))
+}
+
+// If the configured partitioner does not require consistency, then sarama
+// selects among writable partitions only, so explicit partitions are mapped
+// to their index among those.
+func (s *ProducerSuite) TestPartitionerExplicitPartitionWritable(c *C) {
+	ptr := partitioner{
+		keyPartitioner:  panicPartitioner{},
+		partitionLister: &mockPartitionLister{partitions: []int32{0, 1, 2, 3}, writable: []int32{0, 2, 3}},
+	}
+	for i, tc := range []struct {
+		partition int32
+		index     int32
+		err       error
+	}{
+		{partition: 0, index: 0},
+		{partition: 2, index: 1},
+		{partition: 3, index: 2},
+		{partition: 1, index: -1, err: sarama.ErrLeaderNotAvailable},
+		{partition: 4, index: -1, err: ErrPartitionOutOfRange},
+	} {
+		msg := &sarama.ProducerMessage{
+			Topic:     "foo",
+			Partition: tc.partition,
+			Metadata:  &msgMeta{partitionSet: true},
+		}
+
+		// When
+		index, err := ptr.Partition(msg, 3)
+
+		// Then
+		c.Assert(err, Equals, tc.err, Commentf("case #%d", i))
+		c.Assert(index, Equals, tc.index, Commentf("case #%d", i))
+	}
+}
+
+// The consistency requirement of the configured partitioner is preserved, so
+// that the random and round robin partitioners keep skipping partitions that
+// have no leader.
+func (s *ProducerSuite) TestPartitionerRequiresConsistency(c *C) {
+	for i, tc := range []struct {
+		ctor sarama.PartitionerConstructor
+		want bool
+	}{
+		{ctor: sarama.NewHashPartitioner, want: true},
+		{ctor: sarama.NewRandomPartitioner, want: false},
+		{ctor: sarama.NewRoundRobinPartitioner, want: false},
+	} {
+		ptr := partitioner{keyPartitioner: tc.ctor("foo")}
+		c.Assert(ptr.RequiresConsistency(), Equals, tc.want, Commentf("case #%d", i))
+	}
+}
+
+// Round robin partitioning skips partitions that have no leader.
+func (s *ProducerSuite) TestPartitionerRoundRobinSkipsUnwritable(c *C) {
+	ptr := partitioner{
+		keyPartitioner:  sarama.NewRoundRobinPartitioner("foo"),
+		partitionLister: &mockPartitionLister{partitions: []int32{0, 1, 2, 3}, writable: []int32{0, 2, 3}},
+	}
+	c.Assert(ptr.RequiresConsistency(), Equals, false)
+	// Sarama selects partitions the same way, see
+	// sarama.topicProducer.partitionMessage.
+	writable, _ := ptr.partitionLister.WritablePartitions("foo")
+
+	// When
+	selected := make(map[int32]int)
+	for i := 0; i < 9; i++ {
+		index, err := ptr.Partition(&sarama.ProducerMessage{Topic: "foo"}, int32(len(writable)))
+		c.Assert(err, IsNil)
+		selected[writable[index]]++
+	}
+
+	// Then
+	c.Assert(selected, DeepEquals, map[int32]int{0: 3, 2: 3, 3: 3})
+}
+
+func (s *ProducerSuite) TestProduceToPartitionOutOfRange(c *C) {
+	p, _ := Spawn(s.ns, s.cfg)
+
+	// When
+	_, err := p.ProduceToPartition("test.4", 4, sarama.StringEncoder("1"), sarama.StringEncoder("Foo"))
+
+	// Then
+	c.Assert(err, Equals, ErrPartitionOutOfRange)
+
+	// Cleanup
+	p.Stop()
+}
+
 // If `key` is not `nil` then produced messages are deterministically
 // distributed between partitions based on the `key` hash.
 func (s *ProducerSuite) TestAsyncProduce(c *C) {
@@ -179,6 +660,97 @@ func (s *ProducerSuite) TestAsyncProduceEmptyKey(c *C) {
 	c.Assert(offsetsAfter[3], Equals, offsetsBefore[3]+10)
 }
 
+// Metadata is refreshed as soon as it gets older than
+// `producer.metadata_max_age`, and its age is reported in metrics.
+func (s *ProducerSuite) TestMetadataMaxAge(c *C) {
+	clock.Freeze(time.Now())
+	defer clock.Unfreeze()
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.MetadataMaxAge = time.Minute
+	p, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.Stop()
+	c.Assert(clock.Wait4Scheduled(1, 3*time.Second), Equals, true)
+
+	// When
+	clock.Advance(59 * time.Second)
+
+	// Then
+	c.Assert(p.MetadataAge(), Equals, 59*time.Second)
+	c.Assert(p.Metrics().Get(metricMetadataAge).(metrics.Gauge).Value(), Equals, int64(59000))
+	c.Assert(len(s.waitMetadataRqs(c, broker1, 1)), Equals, 1) // Made by Spawn.
+
+	// When
+	clock.Advance(time.Second)
+
+	// Then
+	metadataRqs := s.waitMetadataRqs(c, broker1, 2)
+	c.Assert(metadataRqs[1].Topics, IsNil) // All topics.
+	for i := 0; i < 100 && p.MetadataAge() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(p.MetadataAge(), Equals, time.Duration(0))
+}
+
+// If a message fails to be produced because the partition leader has moved,
+// then metadata of the topic is refreshed right away.
+func (s *ProducerSuite) TestLeaderMovedRefreshesTopic(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("bar", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c).
+			SetError("foo", 0, sarama.ErrNotLeaderForPartition).
+			SetError("bar", 0, sarama.ErrMessageSizeTooLarge),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.RetryMax = 0
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	p, err := Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.Stop()
+
+	// When
+	_, err1 := p.Produce("bar", nil, sarama.StringEncoder("m1"))
+	_, err2 := p.Produce("foo", nil, sarama.StringEncoder("m2"))
+
+	// Then
+	c.Assert(err1, Equals, sarama.ErrMessageSizeTooLarge)
+	c.Assert(err2, Equals, sarama.ErrNotLeaderForPartition)
+	metadataRqs := s.waitMetadataRqs(c, broker1, 2)
+	c.Assert(len(metadataRqs), Equals, 2)
+	c.Assert(metadataRqs[1].Topics, DeepEquals, []string{"foo"})
+}
+
+// waitMetadataRqs waits until the broker receives at least the specified
+// number of metadata requests, and returns all received so far.
+func (s *ProducerSuite) waitMetadataRqs(c *C, broker *sarama.MockBroker, count int) []*sarama.MetadataRequest {
+	for i := 0; i < 100; i++ {
+		var metadataRqs []*sarama.MetadataRequest
+		for _, rr := range broker.History() {
+			if metadataRq, ok := rr.Request.(*sarama.MetadataRequest); ok {
+				metadataRqs = append(metadataRqs, metadataRq)
+			}
+		}
+		if len(metadataRqs) >= count {
+			return metadataRqs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("metadata requests are not received: count=%d", count)
+	return nil
+}
+
 func (s *ProducerSuite) failedMessages() []string {
 	b := []string{}
 	for {
@@ -192,3 +764,39 @@ func (s *ProducerSuite) failedMessages() []string {
 done:
 	return b
 }
+
+// mockPartitionLister reports a fixed set of topic partitions.
+type mockPartitionLister struct {
+	partitions []int32
+	writable   []int32
+}
+
+func (m *mockPartitionLister) Partitions(topic string) ([]int32, error) {
+	return m.partitions, nil
+}
+
+func (m *mockPartitionLister) WritablePartitions(topic string) ([]int32, error) {
+	return m.writable, nil
+}
+
+// panicPartitioner fails a test that it is invoked by.
+type panicPartitioner struct{}
+
+func (panicPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	panic("partitioner must not be invoked")
+}
+
+func (panicPartitioner) RequiresConsistency() bool {
+	return false
+}
+
+// lastPartitioner sends all messages to the last partition of a topic.
+type lastPartitioner struct{}
+
+func (lastPartitioner) Partition(msg *sarama.ProducerMessage, numPartitions int32) (int32, error) {
+	return numPartitions - 1, nil
+}
+
+func (lastPartitioner) RequiresConsistency() bool {
+	return true
+}
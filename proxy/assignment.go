@@ -0,0 +1,235 @@
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/mailgun/kafka-pixy/actor"
+	"github.com/mailgun/kafka-pixy/consumer"
+	"github.com/mailgun/kafka-pixy/none"
+	"github.com/pkg/errors"
+)
+
+// Assignment consumes a fixed set of partitions of a topic that have been
+// assigned by the caller. Like ConsumePartition it bypasses the consumer group
+// machinery entirely, that is it does not register anything in ZooKeeper and
+// does not commit offsets. It is up to the caller to keep track of consumed
+// offsets and to pass them to AssignPartitions when consumption is resumed.
+//
+// An assignment must be closed when it is no longer needed, and before the
+// proxy it was created by is stopped.
+type Assignment struct {
+	actDesc       *actor.Descriptor
+	pollTimeout   time.Duration
+	saramaCsm     sarama.Consumer
+	partitionCsms []sarama.PartitionConsumer
+	messagesCh    chan consumer.Message
+	stopOnce      sync.Once
+	stopCh        chan none.T
+	wg            sync.WaitGroup
+
+	// Partitions that have been consumed up to their end offsets. When all
+	// partitions are, completedCh is closed.
+	completedMu  sync.Mutex
+	completed    map[int32]bool
+	notCompleted int
+	completedCh  chan none.T
+}
+
+// AssignOpts defines options of AssignPartitionsWithOpts.
+type AssignOpts struct {
+	// StartOffsets are offsets that partitions are consumed from. They can
+	// also be sarama.OffsetOldest or sarama.OffsetNewest. Partitions missing
+	// from the map are consumed from the newest offset.
+	StartOffsets map[int32]int64
+
+	// EndOffsets are offsets that partitions are consumed up to, exclusive.
+	// That is a partition is completed as soon as a message preceding its
+	// end offset is returned by Poll. Partitions missing from the map are
+	// consumed indefinitely.
+	EndOffsets map[int32]int64
+}
+
+// AssignPartitions starts consuming the specified partitions of a topic.
+// Partitions are consumed from offsets given in `startOffsets`, that can also
+// be sarama.OffsetOldest or sarama.OffsetNewest. Partitions missing from
+// `startOffsets` are consumed from the newest offset.
+func (p *T) AssignPartitions(topic string, partitions []int32, startOffsets map[int32]int64) (*Assignment, error) {
+	return p.AssignPartitionsWithOpts(topic, partitions, AssignOpts{StartOffsets: startOffsets})
+}
+
+// AssignPartitionsWithOpts is the same as AssignPartitions but also allows
+// consuming partitions up to particular end offsets, e.g. to export a range
+// of messages.
+func (p *T) AssignPartitionsWithOpts(topic string, partitions []int32, opts AssignOpts) (*Assignment, error) {
+	if len(partitions) == 0 {
+		return nil, errors.New("no partitions to assign")
+	}
+	assigned := make(map[int32]bool, len(partitions))
+	for _, partition := range partitions {
+		if assigned[partition] {
+			return nil, errors.Errorf("partition %d assigned more than once", partition)
+		}
+		assigned[partition] = true
+	}
+	for partition := range opts.StartOffsets {
+		if !assigned[partition] {
+			return nil, errors.Errorf("start offset given for unassigned partition %d", partition)
+		}
+	}
+	for partition, endOffset := range opts.EndOffsets {
+		if !assigned[partition] {
+			return nil, errors.Errorf("end offset given for unassigned partition %d", partition)
+		}
+		if endOffset < 0 {
+			return nil, errors.Errorf("bad end offset for partition %d: %d", partition, endOffset)
+		}
+	}
+	saramaCsm, err := sarama.NewConsumerFromClient(p.kafkaClt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create consumer")
+	}
+	a := &Assignment{
+		actDesc:     p.actDesc.NewChild("assignment", topic),
+		pollTimeout: p.cfg.Consumer.LongPollingTimeout,
+		saramaCsm:   saramaCsm,
+		messagesCh:  make(chan consumer.Message),
+		stopCh:      make(chan none.T),
+		completed:   make(map[int32]bool),
+		completedCh: make(chan none.T),
+	}
+	if len(opts.EndOffsets) == len(partitions) {
+		a.notCompleted = len(partitions)
+	} else {
+		// Some partitions are consumed indefinitely, so the assignment
+		// is never completed as a whole.
+		a.notCompleted = -1
+	}
+	for _, partition := range partitions {
+		offset, ok := opts.StartOffsets[partition]
+		if !ok {
+			offset = sarama.OffsetNewest
+		}
+		partitionCsm, err := saramaCsm.ConsumePartition(topic, partition, offset)
+		if err != nil {
+			a.Close()
+			return nil, errors.Wrapf(err, "failed to consume partition %d", partition)
+		}
+		a.partitionCsms = append(a.partitionCsms, partitionCsm)
+		endOffset, ok := opts.EndOffsets[partition]
+		if !ok {
+			endOffset = -1
+		} else if offset >= endOffset {
+			// There is nothing to consume from the partition.
+			a.complete(partition)
+			continue
+		}
+		actor.Spawn(a.actDesc.NewChild(fmt.Sprintf("p%d", partition)), &a.wg, func() {
+			a.forward(partitionCsm, endOffset)
+		})
+	}
+	return a, nil
+}
+
+// Poll returns a message from any of the assigned partitions. If there is no
+// message available, then it blocks for `Config.Consumer.LongPollingTimeout`
+// waiting for one to be produced, and if that does not happen
+// `ErrRequestTimeout` is returned. Messages of a particular partition are
+// returned in the order of their offsets. If all partitions have been
+// consumed up to their end offsets, then ErrAssignmentCompleted is returned.
+func (a *Assignment) Poll() (consumer.Message, error) {
+	select {
+	case msg := <-a.messagesCh:
+		return msg, nil
+	case <-a.completedCh:
+		return consumer.Message{}, ErrAssignmentCompleted
+	case <-a.stopCh:
+		return consumer.Message{}, ErrUnavailable
+	case <-time.After(a.pollTimeout):
+		return consumer.Message{}, consumer.ErrRequestTimeout
+	}
+}
+
+// Completed returns partitions that have been consumed up to their end
+// offsets, that is all their messages preceding the end offsets have been
+// returned by Poll.
+func (a *Assignment) Completed() []int32 {
+	a.completedMu.Lock()
+	defer a.completedMu.Unlock()
+	completed := make([]int32, 0, len(a.completed))
+	for partition := range a.completed {
+		completed = append(completed, partition)
+	}
+	sort.Slice(completed, func(i, j int) bool { return completed[i] < completed[j] })
+	return completed
+}
+
+// Close stops consumption of the assigned partitions. Poll returns
+// ErrUnavailable after that.
+func (a *Assignment) Close() {
+	a.stopOnce.Do(func() {
+		close(a.stopCh)
+		a.wg.Wait()
+		for _, partitionCsm := range a.partitionCsms {
+			if err := partitionCsm.Close(); err != nil {
+				a.actDesc.Log().WithError(err).Error("Failed to close partition consumer")
+			}
+		}
+		if err := a.saramaCsm.Close(); err != nil {
+			a.actDesc.Log().WithError(err).Error("Failed to close consumer")
+		}
+	})
+}
+
+// forward sends messages fetched from a partition to the channel that Poll
+// reads from, until a message preceding the end offset is sent. A negative
+// end offset means that there is no end offset.
+func (a *Assignment) forward(partitionCsm sarama.PartitionConsumer, endOffset int64) {
+	for {
+		select {
+		case consMsg, ok := <-partitionCsm.Messages():
+			if !ok {
+				return
+			}
+			if endOffset >= 0 && consMsg.Offset >= endOffset {
+				// The end offset message is missing, e.g. due to compaction.
+				a.complete(consMsg.Partition)
+				return
+			}
+			msg := consumer.Message{
+				Key:             consMsg.Key,
+				Value:           consMsg.Value,
+				Topic:           consMsg.Topic,
+				Partition:       consMsg.Partition,
+				Offset:          consMsg.Offset,
+				Timestamp:       consMsg.Timestamp,
+				HighWaterMark:   partitionCsm.HighWaterMarkOffset(),
+				CommittedOffset: sarama.OffsetNewest,
+			}
+			select {
+			case a.messagesCh <- msg:
+			case <-a.stopCh:
+				return
+			}
+			if endOffset >= 0 && consMsg.Offset >= endOffset-1 {
+				a.complete(consMsg.Partition)
+				return
+			}
+		case <-a.stopCh:
+			return
+		}
+	}
+}
+
+// complete marks a partition as consumed up to its end offset.
+func (a *Assignment) complete(partition int32) {
+	a.completedMu.Lock()
+	defer a.completedMu.Unlock()
+	a.completed[partition] = true
+	if a.notCompleted--; a.notCompleted == 0 {
+		close(a.completedCh)
+	}
+}
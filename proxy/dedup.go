@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/mailgun/kafka-pixy/consumer"
+)
+
+// dedupCache is a bounded LRU of message keys recently consumed by groups
+// from topics. It is used to flag messages that are likely duplicates of
+// ones consumed shortly before, e.g. redelivered after an ack timeout or
+// produced twice by a retrying producer. It is best-effort hinting: entries
+// are evicted when the cache is full, so a duplicate can go unnoticed.
+type dedupCache struct {
+	size   int
+	window time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List // of *dedupEntry, most recently seen first.
+	entries map[dedupKey]*list.Element
+}
+
+type dedupKey struct {
+	group string
+	topic string
+	key   string
+}
+
+type dedupEntry struct {
+	key    dedupKey
+	digest uint64
+	seenAt time.Time
+}
+
+func newDedupCache(size int, window time.Duration) *dedupCache {
+	return &dedupCache{
+		size:    size,
+		window:  window,
+		lru:     list.New(),
+		entries: make(map[dedupKey]*list.Element, size),
+	}
+}
+
+// seen remembers that the message was consumed by the group at the specified
+// time, and returns true if a message with the same key and value was
+// consumed by the group from the same topic within the dedup window. Messages
+// without a key are never considered duplicates.
+func (dc *dedupCache) seen(group string, msg *consumer.Message, now time.Time) bool {
+	if msg.Key == nil {
+		return false
+	}
+	key := dedupKey{group, msg.Topic, string(msg.Key)}
+	digest := valueDigest(msg.Value)
+	expiredBefore := now.Add(-dc.window)
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	// Entries are ordered by the time they were last seen, so expired ones
+	// are all at the back of the list.
+	for e := dc.lru.Back(); e != nil && !e.Value.(*dedupEntry).seenAt.After(expiredBefore); e = dc.lru.Back() {
+		dc.remove(e)
+	}
+	if e, ok := dc.entries[key]; ok {
+		de := e.Value.(*dedupEntry)
+		duplicate := de.digest == digest
+		de.digest = digest
+		de.seenAt = now
+		dc.lru.MoveToFront(e)
+		return duplicate
+	}
+	if dc.lru.Len() >= dc.size {
+		dc.remove(dc.lru.Back())
+	}
+	dc.entries[key] = dc.lru.PushFront(&dedupEntry{key, digest, now})
+	return false
+}
+
+func (dc *dedupCache) remove(e *list.Element) {
+	dc.lru.Remove(e)
+	delete(dc.entries, e.Value.(*dedupEntry).key)
+}
+
+// valueDigest returns a hash of a message value, so that the cache does not
+// hold on to entire message values.
+func valueDigest(value []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(value)
+	return h.Sum64()
+}
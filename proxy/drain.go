@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	"github.com/mailgun/holster/clock"
+	"github.com/mailgun/kafka-pixy/admin"
+	"github.com/pkg/errors"
+)
+
+// FinalOffsets are offsets committed by a consumer group to partitions of a
+// topic after the group caught up with the topic. They are a clean cutover
+// point when consumers are migrated to another cluster.
+type FinalOffsets struct {
+	Group   string
+	Topic   string
+	Offsets map[int32]int64
+}
+
+// ConsumeUntilCaughtUp waits until the consumer group consumes the topic up to
+// the high water marks that partitions have at the time of the call, then
+// pauses consumption of the topic by the group, as PauseConsume does, and
+// returns offsets committed by the group.
+//
+// Messages are not consumed by the method itself, that is the job of the
+// group members that keep consuming and acknowledging them as usual, so no
+// message is lost. The method just tells when all messages available at the
+// time of the call are acknowledged and their offsets are committed. Since
+// messages can be produced while the group catches up, the returned offsets
+// can be beyond the high water marks observed at the beginning.
+//
+// If the group has no active members, then it never catches up, therefore
+// the context must have a deadline. When the context is done the method
+// stops waiting and returns `ctx.Err()`. Partitions that the group has never
+// committed offsets to are considered caught up only if they are empty.
+func (p *T) ConsumeUntilCaughtUp(ctx context.Context, group, topic string) (FinalOffsets, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		return FinalOffsets{}, errors.New("context has no deadline")
+	}
+	offsets, err := p.GetGroupOffsets(group, topic)
+	if err != nil {
+		return FinalOffsets{}, errors.Wrap(err, "failed to get initial offsets")
+	}
+	targets := make(map[int32]int64, len(offsets))
+	for _, po := range offsets {
+		targets[po.Partition] = po.End
+	}
+	for !caughtUp(offsets, targets) {
+		select {
+		case <-clock.After(p.cfg.Consumer.OffsetsCommitInterval):
+		case <-ctx.Done():
+			return FinalOffsets{}, ctx.Err()
+		case <-p.stopCh:
+			return FinalOffsets{}, ErrUnavailable
+		}
+		if offsets, err = p.GetGroupOffsets(group, topic); err != nil {
+			return FinalOffsets{}, errors.Wrap(err, "failed to get offsets")
+		}
+	}
+	if err := p.PauseConsume(group, topic); err != nil {
+		return FinalOffsets{}, err
+	}
+	// Offsets may have been committed after they were fetched for the last
+	// time, so the final offsets are fetched once consumption is paused.
+	if offsets, err = p.GetGroupOffsets(group, topic); err != nil {
+		return FinalOffsets{}, errors.Wrap(err, "failed to get final offsets")
+	}
+	final := FinalOffsets{
+		Group:   group,
+		Topic:   topic,
+		Offsets: make(map[int32]int64, len(offsets)),
+	}
+	for _, po := range offsets {
+		final.Offsets[po.Partition] = po.Offset
+	}
+	return final, nil
+}
+
+// caughtUp returns true if in all partitions that have targets the group
+// committed offsets at or beyond them. A partition that the group has never
+// committed an offset to is treated as if the group committed its begin
+// offset, for that is where it has to start consuming from not to lose
+// messages.
+func caughtUp(offsets []admin.PartitionOffset, targets map[int32]int64) bool {
+	for _, po := range offsets {
+		target, ok := targets[po.Partition]
+		if !ok {
+			// The partition was added after targets were taken.
+			continue
+		}
+		committed := po.Offset
+		switch committed {
+		case sarama.OffsetNewest, sarama.OffsetOldest:
+			committed = po.Begin
+		}
+		if committed < target {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/mailgun/holster/clock"
+	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	metricProbeRoundTrip = "probe-round-trip-ms"
+	metricProbeSuccesses = "probe-successes"
+	metricProbeFailures  = "probe-failures"
+)
+
+// ProbeMetrics returns the registry of round-trip probe metrics. It contains
+// the `probe-round-trip-ms` gauge with the latency of the latest successful
+// probe, and the `probe-successes` and `probe-failures` counters. It is empty
+// unless `monitoring.probe.enabled` is true.
+func (p *T) ProbeMetrics() metrics.Registry {
+	return p.probeMetrics
+}
+
+// runProber periodically produces a heartbeat message to the probe topic and
+// reads it back, reporting the round-trip latency to probe metrics.
+func (p *T) runProber() {
+	ticker := clock.NewTicker(p.cfg.Monitoring.Probe.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			p.probeAndReport()
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// probeAndReport runs a single round-trip probe and reports its outcome to
+// probe metrics.
+func (p *T) probeAndReport() {
+	latency, err := p.probe()
+	if err != nil {
+		p.actDesc.Log().WithError(err).Warn("Round-trip probe failed")
+		metrics.GetOrRegisterCounter(metricProbeFailures, p.probeMetrics).Inc(1)
+		return
+	}
+	metrics.GetOrRegisterGauge(metricProbeRoundTrip, p.probeMetrics).Update(int64(latency / time.Millisecond))
+	metrics.GetOrRegisterCounter(metricProbeSuccesses, p.probeMetrics).Inc(1)
+}
+
+// probe produces a heartbeat message and consumes it back from the partition
+// and offset that it was produced to, bypassing consumer groups. It returns
+// the time elapsed from producing the message to reading it.
+func (p *T) probe() (time.Duration, error) {
+	topic := p.cfg.Monitoring.Probe.Topic
+	startedAt := clock.Now()
+	heartbeat := []byte(fmt.Sprintf("%s@%d", p.cfg.ClientID, startedAt.UnixNano()))
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Monitoring.Probe.Interval)
+	defer cancel()
+	prodMsg, _, err := p.produceWithOpts(ctx, topic, sarama.StringEncoder(p.cfg.ClientID), sarama.ByteEncoder(heartbeat), ProduceOpts{})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to produce")
+	}
+	consMsg, err := p.ConsumePartition(topic, prodMsg.Partition, prodMsg.Offset)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to consume")
+	}
+	if !bytes.Equal(consMsg.Value, heartbeat) {
+		return 0, errors.Errorf("unexpected message at %d:%d", prodMsg.Partition, prodMsg.Offset)
+	}
+	return clock.Now().Sub(startedAt), nil
+}
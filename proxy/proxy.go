@@ -1,35 +1,134 @@
 package proxy
 
 import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/mailgun/holster/clock"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/admin"
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/consumer"
 	"github.com/mailgun/kafka-pixy/consumer/consumerimpl"
+	"github.com/mailgun/kafka-pixy/logging"
+	"github.com/mailgun/kafka-pixy/none"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
 	"github.com/mailgun/kafka-pixy/producer"
+	"github.com/mailgun/kafka-pixy/schemareg"
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	initEventsChMapCapacity = 256
+
+	// Variant producers that have not been used for that long are stopped.
+	variantProducerIdleTimeout = 10 * time.Minute
+
+	// Topics found missing by validateTopic are not looked up in Kafka again
+	// for that long.
+	missingTopicTTL = 5 * time.Second
+
+	metricConsumeRequests = "consume-requests"
+	metricConsumeMessages = "consume-messages"
+	metricConsumeErrors   = "consume-errors"
 )
 
 var (
 	ErrUnavailable = errors.New("service is shutting down")
+	ErrRateLimited = errors.New("consume rate limit exceeded, consider increasing `consumer.rate_limit`")
+
+	// ErrTooManyRequests is returned by ConsumeWithOpts if the client that
+	// made the request has `consumer.max_in_flight_per_client` other
+	// requests in flight.
+	ErrTooManyRequests = errors.New("too many consume requests in flight, consider increasing `consumer.max_in_flight_per_client`")
+
+	ErrCompressionUnsupported = errors.New("lz4 compression requires `kafka.version` 0.10.0.0 or later")
+	ErrTimestampUnsupported   = errors.New("message timestamps require `kafka.version` 0.10.0.0 or later")
+	ErrCircuitOpen            = errors.New("produce to the topic keeps failing, retry after `producer.circuit_breaker_cooldown`")
+	ErrAvroUnsupported        = errors.New("Avro encoding requires `schema_registry.url`")
+
+	// ErrAssignmentCompleted is returned by Assignment.Poll when all assigned
+	// partitions have been consumed up to their end offsets.
+	ErrAssignmentCompleted = errors.New("all partitions consumed up to end offsets")
+
+	// ErrMessageTooLarge is the cause of errors returned when the total size
+	// of a message key and value exceeds `producer.max_message_bytes`.
+	ErrMessageTooLarge = producer.ErrMessageTooLarge
+
+	// ErrQueueFull is returned by AsyncProduceBounded if the context is done
+	// before the number of messages in flight drops below
+	// `producer.max_async_in_flight`.
+	ErrQueueFull = producer.ErrQueueFull
+
+	// ErrFlushTimeout is returned by Flush if produced messages are not
+	// acknowledged within the given timeout.
+	ErrFlushTimeout = producer.ErrFlushTimeout
+
+	// ErrProduceTimeout is returned by produce functions if a message is not
+	// acknowledged by Kafka within `producer.produce_timeout`. The message
+	// may still be written to Kafka after that.
+	ErrProduceTimeout = producer.ErrProduceTimeout
+
+	// ErrMessageNotFound is the cause of errors returned by GetMessage if
+	// there is no message at the requested offset, that is if the offset is
+	// out of the partition range or the message was removed by compaction.
+	ErrMessageNotFound = errors.New("message not found")
 
 	noAck   = Ack{partition: -1}
 	autoAck = Ack{partition: -2}
 )
 
+// ErrTopicNotFound is returned by produce methods if `producer.validate_topic`
+// is enabled and the topic a message is produced to does not exist.
+type ErrTopicNotFound struct {
+	Topic string
+}
+
+func (e ErrTopicNotFound) Error() string {
+	return fmt.Sprintf("topic %s does not exist", e.Topic)
+}
+
+// ErrNotEnoughReplicas is returned by produce methods when Kafka rejects a
+// message because the partition has fewer in-sync replicas than the
+// `min.insync.replicas` of the topic. The condition is usually transient, so
+// produce can be retried with backoff. If AfterAppend is true, then the
+// leader appended the message to its log before the check failed, hence a
+// retry may result in a duplicate message.
+type ErrNotEnoughReplicas struct {
+	AfterAppend bool
+}
+
+func (e ErrNotEnoughReplicas) Error() string {
+	if e.AfterAppend {
+		return "not enough in-sync replicas after the message was appended"
+	}
+	return "not enough in-sync replicas"
+}
+
+// produceError maps errors reported by the producer to errors that produce
+// methods return.
+func produceError(err error) error {
+	switch errors.Cause(err) {
+	case sarama.ErrNotEnoughReplicas:
+		return ErrNotEnoughReplicas{}
+	case sarama.ErrNotEnoughReplicasAfterAppend:
+		return ErrNotEnoughReplicas{AfterAppend: true}
+	}
+	return err
+}
+
 // T implements a proxy to a particular Kafka/ZooKeeper cluster.
 type T struct {
 	actDesc    *actor.Descriptor
+	cluster    string
 	cfg        *config.Proxy
 	kafkaClt   sarama.Client
 	offsetMgrF offsetmgr.Factory
@@ -40,17 +139,72 @@ type T struct {
 	producerMu sync.RWMutex
 	producer   *producer.T
 
+	// Producers that wait for acknowledgement levels, use compression
+	// codecs and/or retry numbers other than configured in
+	// `producer.required_acks`, `producer.compression` and
+	// `producer.retry_max`. They are spawned on demand, and stopped after
+	// variantProducerIdleTimeout of inactivity.
+	variantProducersMu sync.Mutex
+	variantProducers   map[producerVariant]*variantProducer
+
 	consumerMu sync.RWMutex
 	consumer   consumer.T
 
-	// FIXME: We never remove stale elements from eventsChMap. It is sort of ok
-	// FIXME: since the number of group/topic/partition combinations is fairly
-	// FIXME: limited and should not cause any significant system memory usage.
+	// Elements that have not been updated for eventsChTTL are periodically
+	// removed by the sweeper goroutine.
 	eventsChMapMu sync.RWMutex
-	eventsChMap   map[eventsChID]chan<- consumer.Event
+	eventsChMap   map[eventsChID]eventsChEntry
+	eventsChTTL   time.Duration
+
+	// Messages fetched by ConsumePattern in excess of the one returned to a
+	// caller. They are returned by subsequent calls with the same pattern.
+	patternStashMu sync.Mutex
+	patternStash   map[patternStashID][]stashedMsg
+
+	// Token buckets that enforce `Consumer.RateLimit` per group/topic.
+	// Buckets that have not been used for eventsChTTL are removed by the
+	// sweeper goroutine.
+	tokenBucketsMu sync.Mutex
+	tokenBuckets   map[tokenBucketID]*tokenBucket
+
+	// Circuit breakers of topics that produce requests recently failed to.
+	// A breaker is removed as soon as a request to its topic succeeds.
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   map[string]*circuitBreaker
+
+	// Keys of recently consumed messages used to flag likely duplicates. It
+	// is nil unless `Consumer.DedupCacheSize` is positive.
+	dedupCache *dedupCache
+
+	// Consume metrics of requests that specify a client ID.
+	consumerMetrics metrics.Registry
+	clientMetrics   *producer.ClientMetricNames
+
+	// Metrics reported by the round-trip prober, if it is enabled.
+	probeMetrics metrics.Registry
+
+	// The number of consume requests in flight per client ID. Clients are
+	// removed as soon as they have no requests in flight.
+	inFlightMu sync.Mutex
+	inFlight   map[string]int
+
+	// Records produce and consume requests if access logging is configured.
+	accessLog *log.Logger
+
+	// Topics that validateTopic found missing, and when it did that.
+	missingTopicsMu sync.Mutex
+	missingTopics   map[string]time.Time
+
+	// Encodes and decodes Avro messages. It is nil unless a schema registry
+	// is configured.
+	schemaReg *schemareg.T
+
+	stopCh chan none.T
+	wg     sync.WaitGroup
 }
 
 type Ack struct {
+	topic     string
 	partition int32
 	offset    int64
 }
@@ -65,7 +219,15 @@ func NewAck(partition int32, offset int64) (Ack, error) {
 	if offset < 0 {
 		return Ack{}, errors.Errorf("bad offset: %d", offset)
 	}
-	return Ack{partition, offset}, nil
+	return Ack{partition: partition, offset: offset}, nil
+}
+
+// WithTopic returns a copy of the ack that refers to a message of the
+// specified topic. It is only needed for acks passed to ConsumePattern,
+// for the topic cannot be derived from a pattern.
+func (a Ack) WithTopic(topic string) Ack {
+	a.topic = topic
+	return a
 }
 
 // NoAck returns an ack value that should be passed to proxy.Consume function
@@ -80,18 +242,110 @@ func AutoAck() Ack {
 	return autoAck
 }
 
+type producerVariant struct {
+	requiredAcks sarama.RequiredAcks
+	compression  sarama.CompressionCodec
+	retryMax     int
+}
+
+type variantProducer struct {
+	prod   *producer.T
+	usedAt time.Time
+}
+
 type eventsChID struct {
 	group     string
 	topic     string
 	partition int32
 }
 
+type eventsChEntry struct {
+	eventsCh  chan<- consumer.Event
+	updatedAt time.Time
+}
+
+// SubscriptionInfo describes a group/topic subscription that has been
+// consumed from recently. See ListActiveSubscriptions.
+type SubscriptionInfo struct {
+	Group string
+	Topic string
+	// The number of partitions of the topic that messages have been
+	// consumed from by the group.
+	Partitions int
+	// When a message was last consumed from, or acknowledged to, any of the
+	// partitions.
+	LastConsumedAt time.Time
+}
+
+type patternStashID struct {
+	group string
+	key   string
+}
+
+type stashedMsg struct {
+	msg       consumer.Message
+	stashedAt time.Time
+}
+
+type tokenBucketID struct {
+	group string
+	topic string
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type circuitBreaker struct {
+	failures int
+	openedAt time.Time
+	// True while a probe request is in flight after the cooldown.
+	probing bool
+}
+
+// HealthStatus describes whether a proxy is capable of serving requests.
+type HealthStatus struct {
+	// True if there is a live connection to at least one Kafka broker.
+	KafkaConnected bool `json:"kafka_connected"`
+	ProducerUp     bool `json:"producer_up"`
+	ConsumerUp     bool `json:"consumer_up"`
+	AdminUp        bool `json:"admin_up"`
+	// ID of the controller broker read from ZooKeeper. It is -1 if there is
+	// no controller at the moment or it could not be read.
+	ControllerID int32 `json:"controller_id"`
+}
+
+// Healthy returns true if all proxy subsystems are up and Kafka is reachable.
+func (hs HealthStatus) Healthy() bool {
+	return hs.KafkaConnected && hs.ProducerUp && hs.ConsumerUp && hs.AdminUp
+}
+
 // Spawn creates a proxy instance and starts its internal goroutines.
 func Spawn(parentActDesc *actor.Descriptor, name string, cfg *config.Proxy) (*T, error) {
 	p := T{
-		actDesc:     parentActDesc.NewChild(name),
-		cfg:         cfg,
-		eventsChMap: make(map[eventsChID]chan<- consumer.Event, initEventsChMapCapacity),
+		actDesc:          parentActDesc.NewChild(name),
+		cluster:          name,
+		cfg:              cfg,
+		variantProducers: make(map[producerVariant]*variantProducer),
+		eventsChMap:      make(map[eventsChID]eventsChEntry, initEventsChMapCapacity),
+		eventsChTTL:      eventsChTTL(cfg),
+		patternStash:     make(map[patternStashID][]stashedMsg),
+		tokenBuckets:     make(map[tokenBucketID]*tokenBucket),
+		circuitBreakers:  make(map[string]*circuitBreaker),
+		consumerMetrics:  metrics.NewRegistry(),
+		clientMetrics:    producer.NewClientMetricNames(cfg.Monitoring.MaxClientMetrics),
+		probeMetrics:     metrics.NewRegistry(),
+		inFlight:         make(map[string]int),
+		missingTopics:    make(map[string]time.Time),
+		accessLog:        logging.AccessLogger(),
+		stopCh:           make(chan none.T),
+	}
+	if cfg.SchemaRegistry.URL != "" {
+		p.schemaReg = schemareg.New(cfg)
+	}
+	if cfg.Consumer.DedupCacheSize > 0 {
+		p.dedupCache = newDedupCache(cfg.Consumer.DedupCacheSize, cfg.Consumer.DedupWindow)
 	}
 	var err error
 
@@ -108,9 +362,33 @@ func Spawn(parentActDesc *actor.Descriptor, name string, cfg *config.Proxy) (*T,
 	if p.admin, err = admin.Spawn(p.actDesc, cfg); err != nil {
 		return nil, errors.Wrap(err, "failed to spawn admin")
 	}
+	actor.Spawn(p.actDesc.NewChild("events_ch_sweeper"), &p.wg, p.runEventsChSweeper)
+	if cfg.Monitoring.Probe.Enabled {
+		actor.Spawn(p.actDesc.NewChild("prober"), &p.wg, p.runProber)
+	}
 	return &p, nil
 }
 
+// eventsChTTL returns period of time after which a message events channel
+// of a group/topic/partition can be forgotten. A topic consumer is stopped
+// when there has been no requests for max of subscription timeout and ack
+// timeout, so there is no point to keep an events channel for longer than
+// that. A partition consumer is evicted after it has been idle for the
+// partition idle timeout, if it is configured, so channels of evicted
+// partition consumers are forgotten no later than that. The partition idle
+// timeout is never less than the ack timeout, therefore channels are kept
+// for as long as messages offered via them can be acknowledged.
+func eventsChTTL(cfg *config.Proxy) time.Duration {
+	ttl := cfg.Consumer.SubscriptionTimeout
+	if cfg.Consumer.AckTimeout > ttl {
+		ttl = cfg.Consumer.AckTimeout
+	}
+	if idleTimeout := cfg.Consumer.PartitionIdleTimeout; idleTimeout > 0 && idleTimeout < ttl {
+		ttl = idleTimeout
+	}
+	return ttl
+}
+
 // Stop terminates the proxy instances synchronously.
 func (p *T) Stop() {
 	var wg sync.WaitGroup
@@ -134,6 +412,8 @@ func (p *T) Stop() {
 	p.adminMu.RUnlock()
 
 	wg.Wait()
+	close(p.stopCh)
+	p.wg.Wait()
 	if p.offsetMgrF != nil {
 		p.offsetMgrF.Stop()
 	}
@@ -156,12 +436,23 @@ func (p *T) stopProducer() {
 	p.producer = nil
 	p.producerMu.Unlock()
 	prod.Stop()
+
+	// No more variant producers can be spawned at this point, for p.producer
+	// is already nil.
+	p.variantProducersMu.Lock()
+	defer p.variantProducersMu.Unlock()
+	for variant, variantProd := range p.variantProducers {
+		variantProd.prod.Stop()
+		delete(p.variantProducers, variant)
+	}
 }
 
 func (p *T) stopAdmin() {
 	p.adminMu.Lock()
-	p.admin.Stop()
+	adm := p.admin
+	p.admin = nil
 	p.adminMu.Unlock()
+	adm.Stop()
 }
 
 // Produce submits a message to the specified `topic` of the Kafka cluster
@@ -173,20 +464,181 @@ func (p *T) stopAdmin() {
 // Errors usually indicate a catastrophic failure of the Kafka cluster, or
 // missing topic if there cluster is not configured to auto create topics.
 func (p *T) Produce(topic string, key, message sarama.Encoder) (*sarama.ProducerMessage, error) {
+	return p.ProduceCtx(context.Background(), topic, key, message)
+}
+
+// ProduceBytes is the same as Produce but it takes a message key and value as
+// byte slices. A `nil` key places the message into a random partition, and a
+// `nil` value produces a tombstone, a message with null value, that tells Kafka
+// to remove all messages with the same key from a compacted topic. Note that
+// an empty but non nil value is produced as is.
+func (p *T) ProduceBytes(topic string, key, value []byte) (*sarama.ProducerMessage, error) {
+	return p.Produce(topic, bytesEncoder(key), bytesEncoder(value))
+}
+
+// ProduceCtx is the same as Produce but it stops waiting for the result and
+// returns `ctx.Err()` as soon as the context is done. Note that the message
+// may still be written to Kafka after that.
+func (p *T) ProduceCtx(ctx context.Context, topic string, key, message sarama.Encoder) (*sarama.ProducerMessage, error) {
+	prodMsg, _, err := p.ProduceWithOpts(ctx, topic, key, message, ProduceOpts{})
+	return prodMsg, err
+}
+
+// ProduceOpts holds optional parameters of ProduceWithOpts.
+type ProduceOpts struct {
+	// RequiredAcks overrides `producer.required_acks` of the proxy config for
+	// a particular message. If nil then the configured level is used.
+	RequiredAcks *sarama.RequiredAcks
+
+	// Compression overrides `producer.compression` of the proxy config for
+	// a particular message. If nil then the configured codec is used.
+	Compression *sarama.CompressionCodec
+
+	// RetryMax overrides `producer.retry_max` of the proxy config for a
+	// particular message, e.g. latency sensitive callers can fail fast with
+	// zero retries. It can only lower the configured number, larger values
+	// are clamped to it. If nil then the configured number is used. Retries
+	// are spaced by `producer.retry_backoff`.
+	RetryMax *int
+
+	// Timestamp is stored with the message in Kafka instead of the current
+	// time, e.g. to preserve original event times when data is replayed. It
+	// requires `kafka.version` 0.10.0.0 or later and is only kept by Kafka if
+	// the topic has `message.timestamp.type=CreateTime`, that is the default.
+	// If zero then the current time is used.
+	Timestamp time.Time
+
+	// ClientID is a logical ID of the service that produces the message. If
+	// given, then produce latency and errors are additionally reported to
+	// client specific metrics, and produce failures are logged with it.
+	ClientID string
+}
+
+// ProduceWithOpts is the same as ProduceCtx but allows overriding the proxy
+// producer configuration for a particular message. E.g. critical messages
+// can require `sarama.WaitForAll` even if the proxy is configured with
+// `sarama.WaitForLocal`, and already compressed payloads can be produced
+// with `sarama.CompressionNone`. Along with the produced message it returns
+// the acknowledgement level that was satisfied by Kafka.
+func (p *T) ProduceWithOpts(ctx context.Context, topic string, key, message sarama.Encoder, opts ProduceOpts) (*sarama.ProducerMessage, sarama.RequiredAcks, error) {
+	startedAt := clock.Now()
+	prodMsg, requiredAcks, err := p.produceWithOpts(ctx, topic, key, message, opts)
+	if p.accessLog != nil {
+		fields := log.Fields{
+			"kafka.topic": topic,
+			"bytes":       encodedSize(key, message),
+		}
+		if err == nil {
+			fields["kafka.partition"] = prodMsg.Partition
+			fields["kafka.offset"] = prodMsg.Offset
+		}
+		p.logAccess("produce", fields, opts.ClientID, startedAt, err)
+	}
+	return prodMsg, requiredAcks, err
+}
+
+func (p *T) produceWithOpts(ctx context.Context, topic string, key, message sarama.Encoder, opts ProduceOpts) (*sarama.ProducerMessage, sarama.RequiredAcks, error) {
+	variant := producerVariant{
+		requiredAcks: sarama.RequiredAcks(p.cfg.Producer.RequiredAcks),
+		compression:  sarama.CompressionCodec(p.cfg.Producer.Compression),
+		retryMax:     p.cfg.Producer.RetryMax,
+	}
+	if opts.RequiredAcks != nil {
+		variant.requiredAcks = *opts.RequiredAcks
+	}
+	requiredAcks := variant.requiredAcks
+	if opts.RetryMax != nil {
+		if *opts.RetryMax < 0 {
+			return nil, requiredAcks, errors.Errorf("bad retry max: %d", *opts.RetryMax)
+		}
+		if *opts.RetryMax < variant.retryMax {
+			variant.retryMax = *opts.RetryMax
+		}
+	}
+	if opts.Compression != nil {
+		variant.compression = *opts.Compression
+		if variant.compression == sarama.CompressionLZ4 && !p.cfg.Kafka.Version.IsAtLeast(sarama.V0_10_0_0) {
+			return nil, requiredAcks, ErrCompressionUnsupported
+		}
+	}
+	if !opts.Timestamp.IsZero() && !p.cfg.Kafka.Version.IsAtLeast(sarama.V0_10_0_0) {
+		return nil, requiredAcks, ErrTimestampUnsupported
+	}
+	if err := p.validateTopic(topic); err != nil {
+		return nil, requiredAcks, err
+	}
+	if !p.allowProduce(topic) {
+		return nil, requiredAcks, ErrCircuitOpen
+	}
 	p.producerMu.RLock()
 	if p.producer == nil {
 		p.producerMu.RUnlock()
-		return nil, ErrUnavailable
+		p.releaseProbe(topic)
+		return nil, requiredAcks, ErrUnavailable
+	}
+	prod, err := p.getVariantProducer(variant)
+	if err != nil {
+		p.producerMu.RUnlock()
+		p.releaseProbe(topic)
+		return nil, requiredAcks, err
 	}
-	responseCh := p.producer.AsyncProduce(topic, key, message)
+	responseCh := prod.AsyncProduceWithOpts(topic, key, message,
+		producer.Opts{Timestamp: opts.Timestamp, ClientID: opts.ClientID})
 	p.producerMu.RUnlock()
 
-	rs := <-responseCh
-	return rs.Msg, rs.Err
+	select {
+	case rs := <-responseCh:
+		p.reportProduce(topic, rs.Err)
+		return rs.Msg, requiredAcks, produceError(rs.Err)
+	case <-p.produceTimeoutCh():
+		p.reportProduce(topic, ErrProduceTimeout)
+		return nil, requiredAcks, ErrProduceTimeout
+	case <-ctx.Done():
+		p.releaseProbe(topic)
+		return nil, requiredAcks, ctx.Err()
+	}
+}
+
+// getVariantProducer returns a producer that waits for the specified level of
+// acknowledgements from Kafka, uses the specified compression codec and
+// retries the specified number of times. If any of those differs from the
+// configured one, then a dedicated producer is spawned on the first call. It
+// must be called while p.producerMu is read locked and p.producer is not nil,
+// and the returned producer must be submitted to before the lock is released,
+// so that sweepVariantProducers does not stop it in between.
+func (p *T) getVariantProducer(variant producerVariant) (*producer.T, error) {
+	if variant.requiredAcks == sarama.RequiredAcks(p.cfg.Producer.RequiredAcks) &&
+		variant.compression == sarama.CompressionCodec(p.cfg.Producer.Compression) &&
+		variant.retryMax == p.cfg.Producer.RetryMax {
+		return p.producer, nil
+	}
+	p.variantProducersMu.Lock()
+	defer p.variantProducersMu.Unlock()
+	if variantProd := p.variantProducers[variant]; variantProd != nil {
+		variantProd.usedAt = clock.Now()
+		return variantProd.prod, nil
+	}
+	variantCfg := *p.cfg
+	variantCfg.Producer.RequiredAcks = config.RequiredAcks(variant.requiredAcks)
+	variantCfg.Producer.Compression = config.Compression(variant.compression)
+	variantCfg.Producer.RetryMax = variant.retryMax
+	variantName := fmt.Sprintf("%v_%v", variantCfg.Producer.RequiredAcks, variantCfg.Producer.Compression)
+	if variant.retryMax != p.cfg.Producer.RetryMax {
+		variantName += fmt.Sprintf("_retry%d", variant.retryMax)
+	}
+	prod, err := producer.Spawn(p.actDesc.NewChild("prod_"+variantName), &variantCfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to spawn %s producer", variantName)
+	}
+	p.variantProducers[variant] = &variantProducer{prod: prod, usedAt: clock.Now()}
+	return prod, nil
 }
 
 // AsyncProduce is an asynchronously counterpart of the `Produce` function.
-// Errors are silently ignored.
+// Errors are silently ignored. It is not subject to the circuit breaker, for
+// it has no way to tell the caller that a message was rejected. Use
+// AsyncProduceBounded or AsyncProduceCallback to have produce failures open
+// the breaker and to have messages rejected while it is open.
 func (p *T) AsyncProduce(topic string, key, message sarama.Encoder) {
 	p.producerMu.RLock()
 	if p.producer == nil {
@@ -197,132 +649,1670 @@ func (p *T) AsyncProduce(topic string, key, message sarama.Encoder) {
 	p.producerMu.RUnlock()
 }
 
-// Consume consumes a message from the specified topic on behalf of the
-// specified consumer group. If there are no more new messages in the topic
-// at the time of the request then it will block for
-// `Config.Consumer.LongPollingTimeout`. If no new message is produced during
-// that time, then `ErrRequestTimeout` is returned.
-//
-// Note that during state transitions topic subscribe<->unsubscribe and
-// consumer group register<->deregister the method may return either
-// `ErrBufferOverflow` or `ErrRequestTimeout` even when there are messages
-// available for consumption. In that case the user should back off a bit
-// and then repeat the request.
-func (p *T) Consume(group, topic string, ack Ack) (consumer.Message, error) {
-	if ack != noAck && ack != autoAck {
-		p.eventsChMapMu.RLock()
-		eventsChID := eventsChID{group, topic, ack.partition}
-		eventsCh, ok := p.eventsChMap[eventsChID]
-		p.eventsChMapMu.RUnlock()
-		if ok {
-			go func() {
-				select {
-				case eventsCh <- consumer.Ack(ack.offset):
-				case <-time.After(p.cfg.Consumer.LongPollingTimeout):
-					p.actDesc.Log().WithFields(log.Fields{
-						"kafka.group":     group,
-						"kafka.topic":     topic,
-						"kafka.partition": ack.partition,
-					}).Errorf("ack timeout: offset=%d", ack.offset)
-				}
-			}()
+// AsyncProduceBounded is the same as AsyncProduce, but if
+// `producer.max_async_in_flight` messages submitted with it are already in
+// flight, then it blocks until one of them is acknowledged by Kafka or until
+// ctx is done, in which case ErrQueueFull is returned. If the proxy is stopped
+// then ErrUnavailable is returned, and if the circuit breaker of the topic is
+// open then ErrCircuitOpen is. The outcome of the message, once known, is
+// reported to the circuit breaker. If clientID is not empty, then it is
+// handled the same way as ProduceOpts.ClientID.
+func (p *T) AsyncProduceBounded(ctx context.Context, topic string, key, message sarama.Encoder, clientID string) error {
+	startedAt := clock.Now()
+	err := p.asyncProduceBounded(ctx, topic, key, message, clientID)
+	if p.accessLog != nil {
+		fields := log.Fields{
+			"kafka.topic": topic,
+			"bytes":       encodedSize(key, message),
 		}
+		p.logAccess("async_produce", fields, clientID, startedAt, err)
 	}
+	return err
+}
 
-	p.consumerMu.RLock()
-	if p.consumer == nil {
-		p.consumerMu.RUnlock()
-		return consumer.Message{}, ErrUnavailable
+func (p *T) asyncProduceBounded(ctx context.Context, topic string, key, message sarama.Encoder, clientID string) error {
+	if err := p.validateTopic(topic); err != nil {
+		return err
 	}
-	responseCh := p.consumer.AsyncConsume(group, topic)
-	p.consumerMu.RUnlock()
+	if !p.allowProduce(topic) {
+		return ErrCircuitOpen
+	}
+	p.producerMu.RLock()
+	defer p.producerMu.RUnlock()
+	if p.producer == nil {
+		p.releaseProbe(topic)
+		return ErrUnavailable
+	}
+	responseCh, err := p.producer.AsyncProduceBounded(ctx, topic, key, message, producer.Opts{ClientID: clientID})
+	if err != nil {
+		p.releaseProbe(topic)
+		return err
+	}
+	if p.cfg.Producer.CircuitBreakerThreshold > 0 {
+		go func() {
+			rs := <-responseCh
+			p.reportProduce(topic, rs.Err)
+		}()
+	}
+	return nil
+}
 
-	rs := <-responseCh
-	if rs.Err != nil {
-		return consumer.Message{}, rs.Err
+// validateTopic returns ErrTopicNotFound if `producer.validate_topic` is
+// enabled and the topic does not exist. Cached cluster metadata is checked
+// first, and metadata of the topic is refreshed only if the topic is not
+// there, so that topics created since the last refresh are not rejected.
+// A topic found missing is rejected without asking Kafka again for
+// missingTopicTTL, so that produce requests to a bogus topic cannot flood
+// Kafka with metadata requests.
+func (p *T) validateTopic(topic string) error {
+	if !p.cfg.Producer.ValidateTopic {
+		return nil
+	}
+	exists := func() (bool, error) {
+		topics, err := p.kafkaClt.Topics()
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get topics")
+		}
+		for _, t := range topics {
+			if t == topic {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if ok, err := exists(); ok || err != nil {
+		return err
+	}
+	p.missingTopicsMu.Lock()
+	missingAt, missing := p.missingTopics[topic]
+	p.missingTopicsMu.Unlock()
+	if missing && clock.Now().Sub(missingAt) < missingTopicTTL {
+		return ErrTopicNotFound{Topic: topic}
 	}
+	// Note that requesting metadata of a missing topic makes Kafka create it
+	// if auto create is enabled, that is why validation should not be
+	// enabled in that case.
+	err := p.kafkaClt.RefreshMetadata(topic)
+	if err != nil && err != sarama.ErrUnknownTopicOrPartition {
+		return errors.Wrap(err, "failed to refresh metadata")
+	}
+	if ok, err := exists(); ok || err != nil {
+		return err
+	}
+	p.missingTopicsMu.Lock()
+	p.missingTopics[topic] = clock.Now()
+	p.missingTopicsMu.Unlock()
+	return ErrTopicNotFound{Topic: topic}
+}
 
-	eventsChID := eventsChID{group, topic, rs.Msg.Partition}
-	p.eventsChMapMu.Lock()
-	p.eventsChMap[eventsChID] = rs.Msg.EventsCh
-	p.eventsChMapMu.Unlock()
+// logAccess writes a record of a request to the access log. The record gets
+// the request latency measured from startedAt, and the result that is either
+// `ok` or the error message.
+func (p *T) logAccess(request string, fields log.Fields, clientID string, startedAt time.Time, err error) {
+	fields["cluster"] = p.cluster
+	fields["latency_ms"] = float64(clock.Now().Sub(startedAt)) / float64(time.Millisecond)
+	if clientID != "" {
+		fields["client_id"] = clientID
+	}
+	fields["result"] = "ok"
+	if err != nil {
+		fields["result"] = err.Error()
+	}
+	p.accessLog.WithFields(fields).Info(request)
+}
 
-	if ack == autoAck {
-		rs.Msg.EventsCh <- consumer.Ack(rs.Msg.Offset)
+// encodedSize returns the total length of the given encoders, that may be nil.
+func encodedSize(encoders ...sarama.Encoder) int {
+	size := 0
+	for _, encoder := range encoders {
+		if encoder != nil {
+			size += encoder.Length()
+		}
 	}
-	return rs.Msg, nil
+	return size
 }
 
-func (p *T) Ack(group, topic string, ack Ack) error {
-	eventsChID := eventsChID{group, topic, ack.partition}
-	p.eventsChMapMu.RLock()
-	eventsCh, ok := p.eventsChMap[eventsChID]
-	p.eventsChMapMu.RUnlock()
-	if !ok {
-		return errors.Errorf("acks channel missing for %v", eventsChID)
+// bytesEncoder returns an encoder for a byte slice, or `nil` if the slice is
+// `nil`. Passing a `nil` encoder rather than an encoder of a `nil` slice to the
+// producer makes it explicit that the respective message field is null.
+func bytesEncoder(b []byte) sarama.Encoder {
+	if b == nil {
+		return nil
 	}
-	select {
-	case eventsCh <- consumer.Ack(ack.offset):
-	case <-time.After(p.cfg.Consumer.LongPollingTimeout):
-		return errors.New("ack timeout")
+	return sarama.ByteEncoder(b)
+}
+
+// AsyncProduceCallback is the same as AsyncProduce, but the message partition
+// and offset, or an error if the message could not be produced, are passed to
+// `cb`. The callback is invoked on a goroutine dedicated to the message, see
+// `producer.T.AsyncProduceCallback` for details. If the proxy is stopped then
+// the callback is called with ErrUnavailable, and if the circuit breaker of
+// the topic is open then with ErrCircuitOpen.
+func (p *T) AsyncProduceCallback(topic string, key, message sarama.Encoder, cb func(*sarama.ProducerMessage, error)) {
+	if !p.allowProduce(topic) {
+		go cb(nil, ErrCircuitOpen)
+		return
 	}
-	return nil
+	p.producerMu.RLock()
+	if p.producer == nil {
+		p.producerMu.RUnlock()
+		p.releaseProbe(topic)
+		go cb(nil, ErrUnavailable)
+		return
+	}
+	p.producer.AsyncProduceCallback(topic, key, message, func(prodMsg *sarama.ProducerMessage, err error) {
+		p.reportProduce(topic, err)
+		cb(prodMsg, produceError(err))
+	})
+	p.producerMu.RUnlock()
 }
 
-// GetGroupOffsets for every partition of the specified topic it returns the
-// current offset range along with the latest offset and metadata committed by
-// the specified consumer group.
-func (p *T) GetGroupOffsets(group, topic string) ([]admin.PartitionOffset, error) {
-	p.adminMu.RLock()
-	defer p.adminMu.RUnlock()
-	if p.admin == nil {
-		return nil, ErrUnavailable
+// RefreshMetadata forces refresh of the metadata of the specified topics, or
+// of all topics if none is specified, by all Kafka clients of the proxy that
+// produce messages or look up topics. Call it after a topic is created to
+// make it available for produce right away, rather than after the next
+// periodic refresh.
+func (p *T) RefreshMetadata(topics ...string) error {
+	p.missingTopicsMu.Lock()
+	if len(topics) == 0 {
+		p.missingTopics = make(map[string]time.Time)
 	}
-	return p.admin.GetGroupOffsets(group, topic)
+	for _, topic := range topics {
+		delete(p.missingTopics, topic)
+	}
+	p.missingTopicsMu.Unlock()
+	if err := p.kafkaClt.RefreshMetadata(topics...); err != nil {
+		return errors.Wrap(err, "failed to refresh metadata")
+	}
+	p.producerMu.RLock()
+	defer p.producerMu.RUnlock()
+	if p.producer == nil {
+		return ErrUnavailable
+	}
+	if err := p.producer.RefreshMetadata(topics...); err != nil {
+		return errors.Wrap(err, "failed to refresh producer metadata")
+	}
+	p.variantProducersMu.Lock()
+	defer p.variantProducersMu.Unlock()
+	for _, variantProd := range p.variantProducers {
+		if err := variantProd.prod.RefreshMetadata(topics...); err != nil {
+			return errors.Wrap(err, "failed to refresh producer metadata")
+		}
+	}
+	return nil
 }
 
-// SetGroupOffsets commits specific offset values along with metadata for a list
-// of partitions of a particular topic on behalf of the specified group.
-func (p *T) SetGroupOffsets(group, topic string, offsets []admin.PartitionOffset) error {
-	p.adminMu.RLock()
-	defer p.adminMu.RUnlock()
-	if p.admin == nil {
+// Flush blocks until all messages produced before the call, including
+// asynchronously produced ones, are either acknowledged by Kafka or failed.
+// If that does not happen within timeout then ErrFlushTimeout is returned.
+// It is intended for tests and clean handoffs, e.g. to make sure that
+// messages are written before they are read back.
+func (p *T) Flush(timeout time.Duration) error {
+	p.producerMu.RLock()
+	defer p.producerMu.RUnlock()
+	if p.producer == nil {
 		return ErrUnavailable
 	}
-	return p.admin.SetGroupOffsets(group, topic, offsets)
+	prods := []*producer.T{p.producer}
+	p.variantProducersMu.Lock()
+	for _, variantProd := range p.variantProducers {
+		prods = append(prods, variantProd.prod)
+	}
+	p.variantProducersMu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for _, prod := range prods {
+		if err := prod.Flush(deadline.Sub(time.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// GetTopicConsumers returns client-id -> consumed-partitions-list mapping
-// for a clients from a particular consumer group and a particular topic.
-func (p *T) GetTopicConsumers(group, topic string) (map[string][]int32, error) {
-	p.adminMu.RLock()
-	defer p.adminMu.RUnlock()
-	if p.admin == nil {
+// ConsumerMetrics returns the registry of consume metrics of requests that
+// specify ConsumeOpts.ClientID. For every client ID it contains
+// `consume-requests-for-client-<id>`, `consume-messages-for-client-<id>`, and
+// `consume-errors-for-client-<id>` counters, see producer.ClientMetricNames
+// for how the number of client IDs is limited. Long polling timeouts are not
+// counted as errors.
+func (p *T) ConsumerMetrics() metrics.Registry {
+	return p.consumerMetrics
+}
+
+// ProducerMetrics returns the producer metrics registry. See
+// `producer.T.Metrics` for the list of reported metrics.
+func (p *T) ProducerMetrics() (metrics.Registry, error) {
+	p.producerMu.RLock()
+	defer p.producerMu.RUnlock()
+	if p.producer == nil {
 		return nil, ErrUnavailable
 	}
-	return p.admin.GetTopicConsumers(group, topic)
+	return p.producer.Metrics(), nil
 }
 
-// GetAllTopicConsumers returns group -> client-id -> consumed-partitions-list
-// mapping for a particular topic. Warning, the function performs scan of all
-// consumer groups registered in ZooKeeper and therefore can take a lot of time.
-func (p *T) GetAllTopicConsumers(topic string) (map[string]map[string][]int32, error) {
-	p.adminMu.RLock()
-	defer p.adminMu.RUnlock()
-	if p.admin == nil {
+// GroupMetrics returns the consumer group metrics registry. See
+// `consumer.T.Metrics` for the list of reported metrics.
+func (p *T) GroupMetrics() (metrics.Registry, error) {
+	p.consumerMu.RLock()
+	defer p.consumerMu.RUnlock()
+	if p.consumer == nil {
 		return nil, ErrUnavailable
 	}
-	return p.admin.GetAllTopicConsumers(topic)
+	return p.consumer.Metrics(), nil
 }
 
-// ListTopics returns a list of all topics existing in the Kafka cluster.
-func (p *T) ListTopics(withPartitions, withConfig bool) ([]admin.TopicMetadata, error) {
+// Health returns the proxy health status. Only brokers known from the cached
+// cluster metadata are checked, and no metadata requests are made. If none of
+// them is connected, then a short lived connection to each of them is tried,
+// so an idle proxy is not reported as unhealthy. The controller ID is read from
+// ZooKeeper, if the admin is up.
+func (p *T) Health() HealthStatus {
+	hs := HealthStatus{ControllerID: -1}
+
+	p.producerMu.RLock()
+	hs.ProducerUp = p.producer != nil
+	p.producerMu.RUnlock()
+
+	p.consumerMu.RLock()
+	hs.ConsumerUp = p.consumer != nil
+	p.consumerMu.RUnlock()
+
 	p.adminMu.RLock()
-	defer p.adminMu.RUnlock()
-	if p.admin == nil {
+	hs.AdminUp = p.admin != nil
+	if p.admin != nil {
+		if controllerID, err := p.admin.GetControllerID(); err == nil {
+			hs.ControllerID = controllerID
+		} else {
+			p.actDesc.Log().WithError(err).Warn("Failed to get controller")
+		}
+	}
+	p.adminMu.RUnlock()
+
+	if p.kafkaClt.Closed() {
+		return hs
+	}
+	for _, broker := range p.kafkaClt.Brokers() {
+		if connected, _ := broker.Connected(); connected {
+			hs.KafkaConnected = true
+			return hs
+		}
+	}
+	// None of the brokers is connected, try to connect to them one by one.
+	// Brokers owned by the client are not opened here, for that would race
+	// with the client managing their connections, so dedicated probe
+	// connections are made instead. Connected blocks until a connection
+	// attempt initiated by Open is over.
+	saramaCfg := p.cfg.SaramaClientCfg()
+	for _, broker := range p.kafkaClt.Brokers() {
+		probeBroker := sarama.NewBroker(broker.Addr())
+		_ = probeBroker.Open(saramaCfg)
+		connected, _ := probeBroker.Connected()
+		_ = probeBroker.Close()
+		if connected {
+			hs.KafkaConnected = true
+			return hs
+		}
+	}
+	return hs
+}
+
+// ProduceToPartition submits a message to a particular partition of the
+// specified `topic` regardless of the `key` value. It is intended for cases
+// when a caller needs exact control over message placement, e.g. to replay a
+// partition dump. If the partition does not exist in the topic then
+// `producer.ErrPartitionOutOfRange` is returned. The key can be `nil`, then
+// the message is produced with a null key to the specified partition rather
+// than to a random one.
+func (p *T) ProduceToPartition(topic string, partition int32, key, message sarama.Encoder) (*sarama.ProducerMessage, error) {
+	if !p.allowProduce(topic) {
+		return nil, ErrCircuitOpen
+	}
+	p.producerMu.RLock()
+	if p.producer == nil {
+		p.producerMu.RUnlock()
+		p.releaseProbe(topic)
 		return nil, ErrUnavailable
 	}
-	return p.admin.ListTopics(withPartitions, withConfig)
+	responseCh := p.producer.AsyncProduceToPartition(topic, partition, key, message)
+	p.producerMu.RUnlock()
+
+	select {
+	case rs := <-responseCh:
+		p.reportProduce(topic, rs.Err)
+		return rs.Msg, produceError(rs.Err)
+	case <-p.produceTimeoutCh():
+		p.reportProduce(topic, ErrProduceTimeout)
+		return nil, ErrProduceTimeout
+	}
+}
+
+// produceTimeoutCh returns a channel that fires when `producer.produce_timeout`
+// elapses, or nil that never fires if the timeout is not configured. Produce
+// response channels are buffered, therefore a result that arrives after the
+// timeout does not block the producer and is garbage collected.
+func (p *T) produceTimeoutCh() <-chan time.Time {
+	if p.cfg.Producer.ProduceTimeout <= 0 {
+		return nil
+	}
+	return time.After(p.cfg.Producer.ProduceTimeout)
+}
+
+// ProduceReq represents a single message submitted to ProduceBatch.
+type ProduceReq struct {
+	Key     sarama.Encoder
+	Message sarama.Encoder
+}
+
+// ProduceResult represents an outcome of producing a single message submitted
+// to ProduceBatch. If Err is nil then Partition and Offset are assigned by
+// Kafka to the message. Use KafkaErrorCode to get the Kafka error code of Err.
+type ProduceResult struct {
+	Partition int32
+	Offset    int64
+	Err       error
+}
+
+// KafkaErrorCode returns the error code reported by Kafka if err was caused by
+// a Kafka error, e.g. the one returned by Produce. It allows callers to tell
+// retriable errors like sarama.ErrNotEnoughReplicas from fatal ones like
+// sarama.ErrMessageSizeTooLarge. If err is not a Kafka error, e.g. it is
+// ErrUnavailable or a network error, then false is returned.
+func KafkaErrorCode(err error) (sarama.KError, bool) {
+	if notEnoughReplicasErr, ok := errors.Cause(err).(ErrNotEnoughReplicas); ok {
+		if notEnoughReplicasErr.AfterAppend {
+			return sarama.ErrNotEnoughReplicasAfterAppend, true
+		}
+		return sarama.ErrNotEnoughReplicas, true
+	}
+	kafkaErr, ok := errors.Cause(err).(sarama.KError)
+	if !ok || kafkaErr == sarama.ErrNoError {
+		return sarama.ErrNoError, false
+	}
+	return kafkaErr, true
+}
+
+// ProduceBatch submits several messages to the specified `topic` at once. All
+// messages are handed over to the producer before any result is awaited, so
+// messages are written to Kafka concurrently. Results are returned in the
+// order of the respective requests. A failure to produce a particular message
+// is reported in its result and does not affect the other messages. Messages
+// that are not acknowledged within `producer.produce_timeout` of the call
+// fail with ErrProduceTimeout. A non-nil error is returned only if the batch
+// could not be submitted at all, e.g. ErrCircuitOpen if the circuit breaker of
+// the topic is open. The outcome of every message is reported to the breaker.
+func (p *T) ProduceBatch(topic string, reqs []ProduceReq) ([]ProduceResult, error) {
+	if !p.allowProduce(topic) {
+		return nil, ErrCircuitOpen
+	}
+	responseChs := make([]<-chan producer.Response, len(reqs))
+	p.producerMu.RLock()
+	if p.producer == nil {
+		p.producerMu.RUnlock()
+		p.releaseProbe(topic)
+		return nil, ErrUnavailable
+	}
+	for i, req := range reqs {
+		responseChs[i] = p.producer.AsyncProduce(topic, req.Key, req.Message)
+	}
+	p.producerMu.RUnlock()
+
+	// The timeout is shared by all messages of the batch.
+	ctx := context.Background()
+	if p.cfg.Producer.ProduceTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Producer.ProduceTimeout)
+		defer cancel()
+	}
+	results := make([]ProduceResult, len(reqs))
+	for i, responseCh := range responseChs {
+		var rs producer.Response
+		select {
+		case rs = <-responseCh:
+		case <-ctx.Done():
+			rs.Err = ErrProduceTimeout
+		}
+		p.reportProduce(topic, rs.Err)
+		if rs.Err != nil {
+			results[i].Err = produceError(rs.Err)
+			continue
+		}
+		results[i].Partition = rs.Msg.Partition
+		results[i].Offset = rs.Msg.Offset
+	}
+	return results, nil
+}
+
+// Consume consumes a message from the specified topic on behalf of the
+// specified consumer group. If there are no more new messages in the topic
+// at the time of the request then it will block for
+// `Config.Consumer.LongPollingTimeout`. If no new message is produced during
+// that time, then `ErrRequestTimeout` is returned.
+//
+// Note that during state transitions topic subscribe<->unsubscribe and
+// consumer group register<->deregister the method may return either
+// `ErrTooManyRequests` or `ErrRequestTimeout` even when there are messages
+// available for consumption. In that case the user should back off a bit
+// and then repeat the request. If the consumer group is rebalancing when the
+// long polling timeout expires, then `ErrRebalanceInProgress` is returned
+// instead of `ErrRequestTimeout`.
+func (p *T) Consume(group, topic string, ack Ack) (consumer.Message, error) {
+	return p.ConsumeCtx(context.Background(), group, topic, ack)
+}
+
+// ConsumeCtx is the same as Consume but it stops waiting for a message and
+// returns `ctx.Err()` as soon as the context is done. A message that may be
+// fetched for the request after that is not lost, it is offered again after
+// `Config.Consumer.AckTimeout` expires, as any other unacknowledged message.
+func (p *T) ConsumeCtx(ctx context.Context, group, topic string, ack Ack) (consumer.Message, error) {
+	return p.ConsumeWithOpts(ctx, group, topic, ack, ConsumeOpts{})
+}
+
+// ConsumeOpts holds optional parameters of ConsumeWithOpts.
+type ConsumeOpts struct {
+	// LongPollingTimeout overrides `consumer.long_polling_timeout` of the
+	// proxy config for a particular request. It is clamped to
+	// `consumer.max_long_polling_timeout`. If zero then the configured value
+	// is used.
+	LongPollingTimeout time.Duration
+
+	// InitialOffset is where to start consuming partitions that the group
+	// has no committed offsets for, that is normally the case with a new
+	// group. It can be either sarama.OffsetOldest or sarama.OffsetNewest. If
+	// zero, then the partitions are consumed from the newest offset. It
+	// only takes effect when partitions are assigned to the group member.
+	InitialOffset int64
+
+	// ClientID is a logical ID of the service that consumes. If given, then
+	// the request is accounted for in client specific metrics, see
+	// ConsumerMetrics.
+	ClientID string
+}
+
+// ParseInitialOffset parses `earliest` and `latest` into values that are
+// accepted by ConsumeOpts.InitialOffset. An empty string is parsed to zero.
+func ParseInitialOffset(s string) (int64, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "earliest":
+		return sarama.OffsetOldest, nil
+	case "latest":
+		return sarama.OffsetNewest, nil
+	}
+	return 0, errors.Errorf("bad initial offset: %s", s)
+}
+
+// ConsumeWithOpts is the same as ConsumeCtx but allows overriding the proxy
+// consumer configuration for a particular request. E.g. interactive clients
+// can poll for a short period of time, while batch clients can wait for
+// messages longer than configured.
+func (p *T) ConsumeWithOpts(ctx context.Context, group, topic string, ack Ack, opts ConsumeOpts) (consumer.Message, error) {
+	startedAt := clock.Now()
+	var consMsg consumer.Message
+	var err error
+	if p.acquireInFlight(opts.ClientID) {
+		consMsg, err = p.consumeWithOpts(ctx, group, topic, ack, opts)
+		p.releaseInFlight(opts.ClientID)
+	} else {
+		err = ErrTooManyRequests
+	}
+	if opts.ClientID != "" {
+		p.countConsumed(opts.ClientID, err)
+	}
+	if p.accessLog != nil {
+		fields := log.Fields{
+			"kafka.group": group,
+			"kafka.topic": topic,
+		}
+		if err == nil {
+			fields["kafka.partition"] = consMsg.Partition
+			fields["kafka.offset"] = consMsg.Offset
+			fields["bytes"] = len(consMsg.Key) + len(consMsg.Value)
+		}
+		p.logAccess("consume", fields, opts.ClientID, startedAt, err)
+	}
+	return consMsg, err
+}
+
+// acquireInFlight counts a consume request of the specified client as in
+// flight, unless the client has `consumer.max_in_flight_per_client` requests
+// in flight already, in which case false is returned. Requests without a
+// client ID are all counted as requests of one anonymous client.
+func (p *T) acquireInFlight(clientID string) bool {
+	maxInFlight := p.cfg.Consumer.MaxInFlightPerClient
+	if maxInFlight == 0 {
+		return true
+	}
+	p.inFlightMu.Lock()
+	defer p.inFlightMu.Unlock()
+	if p.inFlight[clientID] >= maxInFlight {
+		return false
+	}
+	p.inFlight[clientID]++
+	return true
+}
+
+// releaseInFlight undoes a successful acquireInFlight.
+func (p *T) releaseInFlight(clientID string) {
+	if p.cfg.Consumer.MaxInFlightPerClient == 0 {
+		return
+	}
+	p.inFlightMu.Lock()
+	defer p.inFlightMu.Unlock()
+	if p.inFlight[clientID]--; p.inFlight[clientID] <= 0 {
+		delete(p.inFlight, clientID)
+	}
+}
+
+// countConsumed updates consume metrics of the specified client.
+func (p *T) countConsumed(clientID string, err error) {
+	metrics.GetOrRegisterCounter(p.clientMetrics.Get(metricConsumeRequests, clientID), p.consumerMetrics).Inc(1)
+	switch err {
+	case nil:
+		metrics.GetOrRegisterCounter(p.clientMetrics.Get(metricConsumeMessages, clientID), p.consumerMetrics).Inc(1)
+	case consumer.ErrRequestTimeout, consumer.ErrRebalanceInProgress:
+	default:
+		metrics.GetOrRegisterCounter(p.clientMetrics.Get(metricConsumeErrors, clientID), p.consumerMetrics).Inc(1)
+	}
+}
+
+func (p *T) consumeWithOpts(ctx context.Context, group, topic string, ack Ack, opts ConsumeOpts) (consumer.Message, error) {
+	if opts.InitialOffset != 0 && opts.InitialOffset != sarama.OffsetOldest && opts.InitialOffset != sarama.OffsetNewest {
+		return consumer.Message{}, errors.Errorf("bad initial offset: %d", opts.InitialOffset)
+	}
+	timeout := p.longPollingTimeout(opts.LongPollingTimeout)
+	if ack != noAck && ack != autoAck {
+		p.asyncAck(group, topic, ack, timeout)
+	}
+	if !p.takeToken(group, topic) {
+		return consumer.Message{}, ErrRateLimited
+	}
+
+	p.consumerMu.RLock()
+	if p.consumer == nil {
+		p.consumerMu.RUnlock()
+		return consumer.Message{}, ErrUnavailable
+	}
+	responseCh := p.consumer.AsyncConsume(group, topic, timeout, opts.InitialOffset)
+	p.consumerMu.RUnlock()
+
+	var rs consumer.Response
+	select {
+	case rs = <-responseCh:
+	case <-ctx.Done():
+		return consumer.Message{}, ctx.Err()
+	}
+	if rs.Err != nil {
+		return consumer.Message{}, rs.Err
+	}
+	p.trackMsg(group, &rs.Msg, ack == autoAck)
+	return rs.Msg, nil
+}
+
+// ConsumeBatch consumes up to maxMessages messages from the specified topic on
+// behalf of the specified consumer group, waiting for them at most maxWait,
+// that is clamped to `Config.Consumer.MaxLongPollingTimeout`. The ack is
+// applied before consumption. If it is AutoAck, then all returned messages
+// are acknowledged, otherwise each of them should be acknowledged separately.
+// If no message is consumed within maxWait then `ErrRequestTimeout` is
+// returned. If consumption fails after some messages have been consumed, then
+// the messages are returned without an error. The request counts towards
+// `consumer.max_in_flight_per_client` of the client identified by clientID.
+func (p *T) ConsumeBatch(group, topic string, maxMessages int, maxWait time.Duration, ack Ack, clientID string) ([]consumer.Message, error) {
+	if maxMessages <= 0 {
+		return nil, errors.Errorf("bad max messages: %d", maxMessages)
+	}
+	if !p.acquireInFlight(clientID) {
+		return nil, ErrTooManyRequests
+	}
+	defer p.releaseInFlight(clientID)
+	maxWait = p.longPollingTimeout(maxWait)
+	if ack != noAck && ack != autoAck {
+		p.asyncAck(group, topic, ack, maxWait)
+	}
+	deadline := clock.Now().Add(maxWait)
+	var msgs []consumer.Message
+	for len(msgs) < maxMessages {
+		timeout := deadline.Sub(clock.Now())
+		if timeout <= 0 {
+			break
+		}
+		if !p.takeToken(group, topic) {
+			if len(msgs) == 0 {
+				return nil, ErrRateLimited
+			}
+			break
+		}
+		rs := p.asyncConsume(group, topic, timeout)
+		if rs.Err != nil {
+			if len(msgs) == 0 {
+				return nil, rs.Err
+			}
+			break
+		}
+		p.trackMsg(group, &rs.Msg, ack == autoAck)
+		msgs = append(msgs, rs.Msg)
+	}
+	if len(msgs) == 0 {
+		return nil, consumer.ErrRequestTimeout
+	}
+	return msgs, nil
+}
+
+// PauseConsume stops delivering messages from the topic to the consumer
+// group, without leaving the group, so that no rebalance is triggered. Consume
+// requests made while paused are replied to with `consumer.ErrRequestTimeout`
+// after their long polling timeout, and offsets are not committed since no
+// messages are consumed. The pause lasts until ResumeConsume is called or
+// the proxy is restarted.
+func (p *T) PauseConsume(group, topic string) error {
+	p.consumerMu.RLock()
+	defer p.consumerMu.RUnlock()
+	if p.consumer == nil {
+		return ErrUnavailable
+	}
+	p.consumer.Pause(group, topic)
+	return nil
+}
+
+// ResumeConsume resumes delivery of messages paused by PauseConsume.
+func (p *T) ResumeConsume(group, topic string) error {
+	p.consumerMu.RLock()
+	defer p.consumerMu.RUnlock()
+	if p.consumer == nil {
+		return ErrUnavailable
+	}
+	p.consumer.Resume(group, topic)
+	return nil
+}
+
+func (p *T) asyncConsume(group, topic string, timeout time.Duration) consumer.Response {
+	p.consumerMu.RLock()
+	if p.consumer == nil {
+		p.consumerMu.RUnlock()
+		return consumer.Response{Err: ErrUnavailable}
+	}
+	responseCh := p.consumer.AsyncConsume(group, topic, timeout, 0)
+	p.consumerMu.RUnlock()
+	return <-responseCh
+}
+
+// longPollingTimeout returns the requested long polling timeout clamped to
+// `consumer.max_long_polling_timeout`, or the configured one if the requested
+// timeout is not positive.
+func (p *T) longPollingTimeout(requested time.Duration) time.Duration {
+	if requested <= 0 {
+		return p.cfg.Consumer.LongPollingTimeout
+	}
+	if requested > p.cfg.Consumer.MaxLongPollingTimeout {
+		return p.cfg.Consumer.MaxLongPollingTimeout
+	}
+	return requested
+}
+
+// ConsumePattern consumes a message from any topic with a name matching the
+// regular expression `pattern` on behalf of the specified consumer group. The
+// pattern has to match an entire topic name. The group is subscribed to all
+// matching topics, and topics created later are picked up as soon as they
+// show up in the cluster metadata. The topic that a message was read from is
+// returned in `consumer.Message.Topic`. Explicit acks must be given the topic
+// of the acknowledged message with `Ack.WithTopic`, and it has to match the
+// pattern.
+//
+// Requests are issued to all matching topics concurrently, therefore more
+// than one message can be fetched. Messages in excess of the returned one are
+// returned by subsequent calls with the same group and pattern. If there are
+// none within `Config.Consumer.AckTimeout` then the messages are offered
+// again, as any other unacknowledged messages. The request counts towards
+// `consumer.max_in_flight_per_client` of the client identified by clientID.
+func (p *T) ConsumePattern(group, pattern string, ack Ack, clientID string) (consumer.Message, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return consumer.Message{}, errors.Wrap(err, "bad topic pattern")
+	}
+	if ack.topic != "" && !re.MatchString(ack.topic) {
+		return consumer.Message{}, errors.Errorf("ack topic %s does not match the pattern", ack.topic)
+	}
+	// The prefix keeps the key apart from keys of topic lists, and from
+	// topic names that rate limits of single topic consumption are keyed by,
+	// for topic names cannot contain colons.
+	return p.consumeMulti(group, "pattern:"+pattern, ack, clientID, func() ([]string, error) {
+		return p.matchTopics(re)
+	})
+}
+
+// ConsumeTopics consumes a message from any of the specified topics on behalf
+// of the specified consumer group. The group is subscribed to all the topics
+// at once, and partitions of every topic are distributed among all members of
+// the group subscribed to it, so the load of all topics is balanced across
+// the members. The topic that a message was read from is returned in
+// `consumer.Message.Topic`. Explicit acks must be given the topic of the
+// acknowledged message with `Ack.WithTopic`, and it has to be one of the
+// consumed topics.
+//
+// Messages fetched in excess of the returned one are handled the same way as
+// by ConsumePattern, that is they are returned by subsequent calls with the
+// same group and topics. The request counts towards
+// `consumer.max_in_flight_per_client` of the client identified by clientID.
+func (p *T) ConsumeTopics(group string, topics []string, ack Ack, clientID string) (consumer.Message, error) {
+	if len(topics) == 0 {
+		return consumer.Message{}, errors.New("no topics to consume")
+	}
+	unique := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		if topic == "" {
+			return consumer.Message{}, errors.New("empty topic name")
+		}
+		unique[topic] = true
+	}
+	if ack.topic != "" && !unique[ack.topic] {
+		return consumer.Message{}, errors.Errorf("ack topic %s is not among consumed topics", ack.topic)
+	}
+	sorted := make([]string, 0, len(unique))
+	for topic := range unique {
+		sorted = append(sorted, topic)
+	}
+	sort.Strings(sorted)
+	// Topic names cannot contain commas, so the key does not clash with
+	// keys of other topic lists. The prefix keeps it apart from keys of
+	// patterns, and from topic names, for they cannot contain colons.
+	key := "topics:" + strings.Join(sorted, ",")
+	return p.consumeMulti(group, key, ack, clientID, func() ([]string, error) {
+		return sorted, nil
+	})
+}
+
+// consumeMulti implements consumption from several topics for ConsumePattern
+// and ConsumeTopics. Rate limiting and stashing of excess messages is done by
+// `key` that identifies the set of topics, and topicsFn is only called to get
+// the topics if there is no stashed message.
+func (p *T) consumeMulti(group, key string, ack Ack, clientID string, topicsFn func() ([]string, error)) (consumer.Message, error) {
+	if !p.acquireInFlight(clientID) {
+		return consumer.Message{}, ErrTooManyRequests
+	}
+	defer p.releaseInFlight(clientID)
+	if ack != noAck && ack != autoAck {
+		if ack.topic == "" {
+			return consumer.Message{}, errors.New("ack topic is not specified")
+		}
+		p.asyncAck(group, ack.topic, ack, p.cfg.Consumer.LongPollingTimeout)
+	}
+	if !p.takeToken(group, key) {
+		return consumer.Message{}, ErrRateLimited
+	}
+
+	stashID := patternStashID{group, key}
+	msg, ok := p.popStashedMsg(stashID)
+	if !ok {
+		topics, err := topicsFn()
+		if err != nil {
+			return consumer.Message{}, err
+		}
+		if msg, err = p.consumeAny(group, topics, stashID); err != nil {
+			return consumer.Message{}, err
+		}
+	}
+	p.trackMsg(group, &msg, ack == autoAck)
+	return msg, nil
+}
+
+// matchTopics returns all topics known to the cluster with names matching
+// the specified regular expression.
+func (p *T) matchTopics(re *regexp.Regexp) ([]string, error) {
+	topics, err := p.kafkaClt.Topics()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get topics")
+	}
+	var matched []string
+	for _, topic := range topics {
+		if re.MatchString(topic) {
+			matched = append(matched, topic)
+		}
+	}
+	return matched, nil
+}
+
+// consumeAny concurrently requests a message from all specified topics and
+// returns the first one fetched. Messages fetched for the rest of the
+// requests are stashed to be returned by subsequent calls.
+func (p *T) consumeAny(group string, topics []string, stashID patternStashID) (consumer.Message, error) {
+	if len(topics) == 0 {
+		<-time.After(p.cfg.Consumer.LongPollingTimeout)
+		return consumer.Message{}, consumer.ErrRequestTimeout
+	}
+	p.consumerMu.RLock()
+	if p.consumer == nil {
+		p.consumerMu.RUnlock()
+		return consumer.Message{}, ErrUnavailable
+	}
+	mergedCh := make(chan consumer.Response, len(topics))
+	for _, topic := range topics {
+		responseCh := p.consumer.AsyncConsume(group, topic, 0, 0)
+		go func() {
+			mergedCh <- <-responseCh
+		}()
+	}
+	p.consumerMu.RUnlock()
+
+	var err error
+	for pending := len(topics); pending > 0; pending-- {
+		rs := <-mergedCh
+		if rs.Err == nil {
+			go p.stashMsgs(stashID, mergedCh, pending-1)
+			return rs.Msg, nil
+		}
+		// Timeouts are only reported if all requests timed out.
+		if err == nil || err == consumer.ErrRequestTimeout {
+			err = rs.Err
+		}
+	}
+	return consumer.Message{}, err
+}
+
+// stashMsgs waits for `count` responses from responseCh and stashes fetched
+// messages to be returned by subsequent ConsumePattern or ConsumeTopics calls.
+func (p *T) stashMsgs(stashID patternStashID, responseCh <-chan consumer.Response, count int) {
+	for ; count > 0; count-- {
+		rs := <-responseCh
+		if rs.Err != nil {
+			continue
+		}
+		p.patternStashMu.Lock()
+		p.patternStash[stashID] = append(p.patternStash[stashID], stashedMsg{rs.Msg, clock.Now()})
+		p.patternStashMu.Unlock()
+	}
+}
+
+// popStashedMsg returns the oldest message stashed for the specified group
+// and pattern. Messages stashed for longer than the ack timeout are discarded,
+// for they are offered again by the consumer anyway.
+func (p *T) popStashedMsg(stashID patternStashID) (consumer.Message, bool) {
+	expiredBefore := clock.Now().Add(-p.cfg.Consumer.AckTimeout)
+	p.patternStashMu.Lock()
+	defer p.patternStashMu.Unlock()
+	stashed := p.patternStash[stashID]
+	for len(stashed) > 0 {
+		sm := stashed[0]
+		stashed = stashed[1:]
+		if sm.stashedAt.After(expiredBefore) {
+			p.patternStash[stashID] = stashed
+			return sm.msg, true
+		}
+	}
+	delete(p.patternStash, stashID)
+	return consumer.Message{}, false
+}
+
+// takeToken takes a token from the bucket of the specified group/topic. It
+// returns false if the bucket is empty, that is if the group has exceeded
+// `Consumer.RateLimit` for the topic.
+func (p *T) takeToken(group, topic string) bool {
+	if p.cfg.Consumer.RateLimit <= 0 {
+		return true
+	}
+	now := clock.Now()
+	burst := float64(p.cfg.Consumer.RateLimitBurst)
+	tokenBucketID := tokenBucketID{group, topic}
+	p.tokenBucketsMu.Lock()
+	defer p.tokenBucketsMu.Unlock()
+	tb := p.tokenBuckets[tokenBucketID]
+	if tb == nil {
+		tb = &tokenBucket{tokens: burst, updatedAt: now}
+		p.tokenBuckets[tokenBucketID] = tb
+	}
+	tb.tokens += now.Sub(tb.updatedAt).Seconds() * p.cfg.Consumer.RateLimit
+	if tb.tokens > burst {
+		tb.tokens = burst
+	}
+	tb.updatedAt = now
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens -= 1
+	return true
+}
+
+// allowProduce returns false if the circuit breaker of the topic is open, that
+// is if produce requests to the topic failed `Producer.CircuitBreakerThreshold`
+// times in a row less than `Producer.CircuitBreakerCooldown` ago. When the
+// cooldown elapses it lets a single probe request through, and the outcome of
+// the probe must be reported with either reportProduce or releaseProbe.
+//
+// Partitions are selected by the producer after a message is submitted, hence
+// failures are tracked per topic rather than per partition or broker.
+func (p *T) allowProduce(topic string) bool {
+	threshold := p.cfg.Producer.CircuitBreakerThreshold
+	if threshold <= 0 {
+		return true
+	}
+	p.circuitBreakersMu.Lock()
+	defer p.circuitBreakersMu.Unlock()
+	cb := p.circuitBreakers[topic]
+	if cb == nil || cb.failures < threshold {
+		return true
+	}
+	if clock.Now().Sub(cb.openedAt) < p.cfg.Producer.CircuitBreakerCooldown || cb.probing {
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+// reportProduce updates the circuit breaker of the topic with the outcome of
+// a produce request. Errors caused by bad requests rather than by Kafka
+// failures do not count.
+func (p *T) reportProduce(topic string, err error) {
+	if p.cfg.Producer.CircuitBreakerThreshold <= 0 {
+		return
+	}
+	p.circuitBreakersMu.Lock()
+	defer p.circuitBreakersMu.Unlock()
+	switch errors.Cause(err) {
+	case nil:
+		delete(p.circuitBreakers, topic)
+		return
+	case sarama.ErrUnknownTopicOrPartition, sarama.ErrMessageSizeTooLarge,
+		producer.ErrPartitionOutOfRange, ErrMessageTooLarge:
+		if cb := p.circuitBreakers[topic]; cb != nil {
+			cb.probing = false
+		}
+		return
+	}
+	cb := p.circuitBreakers[topic]
+	if cb == nil {
+		cb = &circuitBreaker{}
+		p.circuitBreakers[topic] = cb
+	}
+	cb.probing = false
+	cb.failures++
+	if cb.failures >= p.cfg.Producer.CircuitBreakerThreshold {
+		cb.openedAt = clock.Now()
+	}
+}
+
+// releaseProbe lets another probe request through if the one previously let
+// through by allowProduce completed without an outcome, e.g. was canceled.
+func (p *T) releaseProbe(topic string) {
+	if p.cfg.Producer.CircuitBreakerThreshold <= 0 {
+		return
+	}
+	p.circuitBreakersMu.Lock()
+	defer p.circuitBreakersMu.Unlock()
+	if cb := p.circuitBreakers[topic]; cb != nil {
+		cb.probing = false
+	}
+}
+
+// RetryAfter returns how long a client should wait before retrying a request
+// that failed with err, as derived from the proxy config. Zero is returned if
+// the error is not transient or there is no better estimate than an
+// immediate retry.
+func (p *T) RetryAfter(err error) time.Duration {
+	switch errors.Cause(err) {
+	case ErrRateLimited:
+		// The time it takes to refill a single token.
+		if p.cfg.Consumer.RateLimit > 0 {
+			return time.Duration(float64(time.Second) / p.cfg.Consumer.RateLimit)
+		}
+	case ErrCircuitOpen:
+		return p.cfg.Producer.CircuitBreakerCooldown
+	case ErrNotEnoughReplicas{}, ErrNotEnoughReplicas{AfterAppend: true}:
+		return p.cfg.Producer.RetryBackoff
+	case consumer.ErrRebalanceInProgress, admin.ErrOwnershipUnstable:
+		return p.cfg.Consumer.RetryBackoff
+	}
+	return 0
+}
+
+// asyncAck sends an ack to the events channel of the acknowledged message
+// partition, if it is known.
+func (p *T) asyncAck(group, topic string, ack Ack, timeout time.Duration) {
+	p.eventsChMapMu.RLock()
+	eventsChID := eventsChID{group, topic, ack.partition}
+	eventsChEntry, ok := p.eventsChMap[eventsChID]
+	p.eventsChMapMu.RUnlock()
+	if !ok {
+		return
+	}
+	eventsCh := eventsChEntry.eventsCh
+	// The ack goroutine is deliberately not bound to a request context, for
+	// the ack should be delivered even if the request is canceled. It is
+	// bounded by the request long polling timeout so it cannot leak.
+	go func() {
+		select {
+		case eventsCh <- consumer.Ack(ack.offset):
+		case <-time.After(timeout):
+			p.actDesc.Log().WithFields(log.Fields{
+				"kafka.group":     group,
+				"kafka.topic":     topic,
+				"kafka.partition": ack.partition,
+			}).Errorf("ack timeout: offset=%d", ack.offset)
+		}
+	}()
+}
+
+// trackMsg remembers the events channel of a message returned to a client,
+// so that the message can be acknowledged later, and acknowledges the message
+// right away if autoAck is true. It also flags the message if it is a likely
+// duplicate of one recently consumed by the group.
+func (p *T) trackMsg(group string, msg *consumer.Message, autoAck bool) {
+	eventsChID := eventsChID{group, msg.Topic, msg.Partition}
+	p.eventsChMapMu.Lock()
+	p.eventsChMap[eventsChID] = eventsChEntry{msg.EventsCh, clock.Now()}
+	p.eventsChMapMu.Unlock()
+
+	if p.dedupCache != nil {
+		msg.LikelyDuplicate = p.dedupCache.seen(group, msg, clock.Now())
+	}
+	if autoAck {
+		msg.EventsCh <- consumer.Ack(msg.Offset)
+	}
+}
+
+// ConsumePartition reads a message at the specified offset of a particular
+// topic partition. Unlike Consume it bypasses the consumer group machinery
+// entirely, that is it does not register anything in ZooKeeper, does not
+// affect group rebalancing and does not commit offsets. It is intended for
+// tools that need to peek into a partition. The returned message cannot be
+// acknowledged. If there is no message at the offset yet, the method blocks
+// for `Config.Consumer.LongPollingTimeout` waiting for it to be produced, and
+// if that does not happen `ErrRequestTimeout` is returned.
+func (p *T) ConsumePartition(topic string, partition int32, offset int64) (consumer.Message, error) {
+	saramaCsm, err := sarama.NewConsumerFromClient(p.kafkaClt)
+	if err != nil {
+		return consumer.Message{}, errors.Wrap(err, "failed to create consumer")
+	}
+	defer saramaCsm.Close()
+	partitionCsm, err := saramaCsm.ConsumePartition(topic, partition, offset)
+	if err != nil {
+		return consumer.Message{}, err
+	}
+	defer partitionCsm.Close()
+
+	select {
+	case consMsg := <-partitionCsm.Messages():
+		return consumer.Message{
+			Key:             consMsg.Key,
+			Value:           consMsg.Value,
+			Topic:           consMsg.Topic,
+			Partition:       consMsg.Partition,
+			Offset:          consMsg.Offset,
+			Timestamp:       consMsg.Timestamp,
+			HighWaterMark:   partitionCsm.HighWaterMarkOffset(),
+			CommittedOffset: sarama.OffsetNewest,
+		}, nil
+	case <-time.After(p.cfg.Consumer.LongPollingTimeout):
+		return consumer.Message{}, consumer.ErrRequestTimeout
+	}
+}
+
+// GetMessage returns exactly the message stored at the specified offset of a
+// particular topic partition. Like ConsumePartition it bypasses the consumer
+// group machinery, but it never waits for a message to be produced: if the
+// offset is below the partition log start or at/after its high water mark,
+// or if the message at the offset was removed by compaction, then an error
+// with `ErrMessageNotFound` cause is returned. If all messages from the offset
+// up to the high water mark were compacted, then that is only detected after
+// `Config.Consumer.LongPollingTimeout`.
+func (p *T) GetMessage(topic string, partition int32, offset int64) (consumer.Message, error) {
+	begin, err := p.kafkaClt.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return consumer.Message{}, errors.Wrap(err, "failed to get oldest offset")
+	}
+	end, err := p.kafkaClt.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return consumer.Message{}, errors.Wrap(err, "failed to get newest offset")
+	}
+	if offset < begin || offset >= end {
+		return consumer.Message{}, errors.Wrapf(ErrMessageNotFound,
+			"offset %d is out of range [%d, %d)", offset, begin, end)
+	}
+	consMsg, err := p.ConsumePartition(topic, partition, offset)
+	if err != nil {
+		switch err {
+		case sarama.ErrOffsetOutOfRange:
+			// The log start has moved past the offset since we checked.
+			return consumer.Message{}, errors.Wrapf(ErrMessageNotFound, "offset %d is out of range", offset)
+		case consumer.ErrRequestTimeout:
+			// The offset is below the high water mark, so messages at and
+			// after it were removed by compaction.
+			return consumer.Message{}, errors.Wrapf(ErrMessageNotFound, "offset %d was compacted", offset)
+		}
+		return consumer.Message{}, err
+	}
+	if consMsg.Offset != offset {
+		return consumer.Message{}, errors.Wrapf(ErrMessageNotFound, "offset %d was compacted", offset)
+	}
+	return consMsg, nil
+}
+
+func (p *T) Ack(group, topic string, ack Ack) error {
+	eventsChID := eventsChID{group, topic, ack.partition}
+	p.eventsChMapMu.RLock()
+	eventsChEntry, ok := p.eventsChMap[eventsChID]
+	p.eventsChMapMu.RUnlock()
+	if !ok {
+		return errors.Errorf("acks channel missing for %v", eventsChID)
+	}
+	select {
+	case eventsChEntry.eventsCh <- consumer.Ack(ack.offset):
+	case <-time.After(p.cfg.Consumer.LongPollingTimeout):
+		return errors.New("ack timeout")
+	}
+	p.touchEventsCh(eventsChID)
+	return nil
+}
+
+// touchEventsCh resets the TTL of an events channel, so that a client that
+// keeps acknowledging messages consumed long ago, e.g. a slow batch consumer,
+// can keep acknowledging them. A channel that has already been swept is not
+// brought back.
+func (p *T) touchEventsCh(eventsChID eventsChID) {
+	p.eventsChMapMu.Lock()
+	defer p.eventsChMapMu.Unlock()
+	if eventsChEntry, ok := p.eventsChMap[eventsChID]; ok {
+		eventsChEntry.updatedAt = clock.Now()
+		p.eventsChMap[eventsChID] = eventsChEntry
+	}
+}
+
+// AckRange acknowledges all messages consumed by the group from the partition
+// of the topic up to and including upToOffset in one operation, e.g. after a
+// batch returned by ConsumeBatch is processed. It is equivalent to acking all
+// of them individually, so the committed offset moves past upToOffset. The
+// committed offset never moves past messages that have not been consumed
+// yet, even if upToOffset is beyond them.
+func (p *T) AckRange(group, topic string, partition int32, upToOffset int64) error {
+	if partition < 0 {
+		return errors.Errorf("bad partition: %d", partition)
+	}
+	if upToOffset < 0 {
+		return errors.Errorf("bad offset: %d", upToOffset)
+	}
+	eventsChID := eventsChID{group, topic, partition}
+	p.eventsChMapMu.RLock()
+	eventsChEntry, ok := p.eventsChMap[eventsChID]
+	p.eventsChMapMu.RUnlock()
+	if !ok {
+		return errors.Errorf("acks channel missing for %v", eventsChID)
+	}
+	select {
+	case eventsChEntry.eventsCh <- consumer.AckUpTo(upToOffset):
+	case <-time.After(p.cfg.Consumer.LongPollingTimeout):
+		return errors.New("ack timeout")
+	}
+	p.touchEventsCh(eventsChID)
+	return nil
+}
+
+// ListActiveSubscriptions returns group/topic subscriptions that consumed or
+// acknowledged messages within the last `eventsChTTL`, that is those whose
+// partitions still have events channels tracked by the proxy. The result is
+// sorted by group and then by topic.
+func (p *T) ListActiveSubscriptions() []SubscriptionInfo {
+	type subscriptionID struct {
+		group string
+		topic string
+	}
+	subscriptions := make(map[subscriptionID]*SubscriptionInfo)
+	p.eventsChMapMu.RLock()
+	for eventsChID, eventsChEntry := range p.eventsChMap {
+		id := subscriptionID{eventsChID.group, eventsChID.topic}
+		si := subscriptions[id]
+		if si == nil {
+			si = &SubscriptionInfo{Group: id.group, Topic: id.topic}
+			subscriptions[id] = si
+		}
+		si.Partitions++
+		if eventsChEntry.updatedAt.After(si.LastConsumedAt) {
+			si.LastConsumedAt = eventsChEntry.updatedAt
+		}
+	}
+	p.eventsChMapMu.RUnlock()
+
+	infos := make([]SubscriptionInfo, 0, len(subscriptions))
+	for _, si := range subscriptions {
+		infos = append(infos, *si)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Group != infos[j].Group {
+			return infos[i].Group < infos[j].Group
+		}
+		return infos[i].Topic < infos[j].Topic
+	})
+	return infos
+}
+
+// Release tells the group that a message consumed with NoAck is not going to
+// be acknowledged, e.g. because the client that got it disconnected, so that
+// the message is redelivered right away rather than after
+// `consumer.ack_timeout`.
+func (p *T) Release(group, topic string, partition int32, offset int64) error {
+	eventsChID := eventsChID{group, topic, partition}
+	p.eventsChMapMu.RLock()
+	eventsChEntry, ok := p.eventsChMap[eventsChID]
+	p.eventsChMapMu.RUnlock()
+	if !ok {
+		return errors.Errorf("acks channel missing for %v", eventsChID)
+	}
+	select {
+	case eventsChEntry.eventsCh <- consumer.Release(offset):
+	case <-time.After(p.cfg.Consumer.LongPollingTimeout):
+		return errors.New("release timeout")
+	}
+	return nil
+}
+
+// AvroEnabled tells whether messages can be Avro encoded and decoded, that
+// is whether a schema registry is configured.
+func (p *T) AvroEnabled() bool {
+	return p.schemaReg != nil
+}
+
+// EncodeAvro encodes a JSON value to the Confluent Avro wire format to be
+// produced to the topic. The schema is registered under the `<topic>-value`
+// subject, unless it has already been. If the schema is not given, then the
+// latest one registered under the subject is used.
+func (p *T) EncodeAvro(topic, schema string, value []byte) ([]byte, error) {
+	if p.schemaReg == nil {
+		return nil, ErrAvroUnsupported
+	}
+	return p.schemaReg.Encode(topic+"-value", schema, value)
+}
+
+// DecodeAvro decodes a consumed value in the Confluent Avro wire format to
+// JSON.
+func (p *T) DecodeAvro(value []byte) ([]byte, error) {
+	if p.schemaReg == nil {
+		return nil, ErrAvroUnsupported
+	}
+	return p.schemaReg.Decode(value)
+}
+
+// runEventsChSweeper periodically removes events channels that have not been
+// updated for longer than eventsChTTL from eventsChMap. Without that the map
+// would grow indefinitely in presence of short lived consumer groups.
+func (p *T) runEventsChSweeper() {
+	ticker := clock.NewTicker(p.eventsChTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			p.sweepEventsChMap()
+			p.sweepPatternStash()
+			p.sweepTokenBuckets()
+			p.sweepVariantProducers()
+			p.sweepMissingTopics()
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+func (p *T) sweepEventsChMap() {
+	expiredBefore := clock.Now().Add(-p.eventsChTTL)
+	p.eventsChMapMu.Lock()
+	defer p.eventsChMapMu.Unlock()
+	for eventsChID, eventsChEntry := range p.eventsChMap {
+		if eventsChEntry.updatedAt.Before(expiredBefore) {
+			delete(p.eventsChMap, eventsChID)
+		}
+	}
+}
+
+// sweepMissingTopics forgets topics that were found missing more than
+// missingTopicTTL ago.
+func (p *T) sweepMissingTopics() {
+	expiredBefore := clock.Now().Add(-missingTopicTTL)
+	p.missingTopicsMu.Lock()
+	defer p.missingTopicsMu.Unlock()
+	for topic, missingAt := range p.missingTopics {
+		if missingAt.Before(expiredBefore) {
+			delete(p.missingTopics, topic)
+		}
+	}
+}
+
+// sweepVariantProducers stops variant producers that have not been used for
+// variantProducerIdleTimeout. Each of them holds connections to Kafka brokers.
+func (p *T) sweepVariantProducers() {
+	expiredBefore := clock.Now().Add(-variantProducerIdleTimeout)
+	var idleProds []*producer.T
+	// The write lock guarantees that no request is about to submit a message
+	// to a producer obtained with getVariantProducer.
+	p.producerMu.Lock()
+	p.variantProducersMu.Lock()
+	for variant, variantProd := range p.variantProducers {
+		if variantProd.usedAt.Before(expiredBefore) {
+			idleProds = append(idleProds, variantProd.prod)
+			delete(p.variantProducers, variant)
+		}
+	}
+	p.variantProducersMu.Unlock()
+	p.producerMu.Unlock()
+	for _, prod := range idleProds {
+		prod.Stop()
+	}
+}
+
+// sweepPatternStash removes messages that have been stashed by ConsumePattern
+// for longer than the ack timeout. They have been offered again by then.
+func (p *T) sweepPatternStash() {
+	expiredBefore := clock.Now().Add(-p.cfg.Consumer.AckTimeout)
+	p.patternStashMu.Lock()
+	defer p.patternStashMu.Unlock()
+	for stashID, stashed := range p.patternStash {
+		for len(stashed) > 0 && !stashed[0].stashedAt.After(expiredBefore) {
+			stashed = stashed[1:]
+		}
+		if len(stashed) == 0 {
+			delete(p.patternStash, stashID)
+			continue
+		}
+		p.patternStash[stashID] = stashed
+	}
+}
+
+// sweepTokenBuckets removes token buckets of group/topics that have not been
+// consumed for eventsChTTL. A removed bucket is recreated full on the next
+// request, which is acceptable after such a long pause.
+func (p *T) sweepTokenBuckets() {
+	expiredBefore := clock.Now().Add(-p.eventsChTTL)
+	p.tokenBucketsMu.Lock()
+	defer p.tokenBucketsMu.Unlock()
+	for tokenBucketID, tb := range p.tokenBuckets {
+		if tb.updatedAt.Before(expiredBefore) {
+			delete(p.tokenBuckets, tokenBucketID)
+		}
+	}
+}
+
+// GetGroupOffsets for every partition of the specified topic it returns the
+// current offset range along with the latest offset and metadata committed by
+// the specified consumer group.
+func (p *T) GetGroupOffsets(group, topic string) ([]admin.PartitionOffset, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetGroupOffsets(group, topic)
+}
+
+// GetTopicOffsets returns the range of available offsets for every partition
+// of the specified topic.
+func (p *T) GetTopicOffsets(topic string) ([]admin.PartitionOffsetRange, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetTopicOffsets(topic)
+}
+
+// GetGroupLag for every partition of the specified topic returns how far
+// behind the specified consumer group is.
+func (p *T) GetGroupLag(group, topic string) ([]admin.PartitionLag, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetGroupLag(group, topic)
+}
+
+// GetLaggingPartitions returns partitions of all topics consumed by the
+// specified group that the group lags on by more than the threshold number of
+// messages.
+func (p *T) GetLaggingPartitions(group string, threshold int64) ([]admin.PartitionLag, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetLaggingPartitions(group, threshold)
+}
+
+// SetGroupOffsets commits specific offset values along with metadata for a list
+// of partitions of a particular topic on behalf of the specified group.
+func (p *T) SetGroupOffsets(group, topic string, offsets []admin.PartitionOffset) error {
+	return p.SetGroupOffsetsWithOpts(group, topic, offsets, admin.SetOffsetsOpts{})
+}
+
+// SetGroupOffsetsWithOpts is the same as SetGroupOffsets but allows to specify
+// optional parameters of the commit, e.g. how long Kafka should retain the
+// committed offsets. See admin.SetOffsetsOpts for details.
+func (p *T) SetGroupOffsetsWithOpts(group, topic string, offsets []admin.PartitionOffset, opts admin.SetOffsetsOpts) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.SetGroupOffsetsWithOpts(group, topic, offsets, opts)
+}
+
+// ExportGroupOffsets returns all offsets committed by the consumer group in a
+// portable JSON format. See admin.T.ExportGroupOffsets for details.
+func (p *T) ExportGroupOffsets(group string) ([]byte, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.ExportGroupOffsets(group)
+}
+
+// ImportGroupOffsets commits offsets returned by ExportGroupOffsets, possibly
+// by a proxy of another cluster, on behalf of the consumer group.
+func (p *T) ImportGroupOffsets(group string, data []byte) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.ImportGroupOffsets(group, data)
+}
+
+// CommitOffset commits an offset along with metadata for a particular
+// partition of a topic on behalf of the specified group. It is a shortcut
+// for SetGroupOffsets with a single partition.
+func (p *T) CommitOffset(group, topic string, partition int32, offset int64, metadata string) error {
+	return p.SetGroupOffsets(group, topic, []admin.PartitionOffset{{
+		Partition: partition,
+		Offset:    offset,
+		Metadata:  metadata,
+	}})
+}
+
+// ResetGroupOffsets commits offsets selected according to the spec for all
+// partitions of a topic on behalf of the specified group.
+func (p *T) ResetGroupOffsets(group, topic string, spec admin.ResetSpec) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.ResetGroupOffsets(group, topic, spec)
+}
+
+// GetTopicConsumers returns client-id -> consumed-partitions-list mapping
+// for a clients from a particular consumer group and a particular topic.
+func (p *T) GetTopicConsumers(group, topic string) (map[string][]int32, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetTopicConsumers(group, topic)
+}
+
+// GetGroupStatus returns the committed offset, the lag, and the owner client
+// ID of every partition of the specified topic consumed by the group, in one
+// consistent snapshot. See `admin.T.GetGroupStatus` for details.
+func (p *T) GetGroupStatus(group, topic string) (admin.GroupTopicStatus, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return admin.GroupTopicStatus{}, ErrUnavailable
+	}
+	return p.admin.GetGroupStatus(group, topic)
+}
+
+// GetAllTopicConsumers returns group -> client-id -> consumed-partitions-list
+// mapping for a particular topic. Warning, the function performs scan of all
+// consumer groups registered in ZooKeeper and therefore can take a lot of time.
+func (p *T) GetAllTopicConsumers(topic string) (map[string]map[string][]int32, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetAllTopicConsumers(topic)
+}
+
+// ListConsumerGroups returns a sorted list of all consumer groups known to the
+// Kafka cluster.
+func (p *T) ListConsumerGroups() ([]string, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.ListConsumerGroups()
+}
+
+// GetClusterMetadata returns brokers of the Kafka cluster, as currently known
+// to the proxy, and the ID of the controller broker.
+func (p *T) GetClusterMetadata() (admin.ClusterMetadata, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return admin.ClusterMetadata{}, ErrUnavailable
+	}
+	return p.admin.GetClusterMetadata()
+}
+
+// DescribeConsumerGroup returns the state and the members of a consumer group
+// as seen by its coordinator broker.
+func (p *T) DescribeConsumerGroup(group string) (admin.GroupDescription, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return admin.GroupDescription{}, ErrUnavailable
+	}
+	return p.admin.DescribeConsumerGroup(group)
+}
+
+// CommitErrors returns errors of the latest failed offset commits for those
+// partitions of the group consumed by this proxy that offsets have not been
+// committed to since. Offset commits are retried in the background, so
+// consumption proceeds while commits keep failing, but no durable progress is
+// made. Partitions consumed by other group members are not reported.
+func (p *T) CommitErrors(group string) []offsetmgr.CommitError {
+	if p.offsetMgrF == nil {
+		return nil
+	}
+	return p.offsetMgrF.CommitErrors(group)
+}
+
+// PendingCommits returns offsets consumed by this proxy that have been
+// acknowledged but not committed to Kafka yet. Stop commits them before it
+// returns, so a non empty result while Stop is in progress tells that offsets
+// would be lost if the process was terminated right away.
+func (p *T) PendingCommits() []offsetmgr.PendingCommit {
+	if p.offsetMgrF == nil {
+		return nil
+	}
+	return p.offsetMgrF.PendingCommits()
+}
+
+// DeleteConsumerGroup removes registration of a consumer group and expires
+// its committed offsets. It fails with `admin.ErrGroupNotEmpty` if the group
+// has active members. See `admin.T.DeleteConsumerGroup` for details.
+func (p *T) DeleteConsumerGroup(group string) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.DeleteConsumerGroup(group)
+}
+
+// CreateTopic creates a topic in the Kafka cluster. See `admin.T.CreateTopic`
+// for details.
+func (p *T) CreateTopic(topic string, partitions int32, replication int16, configs map[string]string, ifNotExists bool) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.CreateTopic(topic, partitions, replication, configs, ifNotExists)
+}
+
+// DeleteTopic deletes a topic from the Kafka cluster. See
+// `admin.T.DeleteTopic` for details.
+func (p *T) DeleteTopic(topic string) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.DeleteTopic(topic)
+}
+
+// CreatePartitions increases the number of topic partitions to newTotal. See
+// `admin.T.CreatePartitions` for details.
+func (p *T) CreatePartitions(topic string, newTotal int32) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.CreatePartitions(topic, newTotal)
+}
+
+// AlterTopicConfig updates topic level config overrides of a topic. See
+// `admin.T.AlterTopicConfig` for details.
+func (p *T) AlterTopicConfig(topic string, configs map[string]string) error {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return ErrUnavailable
+	}
+	return p.admin.AlterTopicConfig(topic, configs)
+}
+
+// GetOffsetsForTime returns the earliest offsets of messages produced to the
+// topic partitions at or after the given time. See
+// `admin.T.GetOffsetForTime` for details.
+func (p *T) GetOffsetsForTime(topic string, t time.Time) (map[int32]int64, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.GetOffsetsForTime(topic, t)
+}
+
+// ListTopics returns a list of all topics existing in the Kafka cluster.
+func (p *T) ListTopics(withPartitions, withConfig bool) ([]admin.TopicMetadata, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.ListTopics(withPartitions, withConfig)
+}
+
+// ListTopicsWithFilter returns a list of topics existing in the Kafka cluster
+// that are selected by the filter.
+func (p *T) ListTopicsWithFilter(withPartitions, withConfig bool, filter admin.TopicFilter) ([]admin.TopicMetadata, error) {
+	p.adminMu.RLock()
+	defer p.adminMu.RUnlock()
+	if p.admin == nil {
+		return nil, ErrUnavailable
+	}
+	return p.admin.ListTopicsWithFilter(withPartitions, withConfig, filter)
 }
 
 // GetTopicMetadata returns a topic metadata. An optional partition metadata
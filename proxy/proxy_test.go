@@ -0,0 +1,1557 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/mailgun/holster/clock"
+	"github.com/mailgun/kafka-pixy/actor"
+	"github.com/mailgun/kafka-pixy/admin"
+	"github.com/mailgun/kafka-pixy/config"
+	"github.com/mailgun/kafka-pixy/consumer"
+	"github.com/mailgun/kafka-pixy/none"
+	"github.com/mailgun/kafka-pixy/offsetmgr"
+	"github.com/mailgun/kafka-pixy/producer"
+	"github.com/mailgun/kafka-pixy/testhelpers"
+	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
+	log "github.com/sirupsen/logrus"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type ProxySuite struct {
+	ns  *actor.Descriptor
+	cfg *config.Proxy
+}
+
+var _ = Suite(&ProxySuite{})
+
+func (s *ProxySuite) SetUpSuite(c *C) {
+	testhelpers.InitLogging()
+}
+
+func (s *ProxySuite) SetUpTest(c *C) {
+	s.ns = actor.Root().NewChild("T")
+	s.cfg = config.DefaultProxy()
+	clock.Freeze(time.Now())
+}
+
+func (s *ProxySuite) TearDownTest(c *C) {
+	clock.Unfreeze()
+}
+
+// Events channels of groups that stopped consuming are eventually removed,
+// so the map does not grow indefinitely with group churn.
+func (s *ProxySuite) TestEventsChMapBounded(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	ttlSeconds := int(p.eventsChTTL / time.Second)
+
+	// When
+	for i := 0; i < 3*ttlSeconds; i++ {
+		_, err := p.Consume(fmt.Sprintf("g%d", i), "foo", NoAck())
+		c.Assert(err, IsNil)
+		clock.Advance(time.Second)
+		p.sweepEventsChMap()
+	}
+
+	// Then
+	c.Assert(len(p.eventsChMap), Equals, ttlSeconds)
+}
+
+// An events channel that is refreshed by consume or ack requests is not
+// removed.
+func (s *ProxySuite) TestEventsChMapRefreshed(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	_, err := p.Consume("g1", "foo", NoAck())
+	c.Assert(err, IsNil)
+
+	// When
+	clock.Advance(p.eventsChTTL - time.Second)
+	_, err = p.Consume("g1", "foo", NoAck())
+	c.Assert(err, IsNil)
+	clock.Advance(p.eventsChTTL - time.Second)
+	p.sweepEventsChMap()
+
+	// Then
+	c.Assert(len(p.eventsChMap), Equals, 1)
+	c.Assert(p.Ack("g1", "foo", Ack{partition: 0, offset: 1}), IsNil)
+
+	// When
+	clock.Advance(p.eventsChTTL - time.Second)
+	p.sweepEventsChMap()
+
+	// Then
+	c.Assert(len(p.eventsChMap), Equals, 1)
+	c.Assert(p.AckRange("g1", "foo", 0, 1), IsNil)
+
+	// When
+	clock.Advance(p.eventsChTTL + time.Second)
+	p.sweepEventsChMap()
+
+	// Then
+	c.Assert(len(p.eventsChMap), Equals, 0)
+	c.Assert(p.Ack("g1", "foo", Ack{partition: 0, offset: 1}), ErrorMatches, "acks channel missing for .*")
+}
+
+// Messages with the same key and value as one recently consumed by the same
+// group from the same topic are flagged as likely duplicates.
+func (s *ProxySuite) TestLikelyDuplicate(c *C) {
+	s.cfg.Consumer.DedupCacheSize = 2
+	s.cfg.Consumer.DedupWindow = time.Minute
+	p := s.newProxy(&fakeConsumer{})
+	p.dedupCache = newDedupCache(s.cfg.Consumer.DedupCacheSize, s.cfg.Consumer.DedupWindow)
+	eventsCh := make(chan consumer.Event, 10)
+	isDup := func(group, topic, key, value string) bool {
+		msg := consumer.Message{Topic: topic, Value: []byte(value), EventsCh: eventsCh}
+		if key != "" {
+			msg.Key = []byte(key)
+		}
+		p.trackMsg(group, &msg, false)
+		return msg.LikelyDuplicate
+	}
+
+	c.Assert(isDup("g1", "foo", "k1", "v1"), Equals, false)
+	c.Assert(isDup("g1", "foo", "k1", "v1"), Equals, true)
+	// Different value, group, topic or no key at all.
+	c.Assert(isDup("g1", "foo", "k1", "v2"), Equals, false)
+	c.Assert(isDup("g2", "foo", "k1", "v2"), Equals, false)
+	c.Assert(isDup("g1", "bar", "k1", "v2"), Equals, false)
+	c.Assert(isDup("g1", "foo", "", "v3"), Equals, false)
+	c.Assert(isDup("g1", "foo", "", "v3"), Equals, false)
+	// The cache holds 2 entries, so g1/foo/k1 has been evicted by now.
+	c.Assert(isDup("g1", "foo", "k1", "v2"), Equals, false)
+	// Entries expire after the dedup window.
+	clock.Advance(time.Minute)
+	c.Assert(isDup("g1", "foo", "k1", "v2"), Equals, false)
+	c.Assert(p.dedupCache.lru.Len(), Equals, 1)
+}
+
+// Active subscriptions are reported with the number of partitions consumed
+// from and the time of the last consumed message.
+func (s *ProxySuite) TestListActiveSubscriptions(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	eventsCh := make(chan consumer.Event, 10)
+	startedAt := clock.Now()
+	p.trackMsg("g2", &consumer.Message{Topic: "foo", Partition: 0, EventsCh: eventsCh}, false)
+	p.trackMsg("g1", &consumer.Message{Topic: "foo", Partition: 0, EventsCh: eventsCh}, false)
+	clock.Advance(time.Second)
+	p.trackMsg("g1", &consumer.Message{Topic: "foo", Partition: 1, EventsCh: eventsCh}, false)
+	p.trackMsg("g1", &consumer.Message{Topic: "bar", Partition: 3, EventsCh: eventsCh}, false)
+	p.trackMsg("g1", &consumer.Message{Topic: "foo", Partition: 0, EventsCh: eventsCh}, false)
+
+	// When
+	subscriptions := p.ListActiveSubscriptions()
+
+	// Then
+	c.Assert(subscriptions, DeepEquals, []SubscriptionInfo{
+		{Group: "g1", Topic: "bar", Partitions: 1, LastConsumedAt: startedAt.Add(time.Second)},
+		{Group: "g1", Topic: "foo", Partitions: 2, LastConsumedAt: startedAt.Add(time.Second)},
+		{Group: "g2", Topic: "foo", Partitions: 1, LastConsumedAt: startedAt},
+	})
+
+	// When
+	clock.Advance(p.eventsChTTL)
+	p.sweepEventsChMap()
+
+	// Then
+	c.Assert(p.ListActiveSubscriptions(), DeepEquals, []SubscriptionInfo{
+		{Group: "g1", Topic: "bar", Partitions: 1, LastConsumedAt: startedAt.Add(time.Second)},
+		{Group: "g1", Topic: "foo", Partitions: 2, LastConsumedAt: startedAt.Add(time.Second)},
+	})
+}
+
+// If access logging is configured, then consume requests are recorded in JSON
+// along with their outcome.
+func (s *ProxySuite) TestConsumeAccessLog(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	p.cluster = "c1"
+	var buf bytes.Buffer
+	p.accessLog = log.New()
+	p.accessLog.Out = &buf
+	p.accessLog.Formatter = &log.JSONFormatter{}
+
+	// When
+	_, err := p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{ClientID: "svc1"})
+	c.Assert(err, IsNil)
+	_, err = p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{InitialOffset: 7})
+	c.Assert(err, NotNil)
+
+	// Then
+	decoder := json.NewDecoder(&buf)
+	var record map[string]interface{}
+	c.Assert(decoder.Decode(&record), IsNil)
+	c.Assert(record["msg"], Equals, "consume")
+	c.Assert(record["cluster"], Equals, "c1")
+	c.Assert(record["kafka.group"], Equals, "g1")
+	c.Assert(record["kafka.topic"], Equals, "foo")
+	c.Assert(record["kafka.partition"], Equals, float64(0))
+	c.Assert(record["kafka.offset"], Equals, float64(1))
+	c.Assert(record["client_id"], Equals, "svc1")
+	c.Assert(record["result"], Equals, "ok")
+	c.Assert(record["latency_ms"], Equals, float64(0))
+
+	record = nil
+	c.Assert(decoder.Decode(&record), IsNil)
+	c.Assert(record["result"], Equals, "bad initial offset: 7")
+	c.Assert(record["kafka.offset"], IsNil)
+	c.Assert(record["client_id"], IsNil)
+}
+
+// Consume requests of a client in excess of `consumer.max_in_flight_per_client`
+// are rejected, while other clients are not affected. Requests without a
+// client ID share one limit.
+func (s *ProxySuite) TestMaxInFlightPerClient(c *C) {
+	s.cfg.Consumer.MaxInFlightPerClient = 2
+	p := s.newProxy(&fakeConsumer{})
+	c.Assert(p.acquireInFlight("svc1"), Equals, true)
+	c.Assert(p.acquireInFlight("svc1"), Equals, true)
+	c.Assert(p.acquireInFlight(""), Equals, true)
+
+	// When
+	_, err1 := p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{ClientID: "svc1"})
+	_, err2 := p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{ClientID: "svc2"})
+	_, err3 := p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{})
+	_, err4 := p.ConsumeBatch("g1", "foo", 1, time.Second, NoAck(), "svc1")
+	_, err5 := p.ConsumeTopics("g1", []string{"foo"}, NoAck(), "svc1")
+	_, err6 := p.ConsumePattern("g1", "fo.", NoAck(), "svc1")
+	c.Assert(p.acquireInFlight(""), Equals, true)
+	_, err7 := p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{})
+
+	// Then
+	c.Assert(err1, Equals, ErrTooManyRequests)
+	c.Assert(err2, IsNil)
+	c.Assert(err3, IsNil)
+	c.Assert(err4, Equals, ErrTooManyRequests)
+	c.Assert(err5, Equals, ErrTooManyRequests)
+	c.Assert(err6, Equals, ErrTooManyRequests)
+	c.Assert(err7, Equals, ErrTooManyRequests)
+	c.Assert(p.inFlight, DeepEquals, map[string]int{"svc1": 2, "": 2})
+	p.releaseInFlight("")
+	p.releaseInFlight("")
+
+	// Once a request completes another one can be made.
+	p.releaseInFlight("svc1")
+	_, err := p.ConsumeWithOpts(context.Background(), "g1", "foo", NoAck(), ConsumeOpts{ClientID: "svc1"})
+	c.Assert(err, IsNil)
+	p.releaseInFlight("svc1")
+	c.Assert(p.inFlight, DeepEquals, map[string]int{})
+}
+
+// Messages are polled from all assigned partitions starting at given offsets.
+func (s *ProxySuite) TestAssignPartitions(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("foo", 1, broker1.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 0).
+			SetOffset("foo", 0, sarama.OffsetNewest, 10).
+			SetOffset("foo", 1, sarama.OffsetOldest, 0).
+			SetOffset("foo", 1, sarama.OffsetNewest, 10),
+		"FetchRequest": sarama.NewMockFetchResponse(c, 1).
+			SetMessage("foo", 0, 3, sarama.StringEncoder("m0")).
+			SetMessage("foo", 0, 5, sarama.StringEncoder("m1")).
+			SetMessage("foo", 1, 7, sarama.StringEncoder("m2")).
+			SetHighWaterMark("foo", 0, 10).
+			SetHighWaterMark("foo", 1, 10),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	a, err := p.AssignPartitions("foo", []int32{0, 1}, map[int32]int64{0: 5, 1: 7})
+	c.Assert(err, IsNil)
+	defer a.Close()
+
+	// Then
+	consumed := make(map[int32]int64)
+	for i := 0; i < 2; i++ {
+		msg, err := a.Poll()
+		c.Assert(err, IsNil)
+		consumed[msg.Partition] = msg.Offset
+	}
+	c.Assert(consumed, DeepEquals, map[int32]int64{0: 5, 1: 7})
+}
+
+// GetMessage returns the message at the exact offset, and fails with
+// ErrMessageNotFound for offsets out of the partition range and for offsets
+// removed by compaction.
+func (s *ProxySuite) TestGetMessage(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 3).
+			SetOffset("foo", 0, sarama.OffsetNewest, 6),
+		"FetchRequest": sarama.NewMockFetchResponse(c, 1).
+			SetMessage("foo", 0, 3, sarama.StringEncoder("m0")).
+			SetMessage("foo", 0, 5, sarama.StringEncoder("m1")).
+			SetHighWaterMark("foo", 0, 6),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	msg, err := p.GetMessage("foo", 0, 5)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(string(msg.Value), Equals, "m1")
+	c.Assert(msg.Offset, Equals, int64(5))
+	for _, offset := range []int64{2, 4, 6} {
+		_, err = p.GetMessage("foo", 0, offset)
+		c.Assert(errors.Cause(err), Equals, ErrMessageNotFound, Commentf("offset=%d", offset))
+	}
+}
+
+// If all messages from the requested offset up to the high water mark were
+// removed by compaction, then GetMessage fails with ErrMessageNotFound rather
+// than with a long polling timeout.
+func (s *ProxySuite) TestGetMessageCompactedTail(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 3).
+			SetOffset("foo", 0, sarama.OffsetNewest, 6),
+		"FetchRequest": sarama.NewMockFetchResponse(c, 1).
+			SetMessage("foo", 0, 3, sarama.StringEncoder("m0")).
+			SetHighWaterMark("foo", 0, 6),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	s.cfg.Consumer.LongPollingTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	_, err = p.GetMessage("foo", 0, 4)
+
+	// Then
+	c.Assert(errors.Cause(err), Equals, ErrMessageNotFound)
+	c.Assert(err.Error(), Equals, "offset 4 was compacted: message not found")
+}
+
+// ConsumePartition returns the first message at or after the offset, waits
+// for one to be produced at the end of the partition, and rejects offsets
+// out of the partition range.
+func (s *ProxySuite) TestConsumePartition(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 3).
+			SetOffset("foo", 0, sarama.OffsetNewest, 6),
+		"FetchRequest": sarama.NewMockFetchResponse(c, 1).
+			SetMessage("foo", 0, 3, sarama.StringEncoder("m0")).
+			SetMessage("foo", 0, 5, sarama.StringEncoder("m1")).
+			SetHighWaterMark("foo", 0, 6),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	s.cfg.Consumer.LongPollingTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	msg1, err1 := p.ConsumePartition("foo", 0, 3)
+	msg2, err2 := p.ConsumePartition("foo", 0, 4)
+	_, err3 := p.ConsumePartition("foo", 0, 6)
+	_, err4 := p.ConsumePartition("foo", 0, 7)
+
+	// Then
+	c.Assert(err1, IsNil)
+	c.Assert(string(msg1.Value), Equals, "m0")
+	c.Assert(msg1.Offset, Equals, int64(3))
+	c.Assert(msg1.HighWaterMark, Equals, int64(6))
+	c.Assert(err2, IsNil)
+	c.Assert(string(msg2.Value), Equals, "m1")
+	c.Assert(msg2.Offset, Equals, int64(5))
+	c.Assert(err3, Equals, consumer.ErrRequestTimeout)
+	c.Assert(err4, Equals, sarama.ErrOffsetOutOfRange)
+}
+
+// A probe succeeds if the heartbeat it produced is read back from the offset
+// it was produced to, and fails otherwise. Either outcome is counted in probe
+// metrics.
+func (s *ProxySuite) TestProbe(c *C) {
+	s.cfg.ClientID = "pixy1"
+	heartbeat := fmt.Sprintf("pixy1@%d", clock.Now().UnixNano())
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	handlers := map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 0).
+			SetOffset("foo", 0, sarama.OffsetNewest, 1),
+		"FetchRequest": sarama.NewMockFetchResponse(c, 1).
+			SetMessage("foo", 0, 0, sarama.StringEncoder(heartbeat)).
+			SetHighWaterMark("foo", 0, 1),
+	}
+	broker1.SetHandlerByMap(handlers)
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.RetryMax = 0
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	s.cfg.Consumer.LongPollingTimeout = 100 * time.Millisecond
+	s.cfg.Monitoring.Probe.Topic = "foo"
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+	p.probeMetrics = metrics.NewRegistry()
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.producer.Stop()
+	counter := func(name string) int64 {
+		return p.ProbeMetrics().Get(name).(metrics.Counter).Count()
+	}
+
+	// When
+	p.probeAndReport()
+
+	// Then
+	c.Assert(counter(metricProbeSuccesses), Equals, int64(1))
+	c.Assert(p.ProbeMetrics().Get(metricProbeFailures), IsNil)
+	c.Assert(p.ProbeMetrics().Get(metricProbeRoundTrip).(metrics.Gauge).Value(), Equals, int64(0))
+
+	// When: something other than the heartbeat is read back.
+	handlers["FetchRequest"] = sarama.NewMockFetchResponse(c, 1).
+		SetMessage("foo", 0, 0, sarama.StringEncoder("bar")).
+		SetHighWaterMark("foo", 0, 1)
+	broker1.SetHandlerByMap(handlers)
+	p.probeAndReport()
+
+	// Then
+	c.Assert(counter(metricProbeSuccesses), Equals, int64(1))
+	c.Assert(counter(metricProbeFailures), Equals, int64(1))
+
+	// When: the heartbeat cannot be produced.
+	handlers["ProduceRequest"] = sarama.NewMockProduceResponse(c).
+		SetError("foo", 0, sarama.ErrNotEnoughReplicas)
+	broker1.SetHandlerByMap(handlers)
+	p.probeAndReport()
+
+	// Then
+	c.Assert(counter(metricProbeSuccesses), Equals, int64(1))
+	c.Assert(counter(metricProbeFailures), Equals, int64(2))
+}
+
+// If none of the brokers known to the client is connected, then Health makes
+// its own connection to check if Kafka is reachable, leaving brokers of the
+// client alone.
+func (s *ProxySuite) TestHealthProbeConnection(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	hs := p.Health()
+
+	// Then
+	c.Assert(hs.KafkaConnected, Equals, true)
+	c.Assert(hs.ConsumerUp, Equals, true)
+	c.Assert(hs.ProducerUp, Equals, false)
+	c.Assert(hs.ControllerID, Equals, int32(-1))
+	for _, broker := range kafkaClt.Brokers() {
+		connected, _ := broker.Connected()
+		c.Assert(connected, Equals, false)
+	}
+
+	// When
+	broker1.Close()
+	hs = p.Health()
+
+	// Then
+	c.Assert(hs.KafkaConnected, Equals, false)
+}
+
+// CommitOffset commits the offset and metadata of a single partition to the
+// group coordinator.
+func (s *ProxySuite) TestCommitOffset(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("foo", 1, broker1.BrokerID()),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(c),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.admin, err = admin.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.admin.Stop()
+
+	// When
+	err = p.CommitOffset("g1", "foo", 1, 42, "bar")
+
+	// Then
+	c.Assert(err, IsNil)
+	var commitRq *sarama.OffsetCommitRequest
+	for _, rr := range broker1.History() {
+		if rq, ok := rr.Request.(*sarama.OffsetCommitRequest); ok {
+			commitRq = rq
+		}
+	}
+	c.Assert(commitRq, NotNil)
+	c.Assert(commitRq.ConsumerGroup, Equals, "g1")
+	offset, metadata, err := commitRq.Offset("foo", 1)
+	c.Assert(err, IsNil)
+	c.Assert(offset, Equals, int64(42))
+	stamp, ok := offsetmgr.DecodeAdminStamp(metadata)
+	c.Assert(ok, Equals, true)
+	c.Assert(stamp.Meta, Equals, "bar")
+	_, _, err = commitRq.Offset("foo", 0)
+	c.Assert(err, NotNil)
+}
+
+// Partitions assigned with end offsets are completed once all messages
+// preceding their end offsets are polled.
+func (s *ProxySuite) TestAssignPartitionsEndOffsets(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("foo", 1, broker1.BrokerID()).
+			SetLeader("foo", 2, broker1.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 0).
+			SetOffset("foo", 0, sarama.OffsetNewest, 10).
+			SetOffset("foo", 1, sarama.OffsetOldest, 0).
+			SetOffset("foo", 1, sarama.OffsetNewest, 10).
+			SetOffset("foo", 2, sarama.OffsetOldest, 0).
+			SetOffset("foo", 2, sarama.OffsetNewest, 10),
+		"FetchRequest": sarama.NewMockFetchResponse(c, 1).
+			SetMessage("foo", 0, 3, sarama.StringEncoder("m0")).
+			SetMessage("foo", 0, 4, sarama.StringEncoder("m1")).
+			SetMessage("foo", 0, 5, sarama.StringEncoder("m2")).
+			SetMessage("foo", 1, 7, sarama.StringEncoder("m3")).
+			SetMessage("foo", 1, 8, sarama.StringEncoder("m4")).
+			SetHighWaterMark("foo", 0, 10).
+			SetHighWaterMark("foo", 1, 10).
+			SetHighWaterMark("foo", 2, 10),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	a, err := p.AssignPartitionsWithOpts("foo", []int32{0, 1, 2}, AssignOpts{
+		StartOffsets: map[int32]int64{0: 3, 1: 7, 2: 4},
+		EndOffsets:   map[int32]int64{0: 5, 1: 8, 2: 4},
+	})
+	c.Assert(err, IsNil)
+	defer a.Close()
+
+	// Then
+	var consumed []string
+	for {
+		msg, err := a.Poll()
+		if err == ErrAssignmentCompleted {
+			break
+		}
+		c.Assert(err, IsNil)
+		consumed = append(consumed, fmt.Sprintf("%d:%d", msg.Partition, msg.Offset))
+	}
+	sort.Strings(consumed)
+	c.Assert(consumed, DeepEquals, []string{"0:3", "0:4", "1:7"})
+	c.Assert(a.Completed(), DeepEquals, []int32{0, 1, 2})
+}
+
+func (s *ProxySuite) TestAssignPartitionsInvalid(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+
+	_, err := p.AssignPartitions("foo", nil, nil)
+	c.Assert(err.Error(), Equals, "no partitions to assign")
+
+	_, err = p.AssignPartitions("foo", []int32{1, 2, 1}, nil)
+	c.Assert(err.Error(), Equals, "partition 1 assigned more than once")
+
+	_, err = p.AssignPartitions("foo", []int32{1}, map[int32]int64{2: 0})
+	c.Assert(err.Error(), Equals, "start offset given for unassigned partition 2")
+
+	_, err = p.AssignPartitionsWithOpts("foo", []int32{1}, AssignOpts{EndOffsets: map[int32]int64{2: 0}})
+	c.Assert(err.Error(), Equals, "end offset given for unassigned partition 2")
+}
+
+// If `producer.validate_topic` is enabled, then produce to a topic that does
+// not exist fails early.
+func (s *ProxySuite) TestValidateTopic(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	s.cfg.Producer.ValidateTopic = true
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	_, _, err1 := p.ProduceWithOpts(context.Background(), "bar", nil, sarama.StringEncoder("m"), ProduceOpts{})
+	err2 := p.AsyncProduceBounded(context.Background(), "bar", nil, sarama.StringEncoder("m"), "")
+	_, _, err3 := p.ProduceWithOpts(context.Background(), "foo", nil, sarama.StringEncoder("m"), ProduceOpts{})
+
+	// Then
+	c.Assert(err1, Equals, ErrTopicNotFound{Topic: "bar"})
+	c.Assert(err1.Error(), Equals, "topic bar does not exist")
+	c.Assert(err2, Equals, ErrTopicNotFound{Topic: "bar"})
+	// The topic exists, so the message is submitted to the producer that is
+	// not running in this test.
+	c.Assert(err3, Equals, ErrUnavailable)
+	// Only metadata of the missing topic was requested, and only once. The
+	// first request is made by NewClient.
+	history := broker1.History()
+	c.Assert(len(history), Equals, 2)
+	c.Assert(history[1].Request.(*sarama.MetadataRequest).Topics, DeepEquals, []string{"bar"})
+
+	// When the missing topic is forgotten, then it is looked up again.
+	clock.Advance(missingTopicTTL + time.Second)
+	p.sweepMissingTopics()
+	c.Assert(len(p.missingTopics), Equals, 0)
+	err4 := p.AsyncProduceBounded(context.Background(), "bar", nil, sarama.StringEncoder("m"), "")
+	c.Assert(err4, Equals, ErrTopicNotFound{Topic: "bar"})
+	c.Assert(len(broker1.History()), Equals, 3)
+}
+
+// If Kafka does not acknowledge a message within `producer.produce_timeout`,
+// then ErrProduceTimeout is returned rather than blocking indefinitely.
+func (s *ProxySuite) TestProduceTimeout(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.ProduceTimeout = 200 * time.Millisecond
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.producer.Stop()
+	// The broker is slow to respond, as if it was stuck.
+	broker1.SetLatency(time.Second)
+
+	// When
+	begin := time.Now()
+	_, _, err1 := p.ProduceWithOpts(context.Background(), "foo", nil, sarama.StringEncoder("m"), ProduceOpts{})
+	results, err2 := p.ProduceBatch("foo", []ProduceReq{{Message: sarama.StringEncoder("m1")}, {Message: sarama.StringEncoder("m2")}})
+
+	// Then
+	c.Assert(err1, Equals, ErrProduceTimeout)
+	c.Assert(err2, IsNil)
+	c.Assert(results[0].Err, Equals, ErrProduceTimeout)
+	c.Assert(results[1].Err, Equals, ErrProduceTimeout)
+	c.Assert(time.Since(begin) < 2*time.Second, Equals, true)
+}
+
+// Results of a batch are returned in the order of the respective requests,
+// and a message that fails does not affect the others.
+func (s *ProxySuite) TestProduceBatch(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.MaxMessageBytes = 100
+	s.cfg.Producer.RetryMax = 0
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.producer.Stop()
+
+	// When
+	results, err := p.ProduceBatch("foo", []ProduceReq{
+		{Message: sarama.StringEncoder("m1")},
+		{Message: sarama.ByteEncoder(make([]byte, 200))},
+		{Key: sarama.StringEncoder("k3"), Message: sarama.StringEncoder("m3")},
+	})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(len(results), Equals, 3)
+	c.Assert(results[0].Err, IsNil)
+	c.Assert(errors.Cause(results[1].Err), Equals, ErrMessageTooLarge)
+	c.Assert(results[2].Err, IsNil)
+
+	// When
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c).
+			SetError("foo", 0, sarama.ErrNotEnoughReplicas),
+	})
+	results, err = p.ProduceBatch("foo", []ProduceReq{{Message: sarama.StringEncoder("m4")}})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(results[0].Err, Equals, ErrNotEnoughReplicas{})
+}
+
+// Produce latency is reported overall and per topic, and produce errors are
+// counted overall and per Kafka error code.
+func (s *ProxySuite) TestProduceMetrics(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("bar", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c).
+			SetError("bar", 0, sarama.ErrNotEnoughReplicas),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.RetryMax = 0
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.producer.Stop()
+
+	// When
+	_, err1 := p.Produce("foo", nil, sarama.StringEncoder("m1"))
+	_, err2 := p.Produce("foo", nil, sarama.StringEncoder("m2"))
+	_, err3 := p.Produce("bar", nil, sarama.StringEncoder("m3"))
+
+	// Then
+	c.Assert(err1, IsNil)
+	c.Assert(err2, IsNil)
+	c.Assert(err3, Equals, ErrNotEnoughReplicas{})
+	registry, err := p.ProducerMetrics()
+	c.Assert(err, IsNil)
+	histogram := func(name string) int64 {
+		return registry.Get(name).(metrics.Histogram).Count()
+	}
+	counter := func(name string) int64 {
+		return registry.Get(name).(metrics.Counter).Count()
+	}
+	c.Assert(histogram("produce-latency-in-ms"), Equals, int64(3))
+	c.Assert(histogram("produce-latency-in-ms-for-topic-foo"), Equals, int64(2))
+	c.Assert(histogram("produce-latency-in-ms-for-topic-bar"), Equals, int64(1))
+	c.Assert(counter("produce-errors"), Equals, int64(1))
+	c.Assert(counter("produce-errors-for-code-19"), Equals, int64(1))
+}
+
+// Produce to an explicit partition surfaces not enough in-sync replicas
+// errors as ErrNotEnoughReplicas, just like produce to a partition chosen by
+// the partitioner.
+func (s *ProxySuite) TestProduceToPartitionError(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("foo", 1, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c).
+			SetError("foo", 1, sarama.ErrNotEnoughReplicasAfterAppend),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.RetryMax = 0
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.producer.Stop()
+
+	// When
+	prodMsg1, err1 := p.ProduceToPartition("foo", 0, nil, sarama.StringEncoder("m1"))
+	_, err2 := p.ProduceToPartition("foo", 1, nil, sarama.StringEncoder("m2"))
+
+	// Then
+	c.Assert(err1, IsNil)
+	c.Assert(prodMsg1.Partition, Equals, int32(0))
+	c.Assert(err2, Equals, ErrNotEnoughReplicas{AfterAppend: true})
+}
+
+// A retry max override can only lower the configured number of retries, and
+// variant producers spawned for overrides are stopped when idle.
+func (s *ProxySuite) TestProduceRetryMax(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c).
+			SetError("foo", 0, sarama.ErrNotEnoughReplicas),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.RetryMax = 2
+	s.cfg.Producer.RetryBackoff = 10 * time.Millisecond
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.stopProducer()
+	produceRqCount := func() int {
+		count := 0
+		for _, rr := range broker1.History() {
+			if _, ok := rr.Request.(*sarama.ProduceRequest); ok {
+				count++
+			}
+		}
+		return count
+	}
+	retryMax := func(n int) ProduceOpts { return ProduceOpts{RetryMax: &n} }
+
+	// When/Then: a larger number is clamped to the configured one.
+	_, _, err = p.ProduceWithOpts(context.Background(), "foo", nil, sarama.StringEncoder("m1"), retryMax(5))
+	c.Assert(err, Equals, ErrNotEnoughReplicas{})
+	c.Assert(produceRqCount(), Equals, 3)
+	c.Assert(len(p.variantProducers), Equals, 0)
+
+	// When/Then: zero makes the request fail on the first error.
+	_, _, err = p.ProduceWithOpts(context.Background(), "foo", nil, sarama.StringEncoder("m2"), retryMax(0))
+	c.Assert(err, Equals, ErrNotEnoughReplicas{})
+	c.Assert(produceRqCount(), Equals, 4)
+	c.Assert(len(p.variantProducers), Equals, 1)
+
+	// When/Then: an idle variant producer is stopped.
+	clock.Advance(variantProducerIdleTimeout - time.Second)
+	p.sweepVariantProducers()
+	c.Assert(len(p.variantProducers), Equals, 1)
+	clock.Advance(2 * time.Second)
+	p.sweepVariantProducers()
+	c.Assert(len(p.variantProducers), Equals, 0)
+}
+
+// A range ack is reported to the events channel of its partition.
+func (s *ProxySuite) TestAckRange(c *C) {
+	fc := &fakeConsumer{}
+	p := s.newProxy(fc)
+	msg, err := p.Consume("g1", "foo", NoAck())
+	c.Assert(err, IsNil)
+
+	// When
+	err = p.AckRange("g1", "foo", msg.Partition, msg.Offset)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(<-fc.eventsChs[0], Equals, consumer.AckUpTo(msg.Offset))
+	c.Assert(p.AckRange("g2", "foo", 0, 1), ErrorMatches, "acks channel missing for .*")
+	c.Assert(p.AckRange("g1", "foo", 0, -1), ErrorMatches, "bad offset: -1")
+}
+
+// A released message is reported to the events channel of its partition.
+func (s *ProxySuite) TestRelease(c *C) {
+	fc := &fakeConsumer{}
+	p := s.newProxy(fc)
+	msg, err := p.Consume("g1", "foo", NoAck())
+	c.Assert(err, IsNil)
+
+	// When
+	err = p.Release("g1", "foo", msg.Partition, msg.Offset)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(<-fc.eventsChs[0], Equals, consumer.Release(msg.Offset))
+	c.Assert(p.Release("g2", "foo", 0, 1), ErrorMatches, "acks channel missing for .*")
+}
+
+// ConsumeTopics returns messages from all listed topics, regardless of their
+// order and duplicates in the list.
+func (s *ProxySuite) TestConsumeTopics(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+
+	// When
+	consumed := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		topics := []string{"foo", "bar", "baz", "foo"}
+		if i > 0 {
+			topics = []string{"baz", "bar", "foo"}
+			s.waitStashed(c, p, patternStashID{"g1", "topics:bar,baz,foo"}, 3-i)
+		}
+		msg, err := p.ConsumeTopics("g1", topics, NoAck(), "")
+		c.Assert(err, IsNil)
+		consumed[msg.Topic] = true
+	}
+
+	// Then
+	c.Assert(consumed, DeepEquals, map[string]bool{"foo": true, "bar": true, "baz": true})
+	_, err := p.ConsumeTopics("g1", nil, NoAck(), "")
+	c.Assert(err.Error(), Equals, "no topics to consume")
+	_, err = p.ConsumeTopics("g1", []string{"foo"}, Ack{partition: 0, offset: 1}, "")
+	c.Assert(err.Error(), Equals, "ack topic is not specified")
+	_, err = p.ConsumeTopics("g1", []string{"foo", "bar"}, Ack{partition: 0, offset: 1}.WithTopic("baz"), "")
+	c.Assert(err.Error(), Equals, "ack topic baz is not among consumed topics")
+	_, err = p.ConsumePattern("g1", "foo|bar", Ack{partition: 0, offset: 1}.WithTopic("baz"), "")
+	c.Assert(err.Error(), Equals, "ack topic baz does not match the pattern")
+}
+
+// Consumption of a topic list, of a pattern and of a single topic is rate
+// limited separately, even if the list, the pattern and the topic are
+// spelled the same.
+func (s *ProxySuite) TestConsumeMultiKeys(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+	})
+	kafkaClt, err := sarama.NewClient([]string{broker1.Addr()}, nil)
+	c.Assert(err, IsNil)
+	defer kafkaClt.Close()
+	s.cfg.Consumer.RateLimit = 1
+	s.cfg.Consumer.RateLimitBurst = 1
+	p := s.newProxy(&fakeConsumer{})
+	p.kafkaClt = kafkaClt
+
+	// When
+	_, err1 := p.ConsumeTopics("g1", []string{"foo"}, NoAck(), "")
+	_, err2 := p.ConsumePattern("g1", "foo", NoAck(), "")
+	_, err3 := p.Consume("g1", "foo", NoAck())
+	_, err4 := p.ConsumePattern("g1", "foo", NoAck(), "")
+
+	// Then
+	c.Assert(err1, IsNil)
+	c.Assert(err2, IsNil)
+	c.Assert(err3, IsNil)
+	c.Assert(err4, Equals, ErrRateLimited)
+}
+
+// Messages fetched from several topics at once are stashed and returned by
+// subsequent calls.
+func (s *ProxySuite) TestConsumeAnyStashesExcessMsgs(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	stashID := patternStashID{"g1", "foo|bar|baz"}
+
+	// When
+	msg, err := p.consumeAny("g1", []string{"foo", "bar", "baz"}, stashID)
+
+	// Then
+	c.Assert(err, IsNil)
+	consumed := map[string]bool{msg.Topic: true}
+	s.waitStashed(c, p, stashID, 2)
+	for i := 0; i < 2; i++ {
+		msg, ok := p.popStashedMsg(stashID)
+		c.Assert(ok, Equals, true)
+		consumed[msg.Topic] = true
+	}
+	c.Assert(consumed, DeepEquals, map[string]bool{"foo": true, "bar": true, "baz": true})
+	_, ok := p.popStashedMsg(stashID)
+	c.Assert(ok, Equals, false)
+	c.Assert(len(p.patternStash), Equals, 0)
+}
+
+// Stashed messages are discarded after the ack timeout, for by then they are
+// offered by the consumer again.
+func (s *ProxySuite) TestStashedMsgsExpire(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	stashID1 := patternStashID{"g1", "foo|bar"}
+	stashID2 := patternStashID{"g2", "foo|bar"}
+	_, err := p.consumeAny("g1", []string{"foo", "bar"}, stashID1)
+	c.Assert(err, IsNil)
+	_, err = p.consumeAny("g2", []string{"foo", "bar"}, stashID2)
+	c.Assert(err, IsNil)
+	s.waitStashed(c, p, stashID1, 1)
+	s.waitStashed(c, p, stashID2, 1)
+
+	// When
+	clock.Advance(p.cfg.Consumer.AckTimeout)
+	_, ok := p.popStashedMsg(stashID1)
+	p.sweepPatternStash()
+
+	// Then
+	c.Assert(ok, Equals, false)
+	c.Assert(len(p.patternStash), Equals, 0)
+}
+
+// Once a group exceeds the rate limit for a topic, consume requests are
+// rejected until the bucket is refilled. Other topics are not affected.
+func (s *ProxySuite) TestConsumeRateLimited(c *C) {
+	s.cfg.Consumer.RateLimit = 2
+	s.cfg.Consumer.RateLimitBurst = 3
+	p := s.newProxy(&fakeConsumer{})
+	for i := 0; i < 3; i++ {
+		_, err := p.Consume("g1", "foo", NoAck())
+		c.Assert(err, IsNil)
+	}
+
+	// When/Then
+	_, err := p.Consume("g1", "foo", NoAck())
+	c.Assert(err, Equals, ErrRateLimited)
+	_, err = p.Consume("g1", "bar", NoAck())
+	c.Assert(err, IsNil)
+	_, err = p.Consume("g2", "foo", NoAck())
+	c.Assert(err, IsNil)
+
+	clock.Advance(500 * time.Millisecond)
+	_, err = p.Consume("g1", "foo", NoAck())
+	c.Assert(err, IsNil)
+	_, err = p.Consume("g1", "foo", NoAck())
+	c.Assert(err, Equals, ErrRateLimited)
+
+	// The bucket is refilled up to the burst size only.
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		_, err := p.Consume("g1", "foo", NoAck())
+		c.Assert(err, IsNil)
+	}
+	_, err = p.Consume("g1", "foo", NoAck())
+	c.Assert(err, Equals, ErrRateLimited)
+}
+
+// Token buckets of idle group/topics are eventually removed.
+func (s *ProxySuite) TestTokenBucketsSwept(c *C) {
+	s.cfg.Consumer.RateLimit = 2
+	p := s.newProxy(&fakeConsumer{})
+	_, err := p.Consume("g1", "foo", NoAck())
+	c.Assert(err, IsNil)
+	clock.Advance(p.eventsChTTL - time.Second)
+	_, err = p.Consume("g2", "foo", NoAck())
+	c.Assert(err, IsNil)
+
+	// When
+	clock.Advance(2 * time.Second)
+	p.sweepTokenBuckets()
+
+	// Then
+	c.Assert(len(p.tokenBuckets), Equals, 1)
+	c.Assert(p.tokenBuckets[tokenBucketID{"g2", "foo"}], NotNil)
+}
+
+// Message timestamps are rejected if the Kafka version does not support them.
+func (s *ProxySuite) TestProduceTimestampUnsupported(c *C) {
+	p := s.newProxy(&fakeConsumer{})
+	opts := ProduceOpts{Timestamp: clock.Now()}
+
+	// When
+	_, _, err := p.ProduceWithOpts(context.Background(), "foo", nil, sarama.StringEncoder("bar"), opts)
+
+	// Then
+	c.Assert(err, Equals, ErrTimestampUnsupported)
+}
+
+func (s *ProxySuite) TestKafkaErrorCode(c *C) {
+	for i, tc := range []struct {
+		err  error
+		code sarama.KError
+		ok   bool
+	}{
+		{err: sarama.ErrNotEnoughReplicas, code: sarama.ErrNotEnoughReplicas, ok: true},
+		{err: errors.Wrap(sarama.ErrMessageSizeTooLarge, "foo"), code: sarama.ErrMessageSizeTooLarge, ok: true},
+		{err: sarama.ErrNoError, code: sarama.ErrNoError, ok: false},
+		{err: ErrUnavailable, code: sarama.ErrNoError, ok: false},
+		{err: nil, code: sarama.ErrNoError, ok: false},
+		{err: ErrNotEnoughReplicas{}, code: sarama.ErrNotEnoughReplicas, ok: true},
+		{err: ErrNotEnoughReplicas{AfterAppend: true}, code: sarama.ErrNotEnoughReplicasAfterAppend, ok: true},
+	} {
+		code, ok := KafkaErrorCode(tc.err)
+		c.Assert(code, Equals, tc.code, Commentf("case #%d", i))
+		c.Assert(ok, Equals, tc.ok, Commentf("case #%d", i))
+	}
+}
+
+// Not enough in-sync replicas errors are surfaced as ErrNotEnoughReplicas,
+// and other errors are returned as is.
+func (s *ProxySuite) TestProduceError(c *C) {
+	for i, tc := range []struct {
+		in  error
+		out error
+	}{
+		{in: sarama.ErrNotEnoughReplicas, out: ErrNotEnoughReplicas{}},
+		{in: sarama.ErrNotEnoughReplicasAfterAppend, out: ErrNotEnoughReplicas{AfterAppend: true}},
+		{in: sarama.ErrMessageSizeTooLarge, out: sarama.ErrMessageSizeTooLarge},
+		{in: ErrUnavailable, out: ErrUnavailable},
+		{in: nil, out: nil},
+	} {
+		c.Assert(produceError(tc.in), Equals, tc.out, Commentf("case #%d", i))
+	}
+}
+
+func (s *ProxySuite) TestParseInitialOffset(c *C) {
+	for i, tc := range []struct {
+		in  string
+		out int64
+	}{
+		{in: "", out: 0},
+		{in: "earliest", out: sarama.OffsetOldest},
+		{in: "latest", out: sarama.OffsetNewest},
+	} {
+		offset, err := ParseInitialOffset(tc.in)
+		c.Assert(err, IsNil, Commentf("case #%d", i))
+		c.Assert(offset, Equals, tc.out, Commentf("case #%d", i))
+	}
+	_, err := ParseInitialOffset("oldest")
+	c.Assert(err.Error(), Equals, "bad initial offset: oldest")
+}
+
+// A set routes requests to proxies by cluster name, and requests that do not
+// specify a cluster to the default proxy.
+func (s *ProxySuite) TestSet(c *C) {
+	pxyA, pxyB := &T{}, &T{}
+	set := NewSet(map[string]*T{"b": pxyB, "a": pxyA}, pxyB)
+
+	pxy, err := set.Get("a")
+	c.Assert(err, IsNil)
+	c.Assert(pxy, Equals, pxyA)
+	pxy, err = set.Get("")
+	c.Assert(err, IsNil)
+	c.Assert(pxy, Equals, pxyB)
+	_, err = set.Get("c")
+	c.Assert(err.Error(), Equals, "proxy `c` does not exist")
+	c.Assert(set.Clusters(), DeepEquals, []string{"a", "b"})
+	c.Assert(set.DefaultCluster(), Equals, "b")
+}
+
+// Consumed messages and errors are counted per client, but long polling
+// timeouts are not considered errors. Clients in excess of the limit are
+// counted together.
+func (s *ProxySuite) TestCountConsumed(c *C) {
+	p := T{
+		consumerMetrics: metrics.NewRegistry(),
+		clientMetrics:   producer.NewClientMetricNames(2),
+	}
+
+	// When
+	p.countConsumed("svc.a", nil)
+	p.countConsumed("svc.a", nil)
+	p.countConsumed("svc.a", consumer.ErrRequestTimeout)
+	p.countConsumed("svc.a", ErrRateLimited)
+	p.countConsumed("svc.b", nil)
+	p.countConsumed("svc.c", nil)
+	p.countConsumed("svc.d", nil)
+
+	// Then
+	counter := func(name string) int64 {
+		return p.ConsumerMetrics().Get(name).(metrics.Counter).Count()
+	}
+	c.Assert(counter("consume-requests-for-client-svc_a"), Equals, int64(4))
+	c.Assert(counter("consume-messages-for-client-svc_a"), Equals, int64(2))
+	c.Assert(counter("consume-errors-for-client-svc_a"), Equals, int64(1))
+	c.Assert(counter("consume-messages-for-client-svc_b"), Equals, int64(1))
+	c.Assert(counter("consume-messages-for-client-other"), Equals, int64(2))
+}
+
+// After the configured number of consecutive failures the circuit breaker of
+// a topic opens for the cooldown period, then lets a single probe through.
+func (s *ProxySuite) TestCircuitBreaker(c *C) {
+	s.cfg.Producer.CircuitBreakerThreshold = 3
+	s.cfg.Producer.CircuitBreakerCooldown = 10 * time.Second
+	p := s.newProxy(&fakeConsumer{})
+	for i := 0; i < 3; i++ {
+		c.Assert(p.allowProduce("foo"), Equals, true)
+		p.reportProduce("foo", sarama.ErrNotLeaderForPartition)
+	}
+
+	// When/Then
+	c.Assert(p.allowProduce("foo"), Equals, false)
+	c.Assert(p.allowProduce("bar"), Equals, true)
+
+	clock.Advance(10 * time.Second)
+	c.Assert(p.allowProduce("foo"), Equals, true)
+	c.Assert(p.allowProduce("foo"), Equals, false) // Probe is in flight.
+
+	// A failed probe opens the breaker for another cooldown.
+	p.reportProduce("foo", sarama.ErrNotLeaderForPartition)
+	c.Assert(p.allowProduce("foo"), Equals, false)
+	clock.Advance(10 * time.Second)
+	c.Assert(p.allowProduce("foo"), Equals, true)
+
+	// A successful probe closes the breaker.
+	p.reportProduce("foo", nil)
+	c.Assert(p.allowProduce("foo"), Equals, true)
+	c.Assert(p.allowProduce("foo"), Equals, true)
+	c.Assert(len(p.circuitBreakers), Equals, 0)
+}
+
+// Failures caused by bad requests and interleaved with successes do not open
+// the circuit breaker.
+func (s *ProxySuite) TestCircuitBreakerConsecutiveFailuresOnly(c *C) {
+	s.cfg.Producer.CircuitBreakerThreshold = 2
+	p := s.newProxy(&fakeConsumer{})
+
+	// When
+	p.reportProduce("foo", sarama.ErrNotLeaderForPartition)
+	p.reportProduce("foo", nil)
+	p.reportProduce("foo", sarama.ErrNotLeaderForPartition)
+	p.reportProduce("foo", sarama.ErrUnknownTopicOrPartition)
+	p.reportProduce("foo", errors.Wrap(ErrMessageTooLarge, "bar"))
+
+	// Then
+	c.Assert(p.allowProduce("foo"), Equals, true)
+}
+
+// Batch and asynchronous produce requests are rejected while the circuit
+// breaker is open, and their failures count towards opening it.
+func (s *ProxySuite) TestCircuitBreakerBatchAndAsync(c *C) {
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	broker1.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(c).
+			SetError("foo", 0, sarama.ErrNotEnoughReplicas),
+	})
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Producer.RetryMax = 0
+	s.cfg.Producer.ShutdownTimeout = 100 * time.Millisecond
+	s.cfg.Producer.CircuitBreakerThreshold = 1
+	s.cfg.Producer.CircuitBreakerCooldown = 10 * time.Second
+	p := s.newProxy(&fakeConsumer{})
+	var err error
+	p.producer, err = producer.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.producer.Stop()
+	cbErrCh := make(chan error, 1)
+	cb := func(_ *sarama.ProducerMessage, err error) { cbErrCh <- err }
+
+	// When: a failed batch opens the breaker.
+	results, err := p.ProduceBatch("foo", []ProduceReq{{Message: sarama.StringEncoder("m1")}})
+	c.Assert(err, IsNil)
+	c.Assert(results[0].Err, Equals, ErrNotEnoughReplicas{})
+
+	// Then
+	_, err = p.ProduceBatch("foo", []ProduceReq{{Message: sarama.StringEncoder("m2")}})
+	c.Assert(err, Equals, ErrCircuitOpen)
+	err = p.AsyncProduceBounded(context.Background(), "foo", nil, sarama.StringEncoder("m3"), "")
+	c.Assert(err, Equals, ErrCircuitOpen)
+	p.AsyncProduceCallback("foo", nil, sarama.StringEncoder("m4"), cb)
+	c.Assert(<-cbErrCh, Equals, ErrCircuitOpen)
+
+	// When: a failed asynchronous probe opens the breaker again.
+	clock.Advance(10 * time.Second)
+	p.AsyncProduceCallback("foo", nil, sarama.StringEncoder("m5"), cb)
+	c.Assert(<-cbErrCh, Equals, ErrNotEnoughReplicas{})
+
+	// Then
+	c.Assert(p.allowProduce("foo"), Equals, false)
+}
+
+// Clients are told to retry transient failures after a delay derived from
+// the respective config parameters.
+func (s *ProxySuite) TestRetryAfter(c *C) {
+	s.cfg.Consumer.RateLimit = 4
+	s.cfg.Consumer.RetryBackoff = 3 * time.Second
+	s.cfg.Producer.CircuitBreakerCooldown = 20 * time.Second
+	s.cfg.Producer.RetryBackoff = 5 * time.Second
+	p := s.newProxy(&fakeConsumer{})
+	for i, tc := range []struct {
+		err        error
+		retryAfter time.Duration
+	}{
+		{err: ErrRateLimited, retryAfter: 250 * time.Millisecond},
+		{err: ErrCircuitOpen, retryAfter: 20 * time.Second},
+		{err: ErrNotEnoughReplicas{AfterAppend: true}, retryAfter: 5 * time.Second},
+		{err: consumer.ErrRebalanceInProgress, retryAfter: 3 * time.Second},
+		{err: errors.Wrap(admin.ErrOwnershipUnstable, "foo"), retryAfter: 3 * time.Second},
+		{err: ErrUnavailable, retryAfter: 0},
+	} {
+		c.Assert(p.RetryAfter(tc.err), Equals, tc.retryAfter, Commentf("case #%d", i))
+	}
+}
+
+// Requested long polling timeouts are clamped to the configured maximum, and
+// the configured timeout is used if none is requested.
+func (s *ProxySuite) TestLongPollingTimeout(c *C) {
+	s.cfg.Consumer.LongPollingTimeout = 3 * time.Second
+	s.cfg.Consumer.MaxLongPollingTimeout = 30 * time.Second
+	p := s.newProxy(&fakeConsumer{})
+
+	c.Assert(p.longPollingTimeout(0), Equals, 3*time.Second)
+	c.Assert(p.longPollingTimeout(-time.Second), Equals, 3*time.Second)
+	c.Assert(p.longPollingTimeout(100*time.Millisecond), Equals, 100*time.Millisecond)
+	c.Assert(p.longPollingTimeout(time.Minute), Equals, 30*time.Second)
+}
+
+// A batch is filled up to the requested number of messages, and all of them
+// are acknowledged in the auto-ack mode.
+func (s *ProxySuite) TestConsumeBatchAutoAck(c *C) {
+	fc := &fakeConsumer{}
+	p := s.newProxy(fc)
+
+	// When
+	msgs, err := p.ConsumeBatch("g1", "foo", 3, time.Second, AutoAck(), "")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(len(msgs), Equals, 3)
+	for i, msg := range msgs {
+		c.Assert(msg.Offset, Equals, int64(i+1))
+		c.Assert(<-fc.eventsChs[i], Equals, consumer.Ack(msg.Offset))
+	}
+}
+
+// If the rate limit is exceeded in the middle of a batch, then messages
+// consumed so far are returned.
+func (s *ProxySuite) TestConsumeBatchRateLimited(c *C) {
+	s.cfg.Consumer.RateLimit = 1
+	s.cfg.Consumer.RateLimitBurst = 2
+	p := s.newProxy(&fakeConsumer{})
+
+	// When
+	msgs, err := p.ConsumeBatch("g1", "foo", 5, time.Second, NoAck(), "")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(len(msgs), Equals, 2)
+
+	// When
+	_, err = p.ConsumeBatch("g1", "foo", 5, time.Second, NoAck(), "")
+
+	// Then
+	c.Assert(err, Equals, ErrRateLimited)
+}
+
+// A group is caught up when its committed offsets reach targets in all
+// partitions that have them. Partitions the group has never committed to
+// are considered caught up only if they are empty.
+func (s *ProxySuite) TestCaughtUp(c *C) {
+	targets := map[int32]int64{0: 10, 1: 10, 2: 10}
+	for i, tc := range []struct {
+		offsets []admin.PartitionOffset
+		result  bool
+	}{{
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: 10}, {Partition: 1, Offset: 12}, {Partition: 2, Offset: 10}},
+		result:  true,
+	}, {
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: 10}, {Partition: 1, Offset: 9}, {Partition: 2, Offset: 10}},
+		result:  false,
+	}, {
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: 10}, {Partition: 1, Offset: sarama.OffsetNewest, Begin: 3}, {Partition: 2, Offset: 10}},
+		result:  false,
+	}, {
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: 10}, {Partition: 1, Offset: sarama.OffsetNewest, Begin: 10}, {Partition: 2, Offset: 10}},
+		result:  true,
+	}, {
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: sarama.OffsetOldest, Begin: 3}, {Partition: 1, Offset: 10}, {Partition: 2, Offset: 10}},
+		result:  false,
+	}, {
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: sarama.OffsetOldest, Begin: 10}, {Partition: 1, Offset: 10}, {Partition: 2, Offset: 10}},
+		result:  true,
+	}, {
+		// Partition 3 was added after targets were taken.
+		offsets: []admin.PartitionOffset{{Partition: 0, Offset: 10}, {Partition: 1, Offset: 10}, {Partition: 2, Offset: 10}, {Partition: 3, Offset: 0, End: 5}},
+		result:  true,
+	}} {
+		c.Assert(caughtUp(tc.offsets, targets), Equals, tc.result, Commentf("case #%d", i))
+	}
+}
+
+// ConsumeUntilCaughtUp waits for the group to commit offsets up to the high
+// water marks, pauses consumption and returns the committed offsets. It gives
+// up when the context is done, and requires the context to have a deadline.
+func (s *ProxySuite) TestConsumeUntilCaughtUp(c *C) {
+	clock.Unfreeze()
+	broker1 := sarama.NewMockBroker(c, 101)
+	defer broker1.Close()
+	handlers := map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(c).
+			SetBroker(broker1.Addr(), broker1.BrokerID()).
+			SetLeader("foo", 0, broker1.BrokerID()).
+			SetLeader("foo", 1, broker1.BrokerID()),
+		"OffsetRequest": sarama.NewMockOffsetResponse(c).
+			SetOffset("foo", 0, sarama.OffsetOldest, 0).
+			SetOffset("foo", 0, sarama.OffsetNewest, 10).
+			SetOffset("foo", 1, sarama.OffsetOldest, 0).
+			SetOffset("foo", 1, sarama.OffsetNewest, 5),
+		"ConsumerMetadataRequest": sarama.NewMockConsumerMetadataResponse(c).
+			SetCoordinator("g1", broker1),
+		// Partition 1 has no committed offset.
+		"OffsetFetchRequest": sarama.NewMockOffsetFetchResponse(c).
+			SetOffset("g1", "foo", 0, 10, "", sarama.ErrNoError).
+			SetOffset("g1", "foo", 1, sarama.OffsetNewest, "", sarama.ErrNoError),
+	}
+	broker1.SetHandlerByMap(handlers)
+	s.cfg.Kafka.SeedPeers = []string{broker1.Addr()}
+	s.cfg.Consumer.OffsetsCommitInterval = 10 * time.Millisecond
+	fc := &fakeConsumer{}
+	p := s.newProxy(fc)
+	var err error
+	p.admin, err = admin.Spawn(s.ns, s.cfg)
+	c.Assert(err, IsNil)
+	defer p.admin.Stop()
+
+	// When
+	_, err1 := p.ConsumeUntilCaughtUp(context.Background(), "g1", "foo")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err2 := p.ConsumeUntilCaughtUp(ctx, "g1", "foo")
+
+	// Then
+	c.Assert(err1.Error(), Equals, "context has no deadline")
+	c.Assert(err2, Equals, context.DeadlineExceeded)
+	c.Assert(fc.paused, IsNil)
+
+	// When: the group catches up while it is waited for.
+	ctx, cancel = context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	caughtUpHandlers := make(map[string]sarama.MockResponse, len(handlers))
+	for name, handler := range handlers {
+		caughtUpHandlers[name] = handler
+	}
+	caughtUpHandlers["OffsetFetchRequest"] = sarama.NewMockOffsetFetchResponse(c).
+		SetOffset("g1", "foo", 0, 10, "", sarama.ErrNoError).
+		SetOffset("g1", "foo", 1, 5, "", sarama.ErrNoError)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		broker1.SetHandlerByMap(caughtUpHandlers)
+	}()
+	final, err := p.ConsumeUntilCaughtUp(ctx, "g1", "foo")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(final, DeepEquals, FinalOffsets{Group: "g1", Topic: "foo", Offsets: map[int32]int64{0: 10, 1: 5}})
+	c.Assert(fc.paused, DeepEquals, []string{"g1/foo"})
+}
+
+func (s *ProxySuite) waitStashed(c *C, p *T, stashID patternStashID, count int) {
+	for i := 0; i < 100; i++ {
+		p.patternStashMu.Lock()
+		stashed := len(p.patternStash[stashID])
+		p.patternStashMu.Unlock()
+		if stashed == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("messages are not stashed: %v", stashID)
+}
+
+func (s *ProxySuite) newProxy(cons consumer.T) *T {
+	return &T{
+		actDesc:         s.ns,
+		cfg:             s.cfg,
+		consumer:        cons,
+		eventsChMap:     make(map[eventsChID]eventsChEntry),
+		eventsChTTL:     eventsChTTL(s.cfg),
+		patternStash:    make(map[patternStashID][]stashedMsg),
+		tokenBuckets:    make(map[tokenBucketID]*tokenBucket),
+		circuitBreakers: make(map[string]*circuitBreaker),
+		inFlight:        make(map[string]int),
+		stopCh:          make(chan none.T),
+		missingTopics:   make(map[string]time.Time),
+
+		variantProducers: make(map[producerVariant]*variantProducer),
+		consumerMetrics:  metrics.NewRegistry(),
+		clientMetrics:    producer.NewClientMetricNames(s.cfg.Monitoring.MaxClientMetrics),
+	}
+}
+
+// fakeConsumer returns a message from partition 0 of the requested topic to
+// every consume request.
+type fakeConsumer struct {
+	offset    int64
+	eventsChs []chan consumer.Event
+	paused    []string
+}
+
+func (fc *fakeConsumer) Consume(group, topic string) (consumer.Message, error) {
+	rs := <-fc.AsyncConsume(group, topic, 0, 0)
+	return rs.Msg, rs.Err
+}
+
+func (fc *fakeConsumer) AsyncConsume(group, topic string, timeout time.Duration, initialOffset int64) <-chan consumer.Response {
+	fc.offset += 1
+	eventsCh := make(chan consumer.Event, 10)
+	fc.eventsChs = append(fc.eventsChs, eventsCh)
+	responseCh := make(chan consumer.Response, 1)
+	responseCh <- consumer.Response{Msg: consumer.Message{
+		Topic:    topic,
+		Offset:   fc.offset,
+		EventsCh: eventsCh,
+	}}
+	return responseCh
+}
+
+func (fc *fakeConsumer) Pause(group, topic string) {
+	fc.paused = append(fc.paused, group+"/"+topic)
+}
+
+func (fc *fakeConsumer) Resume(group, topic string) {}
+
+func (fc *fakeConsumer) Metrics() metrics.Registry { return metrics.NewRegistry() }
+
+func (fc *fakeConsumer) Stop() {}
+
+func (s *ProxySuite) TestEventsChTTL(c *C) {
+	cfg := testhelpers.NewTestProxyCfg("test")
+	for i, tc := range []struct {
+		ackTimeout           time.Duration
+		subscriptionTimeout  time.Duration
+		partitionIdleTimeout time.Duration
+		ttl                  time.Duration
+	}{
+		{ackTimeout: 5 * time.Minute, subscriptionTimeout: 15 * time.Second, ttl: 5 * time.Minute},
+		{ackTimeout: 5 * time.Second, subscriptionTimeout: 15 * time.Second, ttl: 15 * time.Second},
+		{ackTimeout: 5 * time.Second, subscriptionTimeout: 15 * time.Second, partitionIdleTimeout: 10 * time.Second, ttl: 10 * time.Second},
+		{ackTimeout: 5 * time.Minute, subscriptionTimeout: 15 * time.Second, partitionIdleTimeout: 10 * time.Minute, ttl: 5 * time.Minute},
+	} {
+		cfg.Consumer.AckTimeout = tc.ackTimeout
+		cfg.Consumer.SubscriptionTimeout = tc.subscriptionTimeout
+		cfg.Consumer.PartitionIdleTimeout = tc.partitionIdleTimeout
+		c.Assert(eventsChTTL(cfg), Equals, tc.ttl, Commentf("case #%d", i))
+	}
+}
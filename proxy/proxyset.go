@@ -1,6 +1,8 @@
 package proxy
 
 import (
+	"sort"
+
 	"github.com/pkg/errors"
 )
 
@@ -32,3 +34,24 @@ func (s *Set) Get(cluster string) (*T, error) {
 	}
 	return nil, errors.Errorf("proxy `%s` does not exist", cluster)
 }
+
+// Clusters returns sorted names of all clusters in the set.
+func (s *Set) Clusters() []string {
+	clusters := make([]string, 0, len(s.proxies))
+	for cluster := range s.proxies {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// DefaultCluster returns the name of the cluster that requests that do not
+// specify one are routed to.
+func (s *Set) DefaultCluster() string {
+	for cluster, pxy := range s.proxies {
+		if pxy == s.defaultPxy {
+			return cluster
+		}
+	}
+	return ""
+}
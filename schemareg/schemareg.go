@@ -0,0 +1,274 @@
+// Package schemareg implements a client of the Confluent Schema Registry that
+// encodes JSON values to the Confluent Avro wire format and back. The wire
+// format is a zero magic byte, followed by a 4 byte big-endian ID of the
+// schema in the registry, followed by the Avro binary encoded value.
+package schemareg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mailgun/kafka-pixy/avro"
+	"github.com/mailgun/kafka-pixy/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	magicByte  = 0
+	headerSize = 5
+
+	contentType = "application/vnd.schemaregistry.v1+json"
+
+	// The latest schema version of a subject is looked up in the registry
+	// at most this often, so that new versions are eventually picked up.
+	latestSchemaTTL = time.Minute
+
+	// Schemas given explicitly on produce are arbitrary strings supplied by
+	// clients, so at most that many of them are remembered as registered.
+	maxRegisteredSchemas = 1024
+
+	// Error codes returned by the schema registry.
+	errCodeSubjectNotFound = 40401
+	errCodeSchemaNotFound  = 40403
+)
+
+var (
+	ErrSubjectNotFound = errors.New("subject not found")
+	ErrSchemaNotFound  = errors.New("schema not found")
+	ErrNotAvro         = errors.New("not encoded in the Confluent Avro wire format")
+)
+
+// InvalidValueError is returned when a value does not conform to a schema.
+type InvalidValueError struct {
+	Err error
+}
+
+func (e *InvalidValueError) Error() string {
+	return e.Err.Error()
+}
+
+// T is a schema registry client. Schemas retrieved from the registry are
+// cached, so that it is not queried for every message.
+type T struct {
+	baseURL string
+	httpClt *http.Client
+
+	mu         sync.Mutex
+	byID       map[int32]*avro.Schema
+	latest     map[string]latestSchema
+	registered map[registeredSchema]int32
+}
+
+type latestSchema struct {
+	id        int32
+	schema    *avro.Schema
+	fetchedAt time.Time
+}
+
+type registeredSchema struct {
+	subject string
+	schema  string
+}
+
+// New creates a schema registry client for the registry specified in the
+// given proxy config.
+func New(cfg *config.Proxy) *T {
+	return &T{
+		baseURL:    strings.TrimRight(cfg.SchemaRegistry.URL, "/"),
+		httpClt:    &http.Client{Timeout: cfg.SchemaRegistry.Timeout},
+		byID:       make(map[int32]*avro.Schema),
+		latest:     make(map[string]latestSchema),
+		registered: make(map[registeredSchema]int32),
+	}
+}
+
+// Encode encodes a JSON value to the Confluent Avro wire format. If
+// `schemaJSON` is given, then it is registered under the subject, unless it
+// has already been, and used to encode the value. Otherwise the latest schema
+// version registered under the subject is used.
+func (t *T) Encode(subject, schemaJSON string, value []byte) ([]byte, error) {
+	var id int32
+	var schema *avro.Schema
+	var err error
+	if schemaJSON != "" {
+		id, schema, err = t.register(subject, schemaJSON)
+	} else {
+		id, schema, err = t.getLatest(subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := schema.FromJSON(value)
+	if err != nil {
+		return nil, &InvalidValueError{errors.Wrapf(err, "value does not conform to schema %d", id)}
+	}
+	buf := make([]byte, headerSize, headerSize+len(encoded))
+	buf[0] = magicByte
+	binary.BigEndian.PutUint32(buf[1:], uint32(id))
+	return append(buf, encoded...), nil
+}
+
+// Decode decodes a value in the Confluent Avro wire format to JSON.
+func (t *T) Decode(data []byte) ([]byte, error) {
+	if len(data) < headerSize || data[0] != magicByte {
+		return nil, ErrNotAvro
+	}
+	id := int32(binary.BigEndian.Uint32(data[1:]))
+	schema, err := t.getByID(id)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := schema.ToJSON(data[headerSize:])
+	if err != nil {
+		return nil, &InvalidValueError{errors.Wrapf(err, "value does not conform to schema %d", id)}
+	}
+	return decoded, nil
+}
+
+func (t *T) register(subject, schemaJSON string) (int32, *avro.Schema, error) {
+	key := registeredSchema{subject, schemaJSON}
+	t.mu.Lock()
+	id, ok := t.registered[key]
+	schema := t.byID[id]
+	t.mu.Unlock()
+	if ok {
+		return id, schema, nil
+	}
+
+	schema, err := avro.ParseSchema(schemaJSON)
+	if err != nil {
+		return 0, nil, &InvalidValueError{errors.Wrap(err, "invalid schema")}
+	}
+	var rs struct {
+		ID int32 `json:"id"`
+	}
+	rqBody, _ := json.Marshal(struct {
+		Schema string `json:"schema"`
+	}{schemaJSON})
+	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
+	if err := t.call(http.MethodPost, path, rqBody, &rs); err != nil {
+		return 0, nil, errors.Wrapf(err, "failed to register schema, subject=%s", subject)
+	}
+	t.mu.Lock()
+	if len(t.registered) >= maxRegisteredSchemas {
+		// Evict an arbitrary entry, it is registered again if needed.
+		for evicted := range t.registered {
+			delete(t.registered, evicted)
+			break
+		}
+	}
+	t.registered[key] = rs.ID
+	t.byID[rs.ID] = schema
+	t.mu.Unlock()
+	return rs.ID, schema, nil
+}
+
+func (t *T) getLatest(subject string) (int32, *avro.Schema, error) {
+	t.mu.Lock()
+	latest, ok := t.latest[subject]
+	t.mu.Unlock()
+	if ok && time.Since(latest.fetchedAt) < latestSchemaTTL {
+		return latest.id, latest.schema, nil
+	}
+
+	var rs struct {
+		ID     int32  `json:"id"`
+		Schema string `json:"schema"`
+	}
+	path := fmt.Sprintf("/subjects/%s/versions/latest", url.PathEscape(subject))
+	if err := t.call(http.MethodGet, path, nil, &rs); err != nil {
+		return 0, nil, errors.Wrapf(err, "failed to get latest schema, subject=%s", subject)
+	}
+	schema, err := t.parse(rs.ID, rs.Schema)
+	if err != nil {
+		return 0, nil, err
+	}
+	t.mu.Lock()
+	t.latest[subject] = latestSchema{rs.ID, schema, time.Now()}
+	t.mu.Unlock()
+	return rs.ID, schema, nil
+}
+
+func (t *T) getByID(id int32) (*avro.Schema, error) {
+	t.mu.Lock()
+	schema := t.byID[id]
+	t.mu.Unlock()
+	if schema != nil {
+		return schema, nil
+	}
+
+	var rs struct {
+		Schema string `json:"schema"`
+	}
+	if err := t.call(http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, &rs); err != nil {
+		return nil, errors.Wrapf(err, "failed to get schema, id=%d", id)
+	}
+	return t.parse(id, rs.Schema)
+}
+
+// parse parses a schema retrieved from the registry and caches it by ID.
+func (t *T) parse(id int32, schemaJSON string) (*avro.Schema, error) {
+	t.mu.Lock()
+	schema := t.byID[id]
+	t.mu.Unlock()
+	if schema != nil {
+		return schema, nil
+	}
+	schema, err := avro.ParseSchema(schemaJSON)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schema, id=%d", id)
+	}
+	t.mu.Lock()
+	t.byID[id] = schema
+	t.mu.Unlock()
+	return schema, nil
+}
+
+// call makes a request to the schema registry and parses a JSON response
+// into `rs`.
+func (t *T) call(method, path string, rqBody []byte, rs interface{}) error {
+	req, err := http.NewRequest(method, t.baseURL+path, bytes.NewReader(rqBody))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", contentType)
+	if rqBody != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := t.httpClt.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read response")
+	}
+	if res.StatusCode != http.StatusOK {
+		var errorRs struct {
+			ErrorCode int    `json:"error_code"`
+			Message   string `json:"message"`
+		}
+		json.Unmarshal(body, &errorRs)
+		switch errorRs.ErrorCode {
+		case errCodeSubjectNotFound:
+			return ErrSubjectNotFound
+		case errCodeSchemaNotFound:
+			return ErrSchemaNotFound
+		}
+		return errors.Errorf("registry error: status=%d, code=%d, message=%s",
+			res.StatusCode, errorRs.ErrorCode, errorRs.Message)
+	}
+	if err := json.Unmarshal(body, rs); err != nil {
+		return errors.Wrap(err, "invalid response")
+	}
+	return nil
+}
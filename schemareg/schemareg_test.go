@@ -0,0 +1,176 @@
+package schemareg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mailgun/kafka-pixy/config"
+	"github.com/pkg/errors"
+	. "gopkg.in/check.v1"
+)
+
+const testSchema = `{"type": "record", "name": "R", "fields": [{"name": "f", "type": "string"}]}`
+
+type SchemaRegSuite struct {
+	registry *fakeRegistry
+	srv      *httptest.Server
+	cfg      *config.Proxy
+}
+
+var _ = Suite(&SchemaRegSuite{})
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+func (s *SchemaRegSuite) SetUpTest(c *C) {
+	s.registry = &fakeRegistry{schemas: map[int32]string{}, subjects: map[string]int32{}}
+	s.srv = httptest.NewServer(s.registry)
+	s.cfg = config.DefaultProxy()
+	s.cfg.SchemaRegistry.URL = s.srv.URL + "/"
+	s.cfg.SchemaRegistry.Timeout = time.Second
+}
+
+func (s *SchemaRegSuite) TearDownTest(c *C) {
+	s.srv.Close()
+}
+
+// A schema given explicitly is registered and a value is encoded with it.
+func (s *SchemaRegSuite) TestEncodeRegister(c *C) {
+	sr := New(s.cfg)
+
+	// When
+	encoded, err := sr.Encode("foo-value", testSchema, []byte(`{"f": "bar"}`))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(encoded, DeepEquals, []byte{0, 0, 0, 0, 1, 6, 'b', 'a', 'r'})
+	c.Assert(s.registry.subjects["foo-value"], Equals, int32(1))
+
+	// A registered schema is cached.
+	_, err = sr.Encode("foo-value", testSchema, []byte(`{"f": "baz"}`))
+	c.Assert(err, IsNil)
+	c.Assert(s.registry.callCount, Equals, 1)
+}
+
+// The number of schemas remembered as registered is limited.
+func (s *SchemaRegSuite) TestRegisteredBounded(c *C) {
+	sr := New(s.cfg)
+
+	// When
+	for i := 0; i < maxRegisteredSchemas+10; i++ {
+		schema := strings.Replace(testSchema, `"R"`, `"R`+strconv.Itoa(i)+`"`, 1)
+		_, err := sr.Encode("foo-value", schema, []byte(`{"f": "bar"}`))
+		c.Assert(err, IsNil)
+	}
+
+	// Then
+	c.Assert(len(sr.registered), Equals, maxRegisteredSchemas)
+}
+
+// If a schema is not given, then the latest registered under a subject is
+// used.
+func (s *SchemaRegSuite) TestEncodeLatest(c *C) {
+	s.registry.schemas[7] = testSchema
+	s.registry.subjects["foo-value"] = 7
+	sr := New(s.cfg)
+
+	// When
+	encoded, err := sr.Encode("foo-value", "", []byte(`{"f": "bar"}`))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(encoded, DeepEquals, []byte{0, 0, 0, 0, 7, 6, 'b', 'a', 'r'})
+}
+
+func (s *SchemaRegSuite) TestEncodeNoSubject(c *C) {
+	sr := New(s.cfg)
+
+	// When
+	_, err := sr.Encode("foo-value", "", []byte(`{"f": "bar"}`))
+
+	// Then
+	c.Assert(errors.Cause(err), Equals, ErrSubjectNotFound)
+}
+
+func (s *SchemaRegSuite) TestEncodeInvalidValue(c *C) {
+	sr := New(s.cfg)
+
+	// When
+	_, err := sr.Encode("foo-value", testSchema, []byte(`{"f": 1}`))
+
+	// Then
+	_, ok := err.(*InvalidValueError)
+	c.Assert(ok, Equals, true)
+	c.Assert(err.Error(), Equals, "value does not conform to schema 1: invalid record R field f: string expected, got 1")
+}
+
+func (s *SchemaRegSuite) TestDecode(c *C) {
+	s.registry.schemas[7] = testSchema
+	sr := New(s.cfg)
+
+	// When
+	decoded, err := sr.Decode([]byte{0, 0, 0, 0, 7, 6, 'b', 'a', 'r'})
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(string(decoded), Equals, `{"f":"bar"}`)
+}
+
+func (s *SchemaRegSuite) TestDecodeErrors(c *C) {
+	sr := New(s.cfg)
+
+	_, err := sr.Decode([]byte(`{"f":"bar"}`))
+	c.Assert(err, Equals, ErrNotAvro)
+
+	_, err = sr.Decode([]byte{0, 0, 0, 0, 8, 6, 'b', 'a', 'r'})
+	c.Assert(errors.Cause(err), Equals, ErrSchemaNotFound)
+}
+
+// fakeRegistry implements the subset of the schema registry API used by the
+// client.
+type fakeRegistry struct {
+	schemas   map[int32]string
+	subjects  map[string]int32
+	callCount int
+}
+
+func (fr *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fr.callCount++
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost:
+		subject := strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions")
+		var rq struct {
+			Schema string `json:"schema"`
+		}
+		json.NewDecoder(r.Body).Decode(&rq)
+		id := int32(len(fr.schemas) + 1)
+		fr.schemas[id] = rq.Schema
+		fr.subjects[subject] = id
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id})
+	case strings.HasPrefix(path, "/schemas/ids/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(path, "/schemas/ids/"))
+		schema, ok := fr.schemas[int32(id)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 40403, "message": "Schema not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"schema": schema})
+	default:
+		subject := strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions/latest")
+		id, ok := fr.subjects[subject]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 40401, "message": "Subject not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "schema": fr.schemas[id]})
+	}
+}
@@ -0,0 +1,108 @@
+// Package auth implements authentication and authorization of API requests
+// with API keys, that clients present as bearer tokens.
+package auth
+
+import (
+	"path"
+
+	"github.com/mailgun/kafka-pixy/config"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrUnauthenticated = errors.New("missing or invalid API key")
+	ErrForbidden       = errors.New("API key does not grant access to the topic or group")
+	ErrAdminForbidden  = errors.New("API key does not grant access to administrative operations")
+)
+
+// Resource describes what an API request operates on. Empty fields mean
+// that the request does not operate on a particular topic or group.
+type Resource struct {
+	Topic string
+	Group string
+
+	// AnyTopic is true for requests that can operate on any topic, e.g.
+	// consumption from topics matching a pattern, or listing of topics.
+	AnyTopic bool
+
+	// AnyGroup is true for requests that can operate on any group, e.g.
+	// listing of consumer groups.
+	AnyGroup bool
+
+	// Admin is true for administrative operations, e.g. deletion of topics
+	// and groups, or setting of committed offsets.
+	Admin bool
+}
+
+// Authorizer decides whether a request authenticated with a token, that is
+// empty if the client did not present any, may access a resource. It returns
+// ErrUnauthenticated if the token is not valid, and ErrForbidden if the token
+// does not grant access to the resource.
+type Authorizer interface {
+	// Authenticate returns ErrUnauthenticated if the token is not valid.
+	Authenticate(token string) error
+
+	Authorize(token string, rsc Resource) error
+}
+
+// T authorizes requests with API keys defined in the config.
+type T struct {
+	apiKeys map[string]config.APIKey
+}
+
+// New creates an authorizer that accepts API keys defined in the
+// `auth.api_keys` section of the config.
+func New(cfg *config.App) *T {
+	a := &T{apiKeys: make(map[string]config.APIKey, len(cfg.Auth.APIKeys))}
+	for _, apiKey := range cfg.Auth.APIKeys {
+		a.apiKeys[apiKey.Key] = apiKey
+	}
+	return a
+}
+
+// Authenticate implements Authorizer.
+func (a *T) Authenticate(token string) error {
+	if _, ok := a.apiKeys[token]; token == "" || !ok {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// Authorize implements Authorizer.
+func (a *T) Authorize(token string, rsc Resource) error {
+	apiKey, ok := a.apiKeys[token]
+	if token == "" || !ok {
+		return ErrUnauthenticated
+	}
+	if rsc.Admin && !apiKey.Admin {
+		return ErrAdminForbidden
+	}
+	if rsc.AnyTopic && len(apiKey.Topics) > 0 {
+		return ErrForbidden
+	}
+	if rsc.AnyGroup && len(apiKey.Groups) > 0 {
+		return ErrForbidden
+	}
+	if rsc.Topic != "" && !matchAny(apiKey.Topics, rsc.Topic) {
+		return ErrForbidden
+	}
+	if rsc.Group != "" && !matchAny(apiKey.Groups, rsc.Group) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// matchAny tells whether a name matches any of the glob patterns. An empty
+// list of patterns matches all names.
+func matchAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		// Patterns are validated when the config is parsed.
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/mailgun/kafka-pixy/config"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type AuthSuite struct{}
+
+var _ = Suite(&AuthSuite{})
+
+func (s *AuthSuite) TestAuthorize(c *C) {
+	cfg := &config.App{}
+	cfg.Auth.APIKeys = []config.APIKey{
+		{Key: "admin", Admin: true},
+		{Key: "orders", Topics: []string{"orders.*", "audit"}, Groups: []string{"billing"}},
+		{Key: "orders-admin", Topics: []string{"orders.*"}, Admin: true},
+	}
+	a := New(cfg)
+
+	for i, tc := range []struct {
+		token string
+		rsc   Resource
+		err   error
+	}{
+		{token: "", rsc: Resource{}, err: ErrUnauthenticated},
+		{token: "bogus", rsc: Resource{Topic: "foo"}, err: ErrUnauthenticated},
+		{token: "admin", rsc: Resource{}, err: nil},
+		{token: "admin", rsc: Resource{Topic: "foo", Group: "bar"}, err: nil},
+		{token: "admin", rsc: Resource{AnyTopic: true, Group: "bar"}, err: nil},
+		{token: "orders", rsc: Resource{}, err: nil},
+		{token: "orders", rsc: Resource{Topic: "orders.eu"}, err: nil},
+		{token: "orders", rsc: Resource{Topic: "audit", Group: "billing"}, err: nil},
+		{token: "orders", rsc: Resource{Topic: "orders"}, err: ErrForbidden},
+		{token: "orders", rsc: Resource{Topic: "orders.eu", Group: "shipping"}, err: ErrForbidden},
+		{token: "orders", rsc: Resource{Group: "billing", AnyTopic: true}, err: ErrForbidden},
+		{token: "orders", rsc: Resource{Topic: "audit", AnyGroup: true}, err: ErrForbidden},
+		{token: "orders", rsc: Resource{AnyTopic: true, AnyGroup: true}, err: ErrForbidden},
+		{token: "admin", rsc: Resource{AnyTopic: true, AnyGroup: true}, err: nil},
+		{token: "admin", rsc: Resource{Topic: "foo", Admin: true}, err: nil},
+		{token: "orders", rsc: Resource{Topic: "orders.eu", Admin: true}, err: ErrAdminForbidden},
+		{token: "orders", rsc: Resource{Topic: "orders.eu", Group: "billing", Admin: true}, err: ErrAdminForbidden},
+		{token: "orders-admin", rsc: Resource{Topic: "orders.eu", Admin: true}, err: nil},
+		{token: "orders-admin", rsc: Resource{Topic: "audit", Admin: true}, err: ErrForbidden},
+	} {
+		c.Assert(a.Authorize(tc.token, tc.rsc), Equals, tc.err, Commentf("case #%d", i))
+	}
+}
+
+func (s *AuthSuite) TestAuthenticate(c *C) {
+	cfg := &config.App{}
+	cfg.Auth.APIKeys = []config.APIKey{{Key: "orders", Topics: []string{"orders.*"}}}
+	a := New(cfg)
+
+	c.Assert(a.Authenticate(""), Equals, ErrUnauthenticated)
+	c.Assert(a.Authenticate("bogus"), Equals, ErrUnauthenticated)
+	c.Assert(a.Authenticate("orders"), IsNil)
+}
@@ -0,0 +1,116 @@
+package grpcsrv
+
+import (
+	"strings"
+
+	pb "github.com/mailgun/kafka-pixy/gen/golang"
+	"github.com/mailgun/kafka-pixy/server/auth"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Metadata keys that clients pass API keys in.
+	mdAuthorization = "authorization"
+	mdAPIKey        = "x-api-key"
+
+	bearerPrefix = "Bearer "
+)
+
+type topicRq interface {
+	GetTopic() string
+}
+
+type groupRq interface {
+	GetGroup() string
+}
+
+// unaryAuthInterceptor returns an interceptor that only passes requests to
+// handlers if they are authorized by `authz` to access the topic and consumer
+// group they specify.
+func unaryAuthInterceptor(authz auth.Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, authz, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamAuthInterceptor returns an interceptor that authorizes streams by
+// the first request, that is the one that specifies the topic and the
+// consumer group of a stream.
+func streamAuthInterceptor(authz auth.Authorizer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &authServerStream{ServerStream: ss, authz: authz})
+	}
+}
+
+type authServerStream struct {
+	grpc.ServerStream
+	authz      auth.Authorizer
+	authorized bool
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *authServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if !s.authorized {
+		if err := authorize(s.Context(), s.authz, m); err != nil {
+			return err
+		}
+		s.authorized = true
+	}
+	return nil
+}
+
+// authorize checks whether the API key passed in the request metadata grants
+// access to the topic and the consumer group of the request. Requests that
+// specify neither a topic nor a group, e.g. ListTopics and ListGroups,
+// operate on the whole cluster.
+func authorize(ctx context.Context, authz auth.Authorizer, req interface{}) error {
+	var rsc auth.Resource
+	if rq, ok := req.(topicRq); ok {
+		rsc.Topic = rq.GetTopic()
+	}
+	if rq, ok := req.(groupRq); ok {
+		rsc.Group = rq.GetGroup()
+	}
+	if rsc.Topic == "" && rsc.Group == "" {
+		rsc.AnyTopic, rsc.AnyGroup = true, true
+	}
+	if _, ok := req.(*pb.SetOffsetsRq); ok {
+		rsc.Admin = true
+	}
+	switch err := authz.Authorize(getToken(ctx), rsc); err {
+	case nil:
+		return nil
+	case auth.ErrUnauthenticated:
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+}
+
+// getToken returns a token passed either in the `authorization` metadata
+// with the Bearer scheme or in the `x-api-key` metadata.
+func getToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, authorization := range md[mdAuthorization] {
+		if strings.HasPrefix(authorization, bearerPrefix) {
+			return strings.TrimPrefix(authorization, bearerPrefix)
+		}
+	}
+	if apiKeys := md[mdAPIKey]; len(apiKeys) > 0 {
+		return apiKeys[0]
+	}
+	return ""
+}
@@ -1,24 +1,33 @@
 package grpcsrv
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"regexp"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/admin"
+	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/consumer"
 	"github.com/mailgun/kafka-pixy/consumer/offsettrk"
 	"github.com/mailgun/kafka-pixy/gen/golang"
+	"github.com/mailgun/kafka-pixy/none"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
+	"github.com/mailgun/kafka-pixy/producer"
 	"github.com/mailgun/kafka-pixy/proxy"
+	"github.com/mailgun/kafka-pixy/server/auth"
 	"github.com/pkg/errors"
 	"github.com/samuel/go-zookeeper/zk"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 )
 
@@ -35,14 +44,25 @@ type T struct {
 	errorCh  chan error
 }
 
-// New creates a gRPC server instance.
-func New(addr string, proxySet *proxy.Set) (*T, error) {
+// New creates a gRPC server instance. If `tlsCfg` is not nil, then clients
+// have to connect over TLS. If `authz` is not nil, then it authorizes all
+// requests.
+func New(addr string, tlsCfg *tls.Config, authz auth.Authorizer, proxySet *proxy.Set) (*T, error) {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create listener")
 	}
 
-	grpcSrv := grpc.NewServer(grpc.MaxMsgSize(maxRequestSize))
+	opts := []grpc.ServerOption{grpc.MaxMsgSize(maxRequestSize)}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	if authz != nil {
+		opts = append(opts,
+			grpc.UnaryInterceptor(unaryAuthInterceptor(authz)),
+			grpc.StreamInterceptor(streamAuthInterceptor(authz)))
+	}
+	grpcSrv := grpc.NewServer(opts...)
 	s := T{
 		actDesc:  actor.Root().NewChild(fmt.Sprintf("grpc://%s", addr)),
 		listener: listener,
@@ -87,22 +107,71 @@ func (s *T) Produce(ctx context.Context, req *pb.ProdRq) (*pb.ProdRs, error) {
 	}
 
 	if req.AsyncMode {
-		pxy.AsyncProduce(req.Topic, keyEncoderFor(req), sarama.StringEncoder(req.Message))
-		return &pb.ProdRs{Partition: -1, Offset: -1}, nil
+		err := pxy.AsyncProduceBounded(ctx, req.Topic, keyEncoderFor(req), sarama.StringEncoder(req.Message), req.ClientId)
+		switch err {
+		case nil:
+			return &pb.ProdRs{Partition: -1, Offset: -1}, nil
+		case proxy.ErrQueueFull:
+			return nil, status.Errorf(codes.ResourceExhausted, err.Error())
+		case proxy.ErrTopicNotFound{Topic: req.Topic}:
+			return nil, status.Errorf(codes.NotFound, err.Error())
+		default:
+			return nil, status.Errorf(codes.Unavailable, err.Error())
+		}
 	}
 
-	prodMsg, err := pxy.Produce(req.Topic, keyEncoderFor(req), sarama.StringEncoder(req.Message))
+	opts := proxy.ProduceOpts{ClientID: req.ClientId}
+	if req.RequiredAcks != "" {
+		var requiredAcks config.RequiredAcks
+		if err := requiredAcks.UnmarshalText([]byte(req.RequiredAcks)); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		opts.RequiredAcks = (*sarama.RequiredAcks)(&requiredAcks)
+	}
+	if req.Compression != "" {
+		var compression config.Compression
+		if err := compression.UnmarshalText([]byte(req.Compression)); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		opts.Compression = (*sarama.CompressionCodec)(&compression)
+	}
+	if req.RetryMax < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "bad retry_max: %d", req.RetryMax)
+	}
+	if req.RetryMax != 0 {
+		retryMax := int(req.RetryMax)
+		opts.RetryMax = &retryMax
+	}
+	if req.TimestampMs != 0 {
+		opts.Timestamp = time.Unix(0, req.TimestampMs*int64(time.Millisecond))
+	}
+	prodMsg, requiredAcks, err := pxy.ProduceWithOpts(ctx, req.Topic, keyEncoderFor(req), sarama.StringEncoder(req.Message), opts)
 	if err != nil {
-		switch err {
-		case sarama.ErrUnknownTopicOrPartition:
+		switch errors.Cause(err) {
+		case sarama.ErrUnknownTopicOrPartition, proxy.ErrCompressionUnsupported, proxy.ErrTimestampUnsupported,
+			proxy.ErrMessageTooLarge, sarama.ErrMessageSizeTooLarge:
 			return nil, status.Errorf(codes.InvalidArgument, err.Error())
-		case proxy.ErrUnavailable:
+		case proxy.ErrTopicNotFound{Topic: req.Topic}:
+			return nil, status.Errorf(codes.NotFound, err.Error())
+		case proxy.ErrUnavailable, proxy.ErrCircuitOpen,
+			proxy.ErrNotEnoughReplicas{}, proxy.ErrNotEnoughReplicas{AfterAppend: true}:
 			return nil, status.Errorf(codes.Unavailable, err.Error())
+		case context.Canceled:
+			return nil, status.Errorf(codes.Canceled, err.Error())
+		case context.DeadlineExceeded, proxy.ErrProduceTimeout:
+			return nil, status.Errorf(codes.DeadlineExceeded, err.Error())
 		default:
 			return nil, status.Errorf(codes.Internal, err.Error())
 		}
 	}
-	return &pb.ProdRs{Partition: prodMsg.Partition, Offset: prodMsg.Offset}, nil
+	msgInfo := producer.MessageInfo(prodMsg)
+	return &pb.ProdRs{
+		Partition:      prodMsg.Partition,
+		Offset:         prodMsg.Offset,
+		RequiredAcks:   config.RequiredAcks(requiredAcks).String(),
+		SerializedSize: int32(msgInfo.SerializedBytes),
+		Compression:    config.Compression(msgInfo.Compression).String(),
+	}, nil
 }
 
 // ConsumeNAck implements pb.KafkaPixyServer
@@ -123,32 +192,172 @@ func (s *T) ConsumeNAck(ctx context.Context, req *pb.ConsNAckRq) (*pb.ConsRs, er
 		}
 	}
 
-	consMsg, err := pxy.Consume(req.Group, req.Topic, ack)
+	opts := proxy.ConsumeOpts{
+		LongPollingTimeout: time.Duration(req.LongPollingTimeoutMs) * time.Millisecond,
+		ClientID:           req.ClientId,
+	}
+	if opts.InitialOffset, err = proxy.ParseInitialOffset(req.InitialOffset); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	consMsg, err := pxy.ConsumeWithOpts(ctx, req.Group, req.Topic, ack, opts)
 	if err != nil {
-		switch err {
-		case consumer.ErrRequestTimeout:
-			return nil, status.Errorf(codes.NotFound, err.Error())
-		case consumer.ErrTooManyRequests:
-			return nil, status.Errorf(codes.ResourceExhausted, err.Error())
-		case consumer.ErrUnavailable:
-			fallthrough
-		case proxy.ErrUnavailable:
-			return nil, status.Errorf(codes.Unavailable, err.Error())
+		return nil, consumeErrorStatus(err)
+	}
+	return newConsRs(&consMsg), nil
+}
+
+// ConsumeStream implements pb.KafkaPixyServer
+func (s *T) ConsumeStream(stream pb.KafkaPixy_ConsumeStreamServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	pxy, err := s.proxySet.Get(req.Cluster)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if req.Topic == "" || req.Group == "" {
+		return status.Errorf(codes.InvalidArgument, "both topic and group must be specified")
+	}
+	group, topic := req.Group, req.Topic
+	opts := proxy.ConsumeOpts{ClientID: req.ClientId}
+	ack := proxy.NoAck()
+	if req.AutoAck {
+		ack = proxy.AutoAck()
+	}
+
+	// Messages sent to the client that it has not acknowledged yet. When the
+	// stream ends, e.g. because the client crashed, they are released for
+	// prompt redelivery instead of waiting for consumer.ack_timeout.
+	pending := pendingMsgs{msgs: make(map[pendingMsg]none.T)}
+	defer pending.release(s.actDesc, pxy, group, topic)
+
+	// Acks arrive on the client stream independently from messages that are
+	// sent to the client, so they are handled by a separate goroutine.
+	ackErrorCh := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				// io.EOF just means that the client is not going to ack
+				// anymore, but it still may want to receive messages.
+				if err != io.EOF {
+					ackErrorCh <- err
+				}
+				return
+			}
+			ack, err := proxy.NewAck(req.AckPartition, req.AckOffset)
+			if err != nil {
+				ackErrorCh <- status.Errorf(codes.InvalidArgument, errors.Wrap(err, "invalid ack").Error())
+				return
+			}
+			if err := pxy.Ack(group, topic, ack); err != nil {
+				ackErrorCh <- status.Errorf(codes.Internal, err.Error())
+				return
+			}
+			pending.remove(req.AckPartition, req.AckOffset)
+		}
+	}()
+
+	ctx := stream.Context()
+	for {
+		// The next message is consumed only after the previous one has been
+		// handed over to the transport, that blocks when the client does not
+		// keep up, hence messages never accumulate in the proxy.
+		consMsg, err := pxy.ConsumeWithOpts(ctx, group, topic, ack, opts)
+		select {
+		case ackErr := <-ackErrorCh:
+			return ackErr
 		default:
-			return nil, status.Errorf(codes.Internal, err.Error())
+		}
+		if err != nil {
+			if err == consumer.ErrRequestTimeout || err == consumer.ErrRebalanceInProgress {
+				continue
+			}
+			return consumeErrorStatus(err)
+		}
+		if !req.AutoAck {
+			pending.add(consMsg.Partition, consMsg.Offset)
+		}
+		if err := stream.Send(newConsRs(&consMsg)); err != nil {
+			return err
 		}
 	}
+}
+
+type pendingMsg struct {
+	partition int32
+	offset    int64
+}
+
+// pendingMsgs tracks messages sent over a consume stream that have not been
+// acknowledged by the client yet.
+type pendingMsgs struct {
+	mu   sync.Mutex
+	msgs map[pendingMsg]none.T
+}
+
+func (pm *pendingMsgs) add(partition int32, offset int64) {
+	pm.mu.Lock()
+	pm.msgs[pendingMsg{partition, offset}] = none.V
+	pm.mu.Unlock()
+}
+
+func (pm *pendingMsgs) remove(partition int32, offset int64) {
+	pm.mu.Lock()
+	delete(pm.msgs, pendingMsg{partition, offset})
+	pm.mu.Unlock()
+}
+
+// release releases all pending messages for redelivery.
+func (pm *pendingMsgs) release(actDesc *actor.Descriptor, pxy *proxy.T, group, topic string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for msg := range pm.msgs {
+		if err := pxy.Release(group, topic, msg.partition, msg.offset); err != nil {
+			actDesc.Log().WithError(err).Errorf("Failed to release: group=%s, topic=%s, partition=%d, offset=%d",
+				group, topic, msg.partition, msg.offset)
+		}
+	}
+	pm.msgs = make(map[pendingMsg]none.T)
+}
+
+// consumeErrorStatus converts an error returned by a proxy consume method to a
+// gRPC status error.
+func consumeErrorStatus(err error) error {
+	switch err {
+	case consumer.ErrRequestTimeout:
+		return status.Errorf(codes.NotFound, err.Error())
+	case context.Canceled:
+		return status.Errorf(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Errorf(codes.DeadlineExceeded, err.Error())
+	case consumer.ErrTooManyRequests, proxy.ErrTooManyRequests, proxy.ErrRateLimited:
+		return status.Errorf(codes.ResourceExhausted, err.Error())
+	case consumer.ErrRebalanceInProgress, consumer.ErrUnavailable:
+		fallthrough
+	case proxy.ErrUnavailable:
+		return status.Errorf(codes.Unavailable, err.Error())
+	default:
+		return status.Errorf(codes.Internal, err.Error())
+	}
+}
+
+func newConsRs(consMsg *consumer.Message) *pb.ConsRs {
 	res := pb.ConsRs{
-		Partition: consMsg.Partition,
-		Offset:    consMsg.Offset,
-		Message:   consMsg.Value,
+		Partition:       consMsg.Partition,
+		Offset:          consMsg.Offset,
+		Message:         consMsg.Value,
+		HighWaterMark:   consMsg.HighWaterMark,
+		CommittedOffset: consMsg.CommittedOffset,
+		LikelyDuplicate: consMsg.LikelyDuplicate,
 	}
 	if consMsg.Key == nil {
 		res.KeyUndefined = true
 	} else {
 		res.KeyValue = consMsg.Key
 	}
-	return &res, nil
+	return &res
 }
 
 func (s *T) Ack(ctx context.Context, req *pb.AckRq) (*pb.AckRs, error) {
@@ -161,7 +370,12 @@ func (s *T) Ack(ctx context.Context, req *pb.AckRq) (*pb.AckRs, error) {
 	if err != nil {
 		return nil, status.Errorf(codes.InvalidArgument, errors.Wrap(err, "invalid ack").Error())
 	}
-	if err = pxy.Ack(req.Group, req.Topic, ack); err != nil {
+	if req.UpTo {
+		err = pxy.AckRange(req.Group, req.Topic, req.Partition, req.Offset)
+	} else {
+		err = pxy.Ack(req.Group, req.Topic, ack)
+	}
+	if err != nil {
 		return nil, status.Errorf(codes.Code(http.StatusInternalServerError), err.Error())
 	}
 	return &pb.AckRs{}, nil
@@ -199,11 +413,41 @@ func (s *T) GetOffsets(ctx context.Context, req *pb.GetOffsetsRq) (*pb.GetOffset
 		row.Metadata = po.Metadata
 		offset := offsetmgr.Offset{Val: po.Offset, Meta: po.Metadata}
 		row.SparseAcks = offsettrk.SparseAcks2Str(offset)
+		if po.CommittedBy != "" {
+			row.CommittedBy = po.CommittedBy
+			row.CommittedAtMs = po.CommittedAt.UnixNano() / int64(time.Millisecond)
+		}
 		result.Offsets = append(result.Offsets, &row)
 	}
 	return &result, nil
 }
 
+// GetTopicOffsets implements pb.KafkaPixyServer
+func (s *T) GetTopicOffsets(ctx context.Context, req *pb.GetTopicOffsetsRq) (*pb.GetTopicOffsetsRs, error) {
+	pxy, err := s.proxySet.Get(req.Cluster)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	ranges, err := pxy.GetTopicOffsets(req.Topic)
+	if err != nil {
+		if errors.Cause(err) == sarama.ErrUnknownTopicOrPartition {
+			return nil, status.Errorf(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+
+	result := pb.GetTopicOffsetsRs{}
+	for _, pr := range ranges {
+		result.Ranges = append(result.Ranges, &pb.PartitionOffsetRange{
+			Partition: pr.Partition,
+			Begin:     pr.Begin,
+			End:       pr.End,
+			Count:     pr.End - pr.Begin,
+		})
+	}
+	return &result, nil
+}
+
 func (s *T) SetOffsets(ctx context.Context, req *pb.SetOffsetsRq) (*pb.SetOffsetsRs, error) {
 	pxy, err := s.proxySet.Get(req.Cluster)
 	if err != nil {
@@ -217,11 +461,21 @@ func (s *T) SetOffsets(ctx context.Context, req *pb.SetOffsetsRq) (*pb.SetOffset
 		partitionOffsets[i].Metadata = pov.Metadata
 	}
 
-	err = pxy.SetGroupOffsets(req.Group, req.Topic, partitionOffsets)
+	opts := admin.SetOffsetsOpts{
+		Retention:  time.Duration(req.RetentionMs) * time.Millisecond,
+		NoDecrease: req.NoDecrease,
+	}
+	err = pxy.SetGroupOffsetsWithOpts(req.Group, req.Topic, partitionOffsets, opts)
 	if err != nil {
 		if err = errors.Cause(err); err == sarama.ErrUnknownTopicOrPartition {
 			return nil, status.Errorf(codes.NotFound, err.Error())
 		}
+		if err == admin.ErrRetentionUnsupported {
+			return nil, status.Errorf(codes.InvalidArgument, err.Error())
+		}
+		if err == admin.ErrOffsetDecrease {
+			return nil, status.Errorf(codes.FailedPrecondition, err.Error())
+		}
 		return nil, status.Errorf(codes.Code(http.StatusInternalServerError), err.Error())
 	}
 
@@ -234,7 +488,13 @@ func (s *T) ListTopics(ctx context.Context, req *pb.ListTopicRq) (*pb.ListTopicR
 		return nil, status.Errorf(codes.InvalidArgument, err.Error())
 	}
 
-	tms, err := pxy.ListTopics(req.GetWithPartitions(), true)
+	filter := admin.TopicFilter{Prefix: req.Prefix, Config: req.Config}
+	if req.Pattern != "" {
+		if filter.Pattern, err = regexp.Compile(req.Pattern); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad pattern: %s", err)
+		}
+	}
+	tms, err := pxy.ListTopicsWithFilter(req.GetWithPartitions(), true, filter)
 	if err != nil {
 		if errors.Cause(err) == zk.ErrNoNode {
 			return nil, status.Errorf(codes.NotFound, err.Error())
@@ -311,6 +571,60 @@ func (s *T) ListConsumers(ctx context.Context, req *pb.ListConsumersRq) (*pb.Lis
 	return &res, nil
 }
 
+func (s *T) ListGroups(ctx context.Context, req *pb.ListGroupsRq) (*pb.ListGroupsRs, error) {
+	pxy, err := s.proxySet.Get(req.Cluster)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	groups, err := pxy.ListConsumerGroups()
+	if err != nil {
+		if err == proxy.ErrUnavailable {
+			return nil, status.Errorf(codes.Unavailable, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	return &pb.ListGroupsRs{Groups: groups}, nil
+}
+
+func (s *T) DescribeGroup(ctx context.Context, req *pb.DescribeGroupRq) (*pb.DescribeGroupRs, error) {
+	pxy, err := s.proxySet.Get(req.Cluster)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+
+	gd, err := pxy.DescribeConsumerGroup(req.Group)
+	if err != nil {
+		if err == proxy.ErrUnavailable {
+			return nil, status.Errorf(codes.Unavailable, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, err.Error())
+	}
+	res := pb.DescribeGroupRs{
+		Group:        gd.Group,
+		State:        gd.State,
+		ProtocolType: gd.ProtocolType,
+		Protocol:     gd.Protocol,
+		Members:      make([]*pb.GroupMember, len(gd.Members)),
+	}
+	for i, gmd := range gd.Members {
+		member := pb.GroupMember{
+			MemberId:   gmd.MemberID,
+			ClientId:   gmd.ClientID,
+			ClientHost: gmd.ClientHost,
+			Topics:     gmd.Topics,
+		}
+		if gmd.Assignment != nil {
+			member.Assignment = make(map[string]*pb.ConsumerPartitions, len(gmd.Assignment))
+			for topic, partitions := range gmd.Assignment {
+				member.Assignment[topic] = &pb.ConsumerPartitions{Partitions: partitions}
+			}
+		}
+		res.Members[i] = &member
+	}
+	return &res, nil
+}
+
 func (s *T) GetTopicMetadata(ctx context.Context, req *pb.GetTopicMetadataRq) (*pb.GetTopicMetadataRs, error) {
 	pxy, err := s.proxySet.Get(req.Cluster)
 	if err != nil {
@@ -0,0 +1,216 @@
+package httpsrv
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mailgun/kafka-pixy/server/auth"
+)
+
+const (
+	// Names of routes that can be requested without authentication, so that
+	// load balancers can check health of the service.
+	routeHealth        = "health"
+	routeClusterHealth = "clusterHealth"
+	routePing          = "ping"
+
+	// Names of routes that take topics and consumer groups in form parameters
+	// rather than in the URL path.
+	routeConsume                  = "consume"
+	routeClusterConsume           = "clusterConsume"
+	routeConsumeBatch             = "consumeBatch"
+	routeClusterConsumeBatch      = "clusterConsumeBatch"
+	routeConsumePattern           = "consumePattern"
+	routeClusterConsumePattern    = "clusterConsumePattern"
+	routeAck                      = "ack"
+	routeClusterAck               = "clusterAck"
+	routeGetOffsets               = "getOffsets"
+	routeClusterGetOffsets        = "clusterGetOffsets"
+	routeGetGroupStatus           = "getGroupStatus"
+	routeClusterGetGroupStatus    = "clusterGetGroupStatus"
+	routeSetOffsets               = "setOffsets"
+	routeClusterSetOffsets        = "clusterSetOffsets"
+	routePause                    = "pause"
+	routeClusterPause             = "clusterPause"
+	routeResume                   = "resume"
+	routeClusterResume            = "clusterResume"
+	routeGetTopicConsumers        = "getTopicConsumers"
+	routeClusterGetTopicConsumers = "clusterGetTopicConsumers"
+
+	// Names of routes of administrative operations, that only API keys with
+	// admin permission may request.
+	routeCreateTopic             = "createTopic"
+	routeClusterCreateTopic      = "clusterCreateTopic"
+	routeDeleteTopic             = "deleteTopic"
+	routeClusterDeleteTopic      = "clusterDeleteTopic"
+	routeCreatePartitions        = "createPartitions"
+	routeClusterCreatePartitions = "clusterCreatePartitions"
+	routeAlterTopicConfig        = "alterTopicConfig"
+	routeClusterAlterTopicConfig = "clusterAlterTopicConfig"
+	routeDeleteGroup             = "deleteGroup"
+	routeClusterDeleteGroup      = "clusterDeleteGroup"
+
+	// The maximum size of a form encoded request body that is read to
+	// authorize a request.
+	maxFormBytes = 1 << 20
+
+	bearerPrefix = "Bearer "
+)
+
+var formRoutes = map[string]bool{
+	routeConsume:                  true,
+	routeClusterConsume:           true,
+	routeConsumeBatch:             true,
+	routeClusterConsumeBatch:      true,
+	routeConsumePattern:           true,
+	routeClusterConsumePattern:    true,
+	routeAck:                      true,
+	routeClusterAck:               true,
+	routeGetOffsets:               true,
+	routeClusterGetOffsets:        true,
+	routeGetGroupStatus:           true,
+	routeClusterGetGroupStatus:    true,
+	routeSetOffsets:               true,
+	routeClusterSetOffsets:        true,
+	routePause:                    true,
+	routeClusterPause:             true,
+	routeResume:                   true,
+	routeClusterResume:            true,
+	routeGetTopicConsumers:        true,
+	routeClusterGetTopicConsumers: true,
+}
+
+var adminRoutes = map[string]bool{
+	routeSetOffsets:              true,
+	routeClusterSetOffsets:       true,
+	routeCreateTopic:             true,
+	routeClusterCreateTopic:      true,
+	routeDeleteTopic:             true,
+	routeClusterDeleteTopic:      true,
+	routeCreatePartitions:        true,
+	routeClusterCreatePartitions: true,
+	routeAlterTopicConfig:        true,
+	routeClusterAlterTopicConfig: true,
+	routeDeleteGroup:             true,
+	routeClusterDeleteGroup:      true,
+}
+
+// authHandler wraps the given handler so that requests are only passed to it
+// if they are authorized by `authz` to access the topic and consumer groups
+// they specify. Requests that do not match any route of the router are passed
+// through, for the router to respond with 404. Request bodies are not read
+// until the API key is authenticated, and then only by routes that take
+// topics and consumer groups in form parameters.
+func (s *T) authHandler(authz auth.Authorizer, router *mux.Router, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var match mux.RouteMatch
+		if !router.Match(r, &match) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		switch match.Route.GetName() {
+		case routeHealth, routeClusterHealth, routePing:
+			h.ServeHTTP(w, r)
+			return
+		}
+		token := getToken(r)
+		if err := authz.Authenticate(token); err != nil {
+			w.Header().Set(hdrWWWAuthenticate, "Bearer")
+			s.respondWithJSON(w, http.StatusUnauthorized, errorRs{err.Error()})
+			return
+		}
+		var form url.Values
+		if formRoutes[match.Route.GetName()] {
+			var err error
+			if form, err = parseFormCopy(w, r); err != nil {
+				s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+				return
+			}
+		}
+		var rscs []auth.Resource
+		switch {
+		case match.Vars[prmTopic] != "":
+			rscs = []auth.Resource{{Topic: match.Vars[prmTopic]}}
+		case form.Get(prmTopicPattern) != "":
+			rscs = []auth.Resource{{AnyTopic: true}}
+		case len(form[prmTopic]) > 0:
+			// Consumption from several topics at once. The topic to ack a
+			// message of is authorized too, even though the handler rejects
+			// it unless it is among the consumed topics.
+			for _, topic := range form[prmTopic] {
+				rscs = append(rscs, auth.Resource{Topic: topic})
+			}
+			if ackTopic := form.Get(prmAckTopic); ackTopic != "" && !hasTopic(ackTopic, form[prmTopic]) {
+				rscs = append(rscs, auth.Resource{Topic: ackTopic})
+			}
+		}
+		groups := form[prmGroup]
+		if group := match.Vars[prmGroup]; group != "" {
+			groups = append(groups, group)
+		}
+		if len(rscs) == 0 {
+			rscs = []auth.Resource{{}}
+			// Requests that specify neither a topic nor a group, e.g.
+			// listings of topics and groups, operate on the whole cluster.
+			if len(groups) == 0 {
+				rscs[0].AnyTopic, rscs[0].AnyGroup = true, true
+			}
+		}
+		if len(groups) == 0 {
+			groups = []string{""}
+		}
+		isAdmin := adminRoutes[match.Route.GetName()]
+		for _, rsc := range rscs {
+			for _, group := range groups {
+				rsc.Group = group
+				rsc.Admin = isAdmin
+				switch err := authz.Authorize(token, rsc); err {
+				case nil:
+				case auth.ErrUnauthenticated:
+					w.Header().Set(hdrWWWAuthenticate, "Bearer")
+					s.respondWithJSON(w, http.StatusUnauthorized, errorRs{err.Error()})
+					return
+				default:
+					s.respondWithJSON(w, http.StatusForbidden, errorRs{err.Error()})
+					return
+				}
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// getToken returns a token given either in the `Authorization` header with
+// the Bearer scheme or in the `X-Api-Key` header.
+func getToken(r *http.Request) string {
+	if authorization := r.Header.Get(hdrAuthorization); strings.HasPrefix(authorization, bearerPrefix) {
+		return strings.TrimPrefix(authorization, bearerPrefix)
+	}
+	return r.Header.Get(hdrAPIKey)
+}
+
+// parseFormCopy returns request parameters given either in the URL query or
+// in a form encoded body, leaving the body intact for a handler to read. Only
+// form encoded bodies are read, and they are limited to maxFormBytes.
+func parseFormCopy(w http.ResponseWriter, r *http.Request) (url.Values, error) {
+	var consumed bytes.Buffer
+	rc := *r
+	rc.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(http.MaxBytesReader(w, r.Body, maxFormBytes), &consumed), r.Body}
+	err := rc.ParseForm()
+	// Whatever has been consumed by ParseForm is replayed to the handler.
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(&consumed, r.Body), r.Body}
+	if err != nil {
+		return nil, err
+	}
+	return rc.Form, nil
+}
@@ -0,0 +1,128 @@
+package httpsrv
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// compressHandler wraps the given handler so that its responses are
+// compressed with gzip or deflate, whichever is preferred by a client in the
+// `Accept-Encoding` header. Responses to clients that accept neither, and to
+// HEAD requests, are passed through intact.
+func compressHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(hdrVary, hdrAcceptEncoding)
+		encoding := selectEncoding(r.Header.Get(hdrAcceptEncoding))
+		if encoding == "" || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+		var cw io.WriteCloser
+		switch encoding {
+		case encodingGzip:
+			cw = gzip.NewWriter(w)
+		case encodingDeflate:
+			// Only an invalid compression level results in an error.
+			cw, _ = flate.NewWriter(w, flate.DefaultCompression)
+		}
+		w.Header().Set(hdrContentEncoding, encoding)
+		crw := &compressResponseWriter{ResponseWriter: w, cw: cw}
+		defer crw.close()
+		h.ServeHTTP(crw, r)
+	})
+}
+
+// selectEncoding returns a compression encoding supported by the server that
+// a client accepts according to the given `Accept-Encoding` header value,
+// preferring gzip over deflate. An empty string is returned if there is none.
+func selectEncoding(acceptEncoding string) string {
+	var gzipOK, deflateOK bool
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(item, ";")
+		if !isAcceptable(parts[1:]) {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case encodingGzip:
+			gzipOK = true
+		case encodingDeflate:
+			deflateOK = true
+		}
+	}
+	switch {
+	case gzipOK:
+		return encodingGzip
+	case deflateOK:
+		return encodingDeflate
+	}
+	return ""
+}
+
+// isAcceptable tells whether a content coding with the given parameters is
+// acceptable, that is whether its quality value is not explicitly zero.
+func isAcceptable(params []string) bool {
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+		q, err := strconv.ParseFloat(param[2:], 64)
+		return err != nil || q > 0
+	}
+	return true
+}
+
+// compressResponseWriter passes the response body through a compressing
+// writer. Compression is abandoned if a handler responds with no body.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	cw          io.WriteCloser
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (crw *compressResponseWriter) WriteHeader(status int) {
+	if crw.wroteHeader {
+		return
+	}
+	crw.wroteHeader = true
+	// Content length of a compressed body is not known in advance.
+	crw.Header().Del(hdrContentLength)
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		crw.Header().Del(hdrContentEncoding)
+		crw.cw = nil
+	}
+	crw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (crw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !crw.wroteHeader {
+		crw.WriteHeader(http.StatusOK)
+	}
+	if crw.cw == nil {
+		return crw.ResponseWriter.Write(b)
+	}
+	return crw.cw.Write(b)
+}
+
+func (crw *compressResponseWriter) close() {
+	if crw.cw == nil {
+		return
+	}
+	if !crw.wroteHeader {
+		// The handler wrote nothing at all, so there is no body to compress.
+		crw.Header().Del(hdrContentEncoding)
+		return
+	}
+	crw.cw.Close()
+}
@@ -0,0 +1,53 @@
+package httpsrv
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+type CompressSuite struct{}
+
+var _ = Suite(&CompressSuite{})
+
+func (s *CompressSuite) TestSelectEncoding(c *C) {
+	for i, tc := range []struct {
+		acceptEncoding string
+		encoding       string
+	}{
+		{acceptEncoding: "", encoding: ""},
+		{acceptEncoding: "identity", encoding: ""},
+		{acceptEncoding: "br", encoding: ""},
+		{acceptEncoding: "gzip", encoding: "gzip"},
+		{acceptEncoding: "deflate", encoding: "deflate"},
+		{acceptEncoding: "deflate, gzip", encoding: "gzip"},
+		{acceptEncoding: " GZIP ;q=0.5, deflate", encoding: "gzip"},
+		{acceptEncoding: "gzip;q=0, deflate", encoding: "deflate"},
+		{acceptEncoding: "gzip;q=0.0, deflate;q=0", encoding: ""},
+		{acceptEncoding: "*", encoding: ""},
+	} {
+		c.Assert(selectEncoding(tc.acceptEncoding), Equals, tc.encoding, Commentf("case #%d", i))
+	}
+}
+
+func (s *CompressSuite) TestIsAcceptable(c *C) {
+	for i, tc := range []struct {
+		params     []string
+		acceptable bool
+	}{
+		{params: nil, acceptable: true},
+		{params: []string{"q=1"}, acceptable: true},
+		{params: []string{" q=0.001"}, acceptable: true},
+		{params: []string{"q=0"}, acceptable: false},
+		{params: []string{"q=0.000"}, acceptable: false},
+		{params: []string{"foo=bar", "q=0"}, acceptable: false},
+		// A malformed quality value does not make a coding unacceptable.
+		{params: []string{"q=bogus"}, acceptable: true},
+	} {
+		c.Assert(isAcceptable(tc.params), Equals, tc.acceptable, Commentf("case #%d", i))
+	}
+}
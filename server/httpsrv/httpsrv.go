@@ -2,26 +2,35 @@ package httpsrv
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/gorilla/mux"
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/admin"
+	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/consumer"
 	"github.com/mailgun/kafka-pixy/consumer/offsettrk"
 	"github.com/mailgun/kafka-pixy/offsetmgr"
 	"github.com/mailgun/kafka-pixy/prettyfmt"
+	"github.com/mailgun/kafka-pixy/producer"
 	"github.com/mailgun/kafka-pixy/proxy"
+	"github.com/mailgun/kafka-pixy/schemareg"
+	"github.com/mailgun/kafka-pixy/server/auth"
 	"github.com/pkg/errors"
+	"github.com/rcrowley/go-metrics"
 )
 
 const (
@@ -31,20 +40,78 @@ const (
 	// HTTP headers used by the API.
 	hdrContentLength = "Content-Length"
 	hdrContentType   = "Content-Type"
+	hdrAccept        = "Accept"
+	hdrRetryAfter    = "Retry-After"
+
+	// HTTP headers used to negotiate response compression.
+	hdrAcceptEncoding  = "Accept-Encoding"
+	hdrContentEncoding = "Content-Encoding"
+	hdrVary            = "Vary"
+
+	// HTTP headers used to authenticate requests.
+	hdrAuthorization   = "Authorization"
+	hdrAPIKey          = "X-Api-Key"
+	hdrWWWAuthenticate = "WWW-Authenticate"
+
+	// A logical ID of the service that makes a produce or consume request.
+	// It is used to attribute load and errors to particular services.
+	hdrClientID = "X-Kafka-Pixy-Client-Id"
+
+	// HTTP headers used to return message properties along with a raw message
+	// body, when a message is consumed in the raw mode.
+	hdrKafkaKey             = "X-Kafka-Key"
+	hdrKafkaKeyUndefined    = "X-Kafka-Key-Undefined"
+	hdrKafkaTopic           = "X-Kafka-Topic"
+	hdrKafkaPartition       = "X-Kafka-Partition"
+	hdrKafkaOffset          = "X-Kafka-Offset"
+	hdrKafkaHighWaterMark   = "X-Kafka-High-Water-Mark"
+	hdrKafkaCommittedOffset = "X-Kafka-Committed-Offset"
+	hdrKafkaLikelyDuplicate = "X-Kafka-Likely-Duplicate"
+
+	// An Avro schema to encode a produced message with, when the message is
+	// produced with the avro encoding.
+	hdrKafkaAvroSchema = "X-Kafka-Avro-Schema"
+
+	contentTypeOctetStream = "application/octet-stream"
+
+	// Message encoding that converts JSON message values to the Confluent
+	// Avro wire format on produce and back on consume.
+	msgEncodingAvro = "avro"
+
+	// The number of messages returned by the batch consume endpoint, unless
+	// maxMessages parameter is given.
+	defaultMaxMessages = 10
 
 	// HTTP request parameters.
 	prmCluster              = "cluster"
 	prmTopic                = "topic"
+	prmTopicPattern         = "topicPattern"
+	prmAckTopic             = "ackTopic"
 	prmKey                  = "key"
 	prmSync                 = "sync"
+	prmRequiredAcks         = "requiredAcks"
+	prmCompression          = "compression"
+	prmRetryMax             = "retryMax"
+	prmTimestamp            = "timestamp"
 	prmGroup                = "group"
 	prmNoAck                = "noAck"
+	prmUpTo                 = "upTo"
 	prmAckPartition         = "ackPartition"
 	prmPartition            = "partition"
 	prmAckOffset            = "ackOffset"
 	prmOffset               = "offset"
 	prmTopicsWithPartitions = "withPartitions"
 	prmTopicsWithConfig     = "withConfig"
+	prmTopicsPrefix         = "prefix"
+	prmTopicsPattern        = "pattern"
+	prmTopicsConfig         = "config"
+	prmLongPollingTimeout   = "timeout"
+	prmMaxMessages          = "maxMessages"
+	prmMaxWait              = "maxWait"
+	prmRetention            = "retention"
+	prmNoDecrease           = "noDecrease"
+	prmInitialOffset        = "initialOffset"
+	prmEncoding             = "encoding"
 )
 
 var (
@@ -63,8 +130,11 @@ type T struct {
 
 // New creates an HTTP server instance that will accept API requests at the
 // specified `network`/`address` and execute them with the specified `producer`,
-// `consumer`, or `admin`, depending on the request type.
-func New(addr string, proxySet *proxy.Set) (*T, error) {
+// `consumer`, or `admin`, depending on the request type. If `compress` is
+// true then responses are gzip/deflate compressed for clients that accept it.
+// If `tlsCfg` is not nil, then clients have to connect over TLS. If `authz`
+// is not nil, then it authorizes all requests but health checks.
+func New(addr string, compress bool, tlsCfg *tls.Config, authz auth.Authorizer, proxySet *proxy.Set) (*T, error) {
 	network := networkUnix
 	if strings.Contains(addr, ":") {
 		network = networkTCP
@@ -80,35 +150,65 @@ func New(addr string, proxySet *proxy.Set) (*T, error) {
 			return nil, errors.Wrap(err, "failed to change socket permissions")
 		}
 	}
+	if tlsCfg != nil {
+		listener = tls.NewListener(listener, tlsCfg)
+	}
 	// Create a graceful HTTP server instance.
 	router := mux.NewRouter()
-	httpServer := &http.Server{Handler: router}
 	hs := &T{
-		actDesc:    actor.Root().NewChild(fmt.Sprintf("http://%s", addr)),
-		addr:       addr,
-		listener:   listener,
-		httpServer: httpServer,
-		proxySet:   proxySet,
-		errorCh:    make(chan error, 1),
+		actDesc:  actor.Root().NewChild(fmt.Sprintf("http://%s", addr)),
+		addr:     addr,
+		listener: listener,
+		proxySet: proxySet,
+		errorCh:  make(chan error, 1),
+	}
+	var handler http.Handler = router
+	if authz != nil {
+		handler = hs.authHandler(authz, router, handler)
 	}
+	if compress {
+		handler = compressHandler(handler)
+	}
+	hs.httpServer = &http.Server{Handler: handler}
 	// Configure the API request handlers.
 	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/messages", prmCluster, prmTopic), hs.handleProduce).Methods("POST")
 	router.HandleFunc(fmt.Sprintf("/topics/{%s}/messages", prmTopic), hs.handleProduce).Methods("POST")
 
-	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/messages", prmCluster, prmTopic), hs.handleConsume).Methods("GET")
-	router.HandleFunc(fmt.Sprintf("/topics/{%s}/messages", prmTopic), hs.handleConsume).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/messages", prmCluster, prmTopic), hs.handleConsume).Methods("GET").Name(routeClusterConsume)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/messages", prmTopic), hs.handleConsume).Methods("GET").Name(routeConsume)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/messages/batch", prmCluster, prmTopic), hs.handleConsumeBatch).Methods("GET").Name(routeClusterConsumeBatch)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/messages/batch", prmTopic), hs.handleConsumeBatch).Methods("GET").Name(routeConsumeBatch)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/messages", prmCluster), hs.handleConsumePattern).Methods("GET").Name(routeClusterConsumePattern)
+	router.HandleFunc("/messages", hs.handleConsumePattern).Methods("GET").Name(routeConsumePattern)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/acks", prmCluster, prmTopic), hs.handleAck).Methods("POST").Name(routeClusterAck)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/acks", prmTopic), hs.handleAck).Methods("POST").Name(routeAck)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/offsets", prmCluster, prmTopic), hs.handleGetOffsets).Methods("GET").Name(routeClusterGetOffsets)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/offsets", prmTopic), hs.handleGetOffsets).Methods("GET").Name(routeGetOffsets)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/offsets/range", prmCluster, prmTopic), hs.handleGetTopicOffsets).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/offsets/range", prmTopic), hs.handleGetTopicOffsets).Methods("GET")
 
-	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/acks", prmCluster, prmTopic), hs.handleAck).Methods("POST")
-	router.HandleFunc(fmt.Sprintf("/topics/{%s}/acks", prmTopic), hs.handleAck).Methods("POST")
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/partitions/{%s}/offsets/{%s}", prmCluster, prmTopic, prmPartition, prmOffset), hs.handleGetMessage).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/partitions/{%s}/offsets/{%s}", prmTopic, prmPartition, prmOffset), hs.handleGetMessage).Methods("GET")
 
-	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/offsets", prmCluster, prmTopic), hs.handleGetOffsets).Methods("GET")
-	router.HandleFunc(fmt.Sprintf("/topics/{%s}/offsets", prmTopic), hs.handleGetOffsets).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/status", prmCluster, prmTopic), hs.handleGetGroupStatus).Methods("GET").Name(routeClusterGetGroupStatus)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/status", prmTopic), hs.handleGetGroupStatus).Methods("GET").Name(routeGetGroupStatus)
 
-	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/offsets", prmCluster, prmTopic), hs.handleSetOffsets).Methods("POST")
-	router.HandleFunc(fmt.Sprintf("/topics/{%s}/offsets", prmTopic), hs.handleSetOffsets).Methods("POST")
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/offsets", prmCluster, prmTopic), hs.handleSetOffsets).Methods("POST").Name(routeClusterSetOffsets)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/offsets", prmTopic), hs.handleSetOffsets).Methods("POST").Name(routeSetOffsets)
 
-	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/consumers", prmCluster, prmTopic), hs.handleGetTopicConsumers).Methods("GET")
-	router.HandleFunc(fmt.Sprintf("/topics/{%s}/consumers", prmTopic), hs.handleGetTopicConsumers).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/pause", prmCluster, prmTopic), hs.handlePause).Methods("POST").Name(routeClusterPause)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/pause", prmTopic), hs.handlePause).Methods("POST").Name(routePause)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/resume", prmCluster, prmTopic), hs.handleResume).Methods("POST").Name(routeClusterResume)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/resume", prmTopic), hs.handleResume).Methods("POST").Name(routeResume)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/consumers", prmCluster, prmTopic), hs.handleGetTopicConsumers).Methods("GET").Name(routeClusterGetTopicConsumers)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/consumers", prmTopic), hs.handleGetTopicConsumers).Methods("GET").Name(routeGetTopicConsumers)
 
 	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics", prmCluster), hs.handleListTopics).Methods("GET")
 	router.HandleFunc("/topics", hs.handleListTopics).Methods("GET")
@@ -116,7 +216,42 @@ func New(addr string, proxySet *proxy.Set) (*T, error) {
 	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}", prmCluster, prmTopic), hs.handleGetTopicMetadata).Methods("GET")
 	router.HandleFunc(fmt.Sprintf("/topics/{%s}", prmTopic), hs.handleGetTopicMetadata).Methods("GET")
 
-	router.HandleFunc("/_ping", hs.handlePing).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}", prmCluster, prmTopic), hs.handleCreateTopic).Methods("POST").Name(routeClusterCreateTopic)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}", prmTopic), hs.handleCreateTopic).Methods("POST").Name(routeCreateTopic)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}", prmCluster, prmTopic), hs.handleDeleteTopic).Methods("DELETE").Name(routeClusterDeleteTopic)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}", prmTopic), hs.handleDeleteTopic).Methods("DELETE").Name(routeDeleteTopic)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/partitions", prmCluster, prmTopic), hs.handleCreatePartitions).Methods("POST").Name(routeClusterCreatePartitions)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/partitions", prmTopic), hs.handleCreatePartitions).Methods("POST").Name(routeCreatePartitions)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/topics/{%s}/config", prmCluster, prmTopic), hs.handleAlterTopicConfig).Methods("POST").Name(routeClusterAlterTopicConfig)
+	router.HandleFunc(fmt.Sprintf("/topics/{%s}/config", prmTopic), hs.handleAlterTopicConfig).Methods("POST").Name(routeAlterTopicConfig)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/groups", prmCluster), hs.handleListGroups).Methods("GET")
+	router.HandleFunc("/groups", hs.handleListGroups).Methods("GET")
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/groups/{%s}", prmCluster, prmGroup), hs.handleDescribeGroup).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/groups/{%s}", prmGroup), hs.handleDescribeGroup).Methods("GET")
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/groups/{%s}", prmCluster, prmGroup), hs.handleDeleteGroup).Methods("DELETE").Name(routeClusterDeleteGroup)
+	router.HandleFunc(fmt.Sprintf("/groups/{%s}", prmGroup), hs.handleDeleteGroup).Methods("DELETE").Name(routeDeleteGroup)
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/groups/{%s}/commit_errors", prmCluster, prmGroup), hs.handleGetCommitErrors).Methods("GET")
+	router.HandleFunc(fmt.Sprintf("/groups/{%s}/commit_errors", prmGroup), hs.handleGetCommitErrors).Methods("GET")
+
+	router.HandleFunc("/clusters", hs.handleListClusters).Methods("GET")
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/brokers", prmCluster), hs.handleGetClusterMetadata).Methods("GET")
+	router.HandleFunc("/brokers", hs.handleGetClusterMetadata).Methods("GET")
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/_metrics", prmCluster), hs.handleGetMetrics).Methods("GET")
+	router.HandleFunc("/_metrics", hs.handleGetMetrics).Methods("GET")
+
+	router.HandleFunc(fmt.Sprintf("/clusters/{%s}/health", prmCluster), hs.handleHealth).Methods("GET").Name(routeClusterHealth)
+	router.HandleFunc("/health", hs.handleHealth).Methods("GET").Name(routeHealth)
+
+	router.HandleFunc("/_ping", hs.handlePing).Methods("GET").Name(routePing)
 	return hs, nil
 }
 
@@ -170,32 +305,104 @@ func (s *T) handleProduce(w http.ResponseWriter, r *http.Request) {
 		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
 		return
 	}
+	isAvro, err := getAvroParam(r, pxy)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	if isAvro {
+		value, _ := msg.Encode()
+		encoded, err := pxy.EncodeAvro(topic, r.Header.Get(hdrKafkaAvroSchema), value)
+		if err != nil {
+			s.respondWithJSON(w, avroErrorStatus(err), errorRs{err.Error()})
+			return
+		}
+		msg = sarama.ByteEncoder(encoded)
+	}
 
 	// Asynchronously submit the message to the Kafka cluster.
 	if !isSync {
-		pxy.AsyncProduce(topic, toEncoderPreservingNil(key), msg)
+		if err := pxy.AsyncProduceBounded(r.Context(), topic, toEncoderPreservingNil(key), msg, r.Header.Get(hdrClientID)); err != nil {
+			status := http.StatusServiceUnavailable
+			if err == proxy.ErrQueueFull {
+				status = http.StatusTooManyRequests
+			} else if err == (proxy.ErrTopicNotFound{Topic: topic}) {
+				status = http.StatusNotFound
+			} else if err == proxy.ErrCircuitOpen {
+				setRetryAfter(w, pxy.RetryAfter(err))
+			}
+			s.respondWithJSON(w, status, errorRs{err.Error()})
+			return
+		}
 		s.respondWithJSON(w, http.StatusOK, EmptyResponse)
 		return
 	}
 
-	prodMsg, err := pxy.Produce(topic, toEncoderPreservingNil(key), msg)
+	opts := proxy.ProduceOpts{ClientID: r.Header.Get(hdrClientID)}
+	if requiredAcksStr := r.FormValue(prmRequiredAcks); requiredAcksStr != "" {
+		var requiredAcks config.RequiredAcks
+		if err := requiredAcks.UnmarshalText([]byte(requiredAcksStr)); err != nil {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+			return
+		}
+		opts.RequiredAcks = (*sarama.RequiredAcks)(&requiredAcks)
+	}
+	if compressionStr := r.FormValue(prmCompression); compressionStr != "" {
+		var compression config.Compression
+		if err := compression.UnmarshalText([]byte(compressionStr)); err != nil {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+			return
+		}
+		opts.Compression = (*sarama.CompressionCodec)(&compression)
+	}
+	if retryMaxStr := r.FormValue(prmRetryMax); retryMaxStr != "" {
+		retryMax, err := strconv.Atoi(retryMaxStr)
+		if err != nil || retryMax < 0 {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmRetryMax, retryMaxStr)})
+			return
+		}
+		opts.RetryMax = &retryMax
+	}
+	if timestampStr := r.FormValue(prmTimestamp); timestampStr != "" {
+		timestampMs, err := strconv.ParseInt(timestampStr, 10, 64)
+		if err != nil {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmTimestamp, timestampStr)})
+			return
+		}
+		opts.Timestamp = time.Unix(0, timestampMs*int64(time.Millisecond))
+	}
+	prodMsg, requiredAcks, err := pxy.ProduceWithOpts(r.Context(), topic, toEncoderPreservingNil(key), msg, opts)
 	if err != nil {
 		var status int
-		switch err {
-		case sarama.ErrUnknownTopicOrPartition:
+		switch errors.Cause(err) {
+		case sarama.ErrUnknownTopicOrPartition, proxy.ErrTopicNotFound{Topic: topic}:
 			status = http.StatusNotFound
+		case proxy.ErrCompressionUnsupported, proxy.ErrTimestampUnsupported:
+			status = http.StatusBadRequest
+		case proxy.ErrMessageTooLarge, sarama.ErrMessageSizeTooLarge:
+			status = http.StatusRequestEntityTooLarge
 		case proxy.ErrUnavailable:
 			status = http.StatusServiceUnavailable
+		case proxy.ErrCircuitOpen, proxy.ErrNotEnoughReplicas{}, proxy.ErrNotEnoughReplicas{AfterAppend: true}:
+			setRetryAfter(w, pxy.RetryAfter(err))
+			status = http.StatusServiceUnavailable
+		case proxy.ErrProduceTimeout:
+			status = http.StatusGatewayTimeout
 		default:
 			status = http.StatusInternalServerError
 		}
-		s.respondWithJSON(w, status, errorRs{err.Error()})
+		kafkaErrorCode, _ := proxy.KafkaErrorCode(err)
+		s.respondWithJSON(w, status, produceErrorRs{Error: err.Error(), KafkaErrorCode: int16(kafkaErrorCode)})
 		return
 	}
 
+	msgInfo := producer.MessageInfo(prodMsg)
 	s.respondWithJSON(w, http.StatusOK, produceRs{
-		Partition: prodMsg.Partition,
-		Offset:    prodMsg.Offset,
+		Partition:      prodMsg.Partition,
+		Offset:         prodMsg.Offset,
+		RequiredAcks:   config.RequiredAcks(requiredAcks).String(),
+		SerializedSize: msgInfo.SerializedBytes,
+		Compression:    config.Compression(msgInfo.Compression).String(),
 	})
 }
 
@@ -251,15 +458,223 @@ func (s *T) handleConsume(w http.ResponseWriter, r *http.Request) {
 		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
 		return
 	}
+	opts := proxy.ConsumeOpts{ClientID: r.Header.Get(hdrClientID)}
+	if timeoutStr := r.FormValue(prmLongPollingTimeout); timeoutStr != "" {
+		if opts.LongPollingTimeout, err = time.ParseDuration(timeoutStr); err != nil {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmLongPollingTimeout, timeoutStr)})
+			return
+		}
+	}
+	if opts.InitialOffset, err = proxy.ParseInitialOffset(r.FormValue(prmInitialOffset)); err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	isAvro, err := getAvroParam(r, pxy)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+
+	ackDecoded := isAvro && ack == proxy.AutoAck()
+	if ackDecoded {
+		ack = proxy.NoAck()
+	}
+	consMsg, err := pxy.ConsumeWithOpts(r.Context(), group, topic, ack, opts)
+	if err == nil && isAvro {
+		consMsg.Value, err = pxy.DecodeAvro(consMsg.Value)
+	}
+	if err == nil && ackDecoded {
+		err = ackConsumed(pxy, group, consMsg)
+	}
+	s.respondWithConsumed(w, r, pxy, &consMsg, err)
+}
+
+// handleConsumePattern is an HTTP request handler for `GET /messages`
+func (s *T) handleConsumePattern(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	pattern := r.FormValue(prmTopicPattern)
+	topics := r.Form[prmTopic]
+	if (pattern == "") == (len(topics) == 0) {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("either %s or %s must be specified", prmTopicPattern, prmTopic)})
+		return
+	}
+	group, err := getGroupParam(r, false)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	ack, err := parseAck(r, true)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	if ack != proxy.NoAck() && ack != proxy.AutoAck() {
+		ackTopic := r.FormValue(prmAckTopic)
+		if ackTopic == "" {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("%s is not specified", prmAckTopic)})
+			return
+		}
+		if len(topics) > 0 && !hasTopic(ackTopic, topics) {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("%s %s is not among %ss", prmAckTopic, ackTopic, prmTopic)})
+			return
+		}
+		ack = ack.WithTopic(ackTopic)
+	}
+	isAvro, err := getAvroParam(r, pxy)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+
+	ackDecoded := isAvro && ack == proxy.AutoAck()
+	if ackDecoded {
+		ack = proxy.NoAck()
+	}
+	var consMsg consumer.Message
+	if pattern != "" {
+		consMsg, err = pxy.ConsumePattern(group, pattern, ack, r.Header.Get(hdrClientID))
+	} else {
+		consMsg, err = pxy.ConsumeTopics(group, topics, ack, r.Header.Get(hdrClientID))
+	}
+	if err == nil && isAvro {
+		consMsg.Value, err = pxy.DecodeAvro(consMsg.Value)
+	}
+	if err == nil && ackDecoded {
+		err = ackConsumed(pxy, group, consMsg)
+	}
+	s.respondWithConsumed(w, r, pxy, &consMsg, err)
+}
+
+// handleConsumeBatch is an HTTP request handler for
+// `GET /topics/{topic}/messages/batch`
+func (s *T) handleConsumeBatch(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+	group, err := getGroupParam(r, false)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	ack, err := parseAck(r, true)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	maxMessages := defaultMaxMessages
+	if maxMessagesStr := r.FormValue(prmMaxMessages); maxMessagesStr != "" {
+		if maxMessages, err = strconv.Atoi(maxMessagesStr); err != nil || maxMessages <= 0 {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmMaxMessages, maxMessagesStr)})
+			return
+		}
+	}
+	var maxWait time.Duration
+	if maxWaitStr := r.FormValue(prmMaxWait); maxWaitStr != "" {
+		if maxWait, err = time.ParseDuration(maxWaitStr); err != nil {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmMaxWait, maxWaitStr)})
+			return
+		}
+	}
+	isAvro, err := getAvroParam(r, pxy)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+
+	ackDecoded := isAvro && ack == proxy.AutoAck()
+	if ackDecoded {
+		ack = proxy.NoAck()
+	}
+	consMsgs, err := pxy.ConsumeBatch(group, topic, maxMessages, maxWait, ack, r.Header.Get(hdrClientID))
+	if err != nil {
+		s.respondWithConsumed(w, r, pxy, nil, err)
+		return
+	}
+	res := make([]consumeRs, len(consMsgs))
+	for i, consMsg := range consMsgs {
+		if isAvro {
+			if consMsg.Value, err = pxy.DecodeAvro(consMsg.Value); err != nil {
+				s.respondWithConsumed(w, r, pxy, nil, err)
+				return
+			}
+		}
+		res[i] = consumeRs{
+			Key:             consMsg.Key,
+			Value:           consMsg.Value,
+			Topic:           consMsg.Topic,
+			Partition:       consMsg.Partition,
+			Offset:          consMsg.Offset,
+			HighWaterMark:   consMsg.HighWaterMark,
+			CommittedOffset: consMsg.CommittedOffset,
+			LikelyDuplicate: consMsg.LikelyDuplicate,
+		}
+	}
+	if ackDecoded {
+		if err := ackConsumed(pxy, group, consMsgs...); err != nil {
+			s.respondWithConsumed(w, r, pxy, nil, err)
+			return
+		}
+	}
+	s.respondWithJSON(w, http.StatusOK, res)
+}
+
+// ackConsumed acknowledges messages consumed with proxy.NoAck. Messages that
+// are to be Avro decoded are consumed that way instead of with
+// proxy.AutoAck, and acknowledged only after all of them are decoded. So a
+// message that cannot be decoded is offered again after
+// `consumer.ack_timeout` rather than lost.
+func ackConsumed(pxy *proxy.T, group string, consMsgs ...consumer.Message) error {
+	for _, consMsg := range consMsgs {
+		ack, err := proxy.NewAck(consMsg.Partition, consMsg.Offset)
+		if err != nil {
+			return err
+		}
+		if err := pxy.Ack(group, consMsg.Topic, ack); err != nil {
+			return errors.Wrapf(err, "failed to ack decoded message, partition=%d, offset=%d",
+				consMsg.Partition, consMsg.Offset)
+		}
+	}
+	return nil
+}
+
+// setRetryAfter tells a client how long to wait before retrying a request.
+// The header value is in whole seconds, so the delay is rounded up, and it
+// is at least one second.
+func setRetryAfter(w http.ResponseWriter, delay time.Duration) {
+	seconds := int64((delay + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set(hdrRetryAfter, strconv.FormatInt(seconds, 10))
+}
 
-	consMsg, err := pxy.Consume(group, topic, ack)
+// respondWithConsumed sends either a consumed message or a consume error
+// in an HTTP response.
+func (s *T) respondWithConsumed(w http.ResponseWriter, r *http.Request, pxy *proxy.T, consMsg *consumer.Message, err error) {
 	if err != nil {
 		var status int
 		switch err {
 		case consumer.ErrRequestTimeout:
 			status = http.StatusRequestTimeout
-		case consumer.ErrTooManyRequests:
+		case consumer.ErrTooManyRequests, proxy.ErrTooManyRequests:
 			status = http.StatusTooManyRequests
+		case proxy.ErrRateLimited:
+			setRetryAfter(w, pxy.RetryAfter(err))
+			status = http.StatusTooManyRequests
+		case consumer.ErrRebalanceInProgress:
+			setRetryAfter(w, pxy.RetryAfter(err))
+			status = http.StatusServiceUnavailable
 		case consumer.ErrUnavailable:
 			fallthrough
 		case proxy.ErrUnavailable:
@@ -271,14 +686,48 @@ func (s *T) handleConsume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.Header.Get(hdrAccept) == contentTypeOctetStream {
+		s.respondWithRawMsg(w, consMsg)
+		return
+	}
 	s.respondWithJSON(w, http.StatusOK, consumeRs{
-		Key:       consMsg.Key,
-		Value:     consMsg.Value,
-		Partition: consMsg.Partition,
-		Offset:    consMsg.Offset,
+		Key:             consMsg.Key,
+		Value:           consMsg.Value,
+		Topic:           consMsg.Topic,
+		Partition:       consMsg.Partition,
+		Offset:          consMsg.Offset,
+		HighWaterMark:   consMsg.HighWaterMark,
+		CommittedOffset: consMsg.CommittedOffset,
+		LikelyDuplicate: consMsg.LikelyDuplicate,
 	})
 }
 
+// respondWithRawMsg sends the message value as is in an HTTP response body,
+// so that binary payloads do not have to be decoded from base64 by clients.
+// The rest of the message properties are returned in HTTP headers, with the
+// key being base64 encoded for it can be binary too.
+func (s *T) respondWithRawMsg(w http.ResponseWriter, consMsg *consumer.Message) {
+	if consMsg.Key == nil {
+		w.Header().Set(hdrKafkaKeyUndefined, "true")
+	} else {
+		w.Header().Set(hdrKafkaKey, base64.StdEncoding.EncodeToString(consMsg.Key))
+	}
+	w.Header().Set(hdrKafkaTopic, consMsg.Topic)
+	w.Header().Set(hdrKafkaPartition, strconv.Itoa(int(consMsg.Partition)))
+	w.Header().Set(hdrKafkaOffset, strconv.FormatInt(consMsg.Offset, 10))
+	w.Header().Set(hdrKafkaHighWaterMark, strconv.FormatInt(consMsg.HighWaterMark, 10))
+	w.Header().Set(hdrKafkaCommittedOffset, strconv.FormatInt(consMsg.CommittedOffset, 10))
+	if consMsg.LikelyDuplicate {
+		w.Header().Set(hdrKafkaLikelyDuplicate, "true")
+	}
+	w.Header().Set(hdrContentType, contentTypeOctetStream)
+	w.Header().Set(hdrContentLength, strconv.Itoa(len(consMsg.Value)))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(consMsg.Value); err != nil {
+		s.actDesc.Log().WithError(err).Errorf("Failed to send raw message")
+	}
+}
+
 // handleConsume is an HTTP request handler for `GET /topic/{topic}/messages`
 func (s *T) handleAck(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -300,7 +749,19 @@ func (s *T) handleAck(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = pxy.Ack(group, topic, ack)
+	if _, upTo := r.Form[prmUpTo]; upTo {
+		// The ack has been validated by parseAck already.
+		if ack == proxy.NoAck() || ack == proxy.AutoAck() {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{
+				fmt.Sprintf("%s requires %s and %s", prmUpTo, prmPartition, prmOffset)})
+			return
+		}
+		partition, _ := strconv.ParseInt(r.Form.Get(prmPartition), 10, 32)
+		offset, _ := strconv.ParseInt(r.Form.Get(prmOffset), 10, 64)
+		err = pxy.AckRange(group, topic, int32(partition), offset)
+	} else {
+		err = pxy.Ack(group, topic, ack)
+	}
 	if err != nil {
 		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
 		return
@@ -341,20 +802,134 @@ func (s *T) handleGetOffsets(w http.ResponseWriter, r *http.Request) {
 		offsetViews[i].End = po.End
 		offsetViews[i].Count = po.End - po.Begin
 		offsetViews[i].Offset = po.Offset
-		if po.Offset == sarama.OffsetNewest {
-			offsetViews[i].Lag = 0
-		} else if po.Offset == sarama.OffsetOldest {
-			offsetViews[i].Lag = po.End - po.Begin
-		} else {
-			offsetViews[i].Lag = po.End - po.Offset
-		}
+		offsetViews[i].Lag = po.Lag()
 		offsetViews[i].Metadata = po.Metadata
 		offset := offsetmgr.Offset{Val: po.Offset, Meta: po.Metadata}
 		offsetViews[i].SparseAcks = offsettrk.SparseAcks2Str(offset)
+		if po.CommittedBy != "" {
+			offsetViews[i].CommittedBy = po.CommittedBy
+			committedAt := po.CommittedAt
+			offsetViews[i].CommittedAt = &committedAt
+		}
 	}
 	s.respondWithJSON(w, http.StatusOK, offsetViews)
 }
 
+// handleGetTopicOffsets is an HTTP request handler for
+// `GET /topic/{topic}/offsets/range`
+func (s *T) handleGetTopicOffsets(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+
+	ranges, err := pxy.GetTopicOffsets(topic)
+	if err != nil {
+		if errors.Cause(err) == sarama.ErrUnknownTopicOrPartition {
+			s.respondWithJSON(w, http.StatusNotFound, errorRs{"Unknown topic"})
+			return
+		}
+		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
+		return
+	}
+
+	rangeViews := make([]partitionRange, len(ranges))
+	for i, pr := range ranges {
+		rangeViews[i].Partition = pr.Partition
+		rangeViews[i].Begin = pr.Begin
+		rangeViews[i].End = pr.End
+		rangeViews[i].Count = pr.End - pr.Begin
+	}
+	s.respondWithJSON(w, http.StatusOK, rangeViews)
+}
+
+// handleGetMessage is an HTTP request handler for
+// `GET /topics/{topic}/partitions/{partition}/offsets/{offset}`
+func (s *T) handleGetMessage(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+	partitionStr := mux.Vars(r)[prmPartition]
+	partition, err := strconv.ParseInt(partitionStr, 10, 32)
+	if err != nil || partition < 0 {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmPartition, partitionStr)})
+		return
+	}
+	offsetStr := mux.Vars(r)[prmOffset]
+	offset, err := strconv.ParseInt(offsetStr, 10, 64)
+	if err != nil || offset < 0 {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmOffset, offsetStr)})
+		return
+	}
+
+	consMsg, err := pxy.GetMessage(topic, int32(partition), offset)
+	if err != nil {
+		switch errors.Cause(err) {
+		case proxy.ErrMessageNotFound:
+			s.respondWithJSON(w, http.StatusNotFound, errorRs{err.Error()})
+			return
+		case sarama.ErrUnknownTopicOrPartition:
+			s.respondWithJSON(w, http.StatusNotFound, errorRs{"Unknown topic or partition"})
+			return
+		}
+	}
+	s.respondWithConsumed(w, r, pxy, &consMsg, err)
+}
+
+// handleGetGroupStatus is an HTTP request handler for
+// `GET /topic/{topic}/status`
+func (s *T) handleGetGroupStatus(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+	group, err := getGroupParam(r, false)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+
+	status, err := pxy.GetGroupStatus(group, topic)
+	if err != nil {
+		switch errors.Cause(err) {
+		case sarama.ErrUnknownTopicOrPartition:
+			s.respondWithJSON(w, http.StatusNotFound, errorRs{"Unknown topic"})
+		case admin.ErrOwnershipUnstable:
+			setRetryAfter(w, pxy.RetryAfter(err))
+			s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+		default:
+			s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
+		}
+		return
+	}
+
+	statusViews := make([]partitionStatus, len(status.Partitions))
+	for i, ps := range status.Partitions {
+		statusViews[i].Partition = ps.Partition
+		statusViews[i].Begin = ps.Begin
+		statusViews[i].End = ps.End
+		statusViews[i].Count = ps.End - ps.Begin
+		statusViews[i].Offset = ps.Offset
+		statusViews[i].Lag = ps.Lag
+		statusViews[i].Metadata = ps.Metadata
+		statusViews[i].Owner = ps.Owner
+	}
+	s.respondWithJSON(w, http.StatusOK, statusViews)
+}
+
 // handleGetOffsets is an HTTP request handler for `POST /topic/{topic}/offsets`
 func (s *T) handleSetOffsets(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -385,6 +960,15 @@ func (s *T) handleSetOffsets(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var opts admin.SetOffsetsOpts
+	if retentionStr := r.FormValue(prmRetention); retentionStr != "" {
+		if opts.Retention, err = time.ParseDuration(retentionStr); err != nil || opts.Retention <= 0 {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmRetention, retentionStr)})
+			return
+		}
+	}
+	_, opts.NoDecrease = r.Form[prmNoDecrease]
+
 	partitionOffsets := make([]admin.PartitionOffset, len(partitionOffsetViews))
 	for i, pov := range partitionOffsetViews {
 		partitionOffsets[i].Partition = pov.Partition
@@ -392,12 +976,20 @@ func (s *T) handleSetOffsets(w http.ResponseWriter, r *http.Request) {
 		partitionOffsets[i].Metadata = pov.Metadata
 	}
 
-	err = pxy.SetGroupOffsets(group, topic, partitionOffsets)
+	err = pxy.SetGroupOffsetsWithOpts(group, topic, partitionOffsets, opts)
 	if err != nil {
 		if err = errors.Cause(err); err == sarama.ErrUnknownTopicOrPartition {
 			s.respondWithJSON(w, http.StatusNotFound, errorRs{"Unknown topic"})
 			return
 		}
+		if err == admin.ErrRetentionUnsupported {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+			return
+		}
+		if err == admin.ErrOffsetDecrease {
+			s.respondWithJSON(w, http.StatusConflict, errorRs{err.Error()})
+			return
+		}
 		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
 		return
 	}
@@ -405,10 +997,18 @@ func (s *T) handleSetOffsets(w http.ResponseWriter, r *http.Request) {
 	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
 }
 
-// handleGetTopicConsumers is an HTTP request handler for `GET /topic/{topic}/consumers`
-func (s *T) handleGetTopicConsumers(w http.ResponseWriter, r *http.Request) {
+// handlePause is an HTTP request handler for `POST /topics/{topic}/pause`
+func (s *T) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.handlePauseResume(w, r, (*proxy.T).PauseConsume)
+}
+
+// handleResume is an HTTP request handler for `POST /topics/{topic}/resume`
+func (s *T) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.handlePauseResume(w, r, (*proxy.T).ResumeConsume)
+}
+
+func (s *T) handlePauseResume(w http.ResponseWriter, r *http.Request, fn func(*proxy.T, string, string) error) {
 	defer r.Body.Close()
-	var err error
 
 	pxy, err := s.getProxy(r)
 	if err != nil {
@@ -416,8 +1016,31 @@ func (s *T) handleGetTopicConsumers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	topic := mux.Vars(r)[prmTopic]
-
-	group, err := getGroupParam(r, true)
+	group, err := getGroupParam(r, false)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	if err := fn(pxy, group, topic); err != nil {
+		s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
+}
+
+// handleGetTopicConsumers is an HTTP request handler for `GET /topic/{topic}/consumers`
+func (s *T) handleGetTopicConsumers(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var err error
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+
+	group, err := getGroupParam(r, true)
 	if err != nil {
 		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
 		return
@@ -461,6 +1084,279 @@ func (s *T) handleGetTopicConsumers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleListGroups is an HTTP request handler for `GET /groups`
+func (s *T) handleListGroups(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	groups, err := pxy.ListConsumerGroups()
+	if err != nil {
+		if err == proxy.ErrUnavailable {
+			s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+			return
+		}
+		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, groups)
+}
+
+// handleDescribeGroup is an HTTP request handler for `GET /groups/{group}`
+func (s *T) handleDescribeGroup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	group := mux.Vars(r)[prmGroup]
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	gd, err := pxy.DescribeConsumerGroup(group)
+	if err != nil {
+		if err == proxy.ErrUnavailable {
+			s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+			return
+		}
+		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
+		return
+	}
+	res := groupDescriptionRs{
+		Group:        gd.Group,
+		State:        gd.State,
+		ProtocolType: gd.ProtocolType,
+		Protocol:     gd.Protocol,
+		Members:      make([]groupMemberRs, len(gd.Members)),
+	}
+	for i, gmd := range gd.Members {
+		res.Members[i] = groupMemberRs{
+			MemberID:   gmd.MemberID,
+			ClientID:   gmd.ClientID,
+			ClientHost: gmd.ClientHost,
+			Topics:     gmd.Topics,
+			Assignment: gmd.Assignment,
+		}
+	}
+	s.respondWithJSON(w, http.StatusOK, res)
+}
+
+// handleDeleteGroup is an HTTP request handler for `DELETE /groups/{group}`
+func (s *T) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	group := mux.Vars(r)[prmGroup]
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	if err = pxy.DeleteConsumerGroup(group); err != nil {
+		var status int
+		switch err {
+		case admin.ErrGroupNotExist:
+			status = http.StatusNotFound
+		case admin.ErrGroupNotEmpty:
+			status = http.StatusConflict
+		case proxy.ErrUnavailable:
+			status = http.StatusServiceUnavailable
+		default:
+			status = http.StatusInternalServerError
+		}
+		s.respondWithJSON(w, status, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
+}
+
+// handleGetCommitErrors is an HTTP request handler for
+// `GET /groups/{group}/commit_errors`
+func (s *T) handleGetCommitErrors(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	group := mux.Vars(r)[prmGroup]
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	commitErrors := pxy.CommitErrors(group)
+	commitErrorViews := make([]commitErrorRs, len(commitErrors))
+	for i, commitErr := range commitErrors {
+		commitErrorViews[i] = commitErrorRs{
+			Topic:     commitErr.Topic,
+			Partition: commitErr.Partition,
+			Error:     commitErr.Err.Error(),
+			FailedAt:  commitErr.FailedAt,
+			Failures:  commitErr.Failures,
+		}
+	}
+	s.respondWithJSON(w, http.StatusOK, commitErrorViews)
+}
+
+// handleCreateTopic is an HTTP request handler for `POST /topics/{topic}`
+func (s *T) handleCreateTopic(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorText := fmt.Sprintf("Failed to read the request: err=(%s)", err)
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{errorText})
+		return
+	}
+	var req createTopicRq
+	if err := json.Unmarshal(body, &req); err != nil {
+		errorText := fmt.Sprintf("Failed to parse the request: err=(%s)", err)
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{errorText})
+		return
+	}
+
+	err = pxy.CreateTopic(topic, req.Partitions, req.ReplicationFactor, req.Config, req.IfNotExists)
+	if err != nil {
+		var status int
+		switch err {
+		case admin.ErrBadPartitionCount, admin.ErrBadReplicationFactor:
+			status = http.StatusBadRequest
+		case admin.ErrTopicExists:
+			status = http.StatusConflict
+		case proxy.ErrUnavailable:
+			status = http.StatusServiceUnavailable
+		default:
+			status = http.StatusInternalServerError
+		}
+		s.respondWithJSON(w, status, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
+}
+
+// handleDeleteTopic is an HTTP request handler for `DELETE /topics/{topic}`
+func (s *T) handleDeleteTopic(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+
+	if err = pxy.DeleteTopic(topic); err != nil {
+		var status int
+		switch err {
+		case admin.ErrTopicNotExist:
+			status = http.StatusNotFound
+		case admin.ErrTopicNotDeleted:
+			status = http.StatusConflict
+		case proxy.ErrUnavailable:
+			status = http.StatusServiceUnavailable
+		default:
+			status = http.StatusInternalServerError
+		}
+		s.respondWithJSON(w, status, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
+}
+
+// handleCreatePartitions is an HTTP request handler for
+// `POST /topics/{topic}/partitions`
+func (s *T) handleCreatePartitions(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorText := fmt.Sprintf("Failed to read the request: err=(%s)", err)
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{errorText})
+		return
+	}
+	var req createPartitionsRq
+	if err := json.Unmarshal(body, &req); err != nil {
+		errorText := fmt.Sprintf("Failed to parse the request: err=(%s)", err)
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{errorText})
+		return
+	}
+
+	if err = pxy.CreatePartitions(topic, req.Count); err != nil {
+		var status int
+		switch err {
+		case admin.ErrTopicNotExist:
+			status = http.StatusNotFound
+		case admin.ErrPartitionsNotAdded, admin.ErrBadReplicationFactor:
+			status = http.StatusBadRequest
+		case proxy.ErrUnavailable:
+			status = http.StatusServiceUnavailable
+		default:
+			status = http.StatusInternalServerError
+		}
+		s.respondWithJSON(w, status, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
+}
+
+// handleAlterTopicConfig is an HTTP request handler for
+// `POST /topics/{topic}/config`
+func (s *T) handleAlterTopicConfig(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	topic := mux.Vars(r)[prmTopic]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		errorText := fmt.Sprintf("Failed to read the request: err=(%s)", err)
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{errorText})
+		return
+	}
+	var req alterTopicConfigRq
+	if err := json.Unmarshal(body, &req); err != nil {
+		errorText := fmt.Sprintf("Failed to parse the request: err=(%s)", err)
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{errorText})
+		return
+	}
+
+	if err = pxy.AlterTopicConfig(topic, req.Config); err != nil {
+		var status int
+		switch err {
+		case admin.ErrTopicNotExist:
+			status = http.StatusNotFound
+		case admin.ErrNoTopicConfig:
+			status = http.StatusBadRequest
+		case proxy.ErrUnavailable:
+			status = http.StatusServiceUnavailable
+		default:
+			status = http.StatusInternalServerError
+			if _, ok := err.(admin.ErrInvalidParam); ok {
+				status = http.StatusBadRequest
+			}
+		}
+		s.respondWithJSON(w, status, errorRs{err.Error()})
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, EmptyResponse)
+}
+
 // handleListTopics is an HTTP request handler for `GET /topics`
 func (s *T) handleListTopics(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -481,7 +1377,26 @@ func (s *T) handleListTopics(w http.ResponseWriter, r *http.Request) {
 	_, withConfig := r.Form[prmTopicsWithConfig]
 	_, withPartitions := r.Form[prmTopicsWithPartitions]
 
-	topicsMetadata, err := pxy.ListTopics(withPartitions, withConfig)
+	filter := admin.TopicFilter{Prefix: r.FormValue(prmTopicsPrefix)}
+	if patternStr := r.FormValue(prmTopicsPattern); patternStr != "" {
+		if filter.Pattern, err = regexp.Compile(patternStr); err != nil {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmTopicsPattern, err)})
+			return
+		}
+	}
+	for _, entry := range r.Form[prmTopicsConfig] {
+		kv := strings.SplitN(entry, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			s.respondWithJSON(w, http.StatusBadRequest, errorRs{fmt.Sprintf("bad %s: %s", prmTopicsConfig, entry)})
+			return
+		}
+		if filter.Config == nil {
+			filter.Config = make(map[string]string)
+		}
+		filter.Config[kv[0]] = kv[1]
+	}
+
+	topicsMetadata, err := pxy.ListTopicsWithFilter(withPartitions, withConfig, filter)
 	if err != nil {
 		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
 		return
@@ -535,6 +1450,89 @@ func (s *T) handleGetTopicMetadata(w http.ResponseWriter, r *http.Request) {
 	s.respondWithJSON(w, http.StatusOK, tm_view)
 }
 
+// handleListClusters is an HTTP request handler for `GET /clusters`
+func (s *T) handleListClusters(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	s.respondWithJSON(w, http.StatusOK, listClustersRs{
+		Clusters: s.proxySet.Clusters(),
+		Default:  s.proxySet.DefaultCluster(),
+	})
+}
+
+// handleGetClusterMetadata is an HTTP request handler for `GET /brokers`
+func (s *T) handleGetClusterMetadata(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	cm, err := pxy.GetClusterMetadata()
+	if err != nil {
+		if err == proxy.ErrUnavailable {
+			s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+			return
+		}
+		s.respondWithJSON(w, http.StatusInternalServerError, errorRs{err.Error()})
+		return
+	}
+	clusterMetadataView := clusterMetadataRs{
+		Brokers:    make([]brokerMetadata, len(cm.Brokers)),
+		Controller: cm.ControllerID,
+	}
+	for i, bm := range cm.Brokers {
+		clusterMetadataView.Brokers[i] = brokerMetadata{ID: bm.ID, Host: bm.Host, Port: bm.Port}
+	}
+	s.respondWithJSON(w, http.StatusOK, clusterMetadataView)
+}
+
+// handleGetMetrics is an HTTP request handler for `GET /_metrics`
+func (s *T) handleGetMetrics(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	producerMetrics, err := pxy.ProducerMetrics()
+	if err != nil {
+		s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+		return
+	}
+	groupMetrics, err := pxy.GroupMetrics()
+	if err != nil {
+		s.respondWithJSON(w, http.StatusServiceUnavailable, errorRs{err.Error()})
+		return
+	}
+	registry := metrics.NewRegistry()
+	for _, r := range []metrics.Registry{producerMetrics, pxy.ConsumerMetrics(), groupMetrics, pxy.ProbeMetrics()} {
+		r.Each(func(name string, metric interface{}) {
+			registry.Register(name, metric)
+		})
+	}
+	s.respondWithJSON(w, http.StatusOK, registry)
+}
+
+// handleHealth is an HTTP request handler for `GET /health`
+func (s *T) handleHealth(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pxy, err := s.getProxy(r)
+	if err != nil {
+		s.respondWithJSON(w, http.StatusBadRequest, errorRs{err.Error()})
+		return
+	}
+	health := pxy.Health()
+	if !health.Healthy() {
+		s.respondWithJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	s.respondWithJSON(w, http.StatusOK, health)
+}
+
 func (s *T) handlePing(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	w.WriteHeader(http.StatusOK)
@@ -542,15 +1540,53 @@ func (s *T) handlePing(w http.ResponseWriter, r *http.Request) {
 }
 
 type produceRs struct {
-	Partition int32 `json:"partition"`
-	Offset    int64 `json:"offset"`
+	Partition      int32  `json:"partition"`
+	Offset         int64  `json:"offset"`
+	RequiredAcks   string `json:"required_acks"`
+	SerializedSize int    `json:"serialized_size"`
+	Compression    string `json:"compression"`
 }
 
 type consumeRs struct {
-	Key       []byte `json:"key"`
-	Value     []byte `json:"value"`
-	Partition int32  `json:"partition"`
-	Offset    int64  `json:"offset"`
+	Key             []byte `json:"key"`
+	Value           []byte `json:"value"`
+	Topic           string `json:"topic"`
+	Partition       int32  `json:"partition"`
+	Offset          int64  `json:"offset"`
+	HighWaterMark   int64  `json:"high_water_mark"`
+	CommittedOffset int64  `json:"committed_offset"`
+	LikelyDuplicate bool   `json:"likely_duplicate,omitempty"`
+}
+
+type createTopicRq struct {
+	Partitions        int32             `json:"partitions"`
+	ReplicationFactor int16             `json:"replication_factor"`
+	Config            map[string]string `json:"config"`
+	IfNotExists       bool              `json:"if_not_exists"`
+}
+
+type alterTopicConfigRq struct {
+	Config map[string]string `json:"config"`
+}
+
+type createPartitionsRq struct {
+	Count int32 `json:"count"`
+}
+
+type groupDescriptionRs struct {
+	Group        string          `json:"group"`
+	State        string          `json:"state"`
+	ProtocolType string          `json:"protocol_type"`
+	Protocol     string          `json:"protocol"`
+	Members      []groupMemberRs `json:"members"`
+}
+
+type groupMemberRs struct {
+	MemberID   string             `json:"member_id"`
+	ClientID   string             `json:"client_id"`
+	ClientHost string             `json:"client_host"`
+	Topics     []string           `json:"topics,omitempty"`
+	Assignment map[string][]int32 `json:"assignment,omitempty"`
 }
 
 type partitionInfo struct {
@@ -562,12 +1598,63 @@ type partitionInfo struct {
 	Lag        int64  `json:"lag"`
 	Metadata   string `json:"metadata,omitempty"`
 	SparseAcks string `json:"sparse_acks,omitempty"`
+
+	// Set only if the offset was committed via the admin API.
+	CommittedBy string     `json:"committed_by,omitempty"`
+	CommittedAt *time.Time `json:"committed_at,omitempty"`
+}
+
+type listClustersRs struct {
+	Clusters []string `json:"clusters"`
+	Default  string   `json:"default"`
+}
+
+type clusterMetadataRs struct {
+	Brokers    []brokerMetadata `json:"brokers"`
+	Controller int32            `json:"controller"`
+}
+
+type brokerMetadata struct {
+	ID   int32  `json:"id"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+type partitionStatus struct {
+	Partition int32  `json:"partition"`
+	Begin     int64  `json:"begin"`
+	End       int64  `json:"end"`
+	Count     int64  `json:"count"`
+	Offset    int64  `json:"offset"`
+	Lag       int64  `json:"lag"`
+	Metadata  string `json:"metadata,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+}
+
+type partitionRange struct {
+	Partition int32 `json:"partition"`
+	Begin     int64 `json:"begin"`
+	End       int64 `json:"end"`
+	Count     int64 `json:"count"`
+}
+
+type commitErrorRs struct {
+	Topic     string    `json:"topic"`
+	Partition int32     `json:"partition"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failed_at"`
+	Failures  int       `json:"failures"`
 }
 
 type errorRs struct {
 	Error string `json:"error"`
 }
 
+type produceErrorRs struct {
+	Error          string `json:"error"`
+	KafkaErrorCode int16  `json:"kafka_error_code,omitempty"`
+}
+
 type topicConfig struct {
 	Version int32             `json:"version"`
 	Config  map[string]string `json:"config"`
@@ -626,6 +1713,37 @@ func getGroupParam(r *http.Request, opt bool) (string, error) {
 	return groups[0], nil
 }
 
+// getAvroParam tells whether a request asks for messages to be Avro encoded
+// on produce or decoded on consume.
+func getAvroParam(r *http.Request, pxy *proxy.T) (bool, error) {
+	switch encoding := r.FormValue(prmEncoding); encoding {
+	case "":
+		return false, nil
+	case msgEncodingAvro:
+		if !pxy.AvroEnabled() {
+			return false, proxy.ErrAvroUnsupported
+		}
+		return true, nil
+	default:
+		return false, errors.Errorf("bad %s: %s", prmEncoding, encoding)
+	}
+}
+
+// avroErrorStatus returns an HTTP status corresponding to an Avro encoding
+// error.
+func avroErrorStatus(err error) int {
+	if _, ok := err.(*schemareg.InvalidValueError); ok {
+		return http.StatusBadRequest
+	}
+	switch errors.Cause(err) {
+	case proxy.ErrAvroUnsupported:
+		return http.StatusBadRequest
+	case schemareg.ErrSubjectNotFound:
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
 // toEncoderPreservingNil converts a slice of bytes to `sarama.Encoder` but
 // returns `nil` if the passed slice is `nil`.
 func toEncoderPreservingNil(b []byte) sarama.Encoder {
@@ -635,6 +1753,16 @@ func toEncoderPreservingNil(b []byte) sarama.Encoder {
 	return nil
 }
 
+// hasTopic returns true if the topic is in the list.
+func hasTopic(topic string, topics []string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
 func parseAck(r *http.Request, isConsReq bool) (proxy.Ack, error) {
 	var partitionPrmName, offsetPrmName string
 	if isConsReq {
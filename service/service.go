@@ -9,6 +9,7 @@ import (
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/proxy"
 	"github.com/mailgun/kafka-pixy/server"
+	"github.com/mailgun/kafka-pixy/server/auth"
 	"github.com/mailgun/kafka-pixy/server/grpcsrv"
 	"github.com/mailgun/kafka-pixy/server/httpsrv"
 	"github.com/pkg/errors"
@@ -39,9 +40,13 @@ func Spawn(cfg *config.App) (*T, error) {
 	}
 
 	proxySet := proxy.NewSet(s.proxies, s.proxies[cfg.DefaultCluster])
+	var authz auth.Authorizer
+	if len(cfg.Auth.APIKeys) > 0 {
+		authz = auth.New(cfg)
+	}
 
 	if cfg.GRPCAddr != "" {
-		grpcSrv, err := grpcsrv.New(cfg.GRPCAddr, proxySet)
+		grpcSrv, err := grpcsrv.New(cfg.GRPCAddr, cfg.ServerTLSCfg(), authz, proxySet)
 		if err != nil {
 			s.stopProxies()
 			return nil, errors.Wrap(err, "failed to start gRPC server")
@@ -49,7 +54,7 @@ func Spawn(cfg *config.App) (*T, error) {
 		s.servers = append(s.servers, grpcSrv)
 	}
 	if cfg.TCPAddr != "" {
-		tcpSrv, err := httpsrv.New(cfg.TCPAddr, proxySet)
+		tcpSrv, err := httpsrv.New(cfg.TCPAddr, cfg.HTTPCompression, cfg.ServerTLSCfg(), authz, proxySet)
 		if err != nil {
 			s.stopProxies()
 			return nil, errors.Wrap(err, "failed to start TCP socket based HTTP API server")
@@ -57,7 +62,9 @@ func Spawn(cfg *config.App) (*T, error) {
 		s.servers = append(s.servers, tcpSrv)
 	}
 	if cfg.UnixAddr != "" {
-		unixSrv, err := httpsrv.New(cfg.UnixAddr, proxySet)
+		// Unix domain socket connections do not leave the host, so they
+		// are not encrypted.
+		unixSrv, err := httpsrv.New(cfg.UnixAddr, cfg.HTTPCompression, nil, authz, proxySet)
 		if err != nil {
 			s.stopProxies()
 			return nil, errors.Wrapf(err, "failed to start Unix socket based HTTP API server")
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"time"
@@ -12,6 +13,8 @@ import (
 	"github.com/mailgun/kafka-pixy/testhelpers/kafkahelper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	. "gopkg.in/check.v1"
 )
 
@@ -241,6 +244,72 @@ func (s *ServiceGRPCSuite) TestConsumeAutoAck(c *C) {
 	assertMsgs(c, consumed, produced)
 }
 
+// Messages are streamed to the client and offsets of acknowledged ones are
+// committed.
+func (s *ServiceGRPCSuite) TestConsumeStream(c *C) {
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	s.waitSvcUp(c, 5*time.Second)
+
+	s.kh.ResetOffsets("foo", "test.4")
+	produced := s.kh.PutMessages("stream", "test.4", map[string]int{"A": 17, "B": 19, "C": 23, "D": 29})
+	consumed := make(map[string][]*pb.ConsRs)
+	offsetsBefore := s.kh.GetCommittedOffsets("foo", "test.4")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// When
+	stream, err := s.clt.ConsumeStream(ctx)
+	c.Assert(err, IsNil)
+	err = stream.Send(&pb.ConsStreamRq{Topic: "test.4", Group: "foo"})
+	c.Assert(err, IsNil)
+	for i := 0; i < 88; i++ {
+		res, err := stream.Recv()
+		c.Assert(err, IsNil, Commentf("failed to consume message #%d", i))
+		key := string(res.KeyValue)
+		consumed[key] = append(consumed[key], res)
+		err = stream.Send(&pb.ConsStreamRq{AckPartition: res.Partition, AckOffset: res.Offset})
+		c.Assert(err, IsNil)
+	}
+	c.Assert(stream.CloseSend(), IsNil)
+	// Give the acks sent last some time to reach the proxy.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	svc.Stop()
+
+	// Then
+	offsetsAfter := s.kh.GetCommittedOffsets("foo", "test.4")
+	c.Assert(offsetsAfter[0].Val, Equals, offsetsBefore[0].Val+17)
+	c.Assert(offsetsAfter[1].Val, Equals, offsetsBefore[1].Val+29)
+	c.Assert(offsetsAfter[2].Val, Equals, offsetsBefore[2].Val+23)
+	c.Assert(offsetsAfter[3].Val, Equals, offsetsBefore[3].Val+19)
+
+	assertMsgs(c, consumed, produced)
+}
+
+// If the first stream request does not specify a group, then the stream is
+// terminated with InvalidArgument.
+func (s *ServiceGRPCSuite) TestConsumeStreamNoGroup(c *C) {
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+	s.waitSvcUp(c, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// When
+	stream, err := s.clt.ConsumeStream(ctx)
+	c.Assert(err, IsNil)
+	err = stream.Send(&pb.ConsStreamRq{Topic: "test.4"})
+	c.Assert(err, IsNil)
+	_, err = stream.Recv()
+
+	// Then
+	c.Assert(grpc.Code(err), Equals, codes.InvalidArgument)
+}
+
 // If message is consumed with noAck but is not explicitly acknowledged, then
 // its offset is not committed.
 func (s *ServiceGRPCSuite) TestConsumeNoAck(c *C) {
@@ -464,6 +533,89 @@ func (s *ServiceGRPCSuite) TestConsumeInvalidProxy(c *C) {
 	c.Assert(consRes, IsNil)
 }
 
+// Requests that specify neither a topic nor a group operate on the whole
+// cluster, so they are forbidden for API keys scoped to topics or groups.
+func (s *ServiceGRPCSuite) TestAuthClusterWide(c *C) {
+	s.cfg.Auth.APIKeys = []config.APIKey{
+		{Key: "secret", Topics: []string{"test.*"}, Groups: []string{"foo"}},
+		{Key: "root"},
+	}
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+	scopedCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "secret"))
+	rootCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "root"))
+
+	// When
+	_, listTopicsErr := s.clt.ListTopics(scopedCtx, &pb.ListTopicRq{}, grpc.FailFast(false))
+	_, listGroupsErr := s.clt.ListGroups(scopedCtx, &pb.ListGroupsRq{}, grpc.FailFast(false))
+	_, getOffsetsErr := s.clt.GetOffsets(scopedCtx, &pb.GetOffsetsRq{Topic: "test.1", Group: "foo"}, grpc.FailFast(false))
+	_, rootListTopicsErr := s.clt.ListTopics(rootCtx, &pb.ListTopicRq{}, grpc.FailFast(false))
+	_, rootListGroupsErr := s.clt.ListGroups(rootCtx, &pb.ListGroupsRq{}, grpc.FailFast(false))
+
+	// Then
+	c.Assert(grpc.Code(listTopicsErr), Equals, codes.PermissionDenied)
+	c.Assert(grpc.Code(listGroupsErr), Equals, codes.PermissionDenied)
+	c.Assert(getOffsetsErr, IsNil)
+	c.Assert(rootListTopicsErr, IsNil)
+	c.Assert(rootListGroupsErr, IsNil)
+}
+
+// Setting of committed offsets is an administrative operation, so it requires
+// an API key with admin permission.
+func (s *ServiceGRPCSuite) TestAuthAdmin(c *C) {
+	s.cfg.Auth.APIKeys = []config.APIKey{{Key: "secret", Topics: []string{"test.*"}, Groups: []string{"foo"}}}
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "secret"))
+
+	// When
+	_, err = s.clt.SetOffsets(ctx, &pb.SetOffsetsRq{Topic: "test.1", Group: "foo"}, grpc.FailFast(false))
+
+	// Then
+	c.Assert(grpc.Code(err), Equals, codes.PermissionDenied)
+}
+
+// If TLS is configured, then clients have to connect to the gRPC server over
+// TLS, presenting a certificate if client certificates are required.
+func (s *ServiceGRPCSuite) TestMutualTLS(c *C) {
+	certFile, keyFile := testhelpers.WriteSelfSignedCert(c, c.MkDir())
+	cfg, err := config.FromYAML([]byte("" +
+		"tls:\n" +
+		"  cert_file: " + certFile + "\n" +
+		"  key_file: " + keyFile + "\n" +
+		"  client_ca_cert_file: " + certFile + "\n" +
+		"proxies:\n" +
+		"  pxyG:\n" +
+		"    client_id: foo\n"))
+	c.Assert(err, IsNil)
+	cfg.GRPCAddr = s.cfg.GRPCAddr
+	cfg.Proxies = s.cfg.Proxies
+	svc, err := Spawn(cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+	getOffsets := func(tlsCfg *tls.Config) error {
+		cltConn, err := grpc.Dial(cfg.GRPCAddr, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+		c.Assert(err, IsNil)
+		defer cltConn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = pb.NewKafkaPixyClient(cltConn).GetOffsets(ctx, &pb.GetOffsetsRq{Topic: "test.1", Group: "test"})
+		return err
+	}
+
+	// When
+	_, err1 := s.clt.GetOffsets(context.Background(), &pb.GetOffsetsRq{Topic: "test.1", Group: "test"})
+	err2 := getOffsets(testhelpers.NewClientTLSCfg(c, certFile, keyFile, false))
+	err3 := getOffsets(testhelpers.NewClientTLSCfg(c, certFile, keyFile, true))
+
+	// Then
+	c.Assert(grpc.Code(err1), Equals, codes.Unavailable)
+	c.Assert(grpc.Code(err2), Equals, codes.Unavailable)
+	c.Assert(err3, IsNil)
+}
+
 func (s *ServiceGRPCSuite) waitSvcUp(c *C, timeout time.Duration) {
 	start := time.Now()
 	for {
@@ -2,6 +2,8 @@ package service
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -20,6 +22,7 @@ import (
 	"github.com/mailgun/kafka-pixy/actor"
 	"github.com/mailgun/kafka-pixy/config"
 	pb "github.com/mailgun/kafka-pixy/gen/golang"
+	"github.com/mailgun/kafka-pixy/proxy"
 	"github.com/mailgun/kafka-pixy/server/httpsrv"
 	"github.com/mailgun/kafka-pixy/testhelpers"
 	"github.com/mailgun/kafka-pixy/testhelpers/kafkahelper"
@@ -317,6 +320,66 @@ func (s *ServiceHTTPSuite) TestSyncProduce(c *C) {
 	c.Assert(offsetsAfter[0], Equals, offsetsBefore[0]+1)
 }
 
+// Compression can be overridden for a particular message, and messages
+// compressed with different codecs are consumed alike.
+func (s *ServiceHTTPSuite) TestSyncProduceCompression(c *C) {
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	offsetsBefore := s.kh.GetNewestOffsets("test.4")
+
+	// When
+	for i, compression := range []string{"none", "gzip", "snappy"} {
+		r, err := s.unixClient.Post("http://_/topics/test.4/messages?key=1&sync&compression="+compression,
+			"text/plain", strings.NewReader("Foo"+compression))
+		c.Assert(err, IsNil)
+		c.Assert(r.StatusCode, Equals, http.StatusOK)
+		body := ParseJSONBody(c, r).(map[string]interface{})
+		c.Assert(int64(body["offset"].(float64)), Equals, offsetsBefore[0]+int64(i))
+	}
+	svc.Stop() // Have to stop before getOffsets
+	offsetsAfter := s.kh.GetNewestOffsets("test.4")
+
+	// Then
+	c.Assert(offsetsAfter[0], Equals, offsetsBefore[0]+3)
+	msgs := s.kh.GetMessages("test.4", offsetsBefore, offsetsAfter)
+	c.Assert(msgs[0], DeepEquals, []string{"Foonone", "Foogzip", "Foosnappy"})
+}
+
+// LZ4 compression is rejected if the configured Kafka version does not
+// support it.
+func (s *ServiceHTTPSuite) TestSyncProduceCompressionUnsupported(c *C) {
+	s.cfg.Proxies[s.cfg.DefaultCluster].Kafka.Version.Set(sarama.V0_9_0_1)
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	// When
+	r, err := s.unixClient.Post("http://_/topics/test.4/messages?sync&compression=lz4",
+		"text/plain", strings.NewReader("Foo"))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.StatusCode, Equals, http.StatusBadRequest)
+	body := ParseJSONBody(c, r).(map[string]interface{})
+	c.Assert(body["error"], Equals, proxy.ErrCompressionUnsupported.Error())
+}
+
+func (s *ServiceHTTPSuite) TestSyncProduceInvalidCompression(c *C) {
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	// When
+	r, err := s.unixClient.Post("http://_/topics/test.4/messages?sync&compression=zstd",
+		"text/plain", strings.NewReader("Foo"))
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.StatusCode, Equals, http.StatusBadRequest)
+	body := ParseJSONBody(c, r).(map[string]interface{})
+	c.Assert(body["error"], Equals, "bad compression, zstd is not supported by the Kafka client library")
+}
+
 func (s *ServiceHTTPSuite) TestSyncProduceInvalidTopic(c *C) {
 	svc, err := Spawn(s.cfg)
 	c.Assert(err, IsNil)
@@ -478,6 +541,40 @@ func (s *ServiceHTTPSuite) TestConsumeExplicitAck(c *C) {
 	assertMsgs(c, consumed, produced)
 }
 
+// If a client accepts application/octet-stream then a consumed message is
+// returned as is in the response body, and its properties in headers.
+func (s *ServiceHTTPSuite) TestConsumeRaw(c *C) {
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	s.kh.ResetOffsets("foo", "test.1")
+	binMsg := []byte{0xff, 0x00, 0xfe, 0x80, 0x0a}
+	r, err := s.unixClient.Post("http://_/topics/test.1/messages?key=%C0%01&sync",
+		"text/plain", bytes.NewReader(binMsg))
+	c.Assert(err, IsNil)
+	prodRs := ParseJSONBody(c, r).(map[string]interface{})
+
+	// When
+	req, err := http.NewRequest("GET", "http://_/topics/test.1/messages?group=foo", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept", "application/octet-stream")
+	res, err := s.unixClient.Do(req)
+	c.Assert(err, IsNil)
+
+	// Then
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+	c.Assert(res.Header.Get("Content-Type"), Equals, "application/octet-stream")
+	c.Assert(res.Header.Get("X-Kafka-Key"), Equals, base64.StdEncoding.EncodeToString([]byte{0xc0, 0x01}))
+	c.Assert(res.Header.Get("X-Kafka-Partition"), Equals, "0")
+	offset := int64(prodRs["offset"].(float64))
+	c.Assert(res.Header.Get("X-Kafka-Offset"), Equals, strconv.FormatInt(offset, 10))
+	c.Assert(res.Header.Get("X-Kafka-High-Water-Mark"), Equals, strconv.FormatInt(offset+1, 10))
+	body, err := ioutil.ReadAll(res.Body)
+	c.Assert(err, IsNil)
+	c.Assert(body, DeepEquals, binMsg)
+}
+
 // If offsets for a group that does not exist are requested then -1 is returned
 // as the next offset to be consumed for all topic partitions.
 func (s *ServiceHTTPSuite) TestGetOffsetsNoSuchGroup(c *C) {
@@ -811,6 +908,181 @@ func (s *ServiceHTTPSuite) TestGetTopics(c *C) {
 	}
 }
 
+// Responses are gzip compressed if enabled in config and accepted by a client.
+func (s *ServiceHTTPSuite) TestGetTopicsCompressed(c *C) {
+	s.cfg.HTTPCompression = true
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	req, err := http.NewRequest("GET", "http://_/topics", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip")
+
+	// When
+	r, err := s.unixClient.Do(req)
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.StatusCode, Equals, http.StatusOK)
+	c.Assert(r.Header.Get("Content-Encoding"), Equals, "gzip")
+	c.Assert(r.Header.Get("Vary"), Equals, "Accept-Encoding")
+	gr, err := gzip.NewReader(r.Body)
+	c.Assert(err, IsNil)
+	var topics []string
+	c.Assert(json.NewDecoder(gr).Decode(&topics), IsNil)
+	rawTopics, err := s.kh.KazooClt().Topics()
+	c.Assert(err, IsNil)
+	c.Assert(len(topics), Equals, len(rawTopics))
+}
+
+// If API keys are configured, then requests have to present one that grants
+// access to the requested topic and group, except for health checks.
+func (s *ServiceHTTPSuite) TestAuth(c *C) {
+	s.cfg.Auth.APIKeys = []config.APIKey{
+		{Key: "secret", Topics: []string{"test.*"}, Groups: []string{"foo"}},
+		{Key: "root"},
+	}
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	for i, tc := range []struct {
+		url    string
+		apiKey string
+		status int
+	}{
+		{url: "http://_/_ping", status: http.StatusOK},
+		{url: "http://_/topics/test.1/offsets?group=foo", status: http.StatusUnauthorized},
+		{url: "http://_/topics/test.1/offsets?group=foo", apiKey: "bogus", status: http.StatusUnauthorized},
+		{url: "http://_/topics/test.1/offsets?group=foo", apiKey: "secret", status: http.StatusOK},
+		{url: "http://_/topics/test.1/offsets?group=bar", apiKey: "secret", status: http.StatusForbidden},
+		{url: "http://_/topics/foo/offsets?group=foo", apiKey: "secret", status: http.StatusForbidden},
+		// The topic to ack a message of is authorized along with the
+		// consumed ones, and has to be one of them.
+		{url: "http://_/messages?group=foo&topic=test.1&ackTopic=foo&ackPartition=0&ackOffset=1", apiKey: "secret", status: http.StatusForbidden},
+		{url: "http://_/messages?group=foo&topic=test.1&ackTopic=foo&ackPartition=0&ackOffset=1", apiKey: "root", status: http.StatusBadRequest},
+		// Cluster wide requests are forbidden for keys scoped to topics or
+		// groups.
+		{url: "http://_/topics", apiKey: "secret", status: http.StatusForbidden},
+		{url: "http://_/groups", apiKey: "secret", status: http.StatusForbidden},
+		{url: "http://_/brokers", apiKey: "secret", status: http.StatusForbidden},
+		{url: "http://_/_metrics", apiKey: "secret", status: http.StatusForbidden},
+		{url: "http://_/topics", apiKey: "root", status: http.StatusOK},
+		{url: "http://_/groups", apiKey: "root", status: http.StatusOK},
+		{url: "http://_/brokers", apiKey: "root", status: http.StatusOK},
+		{url: "http://_/_metrics", apiKey: "root", status: http.StatusOK},
+	} {
+		req, err := http.NewRequest("GET", tc.url, nil)
+		c.Assert(err, IsNil)
+		if tc.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.apiKey)
+		}
+
+		// When
+		r, err := s.unixClient.Do(req)
+
+		// Then
+		c.Assert(err, IsNil)
+		r.Body.Close()
+		c.Assert(r.StatusCode, Equals, tc.status, Commentf("case #%d", i))
+	}
+}
+
+// Requests with an invalid API key are rejected before their body is read,
+// and consumer groups given in a form encoded body are authorized.
+func (s *ServiceHTTPSuite) TestAuthBody(c *C) {
+	s.cfg.Auth.APIKeys = []config.APIKey{{Key: "secret", Topics: []string{"test.*"}, Groups: []string{"foo"}}}
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	for i, tc := range []struct {
+		url         string
+		apiKey      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{url: "http://_/topics/test.1/messages", body: strings.Repeat("x", 4<<20), status: http.StatusUnauthorized},
+		{url: "http://_/topics/test.1/pause", apiKey: "secret", contentType: "application/x-www-form-urlencoded",
+			body: "group=bar", status: http.StatusForbidden},
+		{url: "http://_/topics/test.1/pause", apiKey: "secret", contentType: "application/x-www-form-urlencoded",
+			body: "group=foo", status: http.StatusOK},
+		{url: "http://_/topics/test.1/resume", apiKey: "secret", contentType: "application/x-www-form-urlencoded",
+			body: "group=foo", status: http.StatusOK},
+		{url: "http://_/topics/test.1/pause", apiKey: "secret", contentType: "application/x-www-form-urlencoded",
+			body: "group=foo&x=" + strings.Repeat("x", 2<<20), status: http.StatusBadRequest},
+		// Administrative operations require an admin key.
+		{url: "http://_/topics/test.1/offsets?group=foo", apiKey: "secret", contentType: "application/json",
+			body: `[{"partition": 0, "offset": 0}]`, status: http.StatusForbidden},
+		{url: "http://_/topics/test.1/config", apiKey: "secret", contentType: "application/json",
+			body: `{"config": {"retention.ms": "1000"}}`, status: http.StatusForbidden},
+		{url: "http://_/topics/test.1/partitions", apiKey: "secret", contentType: "application/json",
+			body: `{"count": 100}`, status: http.StatusForbidden},
+	} {
+		req, err := http.NewRequest("POST", tc.url, strings.NewReader(tc.body))
+		c.Assert(err, IsNil)
+		if tc.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.apiKey)
+		}
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+
+		// When
+		r, err := s.unixClient.Do(req)
+
+		// Then
+		c.Assert(err, IsNil)
+		r.Body.Close()
+		c.Assert(r.StatusCode, Equals, tc.status, Commentf("case #%d", i))
+	}
+}
+
+func (s *ServiceHTTPSuite) TestGetGroups(c *C) {
+	s.kh.ResetOffsets("foo", "test.1")
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	// When
+	r, err := s.unixClient.Get("http://_/groups")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.StatusCode, Equals, http.StatusOK)
+	groups := ParseJSONBody(c, r).([]interface{})
+	found := false
+	for _, group := range groups {
+		if group.(string) == "foo" {
+			found = true
+		}
+	}
+	c.Assert(found, Equals, true)
+}
+
+func (s *ServiceHTTPSuite) TestDescribeGroup(c *C) {
+	s.kh.ResetOffsets("foo", "test.1")
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	// When
+	r, err := s.unixClient.Get("http://_/groups/foo")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.StatusCode, Equals, http.StatusOK)
+	c.Assert(ParseJSONBody(c, r), DeepEquals, map[string]interface{}{
+		"group":         "foo",
+		"state":         "Empty",
+		"protocol_type": "",
+		"protocol":      "",
+		"members":       []interface{}{},
+	})
+}
+
 func (s *ServiceHTTPSuite) TestGetTopicsWithPartitions(c *C) {
 	svc, err := Spawn(s.cfg)
 	c.Assert(err, IsNil)
@@ -965,6 +1237,46 @@ func (s *ServiceHTTPSuite) TestGetTopicsWithPartitionsAndWithConfig(c *C) {
 
 // Reported partition lags are correct, including those corresponding to -1 and
 // -2 special case offset values.
+// If client certificates are required, then only clients that present one
+// signed by the configured CA can connect to the TCP HTTP API server, while
+// the unix domain socket one stays plain.
+func (s *ServiceHTTPSuite) TestMutualTLS(c *C) {
+	certFile, keyFile := testhelpers.WriteSelfSignedCert(c, c.MkDir())
+	cfg, err := config.FromYAML([]byte("" +
+		"tls:\n" +
+		"  cert_file: " + certFile + "\n" +
+		"  key_file: " + keyFile + "\n" +
+		"  client_ca_cert_file: " + certFile + "\n" +
+		"proxies:\n" +
+		"  pxyH:\n" +
+		"    client_id: foo\n"))
+	c.Assert(err, IsNil)
+	cfg.TCPAddr = s.cfg.TCPAddr
+	cfg.UnixAddr = s.cfg.UnixAddr
+	cfg.Proxies = s.cfg.Proxies
+	svc, err := Spawn(cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+	newClient := func(tlsCfg *tls.Config) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+	}
+
+	// When
+	r1, err1 := s.tcpClient.Get("http://127.0.0.1:19092/_ping")
+	_, err2 := newClient(testhelpers.NewClientTLSCfg(c, certFile, keyFile, false)).Get("https://127.0.0.1:19092/_ping")
+	r3, err3 := newClient(testhelpers.NewClientTLSCfg(c, certFile, keyFile, true)).Get("https://127.0.0.1:19092/_ping")
+	r4, err4 := s.unixClient.Get("http://_/_ping")
+
+	// Then
+	c.Assert(err1, IsNil)
+	c.Assert(r1.StatusCode, Equals, http.StatusBadRequest) // Sent by the TLS server.
+	c.Assert(err2, ErrorMatches, ".*remote error: tls: .*")
+	c.Assert(err3, IsNil)
+	c.Assert(r3.StatusCode, Equals, http.StatusOK)
+	c.Assert(err4, IsNil)
+	c.Assert(r4.StatusCode, Equals, http.StatusOK)
+}
+
 func (s *ServiceHTTPSuite) TestHealthCheck(c *C) {
 	svc, err := Spawn(s.cfg)
 	c.Assert(err, IsNil)
@@ -981,6 +1293,28 @@ func (s *ServiceHTTPSuite) TestHealthCheck(c *C) {
 	c.Assert(string(body), Equals, "pong")
 }
 
+func (s *ServiceHTTPSuite) TestHealth(c *C) {
+	svc, err := Spawn(s.cfg)
+	c.Assert(err, IsNil)
+	defer svc.Stop()
+
+	// When
+	r, err := s.unixClient.Get("http://_/health")
+
+	// Then
+	c.Assert(err, IsNil)
+	c.Assert(r.StatusCode, Equals, http.StatusOK)
+	body := ParseJSONBody(c, r).(map[string]interface{})
+	c.Assert(body["controller_id"].(float64) >= 0, Equals, true)
+	delete(body, "controller_id")
+	c.Assert(body, DeepEquals, map[string]interface{}{
+		"kafka_connected": true,
+		"producer_up":     true,
+		"consumer_up":     true,
+		"admin_up":        true,
+	})
+}
+
 // Ensure that API endpoints that explicitly select a proxy to operate on work.
 func (s *ServiceHTTPSuite) TestExplicitProxyAPIEndpoints(c *C) {
 	s.kh.ResetOffsets("foo", "test.1")
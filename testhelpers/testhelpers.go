@@ -1,15 +1,26 @@
 package testhelpers
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/mailgun/kafka-pixy/config"
 	"github.com/mailgun/kafka-pixy/logging"
+	. "gopkg.in/check.v1"
 )
 
 const (
@@ -67,3 +78,49 @@ func NewUDSHTTPClient(unixSockAddr string) *http.Client {
 	tr := &http.Transport{Dial: dial}
 	return &http.Client{Transport: tr}
 }
+
+// WriteSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its
+// private key to PEM files in the specified directory, and returns their
+// paths. The certificate can be used both as a server and a client one, and
+// as a CA certificate to verify itself.
+func WriteSelfSignedCert(c *C, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-pixy.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), os.FileMode(0600))
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), os.FileMode(0600))
+	c.Assert(err, IsNil)
+	return certFile, keyFile
+}
+
+// NewClientTLSCfg creates a client TLS config that trusts the certificate
+// written by WriteSelfSignedCert. If `withCert` is true, then the client
+// presents the same certificate to the server.
+func NewClientTLSCfg(c *C, certFile, keyFile string, withCert bool) *tls.Config {
+	certPEM, err := ioutil.ReadFile(certFile)
+	c.Assert(err, IsNil)
+	tlsCfg := &tls.Config{RootCAs: x509.NewCertPool()}
+	c.Assert(tlsCfg.RootCAs.AppendCertsFromPEM(certPEM), Equals, true)
+	if withCert {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		c.Assert(err, IsNil)
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg
+}